@@ -38,6 +38,15 @@ func (c *Compiler) createCall(fn llvm.Value, args []llvm.Value, name string) llv
 		fragments := c.expandFormalParam(arg)
 		expanded = append(expanded, fragments...)
 	}
+	// A function declared with //go:linkname may have been defined with a
+	// different (but compatible) pointer type for a parameter, like
+	// *time.runtimeTimer and *runtime.timer. Bitcast those pointers.
+	paramTypes := fn.Type().ElementType().ParamTypes()
+	for i, arg := range expanded {
+		if i < len(paramTypes) && arg.Type() != paramTypes[i] && arg.Type().TypeKind() == llvm.PointerTypeKind && paramTypes[i].TypeKind() == llvm.PointerTypeKind {
+			expanded[i] = c.builder.CreateBitCast(arg, paramTypes[i], "")
+		}
+	}
 	return c.builder.CreateCall(fn, expanded, name)
 }
 
@@ -31,6 +31,16 @@ func init() {
 // The TinyGo import path.
 const tinygoPath = "github.com/tinygo-org/tinygo"
 
+// relocROPI is LLVMRelocROPI (read-only position independence) from the LLVM C
+// API, which is not exported by go-llvm.
+const relocROPI llvm.RelocMode = 4
+
+// minTaskStackSize is the smallest goroutine stack size that can be set with
+// -goroutine-stack-size. The task struct at the bottom of the stack (see
+// src/runtime/scheduler_tasks.go) already takes 56 bytes on Cortex-M, and the
+// goroutine needs space for at least a few call frames.
+const minTaskStackSize = 256
+
 // functionsUsedInTransform is a list of function symbols that may be used
 // during TinyGo optimization passes so they have to be marked as external
 // linkage until all TinyGo passes have finished.
@@ -66,23 +76,31 @@ type Config struct {
 	GOARCH        string   //
 	GC            string   // garbage collection strategy
 	Scheduler     string   // scheduler implementation ("coroutines" or "tasks")
-	PanicStrategy string   // panic strategy ("print" or "trap")
+	PanicStrategy string   // panic strategy ("print", "trap", or "reset")
+	PIC           string   // position-independent code ("" or "ropi")
 	CFlags        []string // cflags to pass to cgo
 	LDFlags       []string // ldflags to pass to cgo
 	ClangHeaders  string   // Clang built-in header include path
 	DumpSSA       bool     // dump Go SSA, for compiler debugging
 	VerifyIR      bool     // run extra checks on the IR
 	Debug         bool     // add debug symbols for gdb
+	TrimPath      bool     // remove file system paths from debug symbols
+	ReflectTags   bool     // store struct tags for reflect.StructField.Tag
 	GOROOT        string   // GOROOT
 	TINYGOROOT    string   // GOROOT for TinyGo
 	GOPATH        string   // GOPATH, like `go env GOPATH`
 	BuildTags     []string // build tags for TinyGo (empty means {Config.GOOS/Config.GOARCH})
+	GoroutinePool int      // number of preallocated goroutine stacks (0 means allocate on the heap)
+	TaskStackSize int      // size of each goroutine stack in bytes (-goroutine-stack-size, 0 means the runtime default)
+	PreemptLoops  bool     // check for preemption at loop back-edges (-preempt-loops)
+	RuntimePath   string   // directory of a replacement runtime package (-runtime), see runtime.go
 	TestConfig    TestConfig
 }
 
 type TestConfig struct {
 	CompileTestBinary bool
-	// TODO: Filter the test functions to run, include verbose flag, etc
+	RunRegexp         string // only include tests and fuzz targets that match this regular expression
+	// TODO: include verbose flag, etc
 }
 
 type Compiler struct {
@@ -93,6 +111,7 @@ type Compiler struct {
 	dibuilder               *llvm.DIBuilder
 	cu                      llvm.Metadata
 	difiles                 map[string]llvm.Metadata
+	trimPaths               map[string]string // package directory -> trimmed path, for -trimpath
 	ditypes                 map[types.Type]llvm.Metadata
 	machine                 llvm.TargetMachine
 	targetData              llvm.TargetData
@@ -105,6 +124,7 @@ type Compiler struct {
 	ir                      *ir.Program
 	diagnostics             []error
 	astComments             map[string]*ast.CommentGroup
+	likelyCases             map[token.Pos][]types.Type // likely types of type switch cases, see typeswitch.go
 }
 
 type Frame struct {
@@ -150,7 +170,11 @@ func NewCompiler(pkgName string, config Config) (*Compiler, error) {
 	if len(config.Features) > 0 {
 		features = strings.Join(config.Features, `,`)
 	}
-	c.machine = target.CreateTargetMachine(config.Triple, config.CPU, features, llvm.CodeGenLevelDefault, llvm.RelocStatic, llvm.CodeModelDefault)
+	relocMode := llvm.RelocStatic
+	if config.PIC == "ropi" {
+		relocMode = relocROPI
+	}
+	c.machine = target.CreateTargetMachine(config.Triple, config.CPU, features, llvm.CodeGenLevelDefault, relocMode, llvm.CodeModelDefault)
 	c.targetData = c.machine.CreateTargetData()
 
 	c.ctx = llvm.NewContext()
@@ -227,6 +251,29 @@ func (c *Compiler) getFunctionsUsedInTransforms() []string {
 // Compile the given package path or .go file path. Return an error when this
 // fails (in any stage).
 func (c *Compiler) Compile(mainPath string) []error {
+	if c.GoroutinePool < 0 {
+		return []error{errors.New("the goroutine pool size must not be negative")}
+	}
+	if c.GoroutinePool != 0 && c.selectScheduler() != "tasks" {
+		// Coroutine frames are allocated on the heap for every blocking call,
+		// not just when starting a goroutine, so they can't be preallocated.
+		return []error{errors.New("a goroutine pool is only supported by the tasks scheduler")}
+	}
+	if c.TaskStackSize != 0 {
+		if c.selectScheduler() != "tasks" {
+			return []error{errors.New("a goroutine stack size is only supported by the tasks scheduler")}
+		}
+		if c.TaskStackSize < minTaskStackSize || c.TaskStackSize%8 != 0 {
+			// The stack pointer must be aligned to 8 bytes at the start of a
+			// goroutine, as required by the ARM procedure call standard.
+			return []error{fmt.Errorf("the goroutine stack size must be a multiple of 8 bytes, of at least %d bytes", minTaskStackSize)}
+		}
+	}
+	if c.PreemptLoops && c.selectScheduler() != "tasks" {
+		// Yielding would turn every function with a loop into a coroutine.
+		return []error{errors.New("-preempt-loops is only supported by the tasks scheduler")}
+	}
+
 	// Prefix the GOPATH with the system GOROOT, as GOROOT is already set to
 	// the TinyGo root.
 	overlayGopath := c.GOPATH
@@ -286,6 +333,7 @@ func (c *Compiler) Compile(mainPath string) []error {
 			}
 			return ""
 		},
+		Unsupported: c.unsupportedPackage,
 		TypeChecker: types.Config{
 			Sizes: &StdSizes{
 				IntSize:  int64(c.targetData.TypeAllocSize(c.intType)),
@@ -295,17 +343,20 @@ func (c *Compiler) Compile(mainPath string) []error {
 		},
 		Dir:          wd,
 		TINYGOROOT:   c.TINYGOROOT,
+		RuntimeDir:   c.RuntimePath,
 		CFlags:       c.CFlags,
 		ClangHeaders: c.ClangHeaders,
+		TestRun:      c.TestConfig.RunRegexp,
 	}
 
+	var mainPkg *loader.Package
 	if strings.HasSuffix(mainPath, ".go") {
-		_, err = lprogram.ImportFile(mainPath)
+		mainPkg, err = lprogram.ImportFile(mainPath)
 		if err != nil {
 			return []error{err}
 		}
 	} else {
-		_, err = lprogram.Import(mainPath, wd)
+		mainPkg, err = lprogram.Import(mainPath, wd)
 		if err != nil {
 			return []error{err}
 		}
@@ -321,6 +372,14 @@ func (c *Compiler) Compile(mainPath string) []error {
 		return []error{err}
 	}
 
+	if c.RuntimePath != "" {
+		// The TinyGo runtime is known to be complete, but a replacement
+		// runtime may not be.
+		if errs := c.checkRuntimePackage(lprogram.Packages["runtime"].Pkg); len(errs) != 0 {
+			return errs
+		}
+	}
+
 	c.ir = ir.NewProgram(lprogram, mainPath)
 
 	// Run a simple dead code elimination pass.
@@ -328,9 +387,14 @@ func (c *Compiler) Compile(mainPath string) []error {
 
 	// Initialize debug information.
 	if c.Debug {
+		cuFile := mainPath
+		if c.TrimPath {
+			c.initTrimPath(lprogram, wd)
+			cuFile = TrimmedPackagePath(mainPkg.ImportPath, mainPkg.Package.Dir, wd)
+		}
 		c.cu = c.dibuilder.CreateCompileUnit(llvm.DICompileUnit{
 			Language:  0xb, // DW_LANG_C99 (0xc, off-by-one?)
-			File:      mainPath,
+			File:      cuFile,
 			Dir:       "",
 			Producer:  "TinyGo",
 			Optimized: true,
@@ -340,6 +404,7 @@ func (c *Compiler) Compile(mainPath string) []error {
 	var frames []*Frame
 
 	c.loadASTComments(lprogram)
+	c.loadLikelyCases(lprogram)
 
 	// Declare all functions.
 	for _, f := range c.ir.Functions {
@@ -434,6 +499,18 @@ func (c *Compiler) Compile(mainPath string) []error {
 		fn.AddAttributeAtIndex(2, readonly)
 	}
 
+	// Set the number of goroutine stacks to preallocate and their size (see
+	// src/runtime/scheduler_tasks.go). This must be done before optimizing,
+	// otherwise the optimizer would assume they are always zero.
+	if c.GoroutinePool != 0 {
+		poolSize := c.mod.NamedGlobal("runtime.goroutinePoolSize")
+		poolSize.SetInitializer(llvm.ConstInt(c.uintptrType, uint64(c.GoroutinePool), false))
+	}
+	if c.TaskStackSize != 0 {
+		stackSize := c.mod.NamedGlobal("runtime.goroutineStackSize")
+		stackSize.SetInitializer(llvm.ConstInt(c.uintptrType, uint64(c.TaskStackSize), false))
+	}
+
 	// see: https://reviews.llvm.org/D18355
 	if c.Debug {
 		c.mod.AddNamedMetadataOperand("llvm.module.flags",
@@ -849,7 +926,7 @@ func (c *Compiler) attachDebugInfo(f *ir.Function) llvm.Metadata {
 
 func (c *Compiler) attachDebugInfoRaw(f *ir.Function, llvmFn llvm.Value, suffix, filename string, line int) llvm.Metadata {
 	if _, ok := c.difiles[filename]; !ok {
-		dir, file := filepath.Split(filename)
+		dir, file := filepath.Split(c.debugFilename(filename))
 		if dir != "" {
 			dir = dir[:len(dir)-1]
 		}
@@ -893,6 +970,10 @@ func (c *Compiler) parseFunc(frame *Frame) {
 	if !frame.fn.IsExported() {
 		frame.fn.LLVMFn.SetLinkage(llvm.InternalLinkage)
 		frame.fn.LLVMFn.SetUnnamedAddr(true)
+	} else if frame.fn.IsWeak() {
+		// A definition elsewhere (for example in C) takes precedence. This
+		// also prevents LLVM from inlining it.
+		frame.fn.LLVMFn.SetLinkage(llvm.WeakAnyLinkage)
 	}
 	if frame.fn.IsInterrupt() && strings.HasPrefix(c.Triple, "avr") {
 		frame.fn.LLVMFn.SetFunctionCallConv(85) // CallingConv::AVR_SIGNAL
@@ -910,6 +991,13 @@ func (c *Compiler) parseFunc(frame *Frame) {
 		frame.fn.LLVMFn.AddFunctionAttr(noinline)
 	}
 
+	// Tell LLVM that functions marked //go:cold are rarely executed, so that
+	// they are optimized for size and branches towards them are unlikely.
+	if frame.fn.Placement() == ir.PlacementCold {
+		cold := c.ctx.CreateEnumAttribute(llvm.AttributeKindID("cold"), 0)
+		frame.fn.LLVMFn.AddFunctionAttr(cold)
+	}
+
 	// Add debug info, if needed.
 	if c.Debug {
 		if frame.fn.Synthetic == "package initializer" {
@@ -1115,9 +1203,16 @@ func (c *Compiler) parseInstr(frame *Frame, instr ssa.Instruction) {
 		block := instr.Block()
 		blockThen := frame.blockEntries[block.Succs[0]]
 		blockElse := frame.blockEntries[block.Succs[1]]
-		c.builder.CreateCondBr(cond, blockThen, blockElse)
+		c.createLoopPreemptCheck(frame, block)
+		br := c.builder.CreateCondBr(cond, blockThen, blockElse)
+		if extract, ok := instr.Cond.(*ssa.Extract); ok && extract.Index == 1 {
+			if assert, ok := extract.Tuple.(*ssa.TypeAssert); ok {
+				c.setTypeAssertBranchWeights(br, assert) // //go:likely
+			}
+		}
 	case *ssa.Jump:
 		blockJump := frame.blockEntries[instr.Block().Succs[0]]
+		c.createLoopPreemptCheck(frame, instr.Block())
 		c.builder.CreateBr(blockJump)
 	case *ssa.MapUpdate:
 		m := c.getValue(frame, instr.Map)
@@ -1372,6 +1467,10 @@ func (c *Compiler) parseCall(frame *Frame, instr *ssa.CallCommon) (llvm.Value, e
 			return c.emitVolatileLoad(frame, instr)
 		case strings.HasPrefix(name, "runtime/volatile.Store"):
 			return c.emitVolatileStore(frame, instr)
+		case name == "fmt.Errorf":
+			if value, ok := c.emitErrorf(frame, instr); ok {
+				return value, nil
+			}
 		}
 
 		targetFunc := c.ir.GetFunction(fn)
@@ -2416,12 +2515,17 @@ func (c *Compiler) parseConvert(typeFrom, typeTo types.Type, value llvm.Value, p
 			// arithmetic. Convert it to real pointer arithmatic here.
 			ptr := value.Operand(0)
 			index := value.Operand(1)
-			if !index.IsAPtrToIntInst().IsNil() {
+			if isPtrToInt(index) {
 				// Swap if necessary, if ptr and index are reversed.
 				ptr, index = index, ptr
 			}
-			if !ptr.IsAPtrToIntInst().IsNil() {
+			if isPtrToInt(ptr) {
 				origptr := ptr.Operand(0)
+				if origptr.IsConstant() && origptr.Type() != c.i8ptrType {
+					// The address of a global, converted to unsafe.Pointer
+					// with a bitcast that was folded away.
+					origptr = llvm.ConstBitCast(origptr, c.i8ptrType)
+				}
 				if origptr.Type() == c.i8ptrType {
 					// This pointer can be calculated from the original
 					// ptrtoint instruction with a GEP. The leftover inttoptr
@@ -2622,14 +2726,38 @@ func (c *Compiler) Verify() error {
 	return llvm.VerifyModule(c.mod, llvm.PrintMessageAction)
 }
 
+// ApplyFunctionSections puts every function in a separate section, named
+// .text.<name>. Functions marked //go:hot are put in .text.hot.<name> instead
+// and functions marked //go:cold in .text.unlikely.<name>, which is the same
+// naming convention as used by GCC and Clang. The sections are assigned before
+// optimization and kept by LLVM, but note that a function that is inlined ends
+// up in the section of its caller.
 func (c *Compiler) ApplyFunctionSections() {
+	// Functions marked //go:hot or //go:cold get a section prefix, so that the
+	// linker script can place them separately from other code. The functions
+	// are looked up by name, as some functions may have been removed or
+	// replaced since IR construction.
+	prefixes := map[string]string{}
+	for _, f := range c.ir.Functions {
+		switch f.Placement() {
+		case ir.PlacementHot:
+			prefixes[f.LinkName()] = ".text.hot."
+		case ir.PlacementCold:
+			prefixes[f.LinkName()] = ".text.unlikely."
+		}
+	}
+
 	// Put every function in a separate section. This makes it possible for the
 	// linker to remove dead code (-ffunction-sections).
 	llvmFn := c.mod.FirstFunction()
 	for !llvmFn.IsNil() {
 		if !llvmFn.IsDeclaration() {
 			name := llvmFn.Name()
-			llvmFn.SetSection(".text." + name)
+			prefix, ok := prefixes[name]
+			if !ok {
+				prefix = ".text."
+			}
+			llvmFn.SetSection(prefix + name)
 		}
 		llvmFn = llvm.NextFunction(llvmFn)
 	}
@@ -2646,6 +2774,19 @@ func (c *Compiler) NonConstGlobals() {
 	}
 }
 
+// AddSection adds a constant global with the given contents in a separate
+// section. This is used for data that is not referenced by the program itself
+// but must be present in the final image, such as the firmware metadata. The
+// linker script must KEEP the section, or it will be removed by --gc-sections.
+func (c *Compiler) AddSection(section, name string, data []byte) {
+	value := c.ctx.ConstString(string(data), false)
+	global := llvm.AddGlobal(c.mod, value.Type(), name)
+	global.SetInitializer(value)
+	global.SetGlobalConstant(true)
+	global.SetSection(section)
+	global.SetAlignment(4)
+}
+
 // When -wasm-abi flag set to "js" (default),
 // replace i64 in an external function with a stack-allocated i64*, to work
 // around the lack of 64-bit integers in JavaScript (commonly used together with
@@ -0,0 +1,102 @@
+package compiler
+
+// This file replaces calls to fmt.Errorf that only wrap an error with a
+// constant prefix, like this:
+//
+//     return fmt.Errorf("could not read config: %w", err)
+//
+// with a call to runtime.wrapErrorf, which returns an equivalent error (with
+// the same message and the same Unwrap method) but doesn't need the formatting
+// machinery of the fmt package. When all calls to fmt.Errorf in a program are
+// replaced, the fmt package isn't linked in at all, unless it is used for
+// something else. Calls with any other format string, or with an argument that
+// doesn't implement error (which fmt.Errorf formats as %!w(...) instead of
+// wrapping it), are left alone.
+//
+// This is done while compiling the call because the static type of the
+// argument (before it is converted to interface{}) is only known in the Go SSA.
+// The runtime only provides wrapErrorf for Go 1.13 and later, as older versions
+// of the fmt package don't support %w.
+
+import (
+	"go/constant"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+	"tinygo.org/x/go-llvm"
+)
+
+// emitErrorf emits a call to runtime.wrapErrorf for a call to fmt.Errorf with
+// a format string of the form "prefix%w" and a single argument that implements
+// error. The prefix must not contain any other formatting verb (or %%). It
+// returns false if the call can't be replaced, in which case nothing is
+// emitted.
+func (c *Compiler) emitErrorf(frame *Frame, instr *ssa.CallCommon) (llvm.Value, bool) {
+	if c.ir.Program.ImportedPackage("runtime").Members["wrapErrorf"] == nil {
+		// Go 1.12 or older, or a runtime that doesn't support this.
+		return llvm.Value{}, false
+	}
+	format, ok := instr.Args[0].(*ssa.Const)
+	if !ok || format.Value == nil || format.Value.Kind() != constant.String {
+		return llvm.Value{}, false
+	}
+	prefix := constant.StringVal(format.Value)
+	if !strings.HasSuffix(prefix, "%w") || strings.IndexByte(prefix[:len(prefix)-2], '%') >= 0 {
+		return llvm.Value{}, false // not a constant prefix followed by %w
+	}
+	prefix = prefix[:len(prefix)-2]
+	arg, itf := errorfArgument(instr.Args[1])
+	if arg == nil {
+		return llvm.Value{}, false
+	}
+	errorType := types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+	if !types.Implements(arg.Type(), errorType) {
+		return llvm.Value{}, false // formatted as %!w(...), not wrapped
+	}
+
+	// fmt.Errorf(prefix+"%w", err) -> runtime.wrapErrorf(prefix, err)
+	// The argument has already been converted to interface{}, which has the
+	// same representation as error. The varargs slice isn't used anymore and
+	// is removed by the optimizer.
+	err := c.getValue(frame, itf)
+	prefixValue := c.parseConst(frame.fn.LinkName(), ssa.NewConst(constant.MakeString(prefix), types.Typ[types.String]))
+	return c.createRuntimeCall("wrapErrorf", []llvm.Value{prefixValue, err}, ""), true
+}
+
+// errorfArgument returns the only value in a varargs slice created for a call,
+// both before and after it was converted to interface{}. It returns nil if the
+// slice wasn't created by the call (for example fmt.Errorf(format, args...)) or
+// doesn't contain exactly one value.
+func errorfArgument(value ssa.Value) (arg, itf ssa.Value) {
+	slice, ok := value.(*ssa.Slice)
+	if !ok {
+		return nil, nil
+	}
+	alloc, ok := slice.X.(*ssa.Alloc)
+	if !ok || alloc.Comment != "varargs" || alloc.Type().(*types.Pointer).Elem().(*types.Array).Len() != 1 {
+		return nil, nil
+	}
+	// The Go SSA builder creates a varargs slice with an address of each
+	// element, a store to it and the slice itself, right before the call.
+	refs := *alloc.Referrers()
+	if len(refs) != 2 {
+		return nil, nil
+	}
+	addr, ok := refs[0].(*ssa.IndexAddr)
+	if !ok || len(*addr.Referrers()) != 1 {
+		return nil, nil
+	}
+	store, ok := (*addr.Referrers())[0].(*ssa.Store)
+	if !ok {
+		return nil, nil
+	}
+	switch conv := store.Val.(type) {
+	case *ssa.ChangeInterface:
+		return conv.X, store.Val
+	case *ssa.MakeInterface:
+		return conv.X, store.Val
+	default:
+		return store.Val, store.Val // already an interface{}
+	}
+}
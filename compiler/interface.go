@@ -108,7 +108,9 @@ func (c *Compiler) makeStructTypeFields(typ *types.Struct) llvm.Value {
 			llvm.ConstInt(llvm.Int32Type(), 0, false),
 		})
 		fieldGlobalValue = llvm.ConstInsertValue(fieldGlobalValue, fieldName, []uint32{1})
-		if typ.Tag(i) != "" {
+		if c.ReflectTags && typ.Tag(i) != "" {
+			// Struct tags are only stored when requested, as they can take
+			// up a lot of space (for example JSON tags).
 			fieldTag := c.makeGlobalArray([]byte(typ.Tag(i)), "reflect/types.structFieldTag", c.ctx.Int8Type())
 			fieldTag.SetLinkage(llvm.PrivateLinkage)
 			fieldTag.SetUnnamedAddr(true)
@@ -359,7 +361,8 @@ func (c *Compiler) parseTypeAssert(frame *Frame, expr *ssa.TypeAssert) llvm.Valu
 	okBlock := c.ctx.AddBasicBlock(frame.fn.LLVMFn, "typeassert.ok")
 	nextBlock := c.ctx.AddBasicBlock(frame.fn.LLVMFn, "typeassert.next")
 	frame.blockExits[frame.currentBlock] = nextBlock // adjust outgoing block for phi nodes
-	c.builder.CreateCondBr(commaOk, okBlock, nextBlock)
+	br := c.builder.CreateCondBr(commaOk, okBlock, nextBlock)
+	c.setTypeAssertBranchWeights(br, expr) // //go:likely
 
 	// Retrieve the value from the interface if the type assert was
 	// successful.
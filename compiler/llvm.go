@@ -1,14 +1,22 @@
 package compiler
 
+// This file contains helper functions for LLVM that are not exposed in the Go
+// bindings.
+
+/*
+typedef struct LLVMOpaqueValue *LLVMValueRef;
+typedef int LLVMBool;
+void LLVMSetExternallyInitialized(LLVMValueRef GlobalVar, LLVMBool IsExtInit);
+*/
+import "C"
+
 import (
 	"reflect"
+	"unsafe"
 
 	"tinygo.org/x/go-llvm"
 )
 
-// This file contains helper functions for LLVM that are not exposed in the Go
-// bindings.
-
 // Return a list of values (actually, instructions) where this value is used as
 // an operand.
 func getUses(value llvm.Value) []llvm.Value {
@@ -24,6 +32,15 @@ func getUses(value llvm.Value) []llvm.Value {
 	return uses
 }
 
+// isPtrToInt returns whether the value is a ptrtoint instruction or a ptrtoint
+// constant expression, as is created for the address of a global.
+func isPtrToInt(value llvm.Value) bool {
+	if !value.IsAPtrToIntInst().IsNil() {
+		return true
+	}
+	return !value.IsAConstantExpr().IsNil() && value.Opcode() == llvm.PtrToInt
+}
+
 // createEntryBlockAlloca creates a new alloca in the entry block, even though
 // the IR builder is located elsewhere. It assumes that the insert point is
 // at the end of the current block.
@@ -215,3 +232,9 @@ func (c *Compiler) replaceGlobalIntWithArray(name string, buf interface{}) llvm.
 	global.SetName(name)
 	return global
 }
+
+// setExternallyInitialized marks the global as externally initialized: its
+// value at program start is not known, even though it has an initializer.
+func setExternallyInitialized(global llvm.Value) {
+	C.LLVMSetExternallyInitialized(C.LLVMValueRef(unsafe.Pointer(global.C)), 1)
+}
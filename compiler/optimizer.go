@@ -2,14 +2,40 @@ package compiler
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/tinygo-org/tinygo/transform"
 	"tinygo.org/x/go-llvm"
 )
 
+// Transforms is a set of optional TinyGo-specific transforms, which make code
+// faster but usually also larger. All other TinyGo transforms are always run
+// at optLevel 1 and above.
+type Transforms uint
+
+const (
+	// Specialize calls to sort.Search with a simple predicate, see
+	// transform.SpecializeSortSearch.
+	TransformSortSearch Transforms = 1 << iota
+
+	// Check the dominant case of a switch first, see
+	// transform.PeelLikelySwitchCases.
+	TransformLikelySwitch
+
+	// Call chains of interfaces that don't change after initialization
+	// directly, see transform.DevirtualizeInterfaceChains.
+	TransformDevirtualize
+
+	// Vectorize loops on targets with SIMD instructions, see
+	// transform.Vectorize.
+	TransformVectorize
+)
+
 // Run the LLVM optimizer over the module.
 // The inliner can be disabled (if necessary) by passing 0 to the inlinerThreshold.
-func (c *Compiler) Optimize(optLevel, sizeLevel int, inlinerThreshold uint) error {
+// The optional TinyGo transforms to run (at optLevel 1 and above) are selected
+// with transforms.
+func (c *Compiler) Optimize(optLevel, sizeLevel int, inlinerThreshold uint, transforms Transforms) error {
 	builder := llvm.NewPassManagerBuilder()
 	defer builder.Dispose()
 	builder.SetOptLevel(optLevel)
@@ -51,15 +77,45 @@ func (c *Compiler) Optimize(optLevel, sizeLevel int, inlinerThreshold uint) erro
 		// Run Go-specific optimization passes.
 		transform.OptimizeMaps(c.mod)
 		transform.OptimizeStringToBytes(c.mod)
+		transform.OptimizeStringLength(c.mod)
+		transform.OptimizeSliceCopy(c.mod)
+		if transforms&TransformSortSearch != 0 {
+			transform.SpecializeSortSearch(c.mod)
+		}
 		transform.OptimizeAllocs(c.mod)
 		c.LowerInterfaces()
 		c.LowerFuncValues()
 
+		// Different interfaces implemented by the same types result in
+		// identical thunks, which only need to be kept once.
+		transform.MergeInterfaceThunks(c.mod)
+
+		// Type codes are known now, so interface values that are type
+		// asserted right after they are created can be removed.
+		transform.OptimizeInterfaceRoundTrips(c.mod)
+
+		// Chains of interfaces that don't change after initialization (like
+		// a logger writing to a multi writer) can be called directly. This
+		// inlines every layer of the chain, so it shouldn't be done when
+		// optimizing for size.
+		if transforms&TransformDevirtualize != 0 {
+			transform.DevirtualizeInterfaceChains(c.mod)
+		}
+
 		// After interfaces are lowered, there are many more opportunities for
 		// interprocedural optimizations. To get them to work, function
 		// attributes have to be updated first.
 		goPasses.Run(c.mod)
 
+		// Mutexes are not needed when no goroutines are started. This must
+		// be done before goroutine lowering and after interface lowering, to
+		// also catch calls through sync.Locker.
+		transform.OptimizeMutexes(c.mod)
+
+		// Constant propagation may have proven some bounds checks and other
+		// panics to be unreachable. Remove them, including their messages.
+		transform.RemoveDeadPanics(c.mod)
+
 		// Run TinyGo-specific interprocedural optimizations.
 		transform.OptimizeAllocs(c.mod)
 		transform.OptimizeStringToBytes(c.mod)
@@ -129,6 +185,36 @@ func (c *Compiler) Optimize(optLevel, sizeLevel int, inlinerThreshold uint) erro
 	builder.Populate(modPasses)
 	modPasses.Run(c.mod)
 
+	if optLevel > 0 && transforms&TransformVectorize != 0 {
+		// Vectorize loops on targets with SIMD instructions, like Clang does
+		// at -O2 and -Os. This shouldn't be done at -Oz as it increases code
+		// size.
+		transform.Vectorize(c.mod, c.machine)
+	}
+
+	if optLevel > 0 {
+		// Branch directly on comparisons instead of on booleans computed from
+		// them. This must be done after all passes that run SimplifyCFG.
+		transform.FoldBranchConditions(c.mod)
+
+		// Check the dominant case of a switch (usually from a //go:likely
+		// type switch case) first. This must also be done after SimplifyCFG.
+		if transforms&TransformLikelySwitch != 0 {
+			transform.PeelLikelySwitchCases(c.mod)
+		}
+
+		if strings.HasPrefix(c.Triple, "armv6m") {
+			// The Cortex-M0 has no hardware divider and LLVM doesn't replace
+			// divisions by a constant on it, not even at -O2.
+			transform.OptimizeConstantDivisions(c.mod)
+		}
+
+		// Now that all allocation optimizations have run, many pointers turn
+		// out not to need tracking by the GC. Remove this tracking before it
+		// is turned into stack objects.
+		transform.OptimizeTrackPointers(c.mod)
+	}
+
 	hasGCPass := c.addGlobalsBitmap()
 	hasGCPass = c.makeGCStackSlots() || hasGCPass
 	if hasGCPass {
@@ -137,6 +223,17 @@ func (c *Compiler) Optimize(optLevel, sizeLevel int, inlinerThreshold uint) erro
 		}
 	}
 
+	if c.PIC == "ropi" {
+		// Globals must not change anymore after this pass, so it must be the
+		// last one.
+		if err := transform.RelocateROPIGlobals(c.mod); err != nil {
+			return err
+		}
+		if err := c.Verify(); err != nil {
+			return errors.New("ROPI relocation caused a verification failure")
+		}
+	}
+
 	return nil
 }
 
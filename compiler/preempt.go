@@ -0,0 +1,60 @@
+package compiler
+
+// This file implements -preempt-loops, which inserts a preemption check at
+// every loop back-edge. TinyGo goroutines are scheduled cooperatively: they
+// only switch to another goroutine in a blocking operation (like a channel
+// operation or time.Sleep). A goroutine that runs a long computation without
+// any blocking operation therefore never gives other goroutines a chance to
+// run. With -preempt-loops, a timer interrupt periodically sets a flag which
+// is checked at the end of every loop iteration, and the goroutine yields to
+// the scheduler when it is set (see src/runtime/preempt.go).
+//
+// This is not real preemption: a goroutine is only preempted at a loop
+// back-edge, not in straight-line code or while waiting in a function that
+// doesn't contain a loop. The check is cheap (a volatile load of a byte, a
+// compare and a branch, which are about 6 bytes of code per back-edge on
+// Cortex-M) but it does prevent some loop optimizations, as the loop now
+// contains a volatile load and a possible call. As the check may yield,
+// programs built with -preempt-loops always start the scheduler.
+
+import (
+	"strings"
+
+	"github.com/tinygo-org/tinygo/ir"
+	"golang.org/x/tools/go/ssa"
+)
+
+// preemptsLoops returns whether a preemption check is inserted at the loop
+// back-edges of the given function. Functions in the runtime, machine and
+// device packages are never preempted: they implement the scheduler and
+// interrupt handlers, where yielding is not possible. The same is true for
+// exported functions, which may be called from C or as an interrupt handler.
+func (c *Compiler) preemptsLoops(f *ir.Function) bool {
+	if !c.PreemptLoops || f.IsExported() || f.IsInterrupt() || f.Pkg == nil {
+		return false
+	}
+	pkgPath := f.Pkg.Pkg.Path()
+	switch {
+	case pkgPath == "runtime" || strings.HasPrefix(pkgPath, "runtime/"):
+		return false
+	case pkgPath == "machine" || strings.HasPrefix(pkgPath, "device/"):
+		return false
+	}
+	return true
+}
+
+// createLoopPreemptCheck inserts a preemption check if the given block jumps
+// back to the header of a loop, which is the case when the jump target
+// dominates the block. It must be called right before the terminator of the
+// block is created.
+func (c *Compiler) createLoopPreemptCheck(frame *Frame, block *ssa.BasicBlock) {
+	if !c.preemptsLoops(frame.fn) {
+		return
+	}
+	for _, succ := range block.Succs {
+		if succ.Dominates(block) {
+			c.createRuntimeCall("preemptLoop", nil, "")
+			return
+		}
+	}
+}
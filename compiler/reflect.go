@@ -155,26 +155,32 @@ func (c *Compiler) assignTypeCodes(typeSlice typeInfoSlice) {
 		t.num = num.Uint64()
 	}
 
-	// Only create this sidetable when it is necessary.
+	// Only create these sidetables when they are necessary. They never change,
+	// so they are marked constant: LLVM can then fold loads from them and move
+	// loads out of loops.
 	if state.needsNamedNonBasicTypesSidetable {
 		global := c.replaceGlobalIntWithArray("reflect.namedNonBasicTypesSidetable", state.namedNonBasicTypesSidetable)
 		global.SetLinkage(llvm.InternalLinkage)
 		global.SetUnnamedAddr(true)
+		global.SetGlobalConstant(true)
 	}
 	if state.needsArrayTypesSidetable {
 		global := c.replaceGlobalIntWithArray("reflect.arrayTypesSidetable", state.arrayTypesSidetable)
 		global.SetLinkage(llvm.InternalLinkage)
 		global.SetUnnamedAddr(true)
+		global.SetGlobalConstant(true)
 	}
 	if state.needsStructTypesSidetable {
 		global := c.replaceGlobalIntWithArray("reflect.structTypesSidetable", state.structTypesSidetable)
 		global.SetLinkage(llvm.InternalLinkage)
 		global.SetUnnamedAddr(true)
+		global.SetGlobalConstant(true)
 	}
 	if state.needsStructNamesSidetable {
 		global := c.replaceGlobalIntWithArray("reflect.structNamesSidetable", state.structNamesSidetable)
 		global.SetLinkage(llvm.InternalLinkage)
 		global.SetUnnamedAddr(true)
+		global.SetGlobalConstant(true)
 	}
 }
 
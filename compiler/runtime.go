@@ -0,0 +1,188 @@
+package compiler
+
+// This file lists the symbols that the compiler needs from the runtime
+// package. The TinyGo runtime (in src/runtime) provides all of them, but it can
+// be replaced with a different runtime package using the -runtime flag, for
+// example to try out a different memory allocator or scheduler. The easiest
+// way to write such a runtime is to start from a copy of the TinyGo runtime.
+//
+// A replacement runtime is checked against the lists below before compiling,
+// so that a missing symbol results in a clear error. Only the names and the
+// kinds of the symbols are checked: the signatures of functions and the layout
+// of types must be the same as in the TinyGo runtime, as the compiler emits
+// calls to these functions and values of these types directly. Additionally,
+// other packages (like the standard library packages time, sync and os) may
+// need more functions that the runtime implements with //go:linkname, such as
+// time.Sleep. These are only needed when the program uses them and result in
+// a linker error when they are missing.
+
+import (
+	"errors"
+	"go/types"
+	"strings"
+)
+
+// runtimeTypes are the types that the compiler uses to lay out strings,
+// interfaces, channels, maps and other built-in types and the values used to
+// implement them.
+var runtimeTypes = []string{
+	"_defer",
+	"_interface",
+	"_string",
+	"chanSelectState",
+	"channel",
+	"funcValue",
+	"funcValueWithSignature",
+	"hashmap",
+	"hashmapIterator",
+	"interfaceMethodInfo",
+	"stringIterator",
+	"structField",
+	"typeInInterface",
+	"typecodeID",
+}
+
+// runtimeFunctions are the functions that the compiler calls directly, for
+// Go operations that are implemented in the runtime. The runtime must also
+// provide the functions in functionsUsedInTransforms and the ones for the
+// scheduler, see getFunctionsUsedInTransforms.
+var runtimeFunctions = []string{
+	// Program startup and shutdown.
+	"abort",
+	"callMain",
+	"initAll",
+
+	// Memory.
+	"memcpy",
+	"memmove",
+
+	// Panics and runtime errors.
+	"_panic",
+	"_recover",
+	"lookupPanic",
+	"slicePanic",
+
+	// Goroutines and channels.
+	"chanClose",
+	"chanMake",
+	"chanRecv",
+	"chanSelect",
+	"chanSend",
+	"deadlock",
+	"yield",
+
+	// Interfaces and func values.
+	"getFuncPtr",
+	"interfaceEqual",
+	"interfaceImplements",
+	"interfaceMethod",
+	"interfaceTypeAssert",
+	"isnil",
+	"typeAssert",
+
+	// Maps.
+	"hashmapBinaryDelete",
+	"hashmapBinaryGet",
+	"hashmapBinarySet",
+	"hashmapLen",
+	"hashmapMake",
+	"hashmapNext",
+	"hashmapStringDelete",
+	"hashmapStringGet",
+	"hashmapStringSet",
+
+	// Strings and slices.
+	"sliceAppend",
+	"sliceCopy",
+	"stringConcat",
+	"stringEqual",
+	"stringFromBytes",
+	"stringFromRunes",
+	"stringFromUnicode",
+	"stringLess",
+	"stringNext",
+	"stringToBytes",
+	"stringToRunes",
+
+	// Arithmetic.
+	"complex64div",
+	"complex128div",
+
+	// The builtin print and println functions.
+	"printbool",
+	"printcomplex64",
+	"printcomplex128",
+	"printfloat32",
+	"printfloat64",
+	"printint8",
+	"printint16",
+	"printint32",
+	"printint64",
+	"printitf",
+	"printmap",
+	"printnl",
+	"printptr",
+	"printspace",
+	"printstring",
+	"printuint8",
+	"printuint16",
+	"printuint32",
+	"printuint64",
+}
+
+// requiredRuntimeSymbols returns the functions, types and global variables
+// that the runtime package must provide with the current configuration.
+func (c *Compiler) requiredRuntimeSymbols() (functions, typeNames, globals []string) {
+	functions = append(functions, runtimeFunctions...)
+	for _, name := range c.getFunctionsUsedInTransforms() {
+		functions = append(functions, strings.TrimPrefix(name, "runtime."))
+	}
+	typeNames = append(typeNames, runtimeTypes...)
+	switch c.selectScheduler() {
+	case "coroutines":
+		functions = append(functions, "fakeCoroutine", "makeGoroutine")
+		typeNames = append(typeNames, "taskState")
+	case "tasks":
+		if c.GoroutinePool != 0 {
+			globals = append(globals, "goroutinePoolSize")
+		}
+		if c.TaskStackSize != 0 {
+			globals = append(globals, "goroutineStackSize")
+		}
+	}
+	if c.needsStackObjects() {
+		functions = append(functions, "trackPointer")
+		globals = append(globals, "stackChainStart")
+	}
+	if c.PreemptLoops {
+		functions = append(functions, "preemptLoop")
+	}
+	return
+}
+
+// checkRuntimePackage checks that the given runtime package provides all
+// symbols needed by the compiler, and returns an error for each missing one.
+func (c *Compiler) checkRuntimePackage(pkg *types.Package) []error {
+	var errs []error
+	check := func(names []string, kind string, ok func(types.Object) bool) {
+		for _, name := range names {
+			if obj := pkg.Scope().Lookup(name); obj == nil || !ok(obj) {
+				errs = append(errs, errors.New("runtime package in "+c.RuntimePath+" does not provide "+kind+" "+name))
+			}
+		}
+	}
+	functions, typeNames, globals := c.requiredRuntimeSymbols()
+	check(functions, "function", func(obj types.Object) bool {
+		_, ok := obj.(*types.Func)
+		return ok
+	})
+	check(typeNames, "type", func(obj types.Object) bool {
+		_, ok := obj.(*types.TypeName)
+		return ok
+	})
+	check(globals, "global", func(obj types.Object) bool {
+		_, ok := obj.(*types.Var)
+		return ok
+	})
+	return errs
+}
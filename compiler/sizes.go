@@ -36,7 +36,7 @@ func (s *StdSizes) Alignof(T types.Type) int64 {
 			}
 		}
 		return max
-	case *types.Slice, *types.Interface:
+	case *types.Slice, *types.Interface, *types.Signature:
 		// Multiword data structures are effectively structs
 		// in which each element has size WordSize.
 		return s.PtrSize
@@ -168,8 +168,13 @@ func (s *StdSizes) Sizeof(T types.Type) int64 {
 		}
 	case *types.Interface:
 		return s.PtrSize * 2
-	case *types.Pointer:
+	case *types.Pointer, *types.Chan, *types.Map:
+		// Channels and maps are pointers to a runtime object.
 		return s.PtrSize
+	case *types.Signature:
+		// A function value is a context pointer and a function pointer (or
+		// function ID), see getFuncType.
+		return s.PtrSize * 2
 	default:
 		panic("unknown type: " + t.String())
 	}
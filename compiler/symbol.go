@@ -22,6 +22,8 @@ type globalInfo struct {
 	linkName string // go:extern
 	extern   bool   // go:extern
 	align    int    // go:align
+	section  string // go:section, go:retained
+	retained bool   // go:retained
 }
 
 // loadASTComments loads comments on globals from the AST, for use later in the
@@ -69,6 +71,15 @@ func (c *Compiler) getGlobal(g *ssa.Global) llvm.Value {
 		if info.align > c.targetData.ABITypeAlignment(llvmType) {
 			llvmGlobal.SetAlignment(info.align)
 		}
+		if info.section != "" {
+			llvmGlobal.SetSection(info.section)
+		}
+		if info.retained {
+			// The initializer is only there because LLVM requires one for
+			// a definition: the value at startup is whatever was left in
+			// memory, so loads must not be folded to zero.
+			setExternallyInitialized(llvmGlobal)
+		}
 	}
 	return llvmGlobal
 }
@@ -94,7 +105,9 @@ func (c *Compiler) getGlobalInfo(g *ssa.Global) globalInfo {
 }
 
 // Parse //go: pragma comments from the source. In particular, it parses the
-// //go:extern pragma on globals.
+// //go:extern pragma on globals, the //go:section pragma which places a global
+// in the given linker section, and the //go:retained pragma which places a
+// global in the .noinit section so that it keeps its value across a reset.
 func (info *globalInfo) parsePragmas(doc *ast.CommentGroup) {
 	for _, comment := range doc.List {
 		if !strings.HasPrefix(comment.Text, "//go:") {
@@ -112,6 +125,13 @@ func (info *globalInfo) parsePragmas(doc *ast.CommentGroup) {
 			if err == nil {
 				info.align = align
 			}
+		case "//go:section":
+			if len(parts) == 2 {
+				info.section = parts[1]
+			}
+		case "//go:retained":
+			info.section = ".noinit"
+			info.retained = true
 		}
 	}
 }
@@ -0,0 +1,72 @@
+package compiler
+
+// This file implements -trimpath, which removes file system paths from the
+// debug information. Like the Go toolchain, the directory of each source file
+// is replaced with the import path of the package, for example
+// /home/user/go/src/github.com/user/project/main.go is stored as
+// github.com/user/project/main.go and $TINYGOROOT/src/runtime/scheduler.go is
+// stored as runtime/scheduler.go. This makes builds reproducible when they are
+// done from different directories and avoids leaking the directory structure
+// of the build machine.
+//
+// Source file paths are only stored in the debug information: TinyGo doesn't
+// include file names or line numbers in panic messages or anywhere else in the
+// binary.
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/loader"
+)
+
+// commandLinePackagePath is the import path used for a main package that is
+// not in GOPATH, such as a single file given on the command line. This is the
+// same pseudo import path as used by the go tool.
+const commandLinePackagePath = "command-line-arguments"
+
+// initTrimPath stores the trimmed path of all package directories, to be used
+// by debugFilename.
+func (c *Compiler) initTrimPath(lprogram *loader.Program, wd string) {
+	c.trimPaths = make(map[string]string, len(lprogram.Packages))
+	for _, pkg := range lprogram.Packages {
+		c.trimPaths[filepath.Clean(pkg.Package.Dir)] = TrimmedPackagePath(pkg.ImportPath, pkg.Package.Dir, wd)
+	}
+}
+
+// TrimmedPackagePath returns the path that replaces the directory dir of the
+// package with the given import path when -trimpath is used. The working
+// directory wd is used for local packages outside of GOPATH.
+func TrimmedPackagePath(importPath, dir, wd string) string {
+	if strings.HasSuffix(importPath, ".go") {
+		// Pseudo-package created from a single file.
+		return commandLinePackagePath
+	}
+	if strings.HasPrefix(importPath, "_/") {
+		// A local import outside of GOPATH, which includes the absolute path
+		// of the directory in the import path.
+		if rel, err := filepath.Rel(wd, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			if rel == "." {
+				return commandLinePackagePath
+			}
+			return filepath.ToSlash(rel)
+		}
+		return filepath.Base(dir)
+	}
+	return importPath
+}
+
+// debugFilename returns the file name to store in the debug information for
+// the given source file. Without -trimpath, this is the file name itself.
+func (c *Compiler) debugFilename(filename string) string {
+	if !c.TrimPath {
+		return filename
+	}
+	dir, file := filepath.Split(filename)
+	if pkgPath, ok := c.trimPaths[filepath.Clean(dir)]; ok {
+		return path.Join(pkgPath, file)
+	}
+	// Not a file of a known package, for example a file generated by CGo.
+	return file
+}
@@ -0,0 +1,135 @@
+package compiler
+
+// This file implements the //go:likely pragma on type switch cases. A case
+// marked with this pragma is expected to match most of the time:
+//
+//     switch v := v.(type) {
+//     //go:likely *Circle
+//     case *Circle, *Square:
+//         ...
+//     case *Triangle:
+//         ...
+//     }
+//
+// The type assert for the likely type gets branch weights that mark it as
+// taken almost always, and the type asserts for the other types in the same
+// type switch get weights that mark them as almost never taken. These weights
+// survive when LLVM turns the chain of type asserts into a switch, after which
+// transform.PeelLikelySwitchCases checks the likely type before all other
+// cases. Marking the other cases as unlikely is necessary as LLVM assumes
+// branches without weights to be taken half of the time when merging them
+// into a switch, which would give the cases before the likely case a higher
+// weight.
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/loader"
+	"golang.org/x/tools/go/ssa"
+	"tinygo.org/x/go-llvm"
+)
+
+// loadLikelyCases finds all //go:likely pragmas in type switches. For every
+// case in a type switch with such a pragma, the likely types of that case (if
+// any) are stored by the position of the case keyword, which is also the
+// position of the type asserts in the SSA form.
+func (c *Compiler) loadLikelyCases(lprogram *loader.Program) {
+	c.likelyCases = map[token.Pos][]types.Type{}
+	fset := c.ir.Program.Fset
+	for _, pkgInfo := range lprogram.Sorted() {
+		for _, file := range pkgInfo.Files {
+			// Find the pragmas in this file by line number, to match them
+			// against the line before each case clause. Most files don't have
+			// any, so they don't need to be inspected.
+			pragmas := map[int]*ast.Comment{}
+			for _, group := range file.Comments {
+				for _, comment := range group.List {
+					if strings.HasPrefix(comment.Text, "//go:likely ") {
+						pragmas[fset.Position(comment.Slash).Line] = comment
+					}
+				}
+			}
+			if len(pragmas) == 0 {
+				continue
+			}
+			ast.Inspect(file, func(node ast.Node) bool {
+				stmt, ok := node.(*ast.TypeSwitchStmt)
+				if !ok {
+					return true
+				}
+				likelyCases := map[token.Pos][]types.Type{}
+				for _, clause := range stmt.Body.List {
+					clause := clause.(*ast.CaseClause)
+					comment := pragmas[fset.Position(clause.Case).Line-1]
+					if comment == nil {
+						continue
+					}
+					typ := c.parseLikelyPragma(pkgInfo, comment, clause)
+					if typ != nil {
+						likelyCases[clause.Case] = append(likelyCases[clause.Case], typ)
+					}
+				}
+				if len(likelyCases) == 0 {
+					return true
+				}
+				for _, clause := range stmt.Body.List {
+					pos := clause.(*ast.CaseClause).Case
+					c.likelyCases[pos] = likelyCases[pos]
+				}
+				return true
+			})
+		}
+	}
+}
+
+// parseLikelyPragma returns the type named in a //go:likely pragma, which must
+// be one of the (concrete) types in the given case clause. It returns nil and
+// adds an error if it isn't.
+func (c *Compiler) parseLikelyPragma(pkgInfo *loader.Package, comment *ast.Comment, clause *ast.CaseClause) types.Type {
+	text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//go:likely "))
+	expr, err := parser.ParseExpr(text)
+	if err != nil {
+		c.addError(comment.Slash, "invalid type in //go:likely pragma: "+text)
+		return nil
+	}
+	for _, caseExpr := range clause.List {
+		if types.ExprString(caseExpr) != types.ExprString(expr) {
+			continue
+		}
+		typ := pkgInfo.TypeOf(caseExpr)
+		if _, ok := typ.Underlying().(*types.Interface); ok {
+			c.addError(comment.Slash, "//go:likely pragma is not supported on interface type "+text)
+			return nil
+		}
+		return typ
+	}
+	c.addError(comment.Slash, "//go:likely pragma type "+text+" is not in the case below it")
+	return nil
+}
+
+// setTypeAssertBranchWeights sets the branch weights of the given conditional
+// branch on the result of a type assert, if the type assert is part of a type
+// switch with a //go:likely pragma. The weights are the same as the ones
+// Clang uses for __builtin_expect.
+func (c *Compiler) setTypeAssertBranchWeights(br llvm.Value, expr *ssa.TypeAssert) {
+	likelyTypes, ok := c.likelyCases[expr.Pos()]
+	if !ok {
+		return
+	}
+	var ifTrue, ifFalse uint64 = 1, 2000
+	for _, typ := range likelyTypes {
+		if types.Identical(typ, expr.AssertedType) {
+			ifTrue, ifFalse = 2000, 1
+		}
+	}
+	weights := c.ctx.MDNode([]llvm.Metadata{
+		c.ctx.MDString("branch_weights"),
+		llvm.ConstInt(c.ctx.Int32Type(), ifTrue, false).ConstantAsMetadata(),
+		llvm.ConstInt(c.ctx.Int32Type(), ifFalse, false).ConstantAsMetadata(),
+	})
+	br.SetMetadata(c.ctx.MDKindID("prof"), weights)
+}
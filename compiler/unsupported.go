@@ -0,0 +1,84 @@
+package compiler
+
+// This file lists standard library packages that cannot work on some targets.
+// Importing them would otherwise result in confusing errors much later in the
+// build, usually a link error about a missing syscall.
+
+// unsupportedPackage describes on which targets a package is unsupported and
+// why.
+type unsupportedPackage struct {
+	targets     []string // build tags of unsupported targets, nil for all targets
+	reason      string
+	alternative string // suggested alternative, if any
+}
+
+// targetKinds maps the build tags used in unsupportedPackages to a
+// human-readable description of the targets that have them.
+var targetKinds = map[string]string{
+	"baremetal": "on microcontrollers",
+	"wasm":      "on WebAssembly",
+}
+
+var noOS = []string{"baremetal", "wasm"}
+
+var noNetwork = unsupportedPackage{
+	targets:     noOS,
+	reason:      "there is no network stack",
+	alternative: "use a network driver from tinygo.org/x/drivers, such as wifinina or espat",
+}
+
+// unsupportedPackages is the support matrix of standard library packages: all
+// packages that are not listed are supported everywhere (as far as the
+// compiler supports the language features they use).
+var unsupportedPackages = map[string]unsupportedPackage{
+	"net":       noNetwork,
+	"net/http":  noNetwork,
+	"net/rpc":   noNetwork,
+	"net/smtp":  noNetwork,
+	"os/exec":   {targets: noOS, reason: "there is no operating system to run processes"},
+	"os/signal": {targets: noOS, reason: "there is no operating system to send signals"},
+	"os/user":   {targets: noOS, reason: "there is no operating system with user accounts"},
+	"plugin":    {reason: "loading Go code at runtime is not supported"},
+}
+
+// unsupportedPackage returns why the package with the given import path cannot
+// be used on the current target, or the empty string if it can be used.
+func (c *Compiler) unsupportedPackage(path string) string {
+	pkg, ok := unsupportedPackages[path]
+	if !ok {
+		return ""
+	}
+	where := "by TinyGo"
+	if pkg.targets != nil {
+		where = ""
+		for _, tag := range pkg.targets {
+			if c.hasBuildTag(tag) {
+				where = targetKinds[tag]
+				break
+			}
+		}
+		if where == "" {
+			// Supported on this target.
+			return ""
+		}
+	}
+	msg := where + ": " + pkg.reason
+	if pkg.alternative != "" {
+		msg += " (" + pkg.alternative + ")"
+	}
+	return msg
+}
+
+// hasBuildTag returns whether the given build tag is set for the current
+// target, either explicitly or because it is the GOOS or GOARCH.
+func (c *Compiler) hasBuildTag(tag string) bool {
+	if tag == c.GOOS || tag == c.GOARCH {
+		return true
+	}
+	for _, t := range c.BuildTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
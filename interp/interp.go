@@ -38,6 +38,14 @@ func Run(mod llvm.Module, debug bool) error {
 	}
 	e.builder = mod.Context().NewBuilder()
 
+	// The initializer of an externally initialized global is not its value at
+	// startup, so all loads and stores must happen at runtime.
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		if isExternallyInitialized(global) {
+			e.dirtyGlobals[global] = struct{}{}
+		}
+	}
+
 	initAll := mod.NamedFunction(name)
 	bb := initAll.EntryBasicBlock()
 	// Create a dummy alloca in the entry block that we can set the insert point
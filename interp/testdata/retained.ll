@@ -0,0 +1,31 @@
+target datalayout = "e-m:e-i64:64-f80:128-n8:16:32:64-S128"
+target triple = "x86_64--linux"
+
+@main.bootCount = internal externally_initialized global i32 0, section ".noinit"
+@main.v1 = internal global i32 0
+
+declare void @runtime.printint32(i32) unnamed_addr
+
+define void @runtime.initAll() unnamed_addr {
+entry:
+  call void @main.init()
+  ret void
+}
+
+define void @main() unnamed_addr {
+entry:
+  %0 = load i32, i32* @main.v1
+  call void @runtime.printint32(i32 %0)
+  ret void
+}
+
+; The value of an externally initialized global is not known at compile time,
+; so it must be loaded and stored at runtime.
+define internal void @main.init() unnamed_addr {
+entry:
+  %0 = load i32, i32* @main.bootCount
+  %1 = add i32 %0, 1
+  store i32 %1, i32* @main.bootCount
+  store i32 3, i32* @main.v1
+  ret void
+}
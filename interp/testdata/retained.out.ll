@@ -0,0 +1,20 @@
+target datalayout = "e-m:e-i64:64-f80:128-n8:16:32:64-S128"
+target triple = "x86_64--linux"
+
+@main.bootCount = internal unnamed_addr externally_initialized global i32 0, section ".noinit"
+
+declare void @runtime.printint32(i32) unnamed_addr
+
+define void @runtime.initAll() unnamed_addr {
+entry:
+  %0 = load i32, i32* @main.bootCount
+  %1 = add i32 %0, 1
+  store i32 %1, i32* @main.bootCount
+  ret void
+}
+
+define void @main() unnamed_addr {
+entry:
+  call void @runtime.printint32(i32 3)
+  ret void
+}
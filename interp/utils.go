@@ -1,6 +1,15 @@
 package interp
 
+/*
+typedef struct LLVMOpaqueValue *LLVMValueRef;
+typedef int LLVMBool;
+LLVMBool LLVMIsExternallyInitialized(LLVMValueRef GlobalVar);
+*/
+import "C"
+
 import (
+	"unsafe"
+
 	"tinygo.org/x/go-llvm"
 )
 
@@ -76,3 +85,10 @@ func isPointerNil(v llvm.Value) (result bool, ok bool) {
 	}
 	return false, false // not valid
 }
+
+// isExternallyInitialized returns whether the global has a value at program
+// start that is not known at compile time (such as a //go:retained global), even
+// though it has an initializer. This is not exposed in the Go bindings for LLVM.
+func isExternallyInitialized(global llvm.Value) bool {
+	return C.LLVMIsExternallyInitialized(C.LLVMValueRef(unsafe.Pointer(global.C))) != 0
+}
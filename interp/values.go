@@ -39,6 +39,12 @@ func (v *LocalValue) IsConstant() bool {
 	if _, ok := v.Eval.dirtyGlobals[v.Underlying]; ok {
 		return false
 	}
+	if !v.Underlying.IsAConstantExpr().IsNil() && v.Underlying.Opcode() == llvm.GetElementPtr {
+		// A field or element of a dirty global.
+		if _, ok := v.Eval.dirtyGlobals[v.Underlying.Operand(0)]; ok {
+			return false
+		}
+	}
 	return v.Underlying.IsConstant()
 }
 
@@ -66,6 +72,11 @@ func (v *LocalValue) Load() llvm.Value {
 // Store stores to the underlying value if the value type is a pointer type,
 // otherwise it panics.
 func (v *LocalValue) Store(value llvm.Value) {
+	if !v.IsConstant() {
+		// Stores to a dirty global must happen at runtime.
+		v.Eval.builder.CreateStore(value, v.Underlying)
+		return
+	}
 	if !v.Underlying.IsAGlobalVariable().IsNil() {
 		if !value.IsConstant() {
 			v.MarkDirty()
@@ -0,0 +1,124 @@
+package main
+
+// This file reads the interrupt vector table from a linked Cortex-M firmware
+// image, to report which interrupt handlers are installed. Interrupt priorities
+// are set at runtime (using arm.SetPriority) and can therefore not be reported.
+
+import (
+	"debug/elf"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Names of the Cortex-M system exceptions, indexed by vector number. Vector 0
+// is the initial stack pointer and not an exception.
+var systemExceptions = [16]string{
+	1:  "Reset",
+	2:  "NMI",
+	3:  "HardFault",
+	4:  "MemoryManagement",
+	5:  "BusFault",
+	6:  "UsageFault",
+	11: "SVC",
+	12: "DebugMon",
+	14: "PendSV",
+	15: "SysTick",
+}
+
+// interruptVector is a single entry in the vector table.
+type interruptVector struct {
+	// Vector number, which is the index in the vector table.
+	Vector int `json:"vector"`
+	// IRQ number as used by CMSIS: system exceptions have a negative number,
+	// peripheral interrupts start at 0.
+	IRQ int `json:"irq"`
+	// Name of the system exception, empty for peripheral interrupts.
+	Exception string `json:"exception,omitempty"`
+	// Symbol name of the handler function, like USART2_IRQHandler.
+	Handler string `json:"handler"`
+	// Address of the handler function.
+	Address uint64 `json:"address"`
+	// Default is true when the interrupt is not handled by the program, and
+	// jumps to Default_Handler instead.
+	Default bool `json:"default"`
+}
+
+// readInterrupts reads the vector table (the .isr_vector section) from the
+// given ELF file. Reserved entries (that are zero) are not included.
+func readInterrupts(path string) ([]interruptVector, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if file.Machine != elf.EM_ARM || file.Class != elf.ELFCLASS32 {
+		return nil, errors.New("interrupt vector can only be read from Cortex-M firmware")
+	}
+	section := file.Section(".isr_vector")
+	if section == nil {
+		return nil, errors.New("no .isr_vector section found in " + path)
+	}
+	data, err := section.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	// Map function addresses to symbol names. Peripheral interrupts that are
+	// not implemented are weak aliases of Default_Handler, so prefer non-weak
+	// symbols.
+	symbols, err := file.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	names := map[uint64]string{}
+	var defaultHandler uint64
+	for _, symbol := range symbols {
+		if elf.ST_TYPE(symbol.Info) != elf.STT_FUNC {
+			continue
+		}
+		addr := symbol.Value &^ 1 // clear the Thumb bit
+		if symbol.Name == "Default_Handler" {
+			defaultHandler = addr
+		}
+		if _, ok := names[addr]; ok && elf.ST_BIND(symbol.Info) == elf.STB_WEAK {
+			continue
+		}
+		names[addr] = symbol.Name
+	}
+
+	var vectors []interruptVector
+	for i := 1; i < len(data)/4; i++ {
+		addr := uint64(file.ByteOrder.Uint32(data[i*4:])) &^ 1
+		if addr == 0 {
+			continue // reserved
+		}
+		vector := interruptVector{
+			Vector:  i,
+			IRQ:     i - 16,
+			Handler: names[addr],
+			Address: addr,
+			Default: addr == defaultHandler,
+		}
+		if i < len(systemExceptions) {
+			vector.Exception = systemExceptions[i]
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+// printInterrupts writes the vector table of the given ELF file as JSON.
+func printInterrupts(w io.Writer, path string) error {
+	vectors, err := readInterrupts(path)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(vectors, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -31,10 +31,12 @@ type Function struct {
 	module    string     // go:wasm-module
 	linkName  string     // go:linkname, go:export, go:interrupt
 	exported  bool       // go:export
+	weak      bool       // go:weak
 	nobounds  bool       // go:nobounds
 	flag      bool       // used by dead code elimination
 	interrupt bool       // go:interrupt
 	inline    InlineType // go:inline
+	placement Placement  // go:hot, go:cold
 }
 
 // Interface type that is at some point used in a type assert (to check whether
@@ -63,6 +65,23 @@ const (
 	InlineNone
 )
 
+// Placement is the section a function is placed in, see
+// Compiler.ApplyFunctionSections.
+type Placement int
+
+const (
+	// Default placement in .text.
+	PlacementDefault Placement = iota
+
+	// Frequently executed code, placed in .text.hot. Signalled using
+	// //go:hot.
+	PlacementHot
+
+	// Rarely executed code, placed in .text.unlikely (the section name used
+	// by GCC and Clang for cold code). Signalled using //go:cold.
+	PlacementCold
+)
+
 // Create and initialize a new *Program from a *ssa.Program.
 func NewProgram(lprogram *loader.Program, mainPath string) *Program {
 	program := lprogram.LoadSSA()
@@ -233,6 +252,10 @@ func (f *Function) parsePragmas() {
 				}
 				f.linkName = parts[1]
 				f.exported = true
+			case "//go:weak":
+				// Only used together with //go:export: the definition may be
+				// overridden by a (strong) definition in C or assembly.
+				f.weak = true
 			case "//go:wasm-module":
 				// Alternative comment for setting the import module.
 				if len(parts) != 2 {
@@ -243,6 +266,10 @@ func (f *Function) parsePragmas() {
 				f.inline = InlineHint
 			case "//go:noinline":
 				f.inline = InlineNone
+			case "//go:hot":
+				f.placement = PlacementHot
+			case "//go:cold":
+				f.placement = PlacementCold
 			case "//go:interrupt":
 				if len(parts) != 2 {
 					continue
@@ -288,6 +315,12 @@ func (f *Function) IsExported() bool {
 	return f.exported || f.CName() != ""
 }
 
+// Return true for exported functions annotated with //go:weak, which can be
+// overridden at link time.
+func (f *Function) IsWeak() bool {
+	return f.weak && f.exported
+}
+
 // Return true for functions annotated with //go:interrupt. The function name is
 // already customized in LinkName() to hook up in the interrupt vector.
 //
@@ -301,6 +334,11 @@ func (f *Function) Inline() InlineType {
 	return f.inline
 }
 
+// Return the placement of this function, as set with //go:hot or //go:cold.
+func (f *Function) Placement() Placement {
+	return f.placement
+}
+
 // Return the module name if not the default.
 func (f *Function) Module() string {
 	return f.module
@@ -37,3 +37,24 @@ func (e *ImportCycleError) Error() string {
 	}
 	return msg.String()
 }
+
+// UnsupportedPackageError is returned when importing a package that can't be
+// used on the current target.
+type UnsupportedPackageError struct {
+	ImportPath      string
+	Reason          string
+	ImportPositions []token.Position
+}
+
+func (e *UnsupportedPackageError) Error() string {
+	var msg strings.Builder
+	if len(e.ImportPositions) != 0 {
+		msg.WriteString(e.ImportPositions[0].String())
+		msg.WriteString(": ")
+	}
+	msg.WriteString("package ")
+	msg.WriteString(e.ImportPath)
+	msg.WriteString(" is not supported ")
+	msg.WriteString(e.Reason)
+	return msg.String()
+}
@@ -0,0 +1,146 @@
+package loader
+
+// This file reads the seed corpus of fuzz targets, so that it can be embedded
+// in the test binary.
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fuzzTarget is a fuzz target (a FuzzXxx function) in the package under test.
+type fuzzTarget struct {
+	Name   string
+	Corpus []corpusEntry
+}
+
+// corpusEntry is a single input from the seed corpus of a fuzz target.
+type corpusEntry struct {
+	Name   string   // file name in testdata/fuzz/FuzzXxx
+	Values []string // Go expressions for the values, like []byte("abc")
+}
+
+// corpusHeader is the first line of every corpus file, as written by the go
+// command.
+const corpusHeader = "go test fuzz v1"
+
+// readFuzzCorpus reads all corpus files of the given fuzz target, which are
+// stored in testdata/fuzz/<name> in the package directory. It is not an error
+// if there is no such directory.
+func readFuzzCorpus(pkgDir, name string) ([]corpusEntry, error) {
+	dir := filepath.Join(pkgDir, "testdata", "fuzz", name)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var corpus []corpusEntry
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		values, err := parseCorpusFile(string(data))
+		if err != nil {
+			return nil, errors.New(path + ": " + err.Error())
+		}
+		corpus = append(corpus, corpusEntry{
+			Name:   file.Name(),
+			Values: values,
+		})
+	}
+	return corpus, nil
+}
+
+// parseCorpusFile parses the contents of a corpus file. Every line after the
+// header contains a single value as a Go conversion of a literal, such as
+// []byte("abc") or int(5). These expressions are returned as-is, after checking
+// that they are in fact conversions of literals, so that they can be inserted
+// in the generated main function of the test binary.
+func parseCorpusFile(data string) ([]string, error) {
+	lines := strings.Split(data, "\n")
+	if strings.TrimSpace(lines[0]) != corpusHeader {
+		return nil, errors.New("unsupported corpus file format: expected \"" + corpusHeader + "\" header")
+	}
+	var values []string
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		expr, err := parser.ParseExpr(line)
+		if err != nil || !isLiteralConversion(expr) {
+			return nil, errors.New("unsupported corpus value: " + line)
+		}
+		values = append(values, line)
+	}
+	return values, nil
+}
+
+// corpusBasicTypes are the basic types that values in a corpus file can be
+// converted to. Any other identifier is not a type, but a function call.
+var corpusBasicTypes = map[string]bool{
+	"string":  true,
+	"bool":    true,
+	"byte":    true,
+	"rune":    true,
+	"int":     true,
+	"int8":    true,
+	"int16":   true,
+	"int32":   true,
+	"int64":   true,
+	"uint":    true,
+	"uint8":   true,
+	"uint16":  true,
+	"uint32":  true,
+	"uint64":  true,
+	"float32": true,
+	"float64": true,
+}
+
+// isLiteralConversion returns whether the given expression is a conversion of a
+// literal to a basic type or []byte, like the values in a corpus file.
+func isLiteralConversion(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		// Conversion to a basic type, like string(...).
+		if !corpusBasicTypes[fn.Name] {
+			return false
+		}
+	case *ast.ArrayType:
+		// Conversion to []byte.
+		elem, ok := fn.Elt.(*ast.Ident)
+		if fn.Len != nil || !ok || elem.Name != "byte" {
+			return false
+		}
+	default:
+		return false
+	}
+	arg := call.Args[0]
+	if unary, ok := arg.(*ast.UnaryExpr); ok && unary.Op == token.SUB {
+		arg = unary.X
+	}
+	switch arg := arg.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.Ident:
+		return arg.Name == "true" || arg.Name == "false"
+	default:
+		return false
+	}
+}
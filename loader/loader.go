@@ -10,6 +10,7 @@ import (
 	"go/types"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"text/template"
@@ -23,14 +24,17 @@ type Program struct {
 	Build        *build.Context
 	OverlayBuild *build.Context
 	OverlayPath  func(path string) string
+	Unsupported  func(path string) string // returns why a package can't be imported, or ""
 	Packages     map[string]*Package
 	sorted       []*Package
 	fset         *token.FileSet
 	TypeChecker  types.Config
 	Dir          string // current working directory (for error reporting)
 	TINYGOROOT   string // root of the TinyGo installation or root of the source code
+	RuntimeDir   string // directory of a replacement runtime package, or "" for the TinyGo runtime
 	CFlags       []string
 	ClangHeaders string
+	TestRun      string // if set, only include tests and fuzz targets that match this regular expression
 }
 
 // Package holds a loaded package, its imports, and its parsed files.
@@ -57,9 +61,24 @@ func (p *Program) Import(path, srcDir string) (*Package, error) {
 		ctx = p.OverlayBuild
 		path = newPath
 	}
-	buildPkg, err := ctx.Import(path, srcDir, build.ImportComment)
-	if err != nil {
-		return nil, err
+	var buildPkg *build.Package
+	var err error
+	if path == "runtime" && p.RuntimeDir != "" {
+		// Use the replacement runtime package, under the same import path so
+		// that the compiler and all other packages find it.
+		buildPkg, err = ctx.ImportDir(p.RuntimeDir, build.ImportComment)
+		if err != nil {
+			return nil, err
+		}
+		if buildPkg.Name != "runtime" {
+			return nil, errors.New("loader: replacement runtime in " + p.RuntimeDir + " is package " + buildPkg.Name + ", not runtime")
+		}
+		buildPkg.ImportPath = "runtime"
+	} else {
+		buildPkg, err = ctx.Import(path, srcDir, build.ImportComment)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if existingPkg, ok := p.Packages[buildPkg.ImportPath]; ok {
 		// Already imported, or at least started the import.
@@ -95,9 +114,12 @@ func (p *Program) ImportFile(path string) (*Package, error) {
 		Dir:        filepath.Dir(path),
 		ImportPath: path,
 		GoFiles:    []string{filepath.Base(path)},
+		ImportPos:  make(map[string][]token.Position),
 	}
 	for _, importSpec := range file.Imports {
-		buildPkg.Imports = append(buildPkg.Imports, importSpec.Path.Value[1:len(importSpec.Path.Value)-1])
+		importPath := importSpec.Path.Value[1 : len(importSpec.Path.Value)-1]
+		buildPkg.Imports = append(buildPkg.Imports, importPath)
+		buildPkg.ImportPos[importPath] = append(buildPkg.ImportPos[importPath], p.fset.Position(importSpec.Pos()))
 	}
 	p.sorted = nil // invalidate the sorted order of packages
 	pkg := p.newPackage(buildPkg)
@@ -229,11 +251,26 @@ func (p *Program) Parse(compileTestBinary bool) error {
 
 func (p *Program) SwapTestMain() error {
 	var tests []string
+	var fuzzTargets []fuzzTarget
 
+	var run *regexp.Regexp
+	if p.TestRun != "" {
+		var err error
+		run, err = regexp.Compile(p.TestRun)
+		if err != nil {
+			return err
+		}
+	}
 	isTestFunc := func(f *ast.FuncDecl) bool {
 		// TODO: improve signature check
 		if strings.HasPrefix(f.Name.Name, "Test") && f.Name.Name != "TestMain" {
-			return true
+			return run == nil || run.MatchString(f.Name.Name)
+		}
+		return false
+	}
+	isFuzzFunc := func(f *ast.FuncDecl) bool {
+		if strings.HasPrefix(f.Name.Name, "Fuzz") && f.Recv == nil {
+			return run == nil || run.MatchString(f.Name.Name)
 		}
 		return false
 	}
@@ -245,6 +282,15 @@ func (p *Program) SwapTestMain() error {
 				if isTestFunc(v) {
 					tests = append(tests, v.Name.Name)
 				}
+				if isFuzzFunc(v) {
+					// The seed corpus is embedded in the test binary, as
+					// there may not be a filesystem to read it from.
+					corpus, err := readFuzzCorpus(mainPkg.Package.Dir, v.Name.Name)
+					if err != nil {
+						return err
+					}
+					fuzzTargets = append(fuzzTargets, fuzzTarget{v.Name.Name, corpus})
+				}
 				if v.Name.Name == "main" {
 					// Remove main
 					if len(f.Decls) == 1 {
@@ -270,6 +316,15 @@ func main () {
 		Tests: []testing.TestToCall{
 {{range .TestFunctions}}
 			{Name: "{{.}}", Func: {{.}}},
+{{end}}
+		},
+		Fuzz: []testing.FuzzTargetToCall{
+{{range .FuzzTargets}}
+			{Name: "{{.Name}}", Func: {{.Name}}, Corpus: []testing.CorpusEntry{
+{{range .Corpus}}
+				{Name: {{printf "%q" .Name}}, Values: []interface{}{ {{range .Values}}{{.}}, {{end}} }},
+{{end}}
+			}},
 {{end}}
 		},
 	}
@@ -281,8 +336,10 @@ func main () {
 	b := bytes.Buffer{}
 	tmplData := struct {
 		TestFunctions []string
+		FuzzTargets   []fuzzTarget
 	}{
 		TestFunctions: tests,
+		FuzzTargets:   fuzzTargets,
 	}
 
 	err := tmpl.Execute(&b, tmplData)
@@ -467,6 +524,11 @@ func (p *Package) importRecursively(includeTests bool) error {
 		if _, ok := p.Imports[to]; ok {
 			continue
 		}
+		if p.Program.Unsupported != nil {
+			if reason := p.Program.Unsupported(to); reason != "" {
+				return &UnsupportedPackageError{to, reason, p.ImportPos[to]}
+			}
+		}
 		importedPkg, err := p.Program.Import(to, p.Package.Dir)
 		if err != nil {
 			if err, ok := err.(*ImportCycleError); ok {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"flag"
 	"fmt"
@@ -11,7 +12,9 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -21,6 +24,7 @@ import (
 	"github.com/tinygo-org/tinygo/goenv"
 	"github.com/tinygo-org/tinygo/interp"
 	"github.com/tinygo-org/tinygo/loader"
+	"github.com/tinygo-org/tinygo/transform"
 
 	serial "go.bug.st/serial.v1"
 )
@@ -51,20 +55,99 @@ type BuildConfig struct {
 	opt           string
 	gc            string
 	panicStrategy string
+	pic           string
+	vectors       string
+	math          string
+	runtime       string // directory of a replacement runtime package, or "" for the TinyGo runtime
 	scheduler     string
 	printIR       bool
 	dumpSSA       bool
 	verifyIR      bool
 	debug         bool
+	trimPath      bool
+	reflectTags   bool
 	printSizes    string
+	printIntr     bool
 	cFlags        []string
 	ldFlags       []string
 	tags          string
 	wasmAbi       string
-	heapSize      int64
+	heapSize      int64 // 0 is the target default, see memorySizeLDFlags
+	cHeapSize     int64 // 0 means malloc uses the garbage collected heap
+	stackSize     int64 // 0 is the target default, see memorySizeLDFlags
+	metadata      map[string]string
+	allocTrace    bool
+	goroutinePool int
+	taskStackSize int64 // 0 is the runtime default, see src/runtime/scheduler_tasks.go
+	preemptLoops  bool
+	emitLLVM      string
+	werror        bool
 	testConfig    compiler.TestConfig
 }
 
+// optimizationLevel is a combination of LLVM optimization settings and
+// optional TinyGo transforms, as passed to Compiler.Optimize.
+type optimizationLevel struct {
+	optLevel         int
+	sizeLevel        int
+	inlinerThreshold uint
+	transforms       compiler.Transforms
+}
+
+// Optional TinyGo transforms (see compiler.Transforms) for each optimization
+// level.
+const (
+	transformsNone      compiler.Transforms = 0
+	transformsSmall                         = compiler.TransformSortSearch | compiler.TransformLikelySwitch
+	transformsSmallSIMD                     = transformsSmall | compiler.TransformVectorize
+	transformsSpeed                         = transformsSmall | compiler.TransformDevirtualize
+	transformsAll                           = transformsSpeed | compiler.TransformVectorize
+)
+
+// optimizationLevels maps the values accepted by the -opt flag to optimization
+// settings. Next to the Clang-like levels (0, 1, 2, s, z), there are a few
+// named presets that are easier to choose between:
+//
+//     minsize   smallest code: -Oz with an inliner threshold of 5 (like
+//               -opt=z), without any of the optional TinyGo transforms
+//     balanced  small code without sacrificing too much performance: -Os with
+//               an inliner threshold of 225 (like -opt=s), with sort.Search
+//               specialization and likely switch cases, but without
+//               devirtualization and loop vectorization
+//     perf      fastest code at the cost of code size: -O3 with an inliner
+//               threshold of 250 (like Clang -O3) and all optional TinyGo
+//               transforms
+//
+// The other TinyGo-specific transforms (such as heap-to-stack and map
+// optimizations) run at every level except 0. The Clang-like levels also run
+// the optional transforms that match them: devirtualization at 1 and 2, and
+// loop vectorization (if the target has SIMD instructions) at 2 and s.
+var optimizationLevels = map[string]optimizationLevel{
+	"none:":    {0, 0, 0, transformsNone},        // -O0
+	"0":        {0, 0, 0, transformsNone},        // -O0
+	"1":        {1, 0, 0, transformsSpeed},       // -O1
+	"2":        {2, 0, 225, transformsAll},       // -O2
+	"s":        {2, 1, 225, transformsSmallSIMD}, // -Os
+	"z":        {2, 2, 5, transformsSmall},       // -Oz, default
+	"minsize":  {2, 2, 5, transformsNone},        // -Oz
+	"balanced": {2, 1, 225, transformsSmall},     // -Os
+	"perf":     {3, 0, 250, transformsAll},       // -O3
+}
+
+// trimPathCFlags returns the Clang flags for -trimpath for a C or assembly file
+// in the directory dir: the directory is replaced with pkgPath in the debug
+// information, like the Go compiler does for Go files (see
+// compiler/trimpath.go). The working directory wd is removed as well, as
+// Clang stores it as the compilation directory. Note that Clang uses the first
+// matching prefix in sorted order, so a file in a subdirectory of wd is stored
+// relative to wd instead: either way, no absolute path remains.
+func trimPathCFlags(wd, dir, pkgPath string) []string {
+	return []string{
+		"-fdebug-prefix-map=" + dir + "=" + pkgPath,
+		"-fdebug-prefix-map=" + wd + "=.",
+	}
+}
+
 // Helper function for Compiler object.
 func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, action func(string) error) error {
 	if config.gc == "" && spec.GC != "" {
@@ -84,6 +167,11 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 	for _, flag := range spec.LDFlags {
 		ldflags = append(ldflags, strings.Replace(flag, "{root}", root, -1))
 	}
+	if spec.StackRegion != "" {
+		// Place the stack at the start of the given memory region instead of
+		// the start of RAM. See targets/arm.ld for details.
+		ldflags = append(ldflags, "--defsym=_stack_region_top=ORIGIN("+spec.StackRegion+")+_stack_size")
+	}
 
 	goroot := goenv.Get("GOROOT")
 	if goroot == "" {
@@ -103,6 +191,106 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 	if extraTags := strings.Fields(config.tags); len(extraTags) != 0 {
 		tags = append(tags, extraTags...)
 	}
+	fpuStackingTag, err := spec.fpuStackingTag()
+	if err != nil {
+		return err
+	}
+	if fpuStackingTag != "" {
+		tags = append(tags, fpuStackingTag)
+	}
+	isCortexM := false
+	isBaremetal := false
+	for _, tag := range tags {
+		switch tag {
+		case "cortexm":
+			isCortexM = true
+		case "baremetal":
+			isBaremetal = true
+		}
+	}
+	if config.panicStrategy == "reset" {
+		// The panic reason is preserved across the reset in the .noinit
+		// section of targets/arm.ld, see src/runtime/panic_reset.go.
+		if !isCortexM {
+			return errors.New("-panic=reset is only supported on Cortex-M targets")
+		}
+		tags = append(tags, "panicreset")
+	}
+	pic := ""
+	pkgCFlags := cflags
+	if config.pic == "ropi" {
+		// Pointers to flash are relocated at startup by the runtime, see
+		// src/runtime/ropi_cortexm.go for details and limitations.
+		if !isCortexM {
+			return errors.New("-pic=ropi is only supported on Cortex-M targets")
+		}
+		pic = "ropi"
+		tags = append(tags, "ropi")
+		// Only C files are compiled with -fropi: Clang warns that the flag
+		// is unused for assembly files, which is an error with -Werror.
+		pkgCFlags = append(append([]string{}, cflags...), "-fropi")
+	}
+	if config.vectors == "ram" || pic == "ropi" {
+		// The vector table is copied to RAM at startup, see
+		// src/runtime/ramvectors_cortexm.go. With -pic=ropi, this is needed
+		// to relocate the handlers.
+		if !isCortexM {
+			return errors.New("-vectors=ram is only supported on Cortex-M targets")
+		}
+		if spec.NoVTOR {
+			// Handlers in the copy would never be called.
+			return errors.New("-vectors=ram and -pic=ropi are not supported on chips without a VTOR register (like the Cortex-M0)")
+		}
+		tags = append(tags, "vectors.ram")
+		ldflags = append(ldflags, "--defsym=_tinygo_ram_vectors=1")
+	}
+	if config.preemptLoops {
+		// The preemption flag is set by the SysTick interrupt, see
+		// src/runtime/preempt.go.
+		if !isCortexM {
+			return errors.New("-preempt-loops is only supported on Cortex-M targets")
+		}
+		tags = append(tags, "preemptloops")
+	}
+	if config.math == "reduced" {
+		// Use smaller, less accurate versions of some math functions, see
+		// src/runtime/math_reduced.go.
+		tags = append(tags, "math.reduced")
+	}
+	if config.cHeapSize != 0 {
+		// Use a separate heap for malloc in C code, see
+		// src/runtime/cmalloc.go.
+		tags = append(tags, "malloc.heap")
+	}
+	if config.stackSize != 0 || config.heapSize != 0 || config.cHeapSize != 0 {
+		// The sizes of the main stack and the heap are set in the linker
+		// script, see targets/arm.ld for details. The heap size of
+		// WebAssembly is the initial memory size instead, set below.
+		sizeFlags, err := memorySizeLDFlags(spec, config, isBaremetal)
+		if err != nil {
+			return err
+		}
+		// The symbols must be defined before the linker script: GNU ld only
+		// sees symbols in DEFINED() that are defined before it.
+		ldflags = append(sizeFlags, ldflags...)
+	}
+	wd := ""
+	if config.trimPath {
+		// The working directory is stored in the debug information of C
+		// files, see trimPathCFlags.
+		wd, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+	}
+	if config.allocTrace {
+		// Allocation sites are stored in the heap metadata of the conservative
+		// GC, see src/runtime/alloctrace.go.
+		if config.gc != "" && config.gc != "conservative" {
+			return errors.New("-alloc-trace requires the conservative GC, not -gc=" + config.gc)
+		}
+		tags = append(tags, "alloctrace")
+	}
 	scheduler := spec.Scheduler
 	if config.scheduler != "" {
 		scheduler = config.scheduler
@@ -115,11 +303,14 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 		GOARCH:        spec.GOARCH,
 		GC:            config.gc,
 		PanicStrategy: config.panicStrategy,
+		PIC:           pic,
 		Scheduler:     scheduler,
 		CFlags:        cflags,
 		LDFlags:       ldflags,
 		ClangHeaders:  getClangHeaderPath(root),
 		Debug:         config.debug,
+		TrimPath:      config.trimPath,
+		ReflectTags:   config.reflectTags,
 		DumpSSA:       config.dumpSSA,
 		VerifyIR:      config.verifyIR,
 		TINYGOROOT:    root,
@@ -127,6 +318,10 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 		GOPATH:        goenv.Get("GOPATH"),
 		BuildTags:     tags,
 		TestConfig:    config.testConfig,
+		GoroutinePool: config.goroutinePool,
+		TaskStackSize: int(config.taskStackSize),
+		PreemptLoops:  config.preemptLoops,
+		RuntimePath:   config.runtime,
 	}
 	c, err := compiler.NewCompiler(pkgName, compilerConfig)
 	if err != nil {
@@ -149,6 +344,9 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 		return errors.New("verification error after IR construction")
 	}
 
+	// Don't call (or interpret) package initializers that don't do anything.
+	transform.RemoveEmptyInits(c.Module())
+
 	err = interp.Run(c.Module(), config.dumpSSA)
 	if err != nil {
 		return err
@@ -157,6 +355,21 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 		return errors.New("verification error after interpreting runtime.initAll")
 	}
 
+	// Interrupt handlers must not block, as they don't run in a goroutine.
+	// This must be checked before goroutine lowering changes all blocking
+	// calls.
+	if warnings := transform.CheckInterruptHandlers(c.Module()); len(warnings) != 0 {
+		if config.werror {
+			if len(warnings) == 1 {
+				return warnings[0]
+			}
+			return &multiError{warnings}
+		}
+		for _, warning := range warnings {
+			fmt.Fprintln(os.Stderr, "warning:", warning)
+		}
+	}
+
 	if spec.GOOS != "darwin" {
 		c.ApplyFunctionSections() // -ffunction-sections
 	}
@@ -175,20 +388,11 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 
 	// Optimization levels here are roughly the same as Clang, but probably not
 	// exactly.
-	switch config.opt {
-	case "none:", "0":
-		err = c.Optimize(0, 0, 0) // -O0
-	case "1":
-		err = c.Optimize(1, 0, 0) // -O1
-	case "2":
-		err = c.Optimize(2, 0, 225) // -O2
-	case "s":
-		err = c.Optimize(2, 1, 225) // -Os
-	case "z":
-		err = c.Optimize(2, 2, 5) // -Oz, default
-	default:
-		err = errors.New("unknown optimization level: -opt=" + config.opt)
+	level, ok := optimizationLevels[config.opt]
+	if !ok {
+		return errors.New("unknown optimization level: -opt=" + config.opt)
 	}
+	err = c.Optimize(level.optLevel, level.sizeLevel, level.inlinerThreshold, level.transforms)
 	if err != nil {
 		return err
 	}
@@ -208,6 +412,25 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 		}
 	}
 
+	// Store build information in the firmware metadata section, if requested.
+	if config.metadata != nil {
+		data, err := encodeMetadata(buildMetadata(spec, config.metadata))
+		if err != nil {
+			return err
+		}
+		c.AddSection(metadataSection, "tinygo_metadata", data)
+	}
+
+	// Write the final bitcode for external tools, if requested. This is exactly
+	// the module that is passed to code generation below. Note that bitcode
+	// files can only be read by the same LLVM version TinyGo uses, or a newer
+	// version.
+	if config.emitLLVM != "" {
+		if err := c.EmitBitcode(config.emitLLVM); err != nil {
+			return err
+		}
+	}
+
 	// Generate output.
 	outext := filepath.Ext(outpath)
 	switch outext {
@@ -254,7 +477,11 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 		if spec.GOARCH == "wasm" {
 			// Round heap size to next multiple of 65536 (the WebAssembly page
 			// size).
-			heapSize := (config.heapSize + (65536 - 1)) &^ (65536 - 1)
+			heapSize := config.heapSize
+			if heapSize == 0 {
+				heapSize = defaultWasmHeapSize
+			}
+			heapSize = (heapSize + (65536 - 1)) &^ (65536 - 1)
 			ldflags = append(ldflags, "--initial-memory="+strconv.FormatInt(heapSize, 10))
 		}
 
@@ -266,7 +493,14 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 			if names, ok := commands[spec.Compiler]; ok {
 				cmdNames = names
 			}
-			err := execCommand(cmdNames, append(cflags, "-c", "-o", outpath, abspath)...)
+			fileCFlags := cflags
+			if config.trimPath {
+				// Extra files are in $TINYGOROOT/src, like the packages
+				// that are overlaid by TinyGo.
+				pkgPath := strings.TrimPrefix(filepath.ToSlash(filepath.Dir(path)), "src/")
+				fileCFlags = append(trimPathCFlags(wd, filepath.Dir(abspath), pkgPath), cflags...)
+			}
+			err := execCommand(cmdNames, append(fileCFlags, "-c", "-o", outpath, abspath)...)
 			if err != nil {
 				return &commandError{"failed to build", path, err}
 			}
@@ -282,7 +516,12 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 				if names, ok := commands[spec.Compiler]; ok {
 					cmdNames = names
 				}
-				err := execCommand(cmdNames, append(cflags, "-c", "-o", outpath, path)...)
+				fileCFlags := pkgCFlags
+				if config.trimPath {
+					pkgPath := compiler.TrimmedPackagePath(pkg.ImportPath, pkg.Package.Dir, wd)
+					fileCFlags = append(trimPathCFlags(wd, pkg.Package.Dir, pkgPath), pkgCFlags...)
+				}
+				err := execCommand(cmdNames, append(fileCFlags, "-c", "-o", outpath, path)...)
 				if err != nil {
 					return &commandError{"failed to build", path, err}
 				}
@@ -315,6 +554,13 @@ func Compile(pkgName, outpath string, spec *TargetSpec, config *BuildConfig, act
 			}
 		}
 
+		if config.printIntr {
+			err := printInterrupts(os.Stdout, executable)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Get an Intel .hex file or .bin file from the .elf file.
 		if outext == ".hex" || outext == ".bin" || outext == ".gba" {
 			tmppath = filepath.Join(dir, "main"+outext)
@@ -377,6 +623,27 @@ func Test(pkgName, target string, config *BuildConfig) error {
 	spec.BuildTags = append(spec.BuildTags, "test")
 	config.testConfig.CompileTestBinary = true
 	return Compile(pkgName, ".elf", spec, config, func(tmppath string) error {
+		if len(spec.Emulator) != 0 {
+			// Run in an emulator. The exit code of QEMU doesn't say whether
+			// the tests passed, so look at the output instead.
+			args := append(spec.Emulator[1:], tmppath)
+			cmd := exec.Command(spec.Emulator[0], args...)
+			stdout := &bytes.Buffer{}
+			cmd.Stdout = io.MultiWriter(os.Stdout, stdout)
+			cmd.Stderr = os.Stderr
+			err := cmd.Run()
+			if err != nil {
+				if _, ok := err.(*exec.ExitError); !ok {
+					return &commandError{"failed to run emulator with", tmppath, err}
+				}
+			}
+			output := strings.Replace(stdout.String(), "\r\n", "\n", -1)
+			if strings.HasSuffix(output, "\nFAIL\n") {
+				os.Exit(1)
+			}
+			return nil
+		}
+
 		cmd := exec.Command(tmppath)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
@@ -682,6 +949,50 @@ func flashHexUsingMSD(volume, tmppath string) error {
 	return moveFile(tmppath, d[0]+"/flash.hex")
 }
 
+// defaultWasmHeapSize is the initial memory size of WebAssembly when
+// -heap-size is not set.
+const defaultWasmHeapSize = 1 << 20
+
+// memorySizeLDFlags returns the linker flags that set the size of the main
+// stack (-stack-size), the heap (-heap-size) and the C heap (-c-heap-size) on
+// baremetal targets. The linker scripts use these sizes instead of their
+// defaults when the _tinygo_stack_size, _tinygo_heap_size and
+// _tinygo_c_heap_size symbols are defined, see targets/arm.ld. WebAssembly
+// only supports setting the heap size.
+func memorySizeLDFlags(spec *TargetSpec, config *BuildConfig, isBaremetal bool) ([]string, error) {
+	if spec.GOARCH == "wasm" {
+		if config.stackSize != 0 {
+			return nil, errors.New("-stack-size is not supported on WebAssembly")
+		}
+		if config.cHeapSize != 0 {
+			return nil, errors.New("-c-heap-size is not supported on WebAssembly")
+		}
+		return nil, nil
+	}
+	if !isBaremetal {
+		return nil, errors.New("-stack-size, -heap-size and -c-heap-size are only supported on baremetal targets and WebAssembly")
+	}
+	if config.stackSize < 0 || config.heapSize < 0 || config.cHeapSize < 0 {
+		return nil, errors.New("-stack-size, -heap-size and -c-heap-size must not be negative")
+	}
+	prefix := ""
+	if strings.HasSuffix(spec.Linker, "gcc") {
+		// The linker is invoked through the GCC driver (for example on AVR).
+		prefix = "-Wl,"
+	}
+	var ldflags []string
+	if config.stackSize != 0 {
+		ldflags = append(ldflags, prefix+"--defsym=_tinygo_stack_size="+strconv.FormatInt(config.stackSize, 10))
+	}
+	if config.heapSize != 0 {
+		ldflags = append(ldflags, prefix+"--defsym=_tinygo_heap_size="+strconv.FormatInt(config.heapSize, 10))
+	}
+	if config.cHeapSize != 0 {
+		ldflags = append(ldflags, prefix+"--defsym=_tinygo_c_heap_size="+strconv.FormatInt(config.cHeapSize, 10))
+	}
+	return ldflags, nil
+}
+
 // parseSize converts a human-readable size (with k/m/g suffix) into a plain
 // number.
 func parseSize(s string) (int64, error) {
@@ -717,6 +1028,7 @@ func usage() {
 	fmt.Fprintln(os.Stderr, "  flash: compile and flash to the device")
 	fmt.Fprintln(os.Stderr, "  gdb:   run/flash and immediately enter GDB")
 	fmt.Fprintln(os.Stderr, "  env:   list environment variables used during build")
+	fmt.Fprintln(os.Stderr, "  meta:  print the build metadata stored in a firmware image")
 	fmt.Fprintln(os.Stderr, "  clean: empty cache directory ("+goenv.Get("GOCACHE")+")")
 	fmt.Fprintln(os.Stderr, "  help:  print this help text")
 	fmt.Fprintln(os.Stderr, "\nflags:")
@@ -751,9 +1063,13 @@ func handleCompilerError(err error) {
 
 func main() {
 	outpath := flag.String("o", "", "output filename")
-	opt := flag.String("opt", "z", "optimization level: 0, 1, 2, s, z")
+	opt := flag.String("opt", "z", "optimization level: 0, 1, 2, s, z, or a preset: minsize, balanced, perf")
 	gc := flag.String("gc", "", "garbage collector to use (none, leaking, conservative)")
-	panicStrategy := flag.String("panic", "print", "panic strategy (print, trap)")
+	panicStrategy := flag.String("panic", "print", "panic strategy (print, trap, reset)")
+	pic := flag.String("pic", "none", "position-independent code, to run the same image from different flash addresses (Cortex-M only): none, ropi")
+	vectors := flag.String("vectors", "flash", "location of the interrupt vector table (Cortex-M only): flash, or ram to allow replacing handlers at runtime")
+	math := flag.String("math", "full", "math library: full, or reduced for smaller but less accurate versions of math.Sin, math.Cos and math.Exp")
+	runtimePath := flag.String("runtime", "", "directory of a replacement for the runtime package, for example with a different allocator (see compiler/runtime.go for the required symbols)")
 	scheduler := flag.String("scheduler", "", "which scheduler to use (coroutines, tasks)")
 	printIR := flag.Bool("printir", false, "print LLVM IR")
 	dumpSSA := flag.Bool("dumpssa", false, "dump internal Go SSA")
@@ -761,13 +1077,26 @@ func main() {
 	tags := flag.String("tags", "", "a space-separated list of extra build tags")
 	target := flag.String("target", "", "LLVM target | .json file with TargetSpec")
 	printSize := flag.String("size", "", "print sizes (none, short, full)")
+	printIntr := flag.Bool("print-interrupts", false, "print the interrupt vector table (Cortex-M only) as JSON")
 	nodebug := flag.Bool("no-debug", false, "disable DWARF debug symbol generation")
+	trimPath := flag.Bool("trimpath", false, "remove file system paths from DWARF debug symbols, like go build -trimpath")
+	reflectTags := flag.Bool("reflect-tags", false, "store struct tags in the binary for reflect.StructField.Tag, which is empty otherwise (costs flash: about the length of each distinct tag)")
 	ocdOutput := flag.Bool("ocd-output", false, "print OCD daemon output during debug")
 	port := flag.String("port", "/dev/ttyACM0", "flash port")
 	cFlags := flag.String("cflags", "", "additional cflags for compiler")
 	ldFlags := flag.String("ldflags", "", "additional ldflags for linker")
 	wasmAbi := flag.String("wasm-abi", "js", "WebAssembly ABI conventions: js (no i64 params) or generic")
-	heapSize := flag.String("heap-size", "1M", "default heap size in bytes (only supported by WebAssembly)")
+	heapSize := flag.String("heap-size", "", "heap size in bytes (default: the rest of RAM on baremetal targets, 1M on WebAssembly)")
+	stackSize := flag.String("stack-size", "", "size of the main stack in bytes on baremetal targets (default: set by the target linker script, usually 2K or 4K)")
+	cHeapSize := flag.String("c-heap-size", "", "size in bytes of a separate heap for malloc in C code, taken from the end of the heap on baremetal targets (default: malloc uses the garbage collected heap)")
+	allocTrace := flag.Bool("alloc-trace", false, "record the allocation site of heap objects, for runtime.DumpAllocs")
+	goroutinePool := flag.Int("goroutine-pool", 0, "preallocate this many goroutine stacks (including main) instead of allocating them on the heap (only supported by the tasks scheduler)")
+	taskStackSize := flag.String("goroutine-stack-size", "", "size in bytes of each goroutine stack, a multiple of 8 of at least 256 (default: 1K, only supported by the tasks scheduler)")
+	preemptLoops := flag.Bool("preempt-loops", false, "yield to other goroutines at loop back-edges after a timer interrupt, to prevent goroutines in long loops from starving others (Cortex-M with the tasks scheduler only)")
+	emitLLVM := flag.String("emit-llvm", "", "also write the optimized LLVM bitcode to this file (readable by the same or a newer LLVM version)")
+	metadata := flag.String("metadata", "", "store build metadata in the "+metadataSection+" section, with extra comma-separated key=value pairs")
+	werror := flag.Bool("werror", false, "treat warnings (such as blocking calls in interrupt handlers) as errors")
+	run := flag.String("run", "", "test: only run tests and fuzz targets matching this regular expression")
 
 	if len(os.Args) < 2 {
 		fmt.Fprintln(os.Stderr, "No command-line arguments supplied.")
@@ -781,14 +1110,28 @@ func main() {
 		opt:           *opt,
 		gc:            *gc,
 		panicStrategy: *panicStrategy,
+		pic:           *pic,
+		vectors:       *vectors,
+		math:          *math,
 		scheduler:     *scheduler,
 		printIR:       *printIR,
 		dumpSSA:       *dumpSSA,
 		verifyIR:      *verifyIR,
 		debug:         !*nodebug,
+		trimPath:      *trimPath,
+		reflectTags:   *reflectTags,
 		printSizes:    *printSize,
+		printIntr:     *printIntr,
 		tags:          *tags,
 		wasmAbi:       *wasmAbi,
+		allocTrace:    *allocTrace,
+		goroutinePool: *goroutinePool,
+		preemptLoops:  *preemptLoops,
+		emitLLVM:      *emitLLVM,
+		werror:        *werror,
+		testConfig: compiler.TestConfig{
+			RunRegexp: *run,
+		},
 	}
 
 	if *cFlags != "" {
@@ -799,19 +1142,92 @@ func main() {
 		config.ldFlags = strings.Split(*ldFlags, " ")
 	}
 
-	if *panicStrategy != "print" && *panicStrategy != "trap" {
-		fmt.Fprintln(os.Stderr, "Panic strategy must be either print or trap.")
+	if *panicStrategy != "print" && *panicStrategy != "trap" && *panicStrategy != "reset" {
+		fmt.Fprintln(os.Stderr, "Panic strategy must be print, trap, or reset.")
 		usage()
 		os.Exit(1)
 	}
 
-	var err error
-	if config.heapSize, err = parseSize(*heapSize); err != nil {
-		fmt.Fprintln(os.Stderr, "Could not read heap size:", *heapSize)
+	if *pic != "none" && *pic != "ropi" {
+		// RWPI (position-independent RAM) is not supported, see
+		// src/runtime/ropi_cortexm.go.
+		fmt.Fprintln(os.Stderr, "Position-independent code must be none or ropi.")
 		usage()
 		os.Exit(1)
 	}
 
+	if *vectors != "flash" && *vectors != "ram" {
+		fmt.Fprintln(os.Stderr, "Vector table location must be flash or ram.")
+		usage()
+		os.Exit(1)
+	}
+
+	if *math != "full" && *math != "reduced" {
+		fmt.Fprintln(os.Stderr, "Math library must be full or reduced.")
+		usage()
+		os.Exit(1)
+	}
+
+	if *runtimePath != "" {
+		// Use an absolute path, which is also more useful in errors.
+		path, err := filepath.Abs(*runtimePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -runtime directory:", err)
+			usage()
+			os.Exit(1)
+		}
+		config.runtime = path
+	}
+
+	if _, err := regexp.Compile(*run); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid -run regular expression:", err)
+		usage()
+		os.Exit(1)
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "metadata" {
+			return
+		}
+		values, err := parseMetadataFlag(*metadata)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			usage()
+			os.Exit(1)
+		}
+		config.metadata = values
+	})
+
+	var err error
+	if *heapSize != "" {
+		if config.heapSize, err = parseSize(*heapSize); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not read heap size:", *heapSize)
+			usage()
+			os.Exit(1)
+		}
+	}
+	if *stackSize != "" {
+		if config.stackSize, err = parseSize(*stackSize); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not read stack size:", *stackSize)
+			usage()
+			os.Exit(1)
+		}
+	}
+	if *cHeapSize != "" {
+		if config.cHeapSize, err = parseSize(*cHeapSize); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not read C heap size:", *cHeapSize)
+			usage()
+			os.Exit(1)
+		}
+	}
+	if *taskStackSize != "" {
+		if config.taskStackSize, err = parseSize(*taskStackSize); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not read goroutine stack size:", *taskStackSize)
+			usage()
+			os.Exit(1)
+		}
+	}
+
 	os.Setenv("CC", "clang -target="+*target)
 
 	switch command {
@@ -886,6 +1302,22 @@ func main() {
 		}
 		err := Test(pkgName, *target, config)
 		handleCompilerError(err)
+	case "meta":
+		if flag.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "No firmware file specified.")
+			usage()
+			os.Exit(1)
+		}
+		metadata, err := readMetadata(flag.Arg(0))
+		handleCompilerError(err)
+		keys := make([]string, 0, len(metadata))
+		for key := range metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("%s=%s\n", key, metadata[key])
+		}
 	case "clean":
 		// remove cache directory
 		err := os.RemoveAll(goenv.Get("GOCACHE"))
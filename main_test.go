@@ -1,19 +1,31 @@
 package main
 
 // This file tests the compiler by running Go files in testdata/*.go and
-// comparing their output with the expected output in testdata/*.txt.
+// comparing their output with the expected output in testdata/*.txt. The
+// programs in testdata/special need special flags or targets, and are only
+// built by the tests for those flags.
 
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/tinygo-org/tinygo/compiler"
+	"github.com/tinygo-org/tinygo/goenv"
 	"github.com/tinygo-org/tinygo/loader"
 )
 
@@ -104,14 +116,6 @@ func runTest(path, tmpdir string, target string, t *testing.T) {
 	if path[len(path)-1] == os.PathSeparator {
 		txtpath = path + "out.txt"
 	}
-	f, err := os.Open(txtpath)
-	if err != nil {
-		t.Fatal("could not open expected output file:", err)
-	}
-	expected, err := ioutil.ReadAll(f)
-	if err != nil {
-		t.Fatal("could not read expected output file:", err)
-	}
 
 	// Build the test binary.
 	config := &BuildConfig{
@@ -122,7 +126,37 @@ func runTest(path, tmpdir string, target string, t *testing.T) {
 		debug:      false,
 		printSizes: "",
 		wasmAbi:    "js",
+		// Struct tags are tested in testdata/reflect.go.
+		reflectTags: true,
+	}
+	buildAndRunTest(path, txtpath, tmpdir, target, config, t)
+}
+
+// runTestWithConfig is like runTest, but builds the program in path with the
+// given configuration and compares its output with the expected output in
+// txtpath. Tests that need special flags use it with a program in
+// testdata/special.
+func runTestWithConfig(path, txtpath, target string, config *BuildConfig, t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tinygo-test")
+	if err != nil {
+		t.Fatal("could not create temporary directory:", err)
 	}
+	defer os.RemoveAll(tmpdir)
+
+	buildAndRunTest(path, txtpath, tmpdir, target, config, t)
+}
+
+func buildAndRunTest(path, txtpath, tmpdir, target string, config *BuildConfig, t *testing.T) {
+	f, err := os.Open(txtpath)
+	if err != nil {
+		t.Fatal("could not open expected output file:", err)
+	}
+	expected, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal("could not read expected output file:", err)
+	}
+
 	binary := filepath.Join(tmpdir, "test")
 	err = Build("./"+path, binary, target, config)
 	if err != nil {
@@ -137,10 +171,13 @@ func runTest(path, tmpdir string, target string, t *testing.T) {
 		return
 	}
 
-	// Run the test.
+	// Run the test. Kill it if it doesn't exit, for example when a program
+	// that resets the chip loses its state and would reset forever.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
 	var cmd *exec.Cmd
 	if target == "" {
-		cmd = exec.Command(binary)
+		cmd = exec.CommandContext(ctx, binary)
 	} else {
 		spec, err := LoadTarget(target)
 		if err != nil {
@@ -150,7 +187,7 @@ func runTest(path, tmpdir string, target string, t *testing.T) {
 			t.Fatal("no emulator available for target:", target)
 		}
 		args := append(spec.Emulator[1:], binary)
-		cmd = exec.Command(spec.Emulator[0], args...)
+		cmd = exec.CommandContext(ctx, spec.Emulator[0], args...)
 	}
 	stdout := &bytes.Buffer{}
 	cmd.Stdout = stdout
@@ -158,7 +195,7 @@ func runTest(path, tmpdir string, target string, t *testing.T) {
 		cmd.Stderr = os.Stderr
 	}
 	err = cmd.Run()
-	if _, ok := err.(*exec.ExitError); ok && target != "" {
+	if _, ok := err.(*exec.ExitError); ok && target != "" && ctx.Err() == nil {
 		err = nil // workaround for QEMU
 	}
 
@@ -188,3 +225,1152 @@ func runTest(path, tmpdir string, target string, t *testing.T) {
 		t.Fail()
 	}
 }
+
+// buildTest builds the program in path for the given target in a temporary
+// directory and returns the contents of the output file. Like with the -o
+// flag, the extension selects the output format, for example ".ll" for the
+// optimized IR or "" for an executable.
+func buildTest(path, target, extension string, config *BuildConfig) ([]byte, error) {
+	tmpdir, err := ioutil.TempDir("", "tinygo-test")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	output := filepath.Join(tmpdir, "test"+extension)
+	err = Build("./"+path, output, target, config)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(output)
+}
+
+func TestGoroutinePool(t *testing.T) {
+	// The coroutine scheduler (used on the host) allocates coroutine frames on
+	// the heap, so it can't use a goroutine pool or a fixed stack size.
+	for _, tc := range []struct {
+		goroutinePool int
+		taskStackSize int64
+		scheduler     string
+		expected      string
+	}{
+		{4, 0, "coroutines", "a goroutine pool is only supported by the tasks scheduler"},
+		{0, 2048, "coroutines", "a goroutine stack size is only supported by the tasks scheduler"},
+		{-1, 0, "tasks", "the goroutine pool size must not be negative"},
+		{0, 2044, "tasks", "the goroutine stack size must be a multiple of 8 bytes, of at least 256 bytes"},
+		{0, 8, "tasks", "the goroutine stack size must be a multiple of 8 bytes, of at least 256 bytes"},
+		{0, -8, "tasks", "the goroutine stack size must be a multiple of 8 bytes, of at least 256 bytes"},
+	} {
+		config := &BuildConfig{
+			opt:           "z",
+			scheduler:     tc.scheduler,
+			goroutinePool: tc.goroutinePool,
+			taskStackSize: tc.taskStackSize,
+			wasmAbi:       "js",
+		}
+		_, err := buildTest("testdata/coroutines.go", "qemu", "", config)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("expected build error %q, got: %v", tc.expected, err)
+		}
+	}
+
+	if testing.Short() {
+		return // the rest requires QEMU
+	}
+
+	// With a pool of three stacks, the main goroutine and two other
+	// goroutines can run at the same time. Goroutines that have exited
+	// return their stack to the pool.
+	config := &BuildConfig{
+		opt:           "z",
+		goroutinePool: 3,
+		taskStackSize: 2048,
+		wasmAbi:       "js",
+	}
+	runTestWithConfig("testdata/special/goroutinepool.go", "testdata/special/goroutinepool.txt", "qemu", config, t)
+}
+
+func TestPinSetFast(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires a full cross compiling toolchain")
+	}
+
+	// buildPulse returns the instructions of the pulse function, which only
+	// sets and clears pins and is exported so that it is not inlined into
+	// main.
+	buildPulse := func(t *testing.T, path, target string) []string {
+		config := &BuildConfig{
+			opt:     "z",
+			wasmAbi: "js",
+		}
+		ir, err := buildTest(path, target, ".ll", config)
+		if err != nil {
+			t.Fatal("failed to build:", err)
+		}
+		var body []string
+		inFunction := false
+		for _, line := range strings.Split(string(ir), "\n") {
+			switch {
+			case strings.HasPrefix(line, "define ") && strings.Contains(line, "@pulse("):
+				inFunction = true
+			case inFunction && line == "}":
+				inFunction = false
+			case inFunction && strings.HasPrefix(line, "  "):
+				body = append(body, strings.TrimSpace(line))
+			}
+		}
+		return body
+	}
+
+	// The function body must consist of exactly one volatile store (to the
+	// set or clear register) for each call and a return: no branches, bounds
+	// checks or nil checks.
+	for _, target := range []string{"itsybitsy-m0", "itsybitsy-m4", "pca10040", "bluepill", "stm32f4disco"} {
+		t.Run(target, func(t *testing.T) {
+			body := buildPulse(t, "testdata/special/pinsetfast.go", target)
+			if len(body) != 3 || !strings.HasPrefix(body[0], "store volatile ") || !strings.HasPrefix(body[1], "store volatile ") || body[2] != "ret void" {
+				t.Errorf("expected two volatile stores, got:\n%s", strings.Join(body, "\n"))
+			}
+		})
+	}
+
+	// The nRF52840 has two GPIO ports, which is checked in every call. For a
+	// constant pin, the check must be optimized away for both ports, and the
+	// pins on different ports must use the registers of their port.
+	t.Run("pca10056", func(t *testing.T) {
+		body := buildPulse(t, "testdata/special/pinsetfastports.go", "pca10056")
+		registers := map[string]bool{}
+		for _, line := range body {
+			if strings.HasPrefix(line, "store volatile ") {
+				// Remember the address operand of the store.
+				registers[line[strings.Index(line, ", ")+2:]] = true
+			}
+		}
+		if len(body) != 5 || len(registers) != 4 || body[4] != "ret void" {
+			t.Errorf("expected four volatile stores to different registers, got:\n%s", strings.Join(body, "\n"))
+		}
+	})
+}
+
+func TestUnsupportedPackage(t *testing.T) {
+	for target, expected := range map[string]string{
+		"qemu": "main.go:3:8: package net/http is not supported on microcontrollers: there is no network stack (use a network driver from tinygo.org/x/drivers, such as wifinina or espat)",
+		"wasm": "main.go:3:8: package net/http is not supported on WebAssembly: there is no network stack (use a network driver from tinygo.org/x/drivers, such as wifinina or espat)",
+	} {
+		config := &BuildConfig{
+			opt:     "z",
+			wasmAbi: "js",
+		}
+		_, err := buildTest("testdata/special/nethttp/main.go", target, "", config)
+		if _, ok := err.(*loader.UnsupportedPackageError); !ok {
+			t.Errorf("%s: expected an unsupported package error, got: %v", target, err)
+			continue
+		}
+		if msg := err.Error(); !strings.HasSuffix(msg, string(filepath.Separator)+expected) {
+			t.Errorf("%s: unexpected error message: %s", target, msg)
+		}
+	}
+}
+
+func TestPanicReset(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires QEMU")
+	}
+
+	// The program panics on the first boot. QEMU then resets the emulated chip
+	// without clearing RAM, like a real chip would, so that the panic reason
+	// is visible after the reset.
+	config := &BuildConfig{
+		opt:           "z",
+		panicStrategy: "reset",
+		wasmAbi:       "js",
+	}
+	runTestWithConfig("testdata/special/panicreset.go", "testdata/special/panicreset.txt", "qemu", config, t)
+}
+
+func TestPanicResetUnsupported(t *testing.T) {
+	config := &BuildConfig{
+		opt:           "z",
+		panicStrategy: "reset",
+		wasmAbi:       "js",
+	}
+	_, err := buildTest("testdata/alias.go", "wasm", "", config)
+	if err == nil || err.Error() != "-panic=reset is only supported on Cortex-M targets" {
+		t.Errorf("expected an error for -panic=reset on WebAssembly, got: %v", err)
+	}
+}
+
+func TestRetained(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires QEMU")
+	}
+
+	// The program counts how often it has booted in a retained global and
+	// resets until it has booted three times. QEMU does not clear RAM on a
+	// reset, like a real chip. The magic number is needed as the counter is
+	// not initialized at power-on.
+	config := &BuildConfig{
+		opt:     "z",
+		wasmAbi: "js",
+	}
+	runTestWithConfig("testdata/special/retained.go", "testdata/special/retained.txt", "qemu", config, t)
+}
+
+func TestMemorySizes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires a full cross compiling toolchain")
+	}
+
+	// The stack and heap are placed in RAM, which starts at 0x20000000 and is
+	// 64K in size on this target (see targets/lm3s6965.ld).
+	config := &BuildConfig{
+		opt:       "z",
+		wasmAbi:   "js",
+		stackSize: 8 * 1024,
+		heapSize:  16 * 1024,
+	}
+	binary, err := buildTest("testdata/alias.go", "qemu", ".elf", config)
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(binary))
+	if err != nil {
+		t.Fatal("could not open binary:", err)
+	}
+	symbols, err := f.Symbols()
+	if err != nil {
+		t.Fatal("could not read symbols:", err)
+	}
+	values := map[string]uint64{}
+	for _, symbol := range symbols {
+		values[symbol.Name] = symbol.Value
+	}
+	if values["_stack_top"] != 0x20000000+8*1024 {
+		t.Errorf("unexpected stack top: %#x", values["_stack_top"])
+	}
+	if values["_heap_end"]-values["_heap_start"] != 16*1024 {
+		t.Errorf("unexpected heap size: %#x..%#x", values["_heap_start"], values["_heap_end"])
+	}
+
+	// A stack or heap that doesn't fit in RAM is a link error.
+	for _, config := range []*BuildConfig{
+		{opt: "z", wasmAbi: "js", stackSize: 64 * 1024},
+		{opt: "z", wasmAbi: "js", heapSize: 64 * 1024},
+	} {
+		_, err = buildTest("testdata/alias.go", "qemu", ".elf", config)
+		if err == nil {
+			t.Errorf("expected a link error for -stack-size=%d -heap-size=%d", config.stackSize, config.heapSize)
+		}
+	}
+}
+
+func TestMemorySizesUnsupported(t *testing.T) {
+	config := &BuildConfig{
+		opt:       "z",
+		stackSize: 8 * 1024,
+		wasmAbi:   "js",
+	}
+	_, err := buildTest("testdata/alias.go", "wasm", "", config)
+	if err == nil || err.Error() != "-stack-size is not supported on WebAssembly" {
+		t.Errorf("expected an error for -stack-size on WebAssembly, got: %v", err)
+	}
+}
+
+func TestPreemptLoops(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires QEMU")
+	}
+
+	// Both goroutines wait in a loop without any blocking operation for the
+	// other goroutine, so each of them only gets to run when the other one is
+	// preempted.
+	config := &BuildConfig{
+		opt:          "z",
+		preemptLoops: true,
+		wasmAbi:      "js",
+	}
+	runTestWithConfig("testdata/special/preemptloops.go", "testdata/special/preemptloops.txt", "qemu", config, t)
+}
+
+func TestPreemptLoopsUnsupported(t *testing.T) {
+	config := &BuildConfig{
+		opt:          "z",
+		preemptLoops: true,
+		wasmAbi:      "js",
+	}
+	_, err := buildTest("testdata/alias.go", "wasm", "", config)
+	if err == nil || err.Error() != "-preempt-loops is only supported on Cortex-M targets" {
+		t.Errorf("expected an error for -preempt-loops on WebAssembly, got: %v", err)
+	}
+}
+
+func TestCMalloc(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires QEMU")
+	}
+
+	// These programs test the default malloc (which uses the garbage collected
+	// heap), the separate C heap and a malloc defined in C, which overrides the
+	// one in the runtime.
+	for _, tc := range []struct {
+		name      string
+		cHeapSize int64
+	}{
+		{"gc", 0},
+		{"cheap", 1024},
+		{"override", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &BuildConfig{
+				opt:       "z",
+				cHeapSize: tc.cHeapSize,
+				wasmAbi:   "js",
+			}
+			path := filepath.Join("testdata", "special", "cmalloc-"+tc.name) + string(filepath.Separator)
+			runTestWithConfig(path, path+"out.txt", "qemu", config, t)
+		})
+	}
+}
+
+func TestErrorf(t *testing.T) {
+	_, minor, err := getGorootVersion(goenv.Get("GOROOT"))
+	if err != nil {
+		t.Fatal("could not read Go version:", err)
+	}
+	if minor < 13 {
+		t.Skip("errors.Is and the %w verb require Go 1.13")
+	}
+
+	// The first two calls to fmt.Errorf only wrap an error and are replaced
+	// by the compiler (see compiler/errorf.go). The third one needs formatting
+	// and the last one doesn't wrap an error, so these must be left alone.
+	config := &BuildConfig{
+		opt:     "z",
+		wasmAbi: "js",
+	}
+	ir, err := buildTest("testdata/special/errorf.go", "", ".ll", config)
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+	calls := 0
+	for _, line := range strings.Split(string(ir), "\n") {
+		if strings.Contains(line, " call ") && strings.Contains(line, "@fmt.Errorf(") {
+			calls++
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls to fmt.Errorf, got %d", calls)
+	}
+
+	runTestWithConfig("testdata/special/errorf.go", "testdata/special/errorf.txt", "", config, t)
+}
+
+func TestSortSearch(t *testing.T) {
+	// Both predicates in testdata/sortsearch.go are simple, so both calls to
+	// sort.Search are replaced by transform.SpecializeSortSearch, with the
+	// predicate inlined. Build with debug information (the default of the
+	// tinygo command), as llvm.dbg.* calls in the predicate must not prevent
+	// the specialization. There must be no call through a func value left,
+	// and therefore no call to sort.Search at all.
+	config := &BuildConfig{
+		opt:     "z",
+		debug:   true,
+		wasmAbi: "js",
+	}
+	ir, err := buildTest("testdata/sortsearch.go", "", ".ll", config)
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+	for _, line := range strings.Split(string(ir), "\n") {
+		if strings.Contains(line, " call ") && strings.Contains(line, "@sort.Search(") {
+			t.Errorf("expected no calls to sort.Search, got: %s", strings.TrimSpace(line))
+		}
+	}
+
+	runTestWithConfig("testdata/sortsearch.go", "testdata/sortsearch.txt", "", config, t)
+}
+
+func TestOptimizationPresets(t *testing.T) {
+	// The presets select which optional TinyGo transforms run: sort.Search
+	// specialization is one of them, and it doesn't run at -opt=minsize.
+	for _, tc := range []struct {
+		opt         string
+		specialized bool
+	}{
+		{"z", true},
+		{"minsize", false},
+		{"balanced", true},
+	} {
+		config := &BuildConfig{
+			opt:     tc.opt,
+			wasmAbi: "js",
+		}
+		ir, err := buildTest("testdata/sortsearch.go", "", ".ll", config)
+		if err != nil {
+			t.Fatalf("failed to build with -opt=%s: %v", tc.opt, err)
+		}
+		called := false
+		for _, line := range strings.Split(string(ir), "\n") {
+			if strings.Contains(line, " call ") && strings.Contains(line, "@sort.Search(") {
+				called = true
+			}
+		}
+		if called == tc.specialized {
+			t.Errorf("-opt=%s: expected sort.Search specialization: %v, got: %v", tc.opt, tc.specialized, !called)
+		}
+	}
+}
+
+func TestMathReduced(t *testing.T) {
+	// The reference values are calculated with the Go math package, which is
+	// accurate to within 1 ULP. The tolerances are the accuracy bounds of the
+	// reduced versions, documented in src/runtime/math_reduced.go, so the
+	// full versions must be within them too.
+	sizes := map[string]int{}
+	for _, math := range []string{"full", "reduced"} {
+		config := &BuildConfig{
+			opt:     "z",
+			wasmAbi: "js",
+			math:    math,
+		}
+		runTestWithConfig("testdata/special/mathreduced.go", "testdata/special/mathreduced.txt", "", config, t)
+		binary, err := buildTest("testdata/special/mathreduced.go", "", "", config)
+		if err != nil {
+			t.Fatalf("failed to build with -math=%s: %v", math, err)
+		}
+		sizes[math] = len(binary)
+	}
+
+	// The point of the reduced versions is to save space.
+	if sizes["reduced"] >= sizes["full"] {
+		t.Errorf("expected a smaller binary with -math=reduced: %d bytes, full: %d bytes", sizes["reduced"], sizes["full"])
+	}
+}
+
+func TestInterruptBlocking(t *testing.T) {
+	// Interrupt handlers are recognized by name, so the check also works on
+	// the host. Only the first handler may block.
+	source := "testdata/special/interruptblocking.go"
+
+	// Without -werror, this is only a warning.
+	_, err := buildTest(source, "", "", &BuildConfig{opt: "z", wasmAbi: "js"})
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+
+	_, err = buildTest(source, "", "", &BuildConfig{opt: "z", wasmAbi: "js", werror: true})
+	expected := "interrupt handler TIM2_IRQHandler may block the scheduler with time.Sleep: TIM2_IRQHandler -> main.debounce -> time.Sleep"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected an error for the blocking handler, got: %v", err)
+	}
+}
+
+func TestLikelyTypeSwitch(t *testing.T) {
+	// The *Square case in testdata/likelyswitch.go is marked as likely, so it
+	// is checked before the switch over all other types. The output of the
+	// program (which checks that the other cases still work) is tested in
+	// TestCompiler.
+	config := &BuildConfig{
+		opt:     "z",
+		wasmAbi: "js",
+	}
+	ir, err := buildTest("testdata/likelyswitch.go", "", ".ll", config)
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+	if !strings.Contains(string(ir), "%switch.likely = icmp eq") {
+		t.Error("expected the likely case to be checked before the switch")
+	}
+
+	// The type in the pragma must be in the case below it.
+	_, err = buildTest("testdata/special/likelywrongtype.go", "", "", config)
+	expectedErr := "//go:likely pragma type string is not in the case below it"
+	if err == nil || !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("expected an error for the wrong pragma type, got: %v", err)
+	}
+}
+
+func TestReflectLoopHoisting(t *testing.T) {
+	// The reflect sidetables are constant, and indexed with a GEP so that
+	// LLVM knows which global is read. Therefore, the lookup of the named type
+	// in numFields in testdata/reflectloop.go is loop invariant and is moved
+	// to the loop preheader. Loops are not rotated at -Oz, which is needed to
+	// move the load, so this uses -Os. The output of the program is tested in
+	// TestCompiler.
+	config := &BuildConfig{
+		opt: "s",
+	}
+	ir, err := buildTest("testdata/reflectloop.go", "qemu", ".ll", config)
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+
+	// Find the successors of each basic block in the function, and the
+	// blocks with a load from the sidetable. Values derived from the address
+	// of the sidetable (with a GEP, or a ptrtoint, add and inttoptr) are
+	// followed.
+	successors := map[string][]string{}
+	loadBlocks := map[string]string{} // load instruction -> block
+	derived := map[string]bool{"@reflect.namedNonBasicTypesSidetable": true}
+	valueRegexp := regexp.MustCompile(`[%@][-\w.$]+|[%@]"[^"]*"`)
+	labelRegexp := regexp.MustCompile(`label %("[^"]*"|[-\w.$]+)`)
+	inFunction := false
+	block := ""
+	for _, line := range strings.Split(string(ir), "\n") {
+		if strings.HasPrefix(line, "define ") {
+			inFunction = strings.Contains(line, "@numFields(")
+			block = "entry"
+			continue
+		}
+		if !inFunction {
+			continue
+		}
+		if line == "}" {
+			break
+		}
+		if !strings.HasPrefix(line, " ") && strings.Contains(line, ":") {
+			block = strings.Trim(line[:strings.Index(line, ":")], `"`)
+			continue
+		}
+		for _, match := range labelRegexp.FindAllStringSubmatch(line, -1) {
+			successors[block] = append(successors[block], strings.Trim(match[1], `"`))
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "=" {
+			continue
+		}
+		for _, value := range valueRegexp.FindAllString(line[strings.Index(line, "=")+1:], -1) {
+			if !derived[value] {
+				continue
+			}
+			if fields[2] == "load" {
+				loadBlocks[strings.TrimSpace(line)] = block
+			} else {
+				derived[fields[0]] = true
+			}
+		}
+	}
+	if len(loadBlocks) == 0 {
+		t.Fatal("expected a load from the named types sidetable in numFields")
+	}
+
+	// A block is part of a loop when it can reach itself.
+	inLoop := func(start string) bool {
+		visited := map[string]bool{}
+		worklist := append([]string{}, successors[start]...)
+		for len(worklist) != 0 {
+			block := worklist[len(worklist)-1]
+			worklist = worklist[:len(worklist)-1]
+			if block == start {
+				return true
+			}
+			if !visited[block] {
+				visited[block] = true
+				worklist = append(worklist, successors[block]...)
+			}
+		}
+		return false
+	}
+	for load, block := range loadBlocks {
+		if inLoop(block) {
+			t.Errorf("expected the sidetable load to be moved out of the loop, found it in block %s: %s", block, load)
+		}
+	}
+}
+
+func TestReflectTags(t *testing.T) {
+	// Struct tags are only stored with -reflect-tags. Without it, programs
+	// still build but reflect.StructField.Tag is always empty. runTest uses
+	// -reflect-tags, for the tests in testdata/reflect.go.
+	for _, tc := range []struct {
+		name        string
+		reflectTags bool
+	}{
+		{"default", false},
+		{"stored", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &BuildConfig{
+				opt:         "z",
+				wasmAbi:     "js",
+				reflectTags: tc.reflectTags,
+			}
+			runTestWithConfig("testdata/special/reflecttags.go", "testdata/special/reflecttags-"+tc.name+".txt", "", config, t)
+		})
+	}
+}
+
+func TestFPUStackingFPCCR(t *testing.T) {
+	// Build for a Cortex-M with an FPU (which is not emulated by QEMU, so
+	// only the IR is checked) with each fpu-stacking mode, and calculate the
+	// value that is written to FPCCR at startup from its reset value.
+	tmpdir, err := ioutil.TempDir("", "tinygo-test")
+	if err != nil {
+		t.Fatal("could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	const (
+		fpccr      = "inttoptr (i32 -536809676 to i32*)" // 0xE000EF34
+		fpccrReset = 0xc0000000                          // ASPEN and LSPEN
+	)
+	for _, tc := range []struct {
+		stacking string
+		value    uint32
+	}{
+		{"lazy", fpccrReset},
+		{"eager", 0x80000000}, // ASPEN
+		{"none", 0},
+	} {
+		target := filepath.Join(tmpdir, "fpu-"+tc.stacking+".json")
+		spec := `{"inherits": ["qemu"], "llvm-target": "armv7em-none-eabi", "features": ["+vfp4"], "fpu-stacking": "` + tc.stacking + `"}`
+		err := ioutil.WriteFile(target, []byte(spec), 0644)
+		if err != nil {
+			t.Fatal("could not write target:", err)
+		}
+		ir, err := buildTest("testdata/calls.go", target, ".ll", &BuildConfig{opt: "z"})
+		if err != nil {
+			t.Errorf("fpu-stacking %s: failed to build: %v", tc.stacking, err)
+			continue
+		}
+
+		// Follow the stored value back to the load of FPCCR, within the
+		// function that stores it.
+		var eval func(definitions map[string][]string, name string) (uint32, bool)
+		eval = func(definitions map[string][]string, name string) (uint32, bool) {
+			definition := definitions[name]
+			if len(definition) >= 2 && definition[0] == "load" && definition[1] == "volatile" {
+				return fpccrReset, true
+			}
+			if len(definition) < 4 || (definition[0] != "and" && definition[0] != "or") {
+				return 0, false
+			}
+			operand, ok := eval(definitions, definition[2])
+			constant, err := strconv.ParseInt(definition[3], 10, 64)
+			if !ok || err != nil {
+				return 0, false
+			}
+			if definition[0] == "and" {
+				return operand & uint32(constant), true
+			}
+			return operand | uint32(constant), true
+		}
+		value := uint32(fpccrReset)
+		stores := 0
+		definitions := map[string][]string{}
+		for _, line := range strings.Split(string(ir), "\n") {
+			if strings.HasPrefix(line, "define ") {
+				definitions = map[string][]string{}
+			}
+			fields := strings.Fields(strings.Replace(line, ",", " ", -1))
+			if len(fields) >= 3 && fields[1] == "=" {
+				definitions[fields[0]] = fields[2:]
+			}
+			if strings.Contains(line, "store volatile i32 ") && strings.Contains(line, fpccr) {
+				stores++
+				var ok bool
+				value, ok = eval(definitions, fields[3])
+				if !ok {
+					t.Errorf("fpu-stacking %s: could not calculate the value stored to FPCCR: %s", tc.stacking, strings.TrimSpace(line))
+				}
+			}
+		}
+		if stores > 1 {
+			t.Errorf("fpu-stacking %s: expected at most one store to FPCCR, got %d", tc.stacking, stores)
+		}
+		if value != tc.value {
+			t.Errorf("fpu-stacking %s: expected FPCCR 0x%08x, got 0x%08x", tc.stacking, tc.value, value)
+		}
+	}
+}
+
+func TestCustomRuntime(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tinygo-test")
+	if err != nil {
+		t.Fatal("could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// Copy the TinyGo runtime, but replace the leaking allocator with one
+	// that also counts allocations.
+	runtimeDir := filepath.Join(tmpdir, "runtime")
+	err = os.Mkdir(runtimeDir, 0777)
+	if err != nil {
+		t.Fatal("could not create runtime directory:", err)
+	}
+	srcDir := filepath.Join(goenv.Get("TINYGOROOT"), "src", "runtime")
+	files, err := ioutil.ReadDir(srcDir)
+	if err != nil {
+		t.Fatal("could not read runtime directory:", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".go") || file.Name() == "gc_leaking.go" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(srcDir, file.Name()))
+		if err != nil {
+			t.Fatal("could not read runtime file:", err)
+		}
+		err = ioutil.WriteFile(filepath.Join(runtimeDir, file.Name()), data, 0666)
+		if err != nil {
+			t.Fatal("could not write runtime file:", err)
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(runtimeDir, "gc_leaking.go"), []byte(`// +build gc.leaking
+
+package runtime
+
+import "unsafe"
+
+var heapptr = heapStart
+
+var allocations int
+
+// Allocations returns the number of heap allocations so far.
+func Allocations() int {
+	return allocations
+}
+
+func alloc(size uintptr) unsafe.Pointer {
+	allocations++
+	size = align(size)
+	addr := heapptr
+	heapptr += size
+	if heapptr >= heapEnd {
+		runtimePanic("out of memory")
+	}
+	for i := uintptr(0); i < size; i += 4 {
+		*(*uint32)(unsafe.Pointer(addr + i)) = 0
+	}
+	return unsafe.Pointer(addr)
+}
+
+func free(ptr unsafe.Pointer) {
+}
+
+func GC() {
+}
+
+func KeepAlive(x interface{}) {
+}
+
+func SetFinalizer(obj interface{}, finalizer interface{}) {
+}
+`), 0666)
+	if err != nil {
+		t.Fatal("could not write runtime file:", err)
+	}
+
+	// The program can only call runtime.Allocations with the replacement
+	// runtime. Every call to newObject allocates, as the object is stored in
+	// a global.
+	source := "testdata/special/customruntime.go"
+	config := &BuildConfig{opt: "z", gc: "leaking", wasmAbi: "js", runtime: runtimeDir}
+	runTestWithConfig(source, "testdata/special/customruntime.txt", "", config, t)
+
+	// A runtime that doesn't provide all required symbols is rejected with
+	// an error for the first missing symbol (runtime.abort).
+	emptyDir := filepath.Join(tmpdir, "empty")
+	err = os.Mkdir(emptyDir, 0777)
+	if err != nil {
+		t.Fatal("could not create runtime directory:", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(emptyDir, "runtime.go"), []byte("package runtime\n\nfunc initAll()\n\nfunc callMain()\n"), 0666)
+	if err != nil {
+		t.Fatal("could not write runtime file:", err)
+	}
+	_, err = buildTest(source, "", "", &BuildConfig{opt: "z", gc: "leaking", wasmAbi: "js", runtime: emptyDir})
+	expectedErr := "runtime package in " + emptyDir + " does not provide function abort"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected an error for the incomplete runtime, got: %v", err)
+	}
+}
+
+func TestFuzzCorpus(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tinygo-test")
+	if err != nil {
+		t.Fatal("could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	expected, err := ioutil.ReadFile("testdata/fuzzcorpus/out.txt")
+	if err != nil {
+		t.Fatal("could not read expected output file:", err)
+	}
+
+	// Build a test binary that only contains the fuzz target, and check that
+	// it replays the seed corpus (including the one failing input).
+	config := &BuildConfig{
+		opt:     "z",
+		wasmAbi: "js",
+		testConfig: compiler.TestConfig{
+			CompileTestBinary: true,
+			RunRegexp:         "^Fuzz",
+		},
+	}
+	targets := []string{""}
+	if !testing.Short() {
+		targets = append(targets, "qemu")
+	}
+	for _, target := range targets {
+		binary := filepath.Join(tmpdir, "test")
+		err = Build("./testdata/fuzzcorpus", binary, target, config)
+		if err != nil {
+			t.Errorf("failed to build for target %q: %v", target, err)
+			continue
+		}
+		var cmd *exec.Cmd
+		if target == "" {
+			cmd = exec.Command(binary)
+		} else {
+			spec, err := LoadTarget(target)
+			if err != nil {
+				t.Fatal("failed to load target spec:", err)
+			}
+			args := append(spec.Emulator[1:], binary)
+			cmd = exec.Command(spec.Emulator[0], args...)
+		}
+		stdout := &bytes.Buffer{}
+		cmd.Stdout = stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			// The test binary exits with an error code, as an input in the
+			// corpus fails.
+			if _, ok := err.(*exec.ExitError); !ok {
+				t.Errorf("failed to run for target %q: %v", target, err)
+				continue
+			}
+		}
+		actual := strings.Replace(stdout.String(), "\r\n", "\n", -1)
+		if actual != string(expected) {
+			t.Errorf("unexpected output for target %q:\n%s", target, actual)
+		}
+	}
+}
+
+func TestFuzzCorpusInvalid(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tinygo-test")
+	if err != nil {
+		t.Fatal("could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// Corpus values that are function calls instead of conversions must be
+	// rejected: they would be inserted as-is in the test binary.
+	for i, value := range []string{`foo("x")`, `panic("x")`, `len("abc")`} {
+		pkgDir := filepath.Join(tmpdir, strconv.Itoa(i))
+		corpusDir := filepath.Join(pkgDir, "testdata", "fuzz", "FuzzParseLength")
+		err := os.MkdirAll(corpusDir, 0777)
+		if err != nil {
+			t.Fatal("could not create corpus directory:", err)
+		}
+		for _, name := range []string{"parse.go", "parse_test.go"} {
+			data, err := ioutil.ReadFile(filepath.Join("testdata", "fuzzcorpus", name))
+			if err != nil {
+				t.Fatal("could not read test package:", err)
+			}
+			err = ioutil.WriteFile(filepath.Join(pkgDir, name), data, 0666)
+			if err != nil {
+				t.Fatal("could not write test package:", err)
+			}
+		}
+		corpusFile := filepath.Join(corpusDir, "invalid")
+		err = ioutil.WriteFile(corpusFile, []byte("go test fuzz v1\n"+value+"\n"), 0666)
+		if err != nil {
+			t.Fatal("could not write corpus file:", err)
+		}
+
+		config := &BuildConfig{
+			opt:     "z",
+			wasmAbi: "js",
+			testConfig: compiler.TestConfig{
+				CompileTestBinary: true,
+				RunRegexp:         "^Fuzz",
+			},
+		}
+		// Packages can only be imported with a path relative to the current
+		// directory.
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal("could not get working directory:", err)
+		}
+		pkgPath, err := filepath.Rel(wd, pkgDir)
+		if err != nil {
+			t.Fatal("could not get relative package path:", err)
+		}
+		err = Build(pkgPath, filepath.Join(tmpdir, "test"), "", config)
+		expectedErr := corpusFile + ": unsupported corpus value: " + value
+		if err == nil || !strings.Contains(err.Error(), expectedErr) {
+			t.Errorf("expected an error for corpus value %s, got: %v", value, err)
+		}
+	}
+}
+
+func TestPrintInterrupts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("cross compiling for Cortex-M is not done in short mode")
+	}
+	tmpdir, err := ioutil.TempDir("", "tinygo-test")
+	if err != nil {
+		t.Fatal("could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	binary := filepath.Join(tmpdir, "interrupts.elf")
+	err = Build("./testdata/interrupts", binary, "qemu", &BuildConfig{opt: "z"})
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+	vectors, err := readInterrupts(binary)
+	if err != nil {
+		t.Fatal("failed to read interrupts:", err)
+	}
+
+	// Both handlers in the program must be installed, while the other
+	// exceptions (except for those implemented by the runtime) must use the
+	// default handler.
+	handlers := map[string]interruptVector{}
+	for _, vector := range vectors {
+		handlers[vector.Exception] = vector
+	}
+	for _, tc := range []struct {
+		exception string
+		irq       int
+		handler   string
+		isDefault bool
+	}{
+		{"SVC", -5, "SVC_Handler", false},
+		{"PendSV", -2, "PendSV_Handler", false},
+		{"SysTick", -1, "SysTick_Handler", false},
+		{"DebugMon", -4, "Default_Handler", true},
+	} {
+		vector, ok := handlers[tc.exception]
+		if !ok {
+			t.Errorf("%s: not found in vector table", tc.exception)
+			continue
+		}
+		if vector.IRQ != tc.irq || vector.Handler != tc.handler || vector.Default != tc.isDefault {
+			t.Errorf("%s: expected irq=%d handler=%s default=%v, got irq=%d handler=%s default=%v", tc.exception, tc.irq, tc.handler, tc.isDefault, vector.IRQ, vector.Handler, vector.Default)
+		}
+	}
+}
+
+func TestHotColdSections(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("function sections are not used on macOS")
+	}
+
+	// Functions are exported so that they are not inlined or removed.
+	ir, err := buildTest("testdata/special/hotcold.go", "", ".ll", &BuildConfig{opt: "z", wasmAbi: "js"})
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+
+	for name, section := range map[string]string{
+		"hotFunc":   ".text.hot.hotFunc",
+		"coldFunc":  ".text.unlikely.coldFunc",
+		"plainFunc": ".text.plainFunc",
+	} {
+		found := false
+		for _, line := range strings.Split(string(ir), "\n") {
+			if strings.HasPrefix(line, "define ") && strings.Contains(line, "@"+name+"(") {
+				found = true
+				if !strings.Contains(line, `section "`+section+`"`) {
+					t.Errorf("%s: expected section %s, got: %s", name, section, line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: function not found in IR", name)
+		}
+	}
+}
+
+func TestPositionIndependentCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires QEMU")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "tinygo-test")
+	if err != nil {
+		t.Fatal("could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// The program uses tables of function pointers and strings (indexed with a
+	// volatile load so they can't be optimized away), an interrupt (for
+	// time.Sleep) and a goroutine. All of these refer to flash by absolute
+	// address, which must be relocated when the image is loaded at a
+	// different address.
+	config := &BuildConfig{
+		opt:     "z",
+		pic:     "ropi",
+		wasmAbi: "js",
+	}
+	image := filepath.Join(tmpdir, "test.bin")
+	err = Build("./testdata/special/pic.go", image, "qemu", config)
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+	data, err := ioutil.ReadFile(image)
+	if err != nil {
+		t.Fatal("could not read image:", err)
+	}
+	expected, err := ioutil.ReadFile("testdata/special/pic.txt")
+	if err != nil {
+		t.Fatal("could not read expected output file:", err)
+	}
+	spec, err := LoadTarget("qemu")
+	if err != nil {
+		t.Fatal("failed to load target spec:", err)
+	}
+
+	// Run the same image from the start of flash (where it was linked) and
+	// from an offset, like a second OTA slot. There is no bootloader, so write
+	// a minimal vector table with the initial stack pointer and the relocated
+	// reset vector to the start of flash, which is what a bootloader would do.
+	for _, offset := range []uint32{0, 0x10000} {
+		kernel := image
+		var extraArgs []string
+		if offset != 0 {
+			boot := make([]byte, 8)
+			binary.LittleEndian.PutUint32(boot[0:], binary.LittleEndian.Uint32(data[0:]))
+			binary.LittleEndian.PutUint32(boot[4:], binary.LittleEndian.Uint32(data[4:])+offset)
+			kernel = filepath.Join(tmpdir, "boot.bin")
+			err := ioutil.WriteFile(kernel, boot, 0666)
+			if err != nil {
+				t.Fatal("could not write boot vector table:", err)
+			}
+			extraArgs = []string{"-device", "loader,file=" + image + ",addr=0x" + strconv.FormatUint(uint64(offset), 16)}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		args := append(append(spec.Emulator[1:], kernel), extraArgs...)
+		cmd := exec.CommandContext(ctx, spec.Emulator[0], args...)
+		stdout := &bytes.Buffer{}
+		cmd.Stdout = stdout
+		cmd.Stderr = os.Stderr
+		err := cmd.Run()
+		cancel()
+		if _, ok := err.(*exec.ExitError); err != nil && (!ok || ctx.Err() != nil) {
+			// QEMU exits with an error code (see buildAndRunTest), but must not
+			// time out.
+			t.Errorf("offset %#x: failed to run: %v\n%s", offset, err, stdout.String())
+			continue
+		}
+
+		actual := strings.Replace(stdout.String(), "\r\n", "\n", -1)
+		if actual != string(expected) {
+			t.Errorf("offset %#x: unexpected output:\n%s", offset, actual)
+		}
+	}
+}
+
+func TestRAMVectors(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires QEMU")
+	}
+
+	// RAM starts at 0x20000000 on the emulated chip, flash at 0.
+	for _, vectors := range []string{"flash", "ram"} {
+		t.Run(vectors, func(t *testing.T) {
+			config := &BuildConfig{
+				opt:     "z",
+				vectors: vectors,
+				wasmAbi: "js",
+			}
+			runTestWithConfig("testdata/special/vectors.go", "testdata/special/vectors-"+vectors+".txt", "qemu", config, t)
+		})
+	}
+}
+
+func TestRAMVectorsUnsupported(t *testing.T) {
+	config := &BuildConfig{
+		opt:     "z",
+		vectors: "ram",
+		wasmAbi: "js",
+	}
+	_, err := buildTest("testdata/alias.go", "wasm", "", config)
+	if err == nil || err.Error() != "-vectors=ram is only supported on Cortex-M targets" {
+		t.Errorf("expected an error for -vectors=ram on WebAssembly, got: %v", err)
+	}
+
+	// The Cortex-M0 of the micro:bit has no VTOR register, so the copy of the
+	// vector table in RAM would never be used.
+	for _, config := range []*BuildConfig{
+		{opt: "z", vectors: "ram"},
+		{opt: "z", pic: "ropi"},
+	} {
+		_, err = buildTest("testdata/alias.go", "microbit", "", config)
+		expected := "-vectors=ram and -pic=ropi are not supported on chips without a VTOR register (like the Cortex-M0)"
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected an error for -vectors=%s -pic=%s on the micro:bit, got: %v", config.vectors, config.pic, err)
+		}
+	}
+}
+
+func TestTrimPath(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only ELF files are checked")
+	}
+
+	binary, err := buildTest("testdata/special/trimpath/main.go", "", "", &BuildConfig{opt: "z", wasmAbi: "js", debug: true, trimPath: true})
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(binary))
+	if err != nil {
+		t.Fatal("could not open binary:", err)
+	}
+	data, err := f.DWARF()
+	if err != nil {
+		t.Fatal("could not read DWARF:", err)
+	}
+
+	// Collect all file names in the line tables of the TinyGo compile unit.
+	// Other compile units (such as the C library) are not built by TinyGo.
+	files := map[string]bool{}
+	r := data.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			t.Fatal("could not read DWARF entry:", err)
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+		if producer, _ := entry.Val(dwarf.AttrProducer).(string); producer != "TinyGo" {
+			r.SkipChildren()
+			continue
+		}
+		lr, err := data.LineReader(entry)
+		if err != nil {
+			t.Fatal("could not read line table:", err)
+		}
+		var line dwarf.LineEntry
+		for lr.Next(&line) == nil {
+			files[line.File.Name] = true
+		}
+		r.SkipChildren()
+	}
+
+	if !files["command-line-arguments/main.go"] {
+		t.Error("main.go is not stored as command-line-arguments/main.go")
+	}
+	foundRuntime := false
+	for name := range files {
+		if filepath.IsAbs(name) {
+			t.Error("file name is not trimmed:", name)
+		}
+		if strings.HasPrefix(name, "runtime/") {
+			foundRuntime = true
+		}
+	}
+	if !foundRuntime {
+		t.Error("no runtime files found in the debug information")
+	}
+}
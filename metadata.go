@@ -0,0 +1,167 @@
+package main
+
+// This file implements the firmware metadata section (.tinygo_meta), which
+// stores build information in the firmware image so that it can be read back
+// without running it.
+//
+// The section contains a single metadata blob with the following format. All
+// integers are little endian, independent of the target byte order.
+//
+//     offset  size  description
+//     0       4     magic: "TGMD"
+//     4       1     format version, currently 1
+//     5       1     reserved, always 0
+//     6       2     number of entries
+//     8       ...   entries
+//
+// Each entry is a key/value pair of strings, sorted by key:
+//
+//     offset  size  description
+//     0       1     key length (n)
+//     1       n     key
+//     n+1     2     value length (m)
+//     n+3     m     value
+//
+// The following keys are added automatically, but can be overridden using the
+// -metadata flag:
+//
+//     tinygo  the TinyGo version used to build the image
+//     target  the LLVM target triple
+//     git     the git commit hash of the current directory, if available
+//     date    the build date in RFC 3339 format, omitted when
+//             SOURCE_DATE_EPOCH is set (for reproducible builds)
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	metadataSection = ".tinygo_meta"
+	metadataMagic   = "TGMD"
+	metadataVersion = 1
+)
+
+// parseMetadataFlag parses the value of the -metadata flag, which is a
+// comma-separated list of key=value pairs.
+func parseMetadataFlag(s string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+		index := strings.IndexByte(pair, '=')
+		if index <= 0 {
+			return nil, errors.New("invalid metadata, expected key=value: " + pair)
+		}
+		metadata[pair[:index]] = pair[index+1:]
+	}
+	return metadata, nil
+}
+
+// buildMetadata returns the metadata to store in the image: the automatically
+// detected build information, overridden by the given user-provided values.
+func buildMetadata(spec *TargetSpec, values map[string]string) map[string]string {
+	metadata := map[string]string{
+		"tinygo": version,
+		"target": spec.Triple,
+	}
+	if out, err := exec.Command("git", "rev-parse", "HEAD").Output(); err == nil {
+		metadata["git"] = strings.TrimSpace(string(out))
+	}
+	if os.Getenv("SOURCE_DATE_EPOCH") == "" {
+		metadata["date"] = time.Now().UTC().Format(time.RFC3339)
+	}
+	for key, value := range values {
+		metadata[key] = value
+	}
+	return metadata
+}
+
+// encodeMetadata returns the binary representation of the given metadata, as
+// stored in the .tinygo_meta section.
+func encodeMetadata(metadata map[string]string) ([]byte, error) {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0xffff {
+		return nil, errors.New("too many metadata entries")
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(metadataMagic)
+	buf.WriteByte(metadataVersion)
+	buf.WriteByte(0)
+	binary.Write(buf, binary.LittleEndian, uint16(len(keys)))
+	for _, key := range keys {
+		value := metadata[key]
+		if len(key) > 0xff {
+			return nil, errors.New("metadata key too long: " + key)
+		}
+		if len(value) > 0xffff {
+			return nil, errors.New("metadata value too long for key: " + key)
+		}
+		buf.WriteByte(uint8(len(key)))
+		buf.WriteString(key)
+		binary.Write(buf, binary.LittleEndian, uint16(len(value)))
+		buf.WriteString(value)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeMetadata parses a metadata blob as created by encodeMetadata.
+func decodeMetadata(data []byte) (map[string]string, error) {
+	errInvalid := errors.New("invalid metadata section")
+	if len(data) < 8 || string(data[:4]) != metadataMagic {
+		return nil, errInvalid
+	}
+	if data[4] != metadataVersion {
+		return nil, errors.New("unsupported metadata format version")
+	}
+	numEntries := int(binary.LittleEndian.Uint16(data[6:]))
+	data = data[8:]
+	metadata := make(map[string]string, numEntries)
+	for i := 0; i < numEntries; i++ {
+		if len(data) < 1 || len(data) < 1+int(data[0])+2 {
+			return nil, errInvalid
+		}
+		keyLen := int(data[0])
+		key := string(data[1 : 1+keyLen])
+		data = data[1+keyLen:]
+		valueLen := int(binary.LittleEndian.Uint16(data))
+		if len(data) < 2+valueLen {
+			return nil, errInvalid
+		}
+		metadata[key] = string(data[2 : 2+valueLen])
+		data = data[2+valueLen:]
+	}
+	return metadata, nil
+}
+
+// readMetadata reads the metadata section from the given ELF file.
+func readMetadata(path string) (map[string]string, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	section := file.Section(metadataSection)
+	if section == nil {
+		return nil, errors.New("no " + metadataSection + " section found in " + path)
+	}
+	data, err := section.Data()
+	if err != nil {
+		return nil, err
+	}
+	return decodeMetadata(data)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	metadata, err := parseMetadataFlag("version=1.2.3,empty=,url=http://example.com/?a=b")
+	if err != nil {
+		t.Fatal("could not parse metadata flag:", err)
+	}
+	expected := map[string]string{
+		"version": "1.2.3",
+		"empty":   "",
+		"url":     "http://example.com/?a=b",
+	}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("unexpected metadata: %v", metadata)
+	}
+
+	if _, err := parseMetadataFlag("version"); err == nil {
+		t.Error("parseMetadataFlag should have failed without a value")
+	}
+
+	data, err := encodeMetadata(metadata)
+	if err != nil {
+		t.Fatal("could not encode metadata:", err)
+	}
+	if string(data[:4]) != metadataMagic {
+		t.Errorf("metadata does not start with magic: %q", data[:4])
+	}
+	decoded, err := decodeMetadata(data)
+	if err != nil {
+		t.Fatal("could not decode metadata:", err)
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("unexpected decoded metadata: %v", decoded)
+	}
+
+	if _, err := decodeMetadata(data[:len(data)-1]); err == nil {
+		t.Error("decodeMetadata should have failed on truncated data")
+	}
+}
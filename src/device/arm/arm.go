@@ -72,8 +72,10 @@ func SVCall4(num uintptr, a1, a2, a3, a4 interface{}) uintptr
 
 const (
 	SCS_BASE  = 0xE000E000
+	SYST_BASE = SCS_BASE + 0x0010
 	NVIC_BASE = SCS_BASE + 0x0100
 	SCB_BASE  = SCS_BASE + 0x0D00
+	FPU_BASE  = SCS_BASE + 0x0F30
 )
 
 const (
@@ -99,6 +101,50 @@ type SCB_Type struct {
 
 var SCB = (*SCB_Type)(unsafe.Pointer(uintptr(SCB_BASE)))
 
+const (
+	FPU_FPCCR_ASPEN_Pos = 31
+	FPU_FPCCR_ASPEN_Msk = 1 << FPU_FPCCR_ASPEN_Pos // automatic FPU state preservation
+	FPU_FPCCR_LSPEN_Pos = 30
+	FPU_FPCCR_LSPEN_Msk = 1 << FPU_FPCCR_LSPEN_Pos // lazy FPU state preservation
+)
+
+// Floating Point Unit (FPU)
+//
+// FPU_Type provides the definitions for the FPU registers that are present on
+// Cortex-M4F and Cortex-M7F chips.
+type FPU_Type struct {
+	_      volatile.Register32 // RESERVED0
+	FPCCR  volatile.Register32 // Floating-Point Context Control Register
+	FPCAR  volatile.Register32 // Floating-Point Context Address Register
+	FPDSCR volatile.Register32 // Floating-Point Default Status Control Register
+	MVFR0  volatile.Register32 // Media and FP Feature Register 0
+	MVFR1  volatile.Register32 // Media and FP Feature Register 1
+}
+
+var FPU = (*FPU_Type)(unsafe.Pointer(uintptr(FPU_BASE)))
+
+const (
+	SYST_CSR_ENABLE_Pos    = 0
+	SYST_CSR_ENABLE_Msk    = 1 << SYST_CSR_ENABLE_Pos // counter enable
+	SYST_CSR_TICKINT_Pos   = 1
+	SYST_CSR_TICKINT_Msk   = 1 << SYST_CSR_TICKINT_Pos // SysTick exception on count to zero
+	SYST_CSR_CLKSOURCE_Pos = 2
+	SYST_CSR_CLKSOURCE_Msk = 1 << SYST_CSR_CLKSOURCE_Pos // use the processor clock
+)
+
+// System Timer (SYST)
+//
+// SYST_Type provides the definitions for the SysTick timer registers, which
+// are present on all Cortex-M chips.
+type SYST_Type struct {
+	CSR   volatile.Register32 // SysTick Control and Status Register
+	RVR   volatile.Register32 // SysTick Reload Value Register
+	CVR   volatile.Register32 // SysTick Current Value Register
+	CALIB volatile.Register32 // SysTick Calibration Value Register
+}
+
+var SYST = (*SYST_Type)(unsafe.Pointer(uintptr(SYST_BASE)))
+
 // Nested Vectored Interrupt Controller (NVIC).
 //
 // Source:
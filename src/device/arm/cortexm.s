@@ -19,3 +19,19 @@ HardFault_Handler:
 
     // Continue handling this error in Go.
     bl handleHardFault
+
+// Return the difference between the address this code runs from and the
+// address it was linked for. This is only non-zero for position independent
+// code (-pic=ropi) that is started from a different flash address, see
+// src/runtime/ropi_cortexm.go.
+.section .text.tinygo_loadOffset
+.global  tinygo_loadOffset
+.type    tinygo_loadOffset, %function
+tinygo_loadOffset:
+    adr  r0, .Lload_offset_anchor // address at runtime (PC-relative)
+    ldr  r1, .Lload_offset_anchor // address at link time (stored at the anchor)
+    subs r0, r0, r1
+    bx   lr
+.align 2
+.Lload_offset_anchor:
+    .word .Lload_offset_anchor
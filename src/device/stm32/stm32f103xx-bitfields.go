@@ -37,6 +37,12 @@ const (
 	RCC_CFGR_PPRE2_DIV_8    = 0x00003000
 	RCC_CFGR_PPRE2_DIV_16   = 0x00003800
 
+	// Sets the ADC clock (from PCLK2)
+	RCC_CFGR_ADCPRE_DIV_2 = 0x00000000
+	RCC_CFGR_ADCPRE_DIV_4 = 0x00004000
+	RCC_CFGR_ADCPRE_DIV_6 = 0x00008000
+	RCC_CFGR_ADCPRE_DIV_8 = 0x0000C000
+
 	// Sets PLL multiplier
 	RCC_CFGR_PLLMUL_2  = 0x00000000
 	RCC_CFGR_PLLMUL_3  = 0x00040000
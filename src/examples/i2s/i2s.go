@@ -8,11 +8,19 @@ import (
 )
 
 func main() {
-	machine.I2S0.Configure(machine.I2SConfig{
-		Mode:        machine.I2SModePDM,
-		ClockSource: machine.I2SClockSourceExternal,
-		Stereo:      true,
+	// The microphone is clocked by SCK, which runs at the sample rate times
+	// the bits per frame: two 16-bit slots in stereo. 24kHz therefore gives a
+	// 768kHz clock.
+	err := machine.I2S0.Configure(machine.I2SConfig{
+		Mode:           machine.I2SModePDM,
+		ClockSource:    machine.I2SClockSourceExternal,
+		Stereo:         true,
+		AudioFrequency: 24000,
 	})
+	if err != nil {
+		println("could not configure I2S:", err.Error())
+		return
+	}
 
 	data := make([]uint32, 64)
 
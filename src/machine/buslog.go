@@ -0,0 +1,61 @@
+package machine
+
+// BusKind is the kind of bus of a logged transaction.
+type BusKind uint8
+
+const (
+	BusSPI BusKind = iota
+	BusI2C
+)
+
+// BusTransaction is a single SPI or I2C transaction, as reported to the logger
+// set with SetBusLogger. The Write and Read slices are the buffers passed to
+// the transaction and the transaction itself is reused for the next one, so
+// they are only valid while the logger is called.
+type BusTransaction struct {
+	Kind    BusKind
+	Address uint16 // I2C address, always zero for SPI
+	Write   []byte // bytes sent to the device
+	Read    []byte // bytes received from the device
+	Err     error  // error returned by the transaction
+}
+
+// busLogger is the logger set with SetBusLogger, or nil.
+var busLogger func(*BusTransaction)
+
+// busLogTransaction is passed to the logger for every transaction. The logger
+// is an unknown function, so a new transaction would always be allocated on
+// the heap.
+var busLogTransaction BusTransaction
+
+// SetBusLogger sets a function that is called after every SPI and I2C
+// transaction has completed, for example to print all bytes sent to and
+// received from a new sensor while debugging. Use nil to stop logging.
+//
+// Transactions are only logged when the program is built with -tags=buslog.
+// Otherwise, this function does nothing and logging adds no code at all to SPI
+// and I2C transactions. Note that when logging is enabled, the logger is called
+// synchronously after each transaction (and SPI.Transfer is a transaction of a
+// single byte): this changes the timing of bus accesses, so logging should not
+// be enabled for devices that are sensitive to the timing between
+// transactions.
+func SetBusLogger(logger func(*BusTransaction)) {
+	if busLogEnabled {
+		busLogger = logger
+	}
+}
+
+// logBus reports a transaction to the bus logger, if there is one.
+func logBus(kind BusKind, addr uint16, w, r []byte, err error) {
+	if busLogEnabled && busLogger != nil {
+		busLogTransaction = BusTransaction{
+			Kind:    kind,
+			Address: addr,
+			Write:   w,
+			Read:    r,
+			Err:     err,
+		}
+		busLogger(&busLogTransaction)
+		busLogTransaction = BusTransaction{}
+	}
+}
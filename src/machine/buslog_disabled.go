@@ -0,0 +1,6 @@
+// +build !buslog
+
+package machine
+
+// Don't log SPI and I2C transactions, see SetBusLogger.
+const busLogEnabled = false
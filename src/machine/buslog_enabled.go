@@ -0,0 +1,6 @@
+// +build buslog
+
+package machine
+
+// Report SPI and I2C transactions to the bus logger, see SetBusLogger.
+const busLogEnabled = true
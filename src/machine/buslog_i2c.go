@@ -0,0 +1,14 @@
+// +build avr nrf sam stm32f103xx !baremetal
+
+package machine
+
+// Tx does a single I2C transaction at the specified address.
+// It clocks out the given address, writes the bytes in w, reads back len(r)
+// bytes and stores them in r, and generates a stop condition on the bus.
+func (i2c I2C) Tx(addr uint16, w, r []byte) error {
+	err := i2c.tx(addr, w, r)
+	if busLogEnabled {
+		logBus(BusI2C, addr, w, r, err)
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+// +build nrf sam stm32f103xx !baremetal
+
+package machine
+
+// busLogTransferBuf holds the bytes of a logged Transfer call. Like the
+// transaction itself, a local buffer would escape to the heap through the
+// logger, which would be an allocation for every byte.
+var busLogTransferBuf [2]byte
+
+// Transfer writes/reads a single byte using the SPI interface.
+func (spi SPI) Transfer(w byte) (byte, error) {
+	r, err := spi.transfer(w)
+	if busLogEnabled {
+		busLogTransferBuf = [2]byte{w, r}
+		logBus(BusSPI, 0, busLogTransferBuf[:1], busLogTransferBuf[1:], err)
+	}
+	return r, err
+}
+
+// Tx handles read/write operation for SPI interface. Since SPI is a syncronous write/read
+// interface, there must always be the same number of bytes written as bytes read.
+// The Tx method knows about this, and offers a few different ways of calling it.
+//
+// This form sends the bytes in tx buffer, putting the resulting bytes read into the rx buffer.
+// Note that the tx and rx buffers must be the same size:
+//
+// 		spi.Tx(tx, rx)
+//
+// This form sends the tx buffer, ignoring the result. Useful for sending "commands" that return zeros
+// until all the bytes in the command packet have been received:
+//
+// 		spi.Tx(tx, nil)
+//
+// This form sends zeros, putting the result into the rx buffer. Good for reading a "result packet":
+//
+// 		spi.Tx(nil, rx)
+//
+func (spi SPI) Tx(w, r []byte) error {
+	err := spi.tx(w, r)
+	if busLogEnabled {
+		logBus(BusSPI, 0, w, r, err)
+	}
+	return err
+}
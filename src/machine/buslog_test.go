@@ -0,0 +1,110 @@
+package machine
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+// The SPI and I2C functions of the generic machine are provided by the host
+// environment. Implement them here with a fake SPI device that returns the
+// inverse of every byte and a fake I2C device that answers every read with
+// 0x71. The symbol names are those of the package when it is tested with the
+// file list in the Makefile.
+
+//go:linkname testSPITransfer command-line-arguments.spiTransfer
+func testSPITransfer(bus uint8, w uint8) uint8 {
+	return ^w
+}
+
+//go:linkname testI2CTransfer command-line-arguments.i2cTransfer
+func testI2CTransfer(bus uint8, w *byte, wlen int, r *byte, rlen int) int {
+	buf := (*[1 << 16]byte)(unsafe.Pointer(r))[:rlen:rlen]
+	for i := range buf {
+		buf[i] = 0x71
+	}
+	return 0
+}
+
+func TestBusLogger(t *testing.T) {
+	var logged []BusTransaction
+	SetBusLogger(func(tx *BusTransaction) {
+		// The transaction and its buffers are reused, so store a copy.
+		logged = append(logged, BusTransaction{
+			Kind:    tx.Kind,
+			Address: tx.Address,
+			Write:   append([]byte(nil), tx.Write...),
+			Read:    append([]byte(nil), tx.Read...),
+			Err:     tx.Err,
+		})
+	})
+	defer SetBusLogger(nil)
+
+	// The transactions must work the same with and without logging.
+	if r, err := SPI0.Transfer(0x12); r != 0xed || err != nil {
+		t.Errorf("unexpected Transfer result: %#x, %v", r, err)
+	}
+	rx := make([]byte, 2)
+	if err := SPI0.Tx([]byte{1, 2}, rx); err != nil || !bytes.Equal(rx, []byte{0xfe, 0xfd}) {
+		t.Errorf("unexpected SPI Tx result: %v, %v", rx, err)
+	}
+	if err := SPI0.Tx([]byte{3}, nil); err != nil {
+		t.Errorf("unexpected SPI Tx error: %v", err)
+	}
+	if err := SPI0.Tx([]byte{4, 5}, make([]byte, 1)); err != ErrTxInvalidSliceSize {
+		t.Errorf("expected ErrTxInvalidSliceSize, got %v", err)
+	}
+	r := make([]byte, 1)
+	if err := I2C0.Tx(0x68, []byte{0x75}, r); err != nil || r[0] != 0x71 {
+		t.Errorf("unexpected I2C Tx result: %v, %v", r, err)
+	}
+
+	if !busLogEnabled {
+		// Built without -tags=buslog: the logger must never be called.
+		if len(logged) != 0 {
+			t.Errorf("expected no logged transactions, got %d", len(logged))
+		}
+		return
+	}
+
+	// Every call is a single transaction: SPI.Tx must not log each byte.
+	expected := []struct {
+		kind  BusKind
+		addr  uint16
+		write []byte
+		read  []byte
+		err   error
+	}{
+		{BusSPI, 0, []byte{0x12}, []byte{0xed}, nil},
+		{BusSPI, 0, []byte{1, 2}, []byte{0xfe, 0xfd}, nil},
+		{BusSPI, 0, []byte{3}, nil, nil},
+		{BusSPI, 0, []byte{4, 5}, []byte{0}, ErrTxInvalidSliceSize},
+		{BusI2C, 0x68, []byte{0x75}, []byte{0x71}, nil},
+	}
+	if len(logged) != len(expected) {
+		t.Fatalf("expected %d logged transactions, got %d: %+v", len(expected), len(logged), logged)
+	}
+	for i, e := range expected {
+		tx := logged[i]
+		if tx.Kind != e.kind || tx.Address != e.addr || !bytes.Equal(tx.Write, e.write) || !bytes.Equal(tx.Read, e.read) || tx.Err != e.err {
+			t.Errorf("unexpected transaction %d: %+v", i, tx)
+		}
+	}
+
+	// Errors of the bus are reported along with the transaction.
+	errNack := errors.New("nack")
+	logBus(BusI2C, 0x3c, []byte{0}, nil, errNack)
+	if tx := logged[len(logged)-1]; tx.Kind != BusI2C || tx.Address != 0x3c || tx.Err != errNack {
+		t.Errorf("unexpected last transaction: %+v", tx)
+	}
+
+	// After removing the logger, nothing should be logged anymore.
+	n := len(logged)
+	SetBusLogger(nil)
+	SPI0.Transfer(3)
+	I2C0.Tx(0x68, []byte{0x75}, r)
+	if len(logged) != n {
+		t.Errorf("expected no transactions to be logged after SetBusLogger(nil)")
+	}
+}
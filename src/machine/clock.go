@@ -0,0 +1,66 @@
+package machine
+
+// This file contains helpers to calculate clock dividers for peripherals, such
+// as the SPI and I2C clock. They all round the resulting frequency down, so
+// that a peripheral is never clocked faster than requested, unless the
+// requested frequency is below the slowest frequency the hardware supports.
+
+// divideClock returns the smallest divider in the range [min, max] for which
+// clock/divider does not exceed freq.
+func divideClock(clock, freq, min, max uint32) uint32 {
+	if freq == 0 {
+		return max
+	}
+	divider := (clock + freq - 1) / freq // round up
+	if divider < min {
+		return min
+	}
+	if divider > max {
+		return max
+	}
+	return divider
+}
+
+// divideClockPow2 is like divideClock, but for dividers that must be a power
+// of two. It returns the shift of the divider (log2(divider)) in the range
+// [min, max].
+func divideClockPow2(clock, freq uint32, min, max uint8) uint8 {
+	shift := min
+	for shift < max && clock>>shift > freq {
+		shift++
+	}
+	return shift
+}
+
+// divideClockNearest is like divideClock, but returns the divider for which
+// clock/divider is closest to freq, even if that is faster than requested. It
+// is used for audio sample rates, where the result is checked against a
+// tolerance (see clockWithinTolerance) instead of being an upper bound.
+func divideClockNearest(clock, freq, min, max uint32) uint32 {
+	if freq == 0 {
+		return max
+	}
+	divider := clock / freq // round down, so clock/divider >= freq
+	if divider < min {
+		return min
+	}
+	if divider >= max {
+		return max
+	}
+	if divider == 0 || clock/divider-freq > freq-clock/(divider+1) {
+		// The next divider is closer.
+		divider++
+	}
+	return divider
+}
+
+// clockWithinTolerance returns whether the actual frequency differs by at most
+// freq/tolerance from the requested frequency freq. For example, a tolerance
+// of 100 allows a deviation of 1%.
+func clockWithinTolerance(actual, freq, tolerance uint32) bool {
+	diff := actual - freq
+	if actual < freq {
+		diff = freq - actual
+	}
+	return uint64(diff)*uint64(tolerance) <= uint64(freq)
+}
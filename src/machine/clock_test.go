@@ -0,0 +1,87 @@
+package machine
+
+import "testing"
+
+func TestDivideClock(t *testing.T) {
+	for _, tc := range []struct {
+		clock, freq, min, max uint32
+		divider               uint32
+	}{
+		{24000000, 4000000, 1, 256, 6},    // exact
+		{24000000, 5000000, 1, 256, 5},    // round down: 4.8MHz
+		{24000000, 7000000, 1, 256, 4},    // round down: 6MHz
+		{24000000, 48000000, 1, 256, 1},   // too high: fastest rate
+		{24000000, 10000, 1, 256, 256},    // too low: slowest rate
+		{24000000, 0, 1, 256, 256},        // no frequency: slowest rate
+		{18000000, 100000, 4, 0xfff, 180}, // STM32 I2C standard mode
+	} {
+		divider := divideClock(tc.clock, tc.freq, tc.min, tc.max)
+		if divider != tc.divider {
+			t.Errorf("divideClock(%d, %d, %d, %d): expected %d, got %d", tc.clock, tc.freq, tc.min, tc.max, tc.divider, divider)
+		}
+	}
+}
+
+func TestDivideClockPow2(t *testing.T) {
+	for _, tc := range []struct {
+		clock, freq uint32
+		min, max    uint8
+		shift       uint8
+	}{
+		{8000000, 1000000, 0, 6, 3},   // exact
+		{8000000, 3000000, 0, 6, 2},   // round down: 2MHz
+		{8000000, 999999, 0, 6, 4},    // round down: 500kHz
+		{8000000, 16000000, 0, 6, 0},  // too high: fastest rate
+		{8000000, 1000, 0, 6, 6},      // too low: slowest rate
+		{72000000, 72000000, 1, 8, 1}, // above the fastest rate with min > 0
+	} {
+		shift := divideClockPow2(tc.clock, tc.freq, tc.min, tc.max)
+		if shift != tc.shift {
+			t.Errorf("divideClockPow2(%d, %d, %d, %d): expected %d, got %d", tc.clock, tc.freq, tc.min, tc.max, tc.shift, shift)
+		}
+	}
+}
+
+func TestDivideClockNearest(t *testing.T) {
+	for _, tc := range []struct {
+		clock, freq, min, max uint32
+		divider               uint32
+	}{
+		{48000000, 1536000, 1, 255, 31}, // 48kHz, 32 bits: 1.548MHz (0.8% fast)
+		{48000000, 768000, 1, 255, 63},  // 48kHz, 16 bits: 762kHz (0.8% slow)
+		{48000000, 705600, 1, 255, 68},  // 44.1kHz, 16 bits: 706kHz (0.04% fast)
+		{48000000, 4000000, 1, 255, 12}, // exact
+		{48000000, 3900000, 1, 255, 12}, // round to the faster 4MHz
+		{48000000, 96000000, 1, 255, 1}, // too high: fastest rate
+		{48000000, 100000, 1, 255, 255}, // too low: slowest rate
+		{48000000, 0, 1, 255, 255},      // no frequency: slowest rate
+		{48000000, 48000000, 2, 255, 2}, // above the fastest rate with min > 1
+		{48000000, 188400, 1, 255, 255}, // just above the slowest rate
+		{48000000, 190000, 1, 255, 253}, // 189.7kHz is closer than 190.5kHz
+		{48000000, 190200, 1, 255, 252}, // 190.5kHz is closer than 189.7kHz
+	} {
+		divider := divideClockNearest(tc.clock, tc.freq, tc.min, tc.max)
+		if divider != tc.divider {
+			t.Errorf("divideClockNearest(%d, %d, %d, %d): expected %d, got %d", tc.clock, tc.freq, tc.min, tc.max, tc.divider, divider)
+		}
+	}
+}
+
+func TestClockWithinTolerance(t *testing.T) {
+	for _, tc := range []struct {
+		actual, freq, tolerance uint32
+		ok                      bool
+	}{
+		{48000, 48000, 100, true},
+		{48387, 48000, 100, true},  // 0.8% fast
+		{47520, 48000, 100, true},  // exactly 1% slow
+		{48481, 48000, 100, false}, // just over 1% fast
+		{24193, 48000, 100, false}, // half the rate
+		{48387, 48000, 1000, false},
+	} {
+		ok := clockWithinTolerance(tc.actual, tc.freq, tc.tolerance)
+		if ok != tc.ok {
+			t.Errorf("clockWithinTolerance(%d, %d, %d): expected %t, got %t", tc.actual, tc.freq, tc.tolerance, tc.ok, ok)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package machine
+
+// DefaultDebounceThreshold is the number of samples used by DebouncedPin when
+// no threshold is set.
+const DefaultDebounceThreshold = 5
+
+// PinReader is a digital input, such as a Pin configured as input. It is
+// implemented by Pin on all targets that support reading pins.
+type PinReader interface {
+	Get() bool
+}
+
+// DebouncedPin debounces a polled digital input, such as a button or a
+// mechanical switch, in software. It does not use interrupts or timers:
+// instead, Tick must be called periodically (for example every millisecond)
+// to sample the pin.
+//
+// The samples are integrated: a counter is incremented for every high sample
+// and decremented for every low sample, limited to the range [0, Threshold].
+// The stable state only changes to high when the counter reaches Threshold and
+// only changes to low when it reaches zero. Short glitches and contact bounce
+// therefore don't change the stable state, as long as they are shorter than
+// the threshold.
+//
+// This introduces a latency: after a clean transition, the stable state
+// follows the pin after Threshold ticks. With bouncing, it takes longer as the
+// bounces count against the counter. For example, with the default threshold
+// of 5 and a tick every millisecond, a change is reported after 5ms at the
+// earliest.
+//
+// For example, to debounce a button:
+//
+//     button := &machine.DebouncedPin{Pin: machine.BUTTON}
+//     for {
+//         button.Tick()
+//         if button.Fell() {
+//             println("pressed")
+//         }
+//         time.Sleep(time.Millisecond)
+//     }
+//
+// The initial stable state is the level read by the first call to Tick, which
+// is not reported as an edge.
+type DebouncedPin struct {
+	Pin PinReader
+
+	// Number of samples needed to change the stable state. If zero,
+	// DefaultDebounceThreshold is used.
+	Threshold uint8
+
+	counter     uint8 // integrator, in the range [0, threshold]
+	state       bool  // stable state
+	changed     bool  // the stable state changed in the last Tick
+	initialized bool  // Tick has been called at least once
+}
+
+// Tick samples the pin and updates the stable state, which is returned.
+func (d *DebouncedPin) Tick() bool {
+	threshold := d.Threshold
+	if threshold == 0 {
+		threshold = DefaultDebounceThreshold
+	}
+	level := d.Pin.Get()
+	if !d.initialized {
+		d.initialized = true
+		d.state = level
+		if level {
+			d.counter = threshold
+		}
+		return d.state
+	}
+
+	if level {
+		if d.counter < threshold {
+			d.counter++
+		}
+	} else if d.counter > 0 {
+		d.counter--
+	}
+	if d.counter > threshold {
+		// The threshold was lowered since the last tick.
+		d.counter = threshold
+	}
+
+	d.changed = false
+	if d.counter == threshold && !d.state {
+		d.state = true
+		d.changed = true
+	} else if d.counter == 0 && d.state {
+		d.state = false
+		d.changed = true
+	}
+	return d.state
+}
+
+// Get returns the stable state, as determined by the last call to Tick.
+func (d *DebouncedPin) Get() bool {
+	return d.state
+}
+
+// Rose returns whether the stable state changed from low to high in the last
+// call to Tick.
+func (d *DebouncedPin) Rose() bool {
+	return d.changed && d.state
+}
+
+// Fell returns whether the stable state changed from high to low in the last
+// call to Tick.
+func (d *DebouncedPin) Fell() bool {
+	return d.changed && !d.state
+}
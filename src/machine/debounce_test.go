@@ -0,0 +1,54 @@
+package machine
+
+import "testing"
+
+// debounceTestPin is a fake input pin that returns a predefined sequence of
+// samples.
+type debounceTestPin struct {
+	samples string // sequence of '0' and '1'
+}
+
+func (p *debounceTestPin) Get() bool {
+	level := p.samples[0] == '1'
+	p.samples = p.samples[1:]
+	return level
+}
+
+func TestDebouncedPin(t *testing.T) {
+	for _, tc := range []struct {
+		threshold uint8
+		samples   string
+		stable    string // expected stable state after each tick
+		edges     string // expected edge after each tick: rising (r), falling (f) or none (.)
+	}{
+		// A clean transition is reported after threshold samples.
+		{3, "0001111000", "0000011110", ".....r...f"},
+		// Glitches shorter than the threshold are ignored.
+		{3, "0010100110", "0000000000", ".........."},
+		// Bouncing delays the transition.
+		{3, "01011111", "00000111", ".....r.."},
+		// The initial level is not an edge, also when it is high.
+		{3, "11100011", "11111000", ".....f.."},
+		// A zero threshold uses the default.
+		{0, "0111110", "0000011", ".....r."},
+	} {
+		pin := &debounceTestPin{samples: tc.samples}
+		d := &DebouncedPin{Pin: pin, Threshold: tc.threshold}
+		for i := range tc.samples {
+			stable := d.Tick()
+			if stable != (tc.stable[i] == '1') || d.Get() != stable {
+				t.Errorf("%s: sample %d: expected stable state %c, got %v", tc.samples, i, tc.stable[i], stable)
+			}
+			edge := byte('.')
+			if d.Rose() {
+				edge = 'r'
+			}
+			if d.Fell() {
+				edge = 'f'
+			}
+			if edge != tc.edges[i] {
+				t.Errorf("%s: sample %d: expected edge %c, got %c", tc.samples, i, tc.edges[i], edge)
+			}
+		}
+	}
+}
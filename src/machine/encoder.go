@@ -0,0 +1,80 @@
+package machine
+
+import "errors"
+
+// ErrEncoderNotSupported is returned by Encoder.Configure on chips without pin
+// interrupts, where neither the hardware nor the software decoder is
+// available.
+var ErrEncoderNotSupported = errors.New("machine: quadrature encoders are not supported")
+
+// EncoderConfig is the configuration of a quadrature encoder.
+type EncoderConfig struct {
+	// The A and B outputs of the encoder, which are configured as inputs with
+	// a pull-up resistor.
+	A Pin
+	B Pin
+
+	// The optional index (Z) output of the encoder, which is active once per
+	// revolution. The position is reset to zero on every rising edge. Set it
+	// to NoPin when the encoder has no index output.
+	Index Pin
+}
+
+// Encoder reads the position of a quadrature encoder, such as a rotary
+// encoder on a motor shaft or a dial.
+//
+// Every edge of the A and B inputs is counted (4x decoding): for an encoder
+// with 24 pulses per revolution, the position changes by 96 for every
+// revolution. The position increases when A leads B and decreases when B
+// leads A. Swap the A and B pins to reverse the direction.
+//
+// Where available, the encoder is decoded in hardware, which doesn't miss any
+// edges up to very high speeds:
+//
+//   - STM32F103: A and B must be PA0 and PA1 (TIM2) or PB6 and PB7 (TIM4).
+//     TIM3 is not available, as it is used by the runtime. The inputs are
+//     filtered, so glitches shorter than about 0.1µs are ignored.
+//
+// On other pins and on other chips with pin interrupts (nrf, stm32), the
+// encoder is decoded in software using pin change interrupts on A and B. The
+// maximum rate is limited by the interrupt latency and the time needed to
+// handle an edge: roughly 20000 edges per second on a Cortex-M at 64MHz or
+// 72MHz (for example 5000 revolutions per minute for an encoder with 60 pulses
+// per revolution). Faster edges are missed or counted in the wrong direction,
+// and other interrupts with a higher priority make this worse. The software
+// decoder uses two pin interrupts, or three with an index pin: on nrf chips,
+// these use GPIOTE channels.
+//
+// The position is a signed 32-bit counter that wraps around on overflow, so
+// the difference between two positions is correct even when the counter
+// overflowed in between (when it is computed as an int32). The hardware
+// counter of the STM32 timers is only 16 bits wide: it is extended to 32 bits
+// in software every time Position is called, so Position must be called at
+// least once every 32767 edges to not lose track of the position.
+type Encoder struct {
+	config   EncoderConfig
+	state    uint8  // last levels of A and B, in the lower two bits
+	position uint32 // the position, as an uint32 so that it can be loaded atomically
+	timer    uint8  // hardware timer (target specific) or 0 for software
+	lastCNT  uint16 // last value of the hardware counter
+}
+
+// encoderTransitions is the change in position for every transition of the A
+// and B inputs, indexed by the old state shifted left by two and the new state,
+// where the state is A<<1 | B. Invalid transitions (where both inputs changed)
+// are ignored: they mean that an edge was missed.
+var encoderTransitions = [16]int8{0, -1, 1, 0, 1, 0, 0, -1, -1, 0, 0, 1, 0, 1, -1, 0}
+
+// update updates the position with the new levels of the A and B inputs. It is
+// called from the pin interrupts of the software decoder.
+func (e *Encoder) update(a, b bool) {
+	state := uint8(0)
+	if a {
+		state |= 2
+	}
+	if b {
+		state |= 1
+	}
+	e.position += uint32(encoderTransitions[e.state<<2|state])
+	e.state = state
+}
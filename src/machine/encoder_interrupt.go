@@ -0,0 +1,76 @@
+// +build nrf stm32
+
+package machine
+
+import (
+	"device/arm"
+)
+
+// Configure configures the encoder pins and starts counting at position zero.
+// See the Encoder documentation for the hardware and software decoders.
+//
+// On stm32 chips, the software decoder uses an EXTI line for each pin, which
+// is shared between pins with the same number on different ports: A, B and
+// Index must therefore have different pin numbers (for example PA0 and PB1,
+// not PA0 and PB0).
+func (e *Encoder) Configure(config EncoderConfig) error {
+	e.config = config
+	e.position = 0
+	if !e.configureHardware() {
+		e.timer = 0
+		configureEncoderInput(config.A)
+		configureEncoderInput(config.B)
+		e.state = 0
+		e.update(config.A.Get(), config.B.Get())
+		e.position = 0 // the initial state is not a step
+		callback := func(Pin) {
+			e.update(e.config.A.Get(), e.config.B.Get())
+		}
+		if err := config.A.SetInterrupt(PinToggle, callback); err != nil {
+			return err
+		}
+		if err := config.B.SetInterrupt(PinToggle, callback); err != nil {
+			config.A.SetInterrupt(PinToggle, nil)
+			return err
+		}
+	}
+	if config.Index != NoPin {
+		configureEncoderInput(config.Index)
+		return config.Index.SetInterrupt(PinRising, func(Pin) {
+			e.reset()
+		})
+	}
+	return nil
+}
+
+// Position returns the current position of the encoder, see the Encoder
+// documentation for how it is counted.
+func (e *Encoder) Position() int32 {
+	mask := arm.DisableInterrupts()
+	if e.timer != 0 {
+		// Extend the 16-bit hardware counter to 32 bits.
+		cnt := e.hardwareCount()
+		e.position += uint32(int16(cnt - e.lastCNT))
+		e.lastCNT = cnt
+	}
+	position := e.position
+	arm.EnableInterrupts(mask)
+	return int32(position)
+}
+
+// Reset sets the current position of the encoder to zero.
+func (e *Encoder) Reset() {
+	mask := arm.DisableInterrupts()
+	e.reset()
+	arm.EnableInterrupts(mask)
+}
+
+// reset sets the position to zero. It must be called with interrupts disabled
+// or from the index pin interrupt.
+func (e *Encoder) reset() {
+	if e.timer != 0 {
+		e.resetHardware()
+		e.lastCNT = 0
+	}
+	e.position = 0
+}
@@ -0,0 +1,21 @@
+// +build nrf stm32,!stm32f103xx
+
+package machine
+
+// configureHardware returns false, as there is no hardware quadrature decoder
+// support on this chip.
+func (e *Encoder) configureHardware() bool {
+	return false
+}
+
+func (e *Encoder) hardwareCount() uint16 {
+	return 0
+}
+
+func (e *Encoder) resetHardware() {
+}
+
+// configureEncoderInput configures an encoder pin as input with pull-up.
+func configureEncoderInput(p Pin) {
+	p.Configure(PinConfig{Mode: PinInputPullup})
+}
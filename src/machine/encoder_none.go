@@ -0,0 +1,19 @@
+// +build !nrf,!stm32
+
+package machine
+
+// Configure returns ErrEncoderNotSupported, as neither the hardware nor the
+// software decoder is available on this chip.
+func (e *Encoder) Configure(config EncoderConfig) error {
+	return ErrEncoderNotSupported
+}
+
+// Position returns the current position of the encoder.
+func (e *Encoder) Position() int32 {
+	return int32(e.position)
+}
+
+// Reset sets the current position of the encoder to zero.
+func (e *Encoder) Reset() {
+	e.position = 0
+}
@@ -0,0 +1,69 @@
+// +build stm32,stm32f103xx
+
+package machine
+
+import (
+	"device/stm32"
+)
+
+// configureHardware configures a timer in encoder mode, if A and B are the
+// channel 1 and 2 inputs of TIM2 or TIM4. It returns false for other pins.
+func (e *Encoder) configureHardware() bool {
+	a, b := e.config.A, e.config.B
+	switch {
+	case a == PA0 && b == PA1:
+		e.timer = 2
+		stm32.RCC.APB1ENR.SetBits(stm32.RCC_APB1ENR_TIM2EN)
+	case a == PB6 && b == PB7:
+		e.timer = 4
+		stm32.RCC.APB1ENR.SetBits(stm32.RCC_APB1ENR_TIM4EN)
+	default:
+		return false
+	}
+	configureEncoderInput(a)
+	configureEncoderInput(b)
+
+	tim := e.getTimer()
+	tim.CR1.ClearBits(stm32.TIM_CR1_CEN)
+
+	// Map TI1 to IC1 and TI2 to IC2, with a filter of 8 samples at fCK_INT
+	// (72MHz) on both inputs.
+	tim.CCMR1_Input.Set(1<<stm32.TIM_CCMR1_Input_CC1S_Pos | 3<<stm32.TIM_CCMR1_Input_IC1F_Pos |
+		1<<stm32.TIM_CCMR1_Input_CC2S_Pos | 3<<stm32.TIM_CCMR1_Input_IC2F_Pos)
+	tim.CCER.Set(0) // non-inverted inputs
+
+	// Encoder mode 3: count on both edges of both inputs.
+	tim.SMCR.Set(3 << stm32.TIM_SMCR_SMS_Pos)
+	tim.PSC.Set(0)
+	tim.ARR.Set(0xffff)
+	tim.CNT.Set(0)
+	e.lastCNT = 0
+
+	tim.CR1.SetBits(stm32.TIM_CR1_CEN)
+	return true
+}
+
+// getTimer returns the timer used by this encoder.
+func (e *Encoder) getTimer() *stm32.TIM_Type {
+	if e.timer == 4 {
+		return stm32.TIM4
+	}
+	return stm32.TIM2
+}
+
+// hardwareCount returns the value of the 16-bit hardware counter.
+func (e *Encoder) hardwareCount() uint16 {
+	return uint16(e.getTimer().CNT.Get())
+}
+
+// resetHardware sets the hardware counter to zero.
+func (e *Encoder) resetHardware() {
+	e.getTimer().CNT.Set(0)
+}
+
+// configureEncoderInput configures an encoder pin as input with pull-up: on
+// this chip, the pull direction is selected with the output data register.
+func configureEncoderInput(p Pin) {
+	p.Configure(PinConfig{Mode: PinInputModePullUpDown})
+	p.Set(true)
+}
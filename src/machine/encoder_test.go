@@ -0,0 +1,47 @@
+package machine
+
+import "testing"
+
+func TestEncoderUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		levels   string // sequence of A/B levels, starting at 00
+		position int32  // expected position afterwards
+	}{
+		// A leads B: counts up, one for every edge.
+		{"10 11 01 00", 4},
+		{"10 11 01 00 10 11 01 00", 8},
+		// B leads A: counts down.
+		{"01 11 10 00", -4},
+		// Changing direction halfway.
+		{"10 11 10 00", 0},
+		{"10 11 01 11 10", 1},
+		// Contact bounce on a single input cancels out.
+		{"10 00 10 00 10 11", 2},
+		// Invalid transitions (missed edges) are ignored.
+		{"11 00", 0},
+		{"10 01 00", 2},
+	} {
+		e := &Encoder{}
+		for i := 0; i < len(tc.levels); i += 3 {
+			e.update(tc.levels[i] == '1', tc.levels[i+1] == '1')
+		}
+		if position := int32(e.position); position != tc.position {
+			t.Errorf("%s: expected position %d, got %d", tc.levels, tc.position, position)
+		}
+	}
+}
+
+func TestEncoderOverflow(t *testing.T) {
+	// The position wraps around, but the difference stays correct.
+	e := &Encoder{position: 1<<31 - 2}
+	start := int32(e.position)
+	for _, levels := range []string{"10", "11", "01", "00"} {
+		e.update(levels[0] == '1', levels[1] == '1')
+	}
+	if position := int32(e.position); position != -1<<31+2 {
+		t.Errorf("expected the position to wrap around, got %d", position)
+	}
+	if diff := int32(e.position) - start; diff != 4 {
+		t.Errorf("expected a difference of 4, got %d", diff)
+	}
+}
@@ -9,6 +9,20 @@
 
 package machine
 
+import "errors"
+
+// i2sSampleRateTolerance is the maximum deviation of the actual sample rate
+// from the requested AudioFrequency, as a fraction: 100 means 1%. The sample
+// rate is derived from a fixed clock with an integer divider, so most rates
+// can't be generated exactly: 48kHz with 16-bit stereo samples is 0.8% slow
+// when derived from a 48MHz clock, for example. A larger deviation is
+// audible as a change in pitch, so Configure refuses it.
+const i2sSampleRateTolerance = 100
+
+// ErrI2SSampleRate is returned by I2S.Configure when the requested
+// AudioFrequency can't be generated within 1%.
+var ErrI2SSampleRate = errors.New("machine: I2S sample rate can't be generated within 1%")
+
 type I2SMode uint8
 type I2SStandard uint8
 type I2SClockSource uint8
@@ -40,6 +54,12 @@ const (
 )
 
 // All fields are optional and may not be required or used on a particular platform.
+//
+// AudioFrequency is the sample rate in Hz (48kHz by default): the number of
+// frames per second, where a frame has one sample for each channel. When the
+// serial clock is generated internally, the actual rate is the closest one the
+// hardware can generate, which is available from I2S.ActualSampleRate after
+// Configure.
 type I2SConfig struct {
 	SCK               Pin
 	WS                Pin
@@ -137,10 +137,10 @@ func (i2c I2C) Configure(config I2CConfig) {
 	avr.TWCR.Set(avr.TWCR_TWEN)
 }
 
-// Tx does a single I2C transaction at the specified address.
+// tx does a single I2C transaction at the specified address.
 // It clocks out the given address, writes the bytes in w, reads back len(r)
 // bytes and stores them in r, and generates a stop condition on the bus.
-func (i2c I2C) Tx(addr uint16, w, r []byte) error {
+func (i2c I2C) tx(addr uint16, w, r []byte) error {
 	if len(w) != 0 {
 		i2c.start(uint8(addr), true) // start transmission for writing
 		for _, b := range w {
@@ -597,18 +597,32 @@ func (i2c I2C) Configure(config I2CConfig) error {
 	return nil
 }
 
-// SetBaudRate sets the communication speed for the I2C.
+// i2cBaudOffset is the number of half SCL periods added to the BAUD value by
+// the hardware, including the rise time of the bus.
+const i2cBaudOffset = 5 + ((CPU_FREQUENCY/1000000)*riseTimeNanoseconds)/(2*1000)
+
+// SetBaudRate sets the communication speed for the I2C. The frequency is
+// rounded down to the nearest frequency the hardware supports, which is
+// clamped between roughly 91kHz and 3MHz. Use ActualFrequency to get the
+// configured frequency.
 func (i2c I2C) SetBaudRate(br uint32) {
 	// Synchronous arithmetic baudrate, via Arduino SAMD implementation:
 	// SystemCoreClock / ( 2 * baudrate) - 5 - (((SystemCoreClock / 1000000) * WIRE_RISE_TIME_NANOSECONDS) / (2 * 1000));
-	baud := CPU_FREQUENCY/(2*br) - 5 - (((CPU_FREQUENCY / 1000000) * riseTimeNanoseconds) / (2 * 1000))
-	i2c.Bus.BAUD.Set(baud)
+	divider := divideClock(CPU_FREQUENCY/2, br, i2cBaudOffset, 0xff+i2cBaudOffset)
+	i2c.Bus.BAUD.Set(divider - i2cBaudOffset)
 }
 
-// Tx does a single I2C transaction at the specified address.
+// ActualFrequency returns the I2C bus frequency in Hz as configured in the
+// hardware, which may be lower than the frequency passed to Configure.
+func (i2c I2C) ActualFrequency() uint32 {
+	baud := i2c.Bus.BAUD.Get() & 0xff
+	return CPU_FREQUENCY / (2 * (baud + i2cBaudOffset))
+}
+
+// tx does a single I2C transaction at the specified address.
 // It clocks out the given address, writes the bytes in w, reads back len(r)
 // bytes and stores them in r, and generates a stop condition on the bus.
-func (i2c I2C) Tx(addr uint16, w, r []byte) error {
+func (i2c I2C) tx(addr uint16, w, r []byte) error {
 	var err error
 	if len(w) != 0 {
 		// send start/address for write
@@ -767,9 +781,21 @@ type I2S struct {
 	Bus *sam.I2S_Type
 }
 
+// i2sSampleRate is the sample rate of the I2S bus as configured by the last
+// call to Configure. There is only one I2S peripheral on the SAMD21.
+var i2sSampleRate uint32
+
 // Configure is used to configure the I2S interface. You must call this
 // before you can use the I2S bus.
-func (i2s I2S) Configure(config I2SConfig) {
+//
+// With I2SClockSourceInternal, the serial clock is derived from the 48MHz
+// DFLL through generic clock generator 3, which has an 8-bit integer divider.
+// The divider is rounded to the nearest value, so the actual sample rate may be
+// slightly above or below the requested rate. It returns ErrI2SSampleRate,
+// without changing the configuration, when the actual rate would deviate by
+// more than 1%. With I2SClockSourceExternal, the other device sets the sample
+// rate, so it is not checked.
+func (i2s I2S) Configure(config I2SConfig) error {
 	// handle defaults
 	if config.SCK == 0 {
 		config.SCK = I2S_SCK_PIN
@@ -789,12 +815,28 @@ func (i2s I2S) Configure(config I2SConfig) {
 		}
 	}
 
+	// Calculate the clock divider for the sample rate. The serial clock
+	// transfers one slot of DataFormat bits per channel in each frame.
+	slots := uint32(1)
+	if config.Stereo {
+		slots = 2
+	}
+	bitsPerFrame := uint32(config.DataFormat) * slots
+	division_factor := divideClockNearest(CPU_FREQUENCY, config.AudioFrequency*bitsPerFrame, 1, 0xff)
+	if config.ClockSource == I2SClockSourceInternal {
+		sampleRate := CPU_FREQUENCY / division_factor / bitsPerFrame
+		if !clockWithinTolerance(sampleRate, config.AudioFrequency, i2sSampleRateTolerance) {
+			return ErrI2SSampleRate
+		}
+		i2sSampleRate = sampleRate
+	} else {
+		// The sample rate is not known.
+		i2sSampleRate = 0
+	}
+
 	// Turn on clock for I2S
 	sam.PM.APBCMASK.SetBits(sam.PM_APBCMASK_I2S_)
 
-	// setting clock rate for sample.
-	division_factor := CPU_FREQUENCY / (config.AudioFrequency * uint32(config.DataFormat))
-
 	// Switch Generic Clock Generator 3 to DFLL48M.
 	sam.GCLK.GENDIV.Set((sam.GCLK_CLKCTRL_GEN_GCLK3 << sam.GCLK_GENDIV_ID_Pos) |
 		(division_factor << sam.GCLK_GENDIV_DIV_Pos))
@@ -926,6 +968,16 @@ func (i2s I2S) Configure(config I2SConfig) {
 	i2s.Bus.CTRLA.SetBits(sam.I2S_CTRLA_SEREN1)
 	for i2s.Bus.SYNCBUSY.HasBits(sam.I2S_SYNCBUSY_SEREN1) {
 	}
+
+	return nil
+}
+
+// ActualSampleRate returns the sample rate in Hz that the I2S bus runs at,
+// which may differ slightly from the requested AudioFrequency (see
+// Configure). It returns 0 if the bus has not been configured, or if it uses
+// I2SClockSourceExternal.
+func (i2s I2S) ActualSampleRate() uint32 {
+	return i2sSampleRate
 }
 
 // Read data from the I2S bus into the provided slice.
@@ -1006,6 +1058,10 @@ type SPIConfig struct {
 }
 
 // Configure is intended to setup the SPI interface.
+//
+// The SPI clock is the CPU clock divided by an even number between 2 and 512.
+// The frequency is rounded down to the nearest frequency that can be reached
+// this way. Use ActualFrequency to get the configured frequency.
 func (spi SPI) Configure(config SPIConfig) error {
 	// Use default pins if not set.
 	if config.SCK == 0 && config.MOSI == 0 && config.MISO == 0 {
@@ -1113,7 +1169,7 @@ func (spi SPI) Configure(config SPIConfig) error {
 	}
 
 	// Set synch speed for SPI
-	baudRate := (CPU_FREQUENCY / (2 * config.Frequency)) - 1
+	baudRate := divideClock(CPU_FREQUENCY/2, config.Frequency, 1, 256) - 1
 	spi.Bus.BAUD.Set(uint8(baudRate))
 
 	// Enable SPI port.
@@ -1124,8 +1180,14 @@ func (spi SPI) Configure(config SPIConfig) error {
 	return nil
 }
 
-// Transfer writes/reads a single byte using the SPI interface.
-func (spi SPI) Transfer(w byte) (byte, error) {
+// ActualFrequency returns the SPI clock frequency in Hz as configured in the
+// hardware, which may be lower than the frequency passed to Configure.
+func (spi SPI) ActualFrequency() uint32 {
+	return CPU_FREQUENCY / (2 * (uint32(spi.Bus.BAUD.Get()) + 1))
+}
+
+// transfer writes/reads a single byte using the SPI interface.
+func (spi SPI) transfer(w byte) (byte, error) {
 	// write data
 	spi.Bus.DATA.Set(uint32(w))
 
@@ -1390,12 +1452,17 @@ var (
 	udd_ep_in_cache_buffer  [7][128]uint8
 	udd_ep_out_cache_buffer [7][128]uint8
 
+	// Data sent on the control endpoint, which may not fit in a regular
+	// endpoint buffer (see SetUSBControlHandler and usb_midi.go).
+	udd_ep_control_in_buffer [256]uint8
+
 	isEndpointHalt        = false
 	isRemoteWakeUpEnabled = false
-	endPoints             = []uint32{usb_ENDPOINT_TYPE_CONTROL,
+	endPoints             = append([]uint32{usb_ENDPOINT_TYPE_CONTROL,
 		(usb_ENDPOINT_TYPE_INTERRUPT | usbEndpointIn),
 		(usb_ENDPOINT_TYPE_BULK | usbEndpointOut),
-		(usb_ENDPOINT_TYPE_BULK | usbEndpointIn)}
+		(usb_ENDPOINT_TYPE_BULK | usbEndpointIn)},
+		midiEndPoints...)
 
 	usbConfiguration uint8
 	usbSetInterface  uint8
@@ -1525,11 +1592,12 @@ func handleUSB() {
 		if (setup.bmRequestType & usb_REQUEST_TYPE) == usb_REQUEST_STANDARD {
 			// Standard Requests
 			ok = handleStandardSetup(setup)
-		} else {
+		} else if (setup.bmRequestType&usb_REQUEST_TYPE) == usb_REQUEST_CLASS && setup.wIndex == usb_CDC_ACM_INTERFACE {
 			// Class Interface Requests
-			if setup.wIndex == usb_CDC_ACM_INTERFACE {
-				ok = cdcSetup(setup)
-			}
+			ok = cdcSetup(setup)
+		} else {
+			// Other class and vendor requests, see SetUSBControlHandler
+			ok = handleControlRequest(setup)
 		}
 
 		if ok {
@@ -1560,6 +1628,9 @@ func handleUSB() {
 			case usb_CDC_ENDPOINT_OUT:
 				handleEndpoint(i)
 				setEPINTFLAG(i, epFlags)
+			case usb_MIDI_ENDPOINT_OUT:
+				handleMIDIEndpoint(i)
+				setEPINTFLAG(i, epFlags)
 			case usb_CDC_ENDPOINT_IN, usb_CDC_ENDPOINT_ACM:
 				setEPSTATUSCLR(i, sam.USB_DEVICE_EPSTATUSCLR_BK1RDY)
 				setEPINTFLAG(i, sam.USB_DEVICE_EPINTFLAG_TRCPT1)
@@ -1733,6 +1804,11 @@ func handleStandardSetup(setup usbSetup) bool {
 			// Enable interrupt for CDC data messages from host
 			setEPINTENSET(usb_CDC_ENDPOINT_OUT, sam.USB_DEVICE_EPINTENSET_TRCPT0)
 
+			if usbMIDIEnabled {
+				// Enable interrupt for MIDI events from host
+				setEPINTENSET(usb_MIDI_ENDPOINT_OUT, sam.USB_DEVICE_EPINTENSET_TRCPT0)
+			}
+
 			sendZlp(0)
 			return true
 		} else {
@@ -1807,10 +1883,14 @@ func cdcSetup(setup usbSetup) bool {
 }
 
 func sendUSBPacket(ep uint32, data []byte) {
-	copy(udd_ep_in_cache_buffer[ep][:], data)
+	buf := udd_ep_in_cache_buffer[ep][:]
+	if ep == 0 {
+		buf = udd_ep_control_in_buffer[:]
+	}
+	copy(buf, data)
 
 	// Set endpoint address for sending data
-	usbEndpointDescriptors[ep].DeviceDescBank[1].ADDR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_in_cache_buffer[ep]))))
+	usbEndpointDescriptors[ep].DeviceDescBank[1].ADDR.Set(uint32(uintptr(unsafe.Pointer(&buf[0]))))
 
 	// clear multi-packet size which is total bytes already sent
 	usbEndpointDescriptors[ep].DeviceDescBank[1].PCKSIZE.ClearBits(usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Mask << usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Pos)
@@ -1930,8 +2010,8 @@ func sendDescriptor(setup usbSetup) {
 // sendConfiguration creates and sends the configuration packet to the host.
 func sendConfiguration(setup usbSetup) {
 	if setup.wLength == 9 {
-		sz := uint16(configDescriptorSize + cdcSize)
-		config := NewConfigDescriptor(sz, 2)
+		sz := uint16(configDescriptorSize + cdcSize + midiSize)
+		config := NewConfigDescriptor(sz, 2+midiInterfaces)
 		sendUSBPacket(0, config.Bytes())
 	} else {
 		iad := NewIADDescriptor(0, 2, usb_CDC_COMMUNICATION_INTERFACE_CLASS, usb_CDC_ABSTRACT_CONTROL_MODEL, 0)
@@ -1965,12 +2045,13 @@ func sendConfiguration(setup usbSetup) {
 			out,
 			in)
 
-		sz := uint16(configDescriptorSize + cdcSize)
-		config := NewConfigDescriptor(sz, 2)
+		sz := uint16(configDescriptorSize + cdcSize + midiSize)
+		config := NewConfigDescriptor(sz, 2+midiInterfaces)
 
 		buf := make([]byte, 0, sz)
 		buf = append(buf, config.Bytes()...)
 		buf = append(buf, cdc.Bytes()...)
+		buf = append(buf, midiDescriptor()...)
 
 		sendUSBPacket(0, buf)
 	}
@@ -33,6 +33,21 @@ func (p Pin) Set(high bool) {
 	}
 }
 
+// SetFast sets the pin to high with a single store to the OUTSET register,
+// without any checks. This is intended for use in interrupt handlers where
+// jitter must be minimal. The pin must have been configured as an output pin.
+//go:inline
+func (p Pin) SetFast() {
+	sam.PORT.OUTSET0.Set(1 << uint8(p))
+}
+
+// ClearFast sets the pin to low with a single store to the OUTCLR register. See
+// SetFast.
+//go:inline
+func (p Pin) ClearFast() {
+	sam.PORT.OUTCLR0.Set(1 << uint8(p))
+}
+
 // Get returns the current value of a GPIO pin.
 func (p Pin) Get() bool {
 	return (sam.PORT.IN0.Get()>>uint8(p))&1 > 0
@@ -9,6 +9,8 @@ package machine
 
 import (
 	"device/sam"
+	"runtime/volatile"
+	"unsafe"
 )
 
 // Return the register and mask to enable a given GPIO pin. This can be used to
@@ -49,6 +51,30 @@ func (p Pin) Set(high bool) {
 	}
 }
 
+// SetFast sets the pin to high with a single store to the OUTSET register. It
+// does not check whether the pin is valid and doesn't branch on the port
+// number, so it is intended for use in interrupt handlers where jitter must be
+// minimal. The pin must have been configured as an output pin.
+//go:inline
+func (p Pin) SetFast() {
+	p.portRegister(&sam.PORT.OUTSET0).Set(1 << uint8(p&31))
+}
+
+// ClearFast sets the pin to low with a single store to the OUTCLR register. See
+// SetFast.
+//go:inline
+func (p Pin) ClearFast() {
+	p.portRegister(&sam.PORT.OUTCLR0).Set(1 << uint8(p&31))
+}
+
+// portRegister returns the register for the port of this pin, given the
+// register of port A. The registers of port B are 0x80 bytes after those of
+// port A.
+//go:inline
+func (p Pin) portRegister(reg *volatile.Register32) *volatile.Register32 {
+	return (*volatile.Register32)(unsafe.Pointer(uintptr(unsafe.Pointer(reg)) + uintptr(p>>5)*0x80))
+}
+
 // Get returns the current value of a GPIO pin.
 func (p Pin) Get() bool {
 	if p < 32 {
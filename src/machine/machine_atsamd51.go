@@ -234,23 +234,7 @@ func (a ADC) Configure() {
 // Get returns the current value of a ADC pin, in the range 0..0xffff.
 func (a ADC) Get() uint16 {
 	bus := a.getADCBus()
-	ch := a.getADCChannel()
-
-	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_INPUTCTRL) {
-	}
-
-	// Selection for the positive ADC input
-	bus.INPUTCTRL.ClearBits(sam.ADC_INPUTCTRL_MUXPOS_Msk)
-	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_ENABLE) {
-	}
-	bus.INPUTCTRL.SetBits(uint16(ch << sam.ADC_INPUTCTRL_MUXPOS_Pos))
-	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_ENABLE) {
-	}
-
-	// Enable ADC
-	bus.CTRLA.SetBits(sam.ADC_CTRLA_ENABLE)
-	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_ENABLE) {
-	}
+	a.enable(bus)
 
 	// Start conversion
 	bus.SWTRIG.SetBits(sam.ADC_SWTRIG_START)
@@ -270,14 +254,98 @@ func (a ADC) Get() uint16 {
 	}
 	val := bus.RESULT.Get()
 
-	// Disable ADC
+	disableADC(bus)
+
+	return uint16(val) << 4 // scales from 12 to 16-bit result
+}
+
+// ErrADCBusy is returned by StartConversion when a conversion is already in
+// progress.
+var ErrADCBusy = errors.New("machine: ADC conversion in progress")
+
+// State of the conversion started by StartConversion.
+var (
+	adcCallback func(value uint16) // nil when no conversion is in progress
+	adcDiscard  bool               // the current conversion is the invalid first one
+)
+
+// StartConversion starts a single conversion of the ADC pin, like Get, but
+// returns immediately instead of waiting for the result. When the conversion
+// is done, the callback is called with the value in the range 0..0xffff. This
+// allows the CPU to do other work while the conversion runs. Only one
+// conversion can be in progress at a time: ErrADCBusy is returned when
+// StartConversion is called before the previous callback has run. Get must not
+// be called while a conversion is in progress.
+//
+// The callback is called from the ADC interrupt, which means it must be short
+// and it must not block or allocate heap memory. It may start a new conversion.
+// To wait for the result in a goroutine, store it in a variable and set a
+// volatile flag (see the runtime/volatile package) that the goroutine polls.
+func (a ADC) StartConversion(callback func(value uint16)) error {
+	if adcCallback != nil {
+		return ErrADCBusy
+	}
+	adcCallback = callback
+	adcDiscard = true
+
+	bus := a.getADCBus()
+	a.enable(bus)
+	bus.INTFLAG.SetBits(sam.ADC_INTFLAG_RESRDY)
+	bus.INTENSET.SetBits(sam.ADC_INTENSET_RESRDY)
+	arm.EnableIRQ(sam.IRQ_ADC0_RESRDY)
+	bus.SWTRIG.SetBits(sam.ADC_SWTRIG_START)
+	return nil
+}
+
+//go:export ADC0_RESRDY_IRQHandler
+func handleADC0ResultReady() {
+	bus := sam.ADC0
+	val := bus.RESULT.Get()
+	bus.INTFLAG.SetBits(sam.ADC_INTFLAG_RESRDY)
+	if adcDiscard {
+		// The first conversion after enabling the ADC is invalid, see Get.
+		adcDiscard = false
+		bus.SWTRIG.SetBits(sam.ADC_SWTRIG_START)
+		return
+	}
+	bus.INTENCLR.SetBits(sam.ADC_INTENCLR_RESRDY)
+	disableADC(bus)
+
+	// Clear the callback before calling it, so that it can start a new
+	// conversion.
+	callback := adcCallback
+	adcCallback = nil
+	callback(uint16(val) << 4) // scales from 12 to 16-bit result
+}
+
+// enable selects the ADC pin as the positive input and enables the ADC.
+func (a ADC) enable(bus *sam.ADC_Type) {
+	ch := a.getADCChannel()
+
+	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_INPUTCTRL) {
+	}
+
+	// Selection for the positive ADC input
+	bus.INPUTCTRL.ClearBits(sam.ADC_INPUTCTRL_MUXPOS_Msk)
 	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_ENABLE) {
 	}
-	bus.CTRLA.ClearBits(sam.ADC_CTRLA_ENABLE)
+	bus.INPUTCTRL.SetBits(uint16(ch << sam.ADC_INPUTCTRL_MUXPOS_Pos))
 	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_ENABLE) {
 	}
 
-	return uint16(val) << 4 // scales from 12 to 16-bit result
+	// Enable ADC
+	bus.CTRLA.SetBits(sam.ADC_CTRLA_ENABLE)
+	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_ENABLE) {
+	}
+}
+
+// disableADC disables the ADC after a conversion.
+func disableADC(bus *sam.ADC_Type) {
+	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_ENABLE) {
+	}
+	bus.CTRLA.ClearBits(sam.ADC_CTRLA_ENABLE)
+	for bus.SYNCBUSY.HasBits(sam.ADC_SYNCBUSY_ENABLE) {
+	}
 }
 
 func (a ADC) getADCBus() *sam.ADC_Type {
@@ -572,18 +640,28 @@ func (i2c I2C) Configure(config I2CConfig) {
 	i2c.SCL.Configure(PinConfig{Mode: i2c.PinMode})
 }
 
-// SetBaudRate sets the communication speed for the I2C.
+// SetBaudRate sets the communication speed for the I2C. The frequency is
+// rounded down to the nearest frequency the hardware supports, which is
+// clamped between roughly 94kHz and 24MHz. Use ActualFrequency to get the
+// configured frequency.
 func (i2c I2C) SetBaudRate(br uint32) {
 	// Synchronous arithmetic baudrate, via Adafruit SAMD51 implementation:
 	// sercom->I2CM.BAUD.bit.BAUD = SERCOM_FREQ_REF / ( 2 * baudrate) - 1 ;
-	baud := SERCOM_FREQ_REF/(2*br) - 1
+	baud := divideClock(SERCOM_FREQ_REF/2, br, 1, 256) - 1
 	i2c.Bus.BAUD.Set(baud)
 }
 
-// Tx does a single I2C transaction at the specified address.
+// ActualFrequency returns the I2C bus frequency in Hz as configured in the
+// hardware, which may be lower than the frequency passed to Configure.
+func (i2c I2C) ActualFrequency() uint32 {
+	baud := i2c.Bus.BAUD.Get() & 0xff
+	return SERCOM_FREQ_REF / (2 * (baud + 1))
+}
+
+// tx does a single I2C transaction at the specified address.
 // It clocks out the given address, writes the bytes in w, reads back len(r)
 // bytes and stores them in r, and generates a stop condition on the bus.
-func (i2c I2C) Tx(addr uint16, w, r []byte) error {
+func (i2c I2C) tx(addr uint16, w, r []byte) error {
 	var err error
 	if len(w) != 0 {
 		// send start/address for write
@@ -756,6 +834,11 @@ type SPIConfig struct {
 }
 
 // Configure is intended to setup the SPI interface.
+//
+// The SPI clock is the SERCOM reference clock divided by an even number
+// between 2 and 512. The frequency is rounded down to the nearest frequency
+// that can be reached this way. Use ActualFrequency to get the configured
+// frequency.
 func (spi SPI) Configure(config SPIConfig) {
 	config.SCK = spi.SCK
 	config.MOSI = spi.MOSI
@@ -823,7 +906,7 @@ func (spi SPI) Configure(config SPIConfig) {
 	}
 
 	// Set synch speed for SPI
-	baudRate := SERCOM_FREQ_REF / (2 * config.Frequency)
+	baudRate := divideClock(SERCOM_FREQ_REF/2, config.Frequency, 1, 256) - 1
 	spi.Bus.BAUD.Set(uint8(baudRate))
 
 	// Enable SPI port.
@@ -832,8 +915,14 @@ func (spi SPI) Configure(config SPIConfig) {
 	}
 }
 
-// Transfer writes/reads a single byte using the SPI interface.
-func (spi SPI) Transfer(w byte) (byte, error) {
+// ActualFrequency returns the SPI clock frequency in Hz as configured in the
+// hardware, which may be lower than the frequency passed to Configure.
+func (spi SPI) ActualFrequency() uint32 {
+	return SERCOM_FREQ_REF / (2 * (uint32(spi.Bus.BAUD.Get()) + 1))
+}
+
+// transfer writes/reads a single byte using the SPI interface.
+func (spi SPI) transfer(w byte) (byte, error) {
 	// write data
 	spi.Bus.DATA.Set(uint32(w))
 
@@ -863,26 +952,7 @@ func InitPWM() {
 
 // Configure configures a PWM pin for output.
 func (pwm PWM) Configure() {
-	// Set pin as output
-	sam.PORT.GROUP[0].DIRSET.Set(1 << uint8(pwm.Pin))
-	// Set pin to low
-	sam.PORT.GROUP[0].OUTCLR.Set(1 << uint8(pwm.Pin))
-
-	// Enable the port multiplexer for pin
-	pwm.setPinCfg(sam.PORT_GROUP_PINCFG_PMUXEN)
-
-	// Connect timer/mux to pin.
-	pwmConfig := pwm.getMux()
-
-	if pwm.Pin&1 > 0 {
-		// odd pin, so save the even pins
-		val := pwm.getPMux() & sam.PORT_GROUP_PMUX_PMUXE_Msk
-		pwm.setPMux(val | uint8(pwmConfig<<sam.PORT_GROUP_PMUX_PMUXO_Pos))
-	} else {
-		// even pin, so save the odd pins
-		val := pwm.getPMux() & sam.PORT_GROUP_PMUX_PMUXO_Msk
-		pwm.setPMux(val | uint8(pwmConfig<<sam.PORT_GROUP_PMUX_PMUXE_Pos))
-	}
+	pwm.configurePin()
 
 	// figure out which TCCX timer for this pin
 	timer := pwm.getTimer()
@@ -930,6 +1000,30 @@ func (pwm PWM) Configure() {
 	}
 }
 
+// configurePin connects the pin to the timer used for PWM on this pin.
+func (pwm PWM) configurePin() {
+	// Set pin as output
+	sam.PORT.GROUP[0].DIRSET.Set(1 << uint8(pwm.Pin))
+	// Set pin to low
+	sam.PORT.GROUP[0].OUTCLR.Set(1 << uint8(pwm.Pin))
+
+	// Enable the port multiplexer for pin
+	pwm.setPinCfg(sam.PORT_GROUP_PINCFG_PMUXEN)
+
+	// Connect timer/mux to pin.
+	pwmConfig := pwm.getMux()
+
+	if pwm.Pin&1 > 0 {
+		// odd pin, so save the even pins
+		val := pwm.getPMux() & sam.PORT_GROUP_PMUX_PMUXE_Msk
+		pwm.setPMux(val | uint8(pwmConfig<<sam.PORT_GROUP_PMUX_PMUXO_Pos))
+	} else {
+		// even pin, so save the odd pins
+		val := pwm.getPMux() & sam.PORT_GROUP_PMUX_PMUXO_Msk
+		pwm.setPMux(val | uint8(pwmConfig<<sam.PORT_GROUP_PMUX_PMUXE_Pos))
+	}
+}
+
 // Set turns on the duty cycle for a PWM pin using the provided value.
 func (pwm PWM) Set(value uint16) {
 	// figure out which TCCX timer for this pin
@@ -1152,12 +1246,17 @@ var (
 	udd_ep_in_cache_buffer  [7][128]uint8
 	udd_ep_out_cache_buffer [7][128]uint8
 
+	// Data sent on the control endpoint, which may not fit in a regular
+	// endpoint buffer (see SetUSBControlHandler and usb_midi.go).
+	udd_ep_control_in_buffer [256]uint8
+
 	isEndpointHalt        = false
 	isRemoteWakeUpEnabled = false
-	endPoints             = []uint32{usb_ENDPOINT_TYPE_CONTROL,
+	endPoints             = append([]uint32{usb_ENDPOINT_TYPE_CONTROL,
 		(usb_ENDPOINT_TYPE_INTERRUPT | usbEndpointIn),
 		(usb_ENDPOINT_TYPE_BULK | usbEndpointOut),
-		(usb_ENDPOINT_TYPE_BULK | usbEndpointIn)}
+		(usb_ENDPOINT_TYPE_BULK | usbEndpointIn)},
+		midiEndPoints...)
 
 	usbConfiguration uint8
 	usbSetInterface  uint8
@@ -1309,11 +1408,12 @@ func handleUSBIRQ() {
 		if (setup.bmRequestType & usb_REQUEST_TYPE) == usb_REQUEST_STANDARD {
 			// Standard Requests
 			ok = handleStandardSetup(setup)
-		} else {
+		} else if (setup.bmRequestType&usb_REQUEST_TYPE) == usb_REQUEST_CLASS && setup.wIndex == usb_CDC_ACM_INTERFACE {
 			// Class Interface Requests
-			if setup.wIndex == usb_CDC_ACM_INTERFACE {
-				ok = cdcSetup(setup)
-			}
+			ok = cdcSetup(setup)
+		} else {
+			// Other class and vendor requests, see SetUSBControlHandler
+			ok = handleControlRequest(setup)
 		}
 
 		if ok {
@@ -1344,6 +1444,9 @@ func handleUSBIRQ() {
 			case usb_CDC_ENDPOINT_OUT:
 				handleEndpoint(i)
 				setEPINTFLAG(i, epFlags)
+			case usb_MIDI_ENDPOINT_OUT:
+				handleMIDIEndpoint(i)
+				setEPINTFLAG(i, epFlags)
 			case usb_CDC_ENDPOINT_IN, usb_CDC_ENDPOINT_ACM:
 				setEPSTATUSCLR(i, sam.USB_DEVICE_ENDPOINT_EPSTATUSCLR_BK1RDY)
 				setEPINTFLAG(i, sam.USB_DEVICE_ENDPOINT_EPINTFLAG_TRCPT1)
@@ -1517,6 +1620,11 @@ func handleStandardSetup(setup usbSetup) bool {
 			// Enable interrupt for CDC data messages from host
 			setEPINTENSET(usb_CDC_ENDPOINT_OUT, sam.USB_DEVICE_ENDPOINT_EPINTENSET_TRCPT0)
 
+			if usbMIDIEnabled {
+				// Enable interrupt for MIDI events from host
+				setEPINTENSET(usb_MIDI_ENDPOINT_OUT, sam.USB_DEVICE_ENDPOINT_EPINTENSET_TRCPT0)
+			}
+
 			sendZlp(0)
 			return true
 		} else {
@@ -1591,10 +1699,14 @@ func cdcSetup(setup usbSetup) bool {
 }
 
 func sendUSBPacket(ep uint32, data []byte) {
-	copy(udd_ep_in_cache_buffer[ep][:], data)
+	buf := udd_ep_in_cache_buffer[ep][:]
+	if ep == 0 {
+		buf = udd_ep_control_in_buffer[:]
+	}
+	copy(buf, data)
 
 	// Set endpoint address for sending data
-	usbEndpointDescriptors[ep].DeviceDescBank[1].ADDR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_in_cache_buffer[ep]))))
+	usbEndpointDescriptors[ep].DeviceDescBank[1].ADDR.Set(uint32(uintptr(unsafe.Pointer(&buf[0]))))
 
 	// clear multi-packet size which is total bytes already sent
 	usbEndpointDescriptors[ep].DeviceDescBank[1].PCKSIZE.ClearBits(usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Mask << usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Pos)
@@ -1714,8 +1826,8 @@ func sendDescriptor(setup usbSetup) {
 // sendConfiguration creates and sends the configuration packet to the host.
 func sendConfiguration(setup usbSetup) {
 	if setup.wLength == 9 {
-		sz := uint16(configDescriptorSize + cdcSize)
-		config := NewConfigDescriptor(sz, 2)
+		sz := uint16(configDescriptorSize + cdcSize + midiSize)
+		config := NewConfigDescriptor(sz, 2+midiInterfaces)
 		sendUSBPacket(0, config.Bytes())
 	} else {
 		iad := NewIADDescriptor(0, 2, usb_CDC_COMMUNICATION_INTERFACE_CLASS, usb_CDC_ABSTRACT_CONTROL_MODEL, 0)
@@ -1749,12 +1861,13 @@ func sendConfiguration(setup usbSetup) {
 			out,
 			in)
 
-		sz := uint16(configDescriptorSize + cdcSize)
-		config := NewConfigDescriptor(sz, 2)
+		sz := uint16(configDescriptorSize + cdcSize + midiSize)
+		config := NewConfigDescriptor(sz, 2+midiInterfaces)
 
 		buf := make([]byte, 0)
 		buf = append(buf, config.Bytes()...)
 		buf = append(buf, cdc.Bytes()...)
+		buf = append(buf, midiDescriptor()...)
 
 		sendUSBPacket(0, buf)
 	}
@@ -0,0 +1,53 @@
+// +build sam,atsamd51,usbmidi
+
+package machine
+
+import (
+	"device/sam"
+	"errors"
+)
+
+// SendEvent sends a single USB-MIDI event packet to the host. Events are
+// dropped when the device has not been configured by the host.
+func (midi USBMIDI) SendEvent(e MIDIEvent) error {
+	if usbConfiguration == 0 {
+		return nil
+	}
+
+	// set the data and the count of bytes to be sent
+	sendUSBPacket(usb_MIDI_ENDPOINT_IN, e[:])
+
+	// clear transfer complete flag
+	setEPINTFLAG(usb_MIDI_ENDPOINT_IN, sam.USB_DEVICE_ENDPOINT_EPINTFLAG_TRCPT1)
+
+	// send data by setting bank ready
+	setEPSTATUSSET(usb_MIDI_ENDPOINT_IN, sam.USB_DEVICE_ENDPOINT_EPSTATUSSET_BK1RDY)
+
+	// wait for transfer to complete
+	timeout := 3000
+	for (getEPINTFLAG(usb_MIDI_ENDPOINT_IN) & sam.USB_DEVICE_ENDPOINT_EPINTFLAG_TRCPT1) == 0 {
+		timeout--
+		if timeout == 0 {
+			return errors.New("USBMIDI send event timeout")
+		}
+	}
+	return nil
+}
+
+func handleMIDIEndpoint(ep uint32) {
+	// get data
+	count := int((usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.Get() >>
+		usb_DEVICE_PCKSIZE_BYTE_COUNT_Pos) & usb_DEVICE_PCKSIZE_BYTE_COUNT_Mask)
+
+	// move events to ring buffer
+	MIDI0.receive(udd_ep_out_cache_buffer[ep][:count])
+
+	// set byte count to zero
+	usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.ClearBits(usb_DEVICE_PCKSIZE_BYTE_COUNT_Mask << usb_DEVICE_PCKSIZE_BYTE_COUNT_Pos)
+
+	// set multi packet size to 64
+	usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(64 << usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Pos)
+
+	// set ready for next data
+	setEPSTATUSCLR(ep, sam.USB_DEVICE_ENDPOINT_EPSTATUSCLR_BK0RDY)
+}
@@ -49,6 +49,25 @@ func (p Pin) Set(high bool) {
 	}
 }
 
+// SetFast sets the pin to high with a single store to the OUTSET register. It
+// does not check whether the pin is valid and compiles to very few
+// instructions without branches, so it is intended for use in interrupt
+// handlers where jitter must be minimal. The pin must have been configured as
+// an output pin.
+//go:inline
+//go:nobounds
+func (p Pin) SetFast() {
+	sam.PORT.GROUP[p>>5].OUTSET.Set(1 << uint8(p&31))
+}
+
+// ClearFast sets the pin to low with a single store to the OUTCLR register. See
+// SetFast.
+//go:inline
+//go:nobounds
+func (p Pin) ClearFast() {
+	sam.PORT.GROUP[p>>5].OUTCLR.Set(1 << uint8(p&31))
+}
+
 // Get returns the current value of a GPIO pin.
 func (p Pin) Get() bool {
 	if p < 32 {
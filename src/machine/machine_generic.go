@@ -56,8 +56,8 @@ func (spi SPI) Configure(config SPIConfig) {
 	spiConfigure(spi.Bus, config.SCK, config.MOSI, config.MISO)
 }
 
-// Transfer writes/reads a single byte using the SPI interface.
-func (spi SPI) Transfer(w byte) (byte, error) {
+// transfer writes/reads a single byte using the SPI interface.
+func (spi SPI) transfer(w byte) (byte, error) {
 	return spiTransfer(spi.Bus, w), nil
 }
 
@@ -118,8 +118,8 @@ func (i2c I2C) Configure(config I2CConfig) {
 	i2cConfigure(i2c.Bus, config.SCL, config.SDA)
 }
 
-// Tx does a single I2C transaction at the specified address.
-func (i2c I2C) Tx(addr uint16, w, r []byte) error {
+// tx does a single I2C transaction at the specified address.
+func (i2c I2C) tx(addr uint16, w, r []byte) error {
 	i2cTransfer(i2c.Bus, &w[0], len(w), &r[0], len(r))
 	// TODO: do something with the returned error code.
 	return nil
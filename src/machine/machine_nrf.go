@@ -10,6 +10,7 @@ import (
 
 var (
 	ErrTxInvalidSliceSize = errors.New("SPI write and read slices must be same size")
+	ErrNoPinChangeChannel = errors.New("machine: no channel available for pin interrupt")
 )
 
 type PinMode uint8
@@ -39,6 +40,23 @@ func (p Pin) Set(high bool) {
 	}
 }
 
+// SetFast sets the pin to high with a single store to the OUTSET register,
+// without any checks. This is intended for use in interrupt handlers where
+// jitter must be minimal. The pin must have been configured as an output pin.
+//go:inline
+func (p Pin) SetFast() {
+	port, pin := p.getPortPin()
+	port.OUTSET.Set(1 << pin)
+}
+
+// ClearFast sets the pin to low with a single store to the OUTCLR register. See
+// SetFast.
+//go:inline
+func (p Pin) ClearFast() {
+	port, pin := p.getPortPin()
+	port.OUTCLR.Set(1 << pin)
+}
+
 // Return the register and mask to enable a given GPIO pin. This can be used to
 // implement bit-banged drivers.
 func (p Pin) PortMaskSet() (*uint32, uint32) {
@@ -59,6 +77,126 @@ func (p Pin) Get() bool {
 	return (port.IN.Get()>>pin)&1 != 0
 }
 
+// Callbacks for edge-triggered interrupts, one for each GPIOTE channel.
+var pinCallbacks [len(nrf.GPIOTE.CONFIG)]func(Pin)
+
+// SetInterrupt sets an interrupt to be executed when a particular pin changes
+// state. The callback is executed in interrupt context.
+//
+// Edge-triggered interrupts (PinRising, PinFalling, PinToggle) use one of the
+// GPIOTE channels. Level-triggered interrupts (PinLevelHigh, PinLevelLow) use
+// the SENSE feature of the pin: the callback is called for as long as the pin
+// stays at the given level. This means the callback must clear the interrupt
+// source (for example, by reading the status register of the sensor that holds
+// the line) before returning, or the CPU will be stuck servicing the same
+// interrupt over and over again.
+//
+// This call will replace a previously set callback on this pin. You can pass a
+// nil func to unset the pin change interrupt. If you do so, the change
+// parameter is only used to determine whether the edge or level interrupt must
+// be removed.
+func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
+	if change.isLevel() {
+		return p.setLevelInterrupt(change, callback)
+	}
+
+	var configs [len(nrf.GPIOTE.CONFIG)]uint32
+	for i := range configs {
+		configs[i] = nrf.GPIOTE.CONFIG[i].Get()
+	}
+	i := findGPIOTEChannel(configs[:], p)
+	if i < 0 {
+		if callback == nil {
+			// Nothing to disable.
+			return nil
+		}
+		return ErrNoPinChangeChannel
+	}
+	nrf.GPIOTE.INTENCLR.Set(nrf.GPIOTE_INTENCLR_IN0 << uint(i))
+	if callback == nil {
+		// Disable this channel.
+		nrf.GPIOTE.CONFIG[i].Set(0)
+		pinCallbacks[i] = nil
+		return nil
+	}
+	nrf.GPIOTE.CONFIG[i].Set(gpioteConfig(p, change))
+	pinCallbacks[i] = callback
+	nrf.GPIOTE.EVENTS_IN[i].Set(0)
+	nrf.GPIOTE.INTENSET.Set(nrf.GPIOTE_INTENSET_IN0 << uint(i))
+
+	// Enable the GPIOTE interrupt. It's not a problem if this happens more
+	// than once.
+	arm.SetPriority(nrf.IRQ_GPIOTE, 0xc0) // low priority
+	arm.EnableIRQ(nrf.IRQ_GPIOTE)
+	return nil
+}
+
+// setLevelInterrupt configures a level-triggered interrupt using the SENSE
+// field of the pin configuration register.
+func (p Pin) setLevelInterrupt(change PinChange, callback func(Pin)) error {
+	port, pin := p.getPortPin()
+	i := findLevelSlot(p)
+	if i < 0 {
+		if callback == nil {
+			// Nothing to disable.
+			return nil
+		}
+		return ErrNoPinChangeChannel
+	}
+	// Disable sensing while the slot is being updated.
+	port.PIN_CNF[pin].ClearBits(nrf.GPIO_PIN_CNF_SENSE_Msk)
+	if callback == nil {
+		levelCallbacks[i] = nil
+		return nil
+	}
+	levelPins[i] = p
+	levelCallbacks[i] = callback
+	nrf.GPIOTE.EVENTS_PORT.Set(0)
+	nrf.GPIOTE.INTENSET.Set(nrf.GPIOTE_INTENSET_PORT)
+	arm.SetPriority(nrf.IRQ_GPIOTE, 0xc0) // low priority
+	arm.EnableIRQ(nrf.IRQ_GPIOTE)
+	// Enabling sense while the pin is already at the given level raises the
+	// DETECT signal, so the callback will be called immediately in that case.
+	port.PIN_CNF[pin].SetBits(change.sense() << nrf.GPIO_PIN_CNF_SENSE_Pos)
+	return nil
+}
+
+//go:export GPIOTE_IRQHandler
+func handleGPIOTE() {
+	for i := range nrf.GPIOTE.EVENTS_IN {
+		if nrf.GPIOTE.EVENTS_IN[i].Get() != 0 {
+			nrf.GPIOTE.EVENTS_IN[i].Set(0)
+			pin := gpioteConfigPin(nrf.GPIOTE.CONFIG[i].Get())
+			if callback := pinCallbacks[i]; callback != nil {
+				callback(pin)
+			}
+		}
+	}
+	if nrf.GPIOTE.EVENTS_PORT.Get() != 0 {
+		nrf.GPIOTE.EVENTS_PORT.Set(0)
+		for i, callback := range levelCallbacks {
+			if callback == nil {
+				continue
+			}
+			p := levelPins[i]
+			port, pin := p.getPortPin()
+			config := port.PIN_CNF[pin].Get()
+			sense := (config & nrf.GPIO_PIN_CNF_SENSE_Msk) >> nrf.GPIO_PIN_CNF_SENSE_Pos
+			if p.Get() != (sense == nrf.GPIO_PIN_CNF_SENSE_High) {
+				// This pin is not at the sensed level.
+				continue
+			}
+			// The PORT event is only raised on a rising edge of the DETECT
+			// signal. Disable sensing for this pin while the callback runs and
+			// restore it afterwards: if the pin is still at the given level,
+			// DETECT will rise again and a new PORT event is generated.
+			port.PIN_CNF[pin].Set(config &^ nrf.GPIO_PIN_CNF_SENSE_Msk)
+			callback(p)
+			port.PIN_CNF[pin].Set(config)
+		}
+	}
+}
+
 // UART on the NRF.
 type UART struct {
 	Buffer *RingBuffer
@@ -142,6 +280,10 @@ type I2CConfig struct {
 }
 
 // Configure is intended to setup the I2C interface.
+//
+// The TWI peripheral only supports 100kHz, 250kHz and 400kHz. Other
+// frequencies are rounded down to the nearest supported frequency, with a
+// minimum of 100kHz. Use ActualFrequency to get the configured frequency.
 func (i2c I2C) Configure(config I2CConfig) {
 	// Default I2C bus speed is 100 kHz.
 	if config.Frequency == 0 {
@@ -168,9 +310,12 @@ func (i2c I2C) Configure(config I2CConfig) {
 		(nrf.GPIO_PIN_CNF_DRIVE_S0D1 << nrf.GPIO_PIN_CNF_DRIVE_Pos) |
 		(nrf.GPIO_PIN_CNF_SENSE_Disabled << nrf.GPIO_PIN_CNF_SENSE_Pos))
 
-	if config.Frequency == TWI_FREQ_400KHZ {
+	switch {
+	case config.Frequency >= TWI_FREQ_400KHZ:
 		i2c.Bus.FREQUENCY.Set(nrf.TWI_FREQUENCY_FREQUENCY_K400)
-	} else {
+	case config.Frequency >= 250000:
+		i2c.Bus.FREQUENCY.Set(nrf.TWI_FREQUENCY_FREQUENCY_K250)
+	default:
 		i2c.Bus.FREQUENCY.Set(nrf.TWI_FREQUENCY_FREQUENCY_K100)
 	}
 
@@ -178,10 +323,23 @@ func (i2c I2C) Configure(config I2CConfig) {
 	i2c.setPins(config.SCL, config.SDA)
 }
 
-// Tx does a single I2C transaction at the specified address.
+// ActualFrequency returns the I2C bus frequency in Hz as configured in the
+// hardware, which may be lower than the frequency passed to Configure.
+func (i2c I2C) ActualFrequency() uint32 {
+	switch i2c.Bus.FREQUENCY.Get() {
+	case nrf.TWI_FREQUENCY_FREQUENCY_K400:
+		return TWI_FREQ_400KHZ
+	case nrf.TWI_FREQUENCY_FREQUENCY_K250:
+		return 250000
+	default:
+		return TWI_FREQ_100KHZ
+	}
+}
+
+// tx does a single I2C transaction at the specified address.
 // It clocks out the given address, writes the bytes in w, reads back len(r)
 // bytes and stores them in r, and generates a stop condition on the bus.
-func (i2c I2C) Tx(addr uint16, w, r []byte) error {
+func (i2c I2C) tx(addr uint16, w, r []byte) error {
 	i2c.Bus.ADDRESS.Set(uint32(addr))
 	if len(w) != 0 {
 		i2c.Bus.TASKS_STARTTX.Set(1) // start transmission for writing
@@ -254,33 +412,34 @@ type SPIConfig struct {
 	Mode      uint8
 }
 
+// spiFrequencies are the FREQUENCY register values for the SPI peripheral,
+// from 8MHz down to 125kHz. Each entry is half the frequency of the previous.
+var spiFrequencies = [...]uint32{
+	nrf.SPI_FREQUENCY_FREQUENCY_M8,
+	nrf.SPI_FREQUENCY_FREQUENCY_M4,
+	nrf.SPI_FREQUENCY_FREQUENCY_M2,
+	nrf.SPI_FREQUENCY_FREQUENCY_M1,
+	nrf.SPI_FREQUENCY_FREQUENCY_K500,
+	nrf.SPI_FREQUENCY_FREQUENCY_K250,
+	nrf.SPI_FREQUENCY_FREQUENCY_K125,
+}
+
 // Configure is intended to setup the SPI interface.
+//
+// The SPI peripheral supports frequencies of 8MHz divided by a power of two,
+// down to 125kHz. Other frequencies are rounded down to the nearest supported
+// frequency, with a minimum of 125kHz. When no frequency is set, 500kHz is
+// used. Use ActualFrequency to get the configured frequency.
 func (spi SPI) Configure(config SPIConfig) {
 	// Disable bus to configure it
 	spi.Bus.ENABLE.Set(nrf.SPI_ENABLE_ENABLE_Disabled)
 
 	// set frequency
-	var freq uint32
-
-	switch config.Frequency {
-	case 125000:
-		freq = nrf.SPI_FREQUENCY_FREQUENCY_K125
-	case 250000:
-		freq = nrf.SPI_FREQUENCY_FREQUENCY_K250
-	case 500000:
-		freq = nrf.SPI_FREQUENCY_FREQUENCY_K500
-	case 1000000:
-		freq = nrf.SPI_FREQUENCY_FREQUENCY_M1
-	case 2000000:
-		freq = nrf.SPI_FREQUENCY_FREQUENCY_M2
-	case 4000000:
-		freq = nrf.SPI_FREQUENCY_FREQUENCY_M4
-	case 8000000:
-		freq = nrf.SPI_FREQUENCY_FREQUENCY_M8
-	default:
-		freq = nrf.SPI_FREQUENCY_FREQUENCY_K500
+	if config.Frequency == 0 {
+		config.Frequency = 500000
 	}
-	spi.Bus.FREQUENCY.Set(freq)
+	shift := divideClockPow2(8000000, config.Frequency, 0, uint8(len(spiFrequencies)-1))
+	spi.Bus.FREQUENCY.Set(spiFrequencies[shift])
 
 	var conf uint32
 
@@ -316,8 +475,20 @@ func (spi SPI) Configure(config SPIConfig) {
 	spi.Bus.ENABLE.Set(nrf.SPI_ENABLE_ENABLE_Enabled)
 }
 
-// Transfer writes/reads a single byte using the SPI interface.
-func (spi SPI) Transfer(w byte) (byte, error) {
+// ActualFrequency returns the SPI clock frequency in Hz as configured in the
+// hardware, which may be lower than the frequency passed to Configure.
+func (spi SPI) ActualFrequency() uint32 {
+	freq := spi.Bus.FREQUENCY.Get()
+	for shift, value := range spiFrequencies {
+		if value == freq {
+			return 8000000 >> uint(shift)
+		}
+	}
+	return 0 // unknown value
+}
+
+// transfer writes/reads a single byte using the SPI interface.
+func (spi SPI) transfer(w byte) (byte, error) {
 	spi.Bus.TXD.Set(uint32(w))
 	for spi.Bus.EVENTS_READY.Get() == 0 {
 	}
@@ -328,32 +499,16 @@ func (spi SPI) Transfer(w byte) (byte, error) {
 	return byte(r), nil
 }
 
-// Tx handles read/write operation for SPI interface. Since SPI is a syncronous write/read
-// interface, there must always be the same number of bytes written as bytes read.
-// The Tx method knows about this, and offers a few different ways of calling it.
-//
-// This form sends the bytes in tx buffer, putting the resulting bytes read into the rx buffer.
-// Note that the tx and rx buffers must be the same size:
-//
-// 		spi.Tx(tx, rx)
-//
-// This form sends the tx buffer, ignoring the result. Useful for sending "commands" that return zeros
-// until all the bytes in the command packet have been received:
-//
-// 		spi.Tx(tx, nil)
-//
-// This form sends zeros, putting the result into the rx buffer. Good for reading a "result packet":
-//
-// 		spi.Tx(nil, rx)
-//
-func (spi SPI) Tx(w, r []byte) error {
+// tx sends and receives the bytes in w and r, one byte at a time. See SPI.Tx
+// for the ways in which it can be called.
+func (spi SPI) tx(w, r []byte) error {
 	var err error
 
 	switch {
 	case len(w) == 0:
 		// read only, so write zero and read a result.
 		for i := range r {
-			r[i], err = spi.Transfer(0)
+			r[i], err = spi.transfer(0)
 			if err != nil {
 				return err
 			}
@@ -381,7 +536,7 @@ func (spi SPI) Tx(w, r []byte) error {
 		}
 
 		for i, b := range w {
-			r[i], err = spi.Transfer(b)
+			r[i], err = spi.transfer(b)
 			if err != nil {
 				return err
 			}
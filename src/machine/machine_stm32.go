@@ -4,4 +4,175 @@ package machine
 
 // Peripheral abstraction layer for the stm32.
 
+import (
+	"device/arm"
+	"device/stm32"
+)
+
 type PinMode uint8
+
+// Callbacks for pin interrupts, one for each EXTI line. An EXTI line can only
+// be connected to a single port at a time, so pin 3 of port A and pin 3 of
+// port B cannot both have an interrupt.
+var (
+	pinCallbacks [16]func(Pin)
+	pinChanges   [16]PinChange
+	pinLines     [16]Pin
+)
+
+// SetInterrupt sets an interrupt to be executed when a particular pin changes
+// state. The callback is executed in interrupt context.
+//
+// The EXTI peripheral only detects edges. Level-triggered interrupts
+// (PinLevelHigh, PinLevelLow) are emulated on top of it: the interrupt is
+// triggered on the edge towards the given level, and after the callback returns
+// the pin is sampled again. If it is still at the given level, the interrupt is
+// re-armed in software so the callback is called again. This means the callback
+// must clear the interrupt source (for example, by reading the status register
+// of the sensor that holds the line) before returning, or the CPU will be stuck
+// servicing the same interrupt over and over again.
+//
+// This call will replace a previously set callback on this pin. You can pass a
+// nil func to unset the pin change interrupt. If you do so, the change
+// parameter is ignored and can be set to any value (such as 0).
+func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
+	line := uint8(p) % 16
+	mask := uint32(1) << line
+
+	// Disable the interrupt while it is being reconfigured.
+	stm32.EXTI.IMR.ClearBits(mask)
+	stm32.EXTI.RTSR.ClearBits(mask)
+	stm32.EXTI.FTSR.ClearBits(mask)
+	stm32.EXTI.PR.Set(mask) // clear pending interrupt, if any
+	pinCallbacks[line] = callback
+	if callback == nil {
+		return nil
+	}
+	pinChanges[line] = change
+	pinLines[line] = p
+
+	// Connect the EXTI line to the port of this pin.
+	p.setEXTILine()
+
+	rising, falling := change.edges()
+	if rising {
+		stm32.EXTI.RTSR.SetBits(mask)
+	}
+	if falling {
+		stm32.EXTI.FTSR.SetBits(mask)
+	}
+	stm32.EXTI.IMR.SetBits(mask)
+
+	irq := p.getEXTIIRQ()
+	arm.SetPriority(irq, 0xc0) // low priority
+	arm.EnableIRQ(irq)
+
+	if change.atLevel(p.Get()) {
+		// The pin is already at the requested level, so there won't be an
+		// edge to trigger the interrupt. Trigger it in software instead.
+		stm32.EXTI.SWIER.Set(mask)
+	}
+	return nil
+}
+
+// ConfigurePins configures all given pins with the same configuration. It is
+// equivalent to calling Configure on each pin, but pins on the same GPIO port
+// are configured together, with a single write to each configuration register
+// of the port.
+func ConfigurePins(pins []Pin, config PinConfig) {
+	// Pins to configure, per port. Ports are indexed by p>>4 plus 8: unlike
+	// p/16, the shift rounds down, so pins -16..-1 don't end up in the same
+	// slot as pins 0..15.
+	var ports [16]uint16
+	for _, p := range pins {
+		ports[p>>4+8] |= 1 << (uint8(p) % 16)
+	}
+	for i, mask := range ports {
+		if mask == 0 {
+			continue
+		}
+		p := Pin((i - 8) * 16)
+		p.enableClock()
+		configurePort(p.getPort(), mask, config.Mode)
+	}
+}
+
+// getEXTIIRQ returns the interrupt number for the EXTI line of this pin.
+func (p Pin) getEXTIIRQ() uint32 {
+	switch line := uint8(p) % 16; {
+	case line == 0:
+		return stm32.IRQ_EXTI0
+	case line == 1:
+		return stm32.IRQ_EXTI1
+	case line == 2:
+		return stm32.IRQ_EXTI2
+	case line == 3:
+		return stm32.IRQ_EXTI3
+	case line == 4:
+		return stm32.IRQ_EXTI4
+	case line <= 9:
+		return stm32.IRQ_EXTI9_5
+	default:
+		return stm32.IRQ_EXTI15_10
+	}
+}
+
+// handlePinInterrupt handles a pending interrupt on the given EXTI line, if
+// there is one.
+func handlePinInterrupt(line uint8) {
+	mask := uint32(1) << line
+	if !stm32.EXTI.PR.HasBits(mask) {
+		return
+	}
+	stm32.EXTI.PR.Set(mask) // writing a 1 clears the pending bit
+	callback := pinCallbacks[line]
+	if callback == nil {
+		return
+	}
+	p := pinLines[line]
+	callback(p)
+	if pinChanges[line].atLevel(p.Get()) {
+		// Level-triggered interrupt and the pin is still at the given level:
+		// re-arm the interrupt so it fires again.
+		stm32.EXTI.SWIER.Set(mask)
+	}
+}
+
+//go:export EXTI0_IRQHandler
+func handleEXTI0() {
+	handlePinInterrupt(0)
+}
+
+//go:export EXTI1_IRQHandler
+func handleEXTI1() {
+	handlePinInterrupt(1)
+}
+
+//go:export EXTI2_IRQHandler
+func handleEXTI2() {
+	handlePinInterrupt(2)
+}
+
+//go:export EXTI3_IRQHandler
+func handleEXTI3() {
+	handlePinInterrupt(3)
+}
+
+//go:export EXTI4_IRQHandler
+func handleEXTI4() {
+	handlePinInterrupt(4)
+}
+
+//go:export EXTI9_5_IRQHandler
+func handleEXTI9_5() {
+	for line := uint8(5); line <= 9; line++ {
+		handlePinInterrupt(line)
+	}
+}
+
+//go:export EXTI15_10_IRQHandler
+func handleEXTI15_10() {
+	for line := uint8(10); line <= 15; line++ {
+		handlePinInterrupt(line)
+	}
+}
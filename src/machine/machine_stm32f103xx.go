@@ -8,6 +8,8 @@ import (
 	"device/arm"
 	"device/stm32"
 	"errors"
+	"runtime/volatile"
+	"unsafe"
 )
 
 const CPU_FREQUENCY = 72000000
@@ -77,13 +79,17 @@ func (p Pin) enableClock() {
 func (p Pin) Configure(config PinConfig) {
 	// Configure the GPIO pin.
 	p.enableClock()
-	port := p.getPort()
-	pin := uint8(p) % 16
-	pos := uint8(p) % 8 * 4
-	if pin < 8 {
-		port.CRL.Set((uint32(port.CRL.Get()) &^ (0xf << pos)) | (uint32(config.Mode) << pos))
-	} else {
-		port.CRH.Set((uint32(port.CRH.Get()) &^ (0xf << pos)) | (uint32(config.Mode) << pos))
+	configurePort(p.getPort(), 1<<(uint8(p)%16), config.Mode)
+}
+
+// configurePort configures all pins of the port in the pins mask with the given
+// mode. CRL contains the configuration of pin 0-7, CRH of pin 8-15.
+func configurePort(port *stm32.GPIO_Type, pins uint16, mode PinMode) {
+	if mask, bits := pinFields(pins&0xff, 4, uint32(mode)); mask != 0 {
+		port.CRL.Set(port.CRL.Get()&^mask | bits)
+	}
+	if mask, bits := pinFields(pins>>8, 4, uint32(mode)); mask != 0 {
+		port.CRH.Set(port.CRH.Get()&^mask | bits)
 	}
 }
 
@@ -99,6 +105,30 @@ func (p Pin) Set(high bool) {
 	}
 }
 
+// SetFast sets the pin to high with a single store to the BSRR register. It
+// does not check whether the pin is valid and doesn't branch on the port
+// number, so it is intended for use in interrupt handlers where jitter must be
+// minimal. The pin must have been configured as an output pin.
+//go:inline
+func (p Pin) SetFast() {
+	p.getPortFast().BSRR.Set(1 << uint8(p%16))
+}
+
+// ClearFast sets the pin to low with a single store to the BSRR register. See
+// SetFast.
+//go:inline
+func (p Pin) ClearFast() {
+	p.getPortFast().BSRR.Set(1 << uint8(p%16+16))
+}
+
+// getPortFast returns the same port as getPort, but calculates the address
+// instead of branching on the port number. All GPIO ports are 0x400 bytes
+// apart.
+//go:inline
+func (p Pin) getPortFast() *stm32.GPIO_Type {
+	return (*stm32.GPIO_Type)(unsafe.Pointer(uintptr(unsafe.Pointer(stm32.GPIOA)) + uintptr(p/16)*0x400))
+}
+
 // Get returns the current value of a GPIO pin.
 func (p Pin) Get() bool {
 	port := p.getPort()
@@ -107,6 +137,27 @@ func (p Pin) Get() bool {
 	return (val > 0)
 }
 
+// setEXTILine connects the EXTI line of this pin to the port of this pin, using
+// the AFIO_EXTICRx registers.
+func (p Pin) setEXTILine() {
+	stm32.RCC.APB2ENR.SetBits(stm32.RCC_APB2ENR_AFIOEN)
+	line := uint8(p) % 16
+	port := uint32(p) / 16
+	pos := line % 4 * 4
+	var reg *volatile.Register32
+	switch line / 4 {
+	case 0:
+		reg = &stm32.AFIO.EXTICR1
+	case 1:
+		reg = &stm32.AFIO.EXTICR2
+	case 2:
+		reg = &stm32.AFIO.EXTICR3
+	default:
+		reg = &stm32.AFIO.EXTICR4
+	}
+	reg.Set(reg.Get()&^(0xf<<pos) | port<<pos)
+}
+
 // UART
 type UART struct {
 	Buffer *RingBuffer
@@ -209,6 +260,11 @@ type SPIConfig struct {
 // - allow setting data size to 16 bits?
 // - allow setting direction in HW for additional optimization?
 // - hardware SS pin?
+//
+// The SPI clock is PCLK2 divided by a power of two between 2 and 256. The
+// frequency is rounded down to the nearest frequency that can be reached this
+// way, with a minimum of PCLK2/256 which is also used when no frequency is set.
+// Use ActualFrequency to get the configured frequency.
 func (spi SPI) Configure(config SPIConfig) {
 	// enable clock for SPI
 	stm32.RCC.APB2ENR.SetBits(stm32.RCC_APB2ENR_SPI1EN)
@@ -216,25 +272,9 @@ func (spi SPI) Configure(config SPIConfig) {
 	var conf uint32
 
 	// set frequency dependent on PCLK2 prescaler (div 1)
-	switch config.Frequency {
-	case 125000:
-		// Note: impossible to achieve lower frequency with current PCLK2!
-		conf |= stm32.SPI_BaudRatePrescaler_256
-	case 250000:
-		conf |= stm32.SPI_BaudRatePrescaler_256
-	case 500000:
-		conf |= stm32.SPI_BaudRatePrescaler_128
-	case 1000000:
-		conf |= stm32.SPI_BaudRatePrescaler_64
-	case 2000000:
-		conf |= stm32.SPI_BaudRatePrescaler_32
-	case 4000000:
-		conf |= stm32.SPI_BaudRatePrescaler_16
-	case 8000000:
-		conf |= stm32.SPI_BaudRatePrescaler_8
-	default:
-		conf |= stm32.SPI_BaudRatePrescaler_256
-	}
+	// The baud rate divider is 2 << BR.
+	shift := divideClockPow2(CPU_FREQUENCY, config.Frequency, 1, 8)
+	conf |= uint32(shift-1) << stm32.SPI_CR1_BR_Pos
 
 	// set bit transfer order
 	if config.LSBFirst {
@@ -273,8 +313,15 @@ func (spi SPI) Configure(config SPIConfig) {
 	spi.Bus.CR1.SetBits(stm32.SPI_CR1_SPE)
 }
 
-// Transfer writes/reads a single byte using the SPI interface.
-func (spi SPI) Transfer(w byte) (byte, error) {
+// ActualFrequency returns the SPI clock frequency in Hz as configured in the
+// hardware, which may be lower than the frequency passed to Configure.
+func (spi SPI) ActualFrequency() uint32 {
+	br := (spi.Bus.CR1.Get() & stm32.SPI_CR1_BR_Msk) >> stm32.SPI_CR1_BR_Pos
+	return CPU_FREQUENCY >> (br + 1)
+}
+
+// transfer writes/reads a single byte using the SPI interface.
+func (spi SPI) transfer(w byte) (byte, error) {
 	// Write data to be transmitted to the SPI data register
 	spi.Bus.DR.Set(uint32(w))
 
@@ -331,6 +378,11 @@ type I2CConfig struct {
 }
 
 // Configure is intended to setup the I2C interface.
+//
+// Frequencies up to 100kHz use standard mode, higher frequencies use fast mode
+// and are clamped to 400kHz. The frequency is rounded down to the nearest
+// frequency the clock divider can produce. Use ActualFrequency to get the
+// configured frequency.
 func (i2c I2C) Configure(config I2CConfig) {
 	// Default I2C bus speed is 100 kHz.
 	if config.Frequency == 0 {
@@ -367,10 +419,10 @@ func (i2c I2C) Configure(config I2CConfig) {
 	pclk1Mhz := pclk1 / 1000000
 	i2c.Bus.CR2.SetBits(pclk1Mhz)
 
-	switch config.Frequency {
-	case TWI_FREQ_100KHZ:
+	switch {
+	case config.Frequency <= TWI_FREQ_100KHZ:
 		// Normal mode speed calculation
-		ccr := pclk1 / (config.Frequency * 2)
+		ccr := divideClock(pclk1/2, config.Frequency, 4, stm32.I2C_CCR_CCR_Msk)
 		i2c.Bus.CCR.Set(ccr)
 
 		// duty cycle 2
@@ -382,9 +434,12 @@ func (i2c I2C) Configure(config I2CConfig) {
 		// Set Maximum Rise Time for standard mode
 		i2c.Bus.TRISE.Set(pclk1Mhz)
 
-	case TWI_FREQ_400KHZ:
+	default:
 		// Fast mode speed calculation
-		ccr := pclk1 / (config.Frequency * 3)
+		if config.Frequency > TWI_FREQ_400KHZ {
+			config.Frequency = TWI_FREQ_400KHZ
+		}
+		ccr := divideClock(pclk1/3, config.Frequency, 1, stm32.I2C_CCR_CCR_Msk)
 		i2c.Bus.CCR.Set(ccr)
 
 		// duty cycle 2
@@ -401,10 +456,26 @@ func (i2c I2C) Configure(config I2CConfig) {
 	i2c.Bus.CR1.SetBits(stm32.I2C_CR1_PE)
 }
 
-// Tx does a single I2C transaction at the specified address.
+// ActualFrequency returns the I2C bus frequency in Hz as configured in the
+// hardware, which may be lower than the frequency passed to Configure.
+func (i2c I2C) ActualFrequency() uint32 {
+	pclk1 := uint32(CPU_FREQUENCY / 2)
+	ccr := i2c.Bus.CCR.Get()
+	divider := ccr & stm32.I2C_CCR_CCR_Msk
+	if divider == 0 {
+		return 0 // not configured
+	}
+	if ccr&stm32.I2C_CCR_F_S != 0 {
+		// fast mode, duty cycle 2
+		return pclk1 / (3 * divider)
+	}
+	return pclk1 / (2 * divider)
+}
+
+// tx does a single I2C transaction at the specified address.
 // It clocks out the given address, writes the bytes in w, reads back len(r)
 // bytes and stores them in r, and generates a stop condition on the bus.
-func (i2c I2C) Tx(addr uint16, w, r []byte) error {
+func (i2c I2C) tx(addr uint16, w, r []byte) error {
 	var err error
 	if len(w) != 0 {
 		// start transmission for writing
@@ -7,6 +7,8 @@ package machine
 import (
 	"device/arm"
 	"device/stm32"
+	"runtime/volatile"
+	"unsafe"
 )
 
 const CPU_FREQUENCY = 168000000
@@ -96,46 +98,77 @@ func (p Pin) enableClock() {
 	}
 }
 
+// setEXTILine connects the EXTI line of this pin to the port of this pin, using
+// the SYSCFG_EXTICRx registers.
+func (p Pin) setEXTILine() {
+	stm32.RCC.APB2ENR.SetBits(stm32.RCC_APB2ENR_SYSCFGEN)
+	line := uint8(p) % 16
+	port := uint32(p) / 16
+	pos := line % 4 * 4
+	var reg *volatile.Register32
+	switch line / 4 {
+	case 0:
+		reg = &stm32.SYSCFG.EXTICR1
+	case 1:
+		reg = &stm32.SYSCFG.EXTICR2
+	case 2:
+		reg = &stm32.SYSCFG.EXTICR3
+	default:
+		reg = &stm32.SYSCFG.EXTICR4
+	}
+	reg.Set(reg.Get()&^(0xf<<pos) | port<<pos)
+}
+
 // Configure this pin with the given configuration.
 func (p Pin) Configure(config PinConfig) {
 	// Configure the GPIO pin.
 	p.enableClock()
-	port := p.getPort()
-	pin := uint8(p) % 16
-	pos := pin * 2
-
-	if config.Mode == PinInputFloating {
-		port.MODER.Set((uint32(port.MODER.Get())&^(0x3<<pos) | (uint32(GPIO_MODE_INPUT) << pos)))
-		port.PUPDR.Set((uint32(port.PUPDR.Get())&^(0x3<<pos) | (uint32(GPIO_FLOATING) << pos)))
-	} else if config.Mode == PinInputPulldown {
-		port.MODER.Set((uint32(port.MODER.Get())&^(0x3<<pos) | (uint32(GPIO_MODE_INPUT) << pos)))
-		port.PUPDR.Set((uint32(port.PUPDR.Get())&^(0x3<<pos) | (uint32(GPIO_PULL_DOWN) << pos)))
-	} else if config.Mode == PinInputPullup {
-		port.MODER.Set((uint32(port.MODER.Get())&^(0x3<<pos) | (uint32(GPIO_MODE_INPUT) << pos)))
-		port.PUPDR.Set((uint32(port.PUPDR.Get())&^(0x3<<pos) | (uint32(GPIO_PULL_UP) << pos)))
-	} else if config.Mode == PinOutput {
-		port.MODER.Set((uint32(port.MODER.Get())&^(0x3<<pos) | (uint32(GPIO_MODE_GENERAL_OUTPUT) << pos)))
-		port.OSPEEDR.Set((uint32(port.OSPEEDR.Get())&^(0x3<<pos) | (uint32(GPIO_SPEED_HI) << pos)))
-	} else if config.Mode == PinModeUartTX {
-		port.MODER.Set((uint32(port.MODER.Get())&^(0x3<<pos) | (uint32(GPIO_MODE_ALTERNABTIVE) << pos)))
-		port.OSPEEDR.Set((uint32(port.OSPEEDR.Get())&^(0x3<<pos) | (uint32(GPIO_SPEED_HI) << pos)))
-		port.PUPDR.Set((uint32(port.PUPDR.Get())&^(0x3<<pos) | (uint32(GPIO_PULL_UP) << pos)))
-		p.setAltFunc(0x7)
-	} else if config.Mode == PinModeUartRX {
-		port.MODER.Set((uint32(port.MODER.Get())&^(0x3<<pos) | (uint32(GPIO_MODE_ALTERNABTIVE) << pos)))
-		port.PUPDR.Set((uint32(port.PUPDR.Get())&^(0x3<<pos) | (uint32(GPIO_FLOATING) << pos)))
-		p.setAltFunc(0x7)
+	configurePort(p.getPort(), 1<<(uint8(p)%16), config.Mode)
+}
+
+// configurePort configures all pins of the port in the pins mask with the given
+// mode.
+func configurePort(port *stm32.GPIO_Type, pins uint16, mode PinMode) {
+	switch mode {
+	case PinInputFloating:
+		setPinFields(&port.MODER, pins, GPIO_MODE_INPUT)
+		setPinFields(&port.PUPDR, pins, GPIO_FLOATING)
+	case PinInputPulldown:
+		setPinFields(&port.MODER, pins, GPIO_MODE_INPUT)
+		setPinFields(&port.PUPDR, pins, GPIO_PULL_DOWN)
+	case PinInputPullup:
+		setPinFields(&port.MODER, pins, GPIO_MODE_INPUT)
+		setPinFields(&port.PUPDR, pins, GPIO_PULL_UP)
+	case PinOutput:
+		setPinFields(&port.MODER, pins, GPIO_MODE_GENERAL_OUTPUT)
+		setPinFields(&port.OSPEEDR, pins, GPIO_SPEED_HI)
+	case PinModeUartTX:
+		setPinFields(&port.MODER, pins, GPIO_MODE_ALTERNABTIVE)
+		setPinFields(&port.OSPEEDR, pins, GPIO_SPEED_HI)
+		setPinFields(&port.PUPDR, pins, GPIO_PULL_UP)
+		setAltFunc(port, pins, 0x7)
+	case PinModeUartRX:
+		setPinFields(&port.MODER, pins, GPIO_MODE_ALTERNABTIVE)
+		setPinFields(&port.PUPDR, pins, GPIO_FLOATING)
+		setAltFunc(port, pins, 0x7)
 	}
 }
 
-func (p Pin) setAltFunc(af uint32) {
-	port := p.getPort()
-	pin := uint8(p) % 16
-	pos := pin * 4
-	if pin >= 8 {
-		port.AFRH.Set(uint32(port.AFRH.Get())&^(0xF<<pos) | ((af & 0xF) << pos))
-	} else {
-		port.AFRL.Set(uint32(port.AFRL.Get())&^(0xF<<pos) | ((af & 0xF) << pos))
+// setPinFields sets the 2-bit field of all pins in the mask to the given value,
+// in a register with a 2-bit field per pin like MODER or PUPDR.
+func setPinFields(reg *volatile.Register32, pins uint16, value uint32) {
+	mask, bits := pinFields(pins, 2, value)
+	reg.Set(reg.Get()&^mask | bits)
+}
+
+// setAltFunc sets the alternate function of all pins in the mask. AFRL contains
+// the alternate function of pin 0-7, AFRH of pin 8-15.
+func setAltFunc(port *stm32.GPIO_Type, pins uint16, af uint32) {
+	if mask, bits := pinFields(pins&0xff, 4, af); mask != 0 {
+		port.AFRL.Set(port.AFRL.Get()&^mask | bits)
+	}
+	if mask, bits := pinFields(pins>>8, 4, af); mask != 0 {
+		port.AFRH.Set(port.AFRH.Get()&^mask | bits)
 	}
 }
 
@@ -151,6 +184,38 @@ func (p Pin) Set(high bool) {
 	}
 }
 
+// Get returns the current value of a GPIO pin.
+func (p Pin) Get() bool {
+	port := p.getPort()
+	pin := uint8(p) % 16
+	val := port.IDR.Get() & (1 << pin)
+	return (val > 0)
+}
+
+// SetFast sets the pin to high with a single store to the BSRR register. It
+// does not check whether the pin is valid and doesn't branch on the port
+// number, so it is intended for use in interrupt handlers where jitter must be
+// minimal. The pin must have been configured as an output pin.
+//go:inline
+func (p Pin) SetFast() {
+	p.getPortFast().BSRR.Set(1 << uint8(p%16))
+}
+
+// ClearFast sets the pin to low with a single store to the BSRR register. See
+// SetFast.
+//go:inline
+func (p Pin) ClearFast() {
+	p.getPortFast().BSRR.Set(1 << uint8(p%16+16))
+}
+
+// getPortFast returns the same port as getPort, but calculates the address
+// instead of branching on the port number. All GPIO ports are 0x400 bytes
+// apart.
+//go:inline
+func (p Pin) getPortFast() *stm32.GPIO_Type {
+	return (*stm32.GPIO_Type)(unsafe.Pointer(uintptr(unsafe.Pointer(stm32.GPIOA)) + uintptr(p/16)*0x400))
+}
+
 // UART
 type UART struct {
 	Buffer *RingBuffer
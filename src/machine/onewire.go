@@ -0,0 +1,185 @@
+// +build arduino nrf sam stm32f407
+
+package machine
+
+import (
+	"errors"
+	"runtime/volatile"
+)
+
+var (
+	ErrOneWireNoPresence = errors.New("machine: no 1-Wire device present")
+	ErrOneWireCRC        = errors.New("machine: 1-Wire CRC mismatch")
+	ErrOneWireSearch     = errors.New("machine: 1-Wire search failed")
+)
+
+// ROM commands supported by all 1-Wire devices.
+const (
+	OneWireSearchROM = 0xF0
+	OneWireReadROM   = 0x33
+	OneWireMatchROM  = 0x55
+	OneWireSkipROM   = 0xCC
+)
+
+// OneWire is a Dallas/Maxim 1-Wire bus, bit-banged on a single GPIO pin.
+//
+// The 1-Wire bus is open drain: devices (and the master) can only pull the line
+// low, so there must be an external pull-up resistor (usually 4.7kΩ) between
+// the data line and the supply voltage. The pin is never driven high. Instead,
+// it is switched to an input to release the line.
+//
+// All timing is done in software with busy loops calibrated using the CPU
+// frequency, following the standard speed timing of Maxim application note
+// 126. Interrupts that take more than a few microseconds may disturb the
+// timing, so it may be necessary to disable interrupts during a transaction.
+type OneWire struct {
+	Pin Pin
+}
+
+// Configure releases the bus, so that it is pulled high by the external
+// pull-up resistor.
+func (ow OneWire) Configure() {
+	ow.release()
+}
+
+// Reset sends a reset pulse and returns whether any device on the bus responded
+// with a presence pulse. Every transaction starts with a reset.
+func (ow OneWire) Reset() bool {
+	ow.drive()
+	busyWaitMicroseconds(480)
+	ow.release()
+	busyWaitMicroseconds(70)
+	present := !ow.Pin.Get() // devices pull the line low to signal presence
+	busyWaitMicroseconds(410)
+	return present
+}
+
+// WriteBit writes a single bit to the bus.
+func (ow OneWire) WriteBit(bit bool) {
+	if bit {
+		ow.drive()
+		busyWaitMicroseconds(6)
+		ow.release()
+		busyWaitMicroseconds(64)
+	} else {
+		ow.drive()
+		busyWaitMicroseconds(60)
+		ow.release()
+		busyWaitMicroseconds(10)
+	}
+}
+
+// ReadBit reads a single bit from the bus.
+func (ow OneWire) ReadBit() bool {
+	ow.drive()
+	busyWaitMicroseconds(6)
+	ow.release()
+	busyWaitMicroseconds(9)
+	bit := ow.Pin.Get()
+	busyWaitMicroseconds(55)
+	return bit
+}
+
+// WriteByte writes a single byte to the bus, least significant bit first. It
+// implements io.ByteWriter and never returns an error, as the master can't
+// detect whether any device received the byte.
+func (ow OneWire) WriteByte(b byte) error {
+	for i := uint(0); i < 8; i++ {
+		ow.WriteBit(b&(1<<i) != 0)
+	}
+	return nil
+}
+
+// ReadByte reads a single byte from the bus, least significant bit first. It
+// implements io.ByteReader and never returns an error: when no device is
+// sending, the pull-up resistor makes all bits read as 1.
+func (ow OneWire) ReadByte() (byte, error) {
+	var b byte
+	for i := uint(0); i < 8; i++ {
+		if ow.ReadBit() {
+			b |= 1 << i
+		}
+	}
+	return b, nil
+}
+
+// Search finds the 64-bit ROM codes of the devices on the bus, using the
+// search algorithm of Maxim application note 187. It stores up to len(roms)
+// ROM codes in roms and returns the number of ROM codes found.
+func (ow OneWire) Search(roms [][8]byte) (int, error) {
+	var rom [8]byte
+	lastDiscrepancy := -1
+	for n := 0; n < len(roms); n++ {
+		if !ow.Reset() {
+			return n, ErrOneWireNoPresence
+		}
+		ow.WriteByte(OneWireSearchROM)
+
+		discrepancy := -1
+		for i := 0; i < 64; i++ {
+			bit := ow.ReadBit()
+			complement := ow.ReadBit()
+			mask := byte(1) << uint(i%8)
+			switch {
+			case bit && complement:
+				// No device responded.
+				return n, ErrOneWireSearch
+			case bit != complement:
+				// All remaining devices have the same bit here.
+			case i == lastDiscrepancy:
+				// Take the 1 branch this time, the 0 branch has already been
+				// searched.
+				bit = true
+			case i > lastDiscrepancy:
+				// New discrepancy: take the 0 branch first.
+				bit = false
+				discrepancy = i
+			default:
+				// Earlier discrepancy: take the same branch as last time.
+				bit = rom[i/8]&mask != 0
+				if !bit {
+					discrepancy = i
+				}
+			}
+			if bit {
+				rom[i/8] |= mask
+			} else {
+				rom[i/8] &^= mask
+			}
+			ow.WriteBit(bit)
+		}
+
+		if oneWireCRC8(rom[:7]) != rom[7] {
+			return n, ErrOneWireCRC
+		}
+		roms[n] = rom
+		lastDiscrepancy = discrepancy
+		if lastDiscrepancy < 0 {
+			// This was the last device.
+			return n + 1, nil
+		}
+	}
+	return len(roms), nil
+}
+
+// drive pulls the bus low.
+func (ow OneWire) drive() {
+	ow.Pin.Configure(PinConfig{Mode: PinOutput})
+	ow.Pin.Low()
+}
+
+// release stops pulling the bus low, so that it is pulled high by the pull-up
+// resistor unless a device is pulling it low.
+func (ow OneWire) release() {
+	ow.Pin.Configure(PinConfig{Mode: PinInput})
+}
+
+// busyWaitMicroseconds waits for approximately the given number of
+// microseconds, by counting CPU cycles.
+func busyWaitMicroseconds(us uint32) {
+	var dummy uint8
+	n := us * (CPU_FREQUENCY / 1000000) / busyLoopCycles
+	for i := uint32(0); i < n; i++ {
+		volatile.LoadUint8(&dummy)
+	}
+}
@@ -0,0 +1,7 @@
+// +build nrf sam stm32f407
+
+package machine
+
+// busyLoopCycles is the approximate number of CPU cycles per iteration of the
+// loop in busyWaitMicroseconds.
+const busyLoopCycles = 5
@@ -0,0 +1,7 @@
+// +build arduino
+
+package machine
+
+// busyLoopCycles is the approximate number of CPU cycles per iteration of the
+// loop in busyWaitMicroseconds.
+const busyLoopCycles = 12
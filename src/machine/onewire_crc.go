@@ -0,0 +1,18 @@
+package machine
+
+// oneWireCRC8 calculates the Dallas/Maxim CRC8 (polynomial x^8 + x^5 + x^4 + 1)
+// over the given data, as used in ROM codes and scratchpads of 1-Wire devices.
+func oneWireCRC8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			mix := (crc ^ b) & 1
+			crc >>= 1
+			if mix != 0 {
+				crc ^= 0x8C
+			}
+			b >>= 1
+		}
+	}
+	return crc
+}
@@ -0,0 +1,32 @@
+package machine
+
+import "testing"
+
+func TestOneWireCRC8(t *testing.T) {
+	for _, tc := range []struct {
+		data []byte
+		crc  byte
+	}{
+		{[]byte{}, 0x00},
+		{[]byte{0x00}, 0x00},
+		{[]byte{0x01}, 0x5E},
+		{[]byte{0x02, 0x1C, 0xB8, 0x01, 0x00, 0x00, 0x00}, 0xA2}, // Maxim application note 27
+	} {
+		if crc := oneWireCRC8(tc.data); crc != tc.crc {
+			t.Errorf("oneWireCRC8(%x): expected 0x%02x, got 0x%02x", tc.data, tc.crc, crc)
+		}
+	}
+}
+
+func TestOneWireCRC8Residue(t *testing.T) {
+	// A ROM code including its CRC byte has a CRC of zero, which is how
+	// received data is usually checked.
+	rom := []byte{0x02, 0x1C, 0xB8, 0x01, 0x00, 0x00, 0x00, 0xA2}
+	if crc := oneWireCRC8(rom); crc != 0 {
+		t.Errorf("expected a CRC of zero over data and CRC, got 0x%02x", crc)
+	}
+	rom[3] ^= 0x10
+	if crc := oneWireCRC8(rom); crc == 0 {
+		t.Errorf("expected a non-zero CRC over corrupted data")
+	}
+}
@@ -0,0 +1,99 @@
+// +build nrf
+
+package machine
+
+// PinChange is the condition on which a pin interrupt is triggered.
+type PinChange uint8
+
+// pinLevel is set in a PinChange for level-triggered interrupts. The lower bits
+// then contain the value of the SENSE field in the PIN_CNF register instead of
+// the GPIOTE polarity.
+const pinLevel PinChange = 0x10
+
+// Pin change interrupt constants for SetInterrupt.
+const (
+	PinRising    PinChange = 1            // GPIOTE_CONFIG_POLARITY_LoToHi
+	PinFalling   PinChange = 2            // GPIOTE_CONFIG_POLARITY_HiToLo
+	PinToggle    PinChange = 3            // GPIOTE_CONFIG_POLARITY_Toggle
+	PinLevelHigh PinChange = pinLevel | 2 // GPIO_PIN_CNF_SENSE_High
+	PinLevelLow  PinChange = pinLevel | 3 // GPIO_PIN_CNF_SENSE_Low
+)
+
+// Fields of the GPIOTE CONFIG registers, which are the same on all nRF chips.
+// The PSEL field includes the PORT bit on chips with more than 32 pins.
+const (
+	gpioteConfigModeMsk     = 0x3
+	gpioteConfigModeEvent   = 0x1
+	gpioteConfigPSELPos     = 8
+	gpioteConfigPSELMsk     = 0x3f << gpioteConfigPSELPos
+	gpioteConfigPolarityPos = 16
+)
+
+// Level-triggered interrupts do not use a GPIOTE channel but the SENSE
+// mechanism of the GPIO peripheral, which raises a single PORT event for all
+// pins. The number of such interrupts is limited by the size of this table.
+var (
+	levelPins      [4]Pin
+	levelCallbacks [4]func(Pin)
+)
+
+// isLevel returns whether this is a level-triggered interrupt.
+func (change PinChange) isLevel() bool {
+	return change&pinLevel != 0
+}
+
+// sense returns the value of the SENSE field in the PIN_CNF register for a
+// level-triggered interrupt.
+func (change PinChange) sense() uint32 {
+	return uint32(change &^ pinLevel)
+}
+
+// gpioteConfig returns the value of a GPIOTE CONFIG register that generates an
+// event on the given edge of the pin.
+func gpioteConfig(p Pin, change PinChange) uint32 {
+	return gpioteConfigModeEvent | uint32(p)<<gpioteConfigPSELPos | uint32(change)<<gpioteConfigPolarityPos
+}
+
+// gpioteConfigPin returns the pin selected in a GPIOTE CONFIG register value.
+func gpioteConfigPin(config uint32) Pin {
+	return Pin((config & gpioteConfigPSELMsk) >> gpioteConfigPSELPos)
+}
+
+// findGPIOTEChannel returns the GPIOTE channel to use for the pin, given the
+// values of all CONFIG registers. Configuring more than one channel for a
+// single pin results in unpredictable behavior according to the datasheet, so
+// this is the channel that is already configured for this pin or else the
+// first unused channel. It returns -1 if all channels are used by other pins.
+func findGPIOTEChannel(configs []uint32, p Pin) int {
+	expected := gpioteConfig(p, 0)
+	free := -1
+	for i, config := range configs {
+		if config&(gpioteConfigModeMsk|gpioteConfigPSELMsk) == expected {
+			return i
+		}
+		if config == 0 && free < 0 {
+			free = i
+		}
+	}
+	return free
+}
+
+// findLevelSlot returns the index in levelPins and levelCallbacks to use for a
+// level-triggered interrupt on the pin: the slot already used for this pin or
+// else the first unused slot. It returns -1 if all slots are used by other
+// pins.
+func findLevelSlot(p Pin) int {
+	free := -1
+	for i, callback := range levelCallbacks {
+		if callback == nil {
+			if free < 0 {
+				free = i
+			}
+			continue
+		}
+		if levelPins[i] == p {
+			return i
+		}
+	}
+	return free
+}
@@ -0,0 +1,103 @@
+// +build nrf
+
+package machine
+
+import "testing"
+
+func TestPinChangeGPIOTEConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		pin    Pin
+		change PinChange
+		config uint32
+	}{
+		{"PinRising", 3, PinRising, 0x00010301},
+		{"PinFalling", 3, PinFalling, 0x00020301},
+		{"PinToggle", 31, PinToggle, 0x00031f01},
+		{"PinRising on port 1", 47, PinRising, 0x00012f01}, // P1.15
+	} {
+		if config := gpioteConfig(tc.pin, tc.change); config != tc.config {
+			t.Errorf("%s: expected config %#08x, got %#08x", tc.name, tc.config, config)
+		}
+		if tc.change.isLevel() {
+			t.Errorf("%s: expected an edge-triggered interrupt", tc.name)
+		}
+		if pin := gpioteConfigPin(tc.config); pin != tc.pin {
+			t.Errorf("%s: expected pin %d in config %#08x, got %d", tc.name, int(tc.pin), tc.config, int(pin))
+		}
+	}
+}
+
+func TestPinChangeSense(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		change PinChange
+		sense  uint32
+	}{
+		{"PinLevelHigh", PinLevelHigh, 2},
+		{"PinLevelLow", PinLevelLow, 3},
+	} {
+		if !tc.change.isLevel() {
+			t.Errorf("%s: expected a level-triggered interrupt", tc.name)
+		}
+		if sense := tc.change.sense(); sense != tc.sense {
+			t.Errorf("%s: expected SENSE field %d, got %d", tc.name, tc.sense, sense)
+		}
+	}
+}
+
+func TestPinChangeGPIOTEChannel(t *testing.T) {
+	// A channel configured as a task (mode 3) for pin 5 doesn't count as a pin
+	// change interrupt on that pin.
+	task := uint32(0x00000503)
+	for _, tc := range []struct {
+		name    string
+		configs []uint32
+		pin     Pin
+		channel int
+	}{
+		{"all free", []uint32{0, 0, 0, 0}, 5, 0},
+		{"first free", []uint32{gpioteConfig(2, PinRising), task, 0, 0}, 5, 2},
+		{"already configured", []uint32{gpioteConfig(2, PinRising), gpioteConfig(5, PinToggle), 0, 0}, 5, 1},
+		{"configured after a free channel", []uint32{0, gpioteConfig(2, PinRising), gpioteConfig(5, PinFalling), 0}, 5, 2},
+		{"same pin on another port", []uint32{gpioteConfig(37, PinRising), 0, 0, 0}, 5, 1},
+		{"out of channels", []uint32{gpioteConfig(1, PinRising), gpioteConfig(2, PinRising), gpioteConfig(3, PinRising), task}, 5, -1},
+		{"out of channels, reconfigure", []uint32{gpioteConfig(1, PinRising), gpioteConfig(2, PinRising), gpioteConfig(3, PinRising), gpioteConfig(5, PinRising)}, 5, 3},
+	} {
+		if channel := findGPIOTEChannel(tc.configs, tc.pin); channel != tc.channel {
+			t.Errorf("%s: expected channel %d, got %d", tc.name, tc.channel, channel)
+		}
+	}
+}
+
+func TestPinChangeLevelSlot(t *testing.T) {
+	defer func() {
+		levelPins = [len(levelPins)]Pin{}
+		levelCallbacks = [len(levelCallbacks)]func(Pin){}
+	}()
+	callback := func(Pin) {}
+	set := func(i int, p Pin) {
+		levelPins[i] = p
+		levelCallbacks[i] = callback
+	}
+
+	if slot := findLevelSlot(5); slot != 0 {
+		t.Errorf("expected the first slot for no interrupts, got %d", slot)
+	}
+	set(0, 2)
+	set(2, 5)
+	if slot := findLevelSlot(5); slot != 2 {
+		t.Errorf("expected the slot already used for the pin, got %d", slot)
+	}
+	if slot := findLevelSlot(6); slot != 1 {
+		t.Errorf("expected the first free slot, got %d", slot)
+	}
+	set(1, 6)
+	set(3, 7)
+	if slot := findLevelSlot(8); slot != -1 {
+		t.Errorf("expected no free slot, got %d", slot)
+	}
+	if slot := findLevelSlot(7); slot != 3 {
+		t.Errorf("expected the slot already used for the pin when all slots are used, got %d", slot)
+	}
+}
@@ -0,0 +1,31 @@
+// +build stm32
+
+package machine
+
+// PinChange is the condition on which a pin interrupt is triggered.
+type PinChange uint8
+
+// pinLevel is set in a PinChange for level-triggered interrupts.
+const pinLevel PinChange = 1 << 2
+
+// Pin change interrupt constants for SetInterrupt.
+const (
+	PinRising    PinChange = 1 << 0
+	PinFalling   PinChange = 1 << 1
+	PinToggle    PinChange = PinRising | PinFalling
+	PinLevelHigh PinChange = pinLevel | PinRising
+	PinLevelLow  PinChange = pinLevel | PinFalling
+)
+
+// edges returns on which edges the EXTI line must trigger for this change. A
+// level-triggered interrupt triggers on the edge towards its level.
+func (change PinChange) edges() (rising, falling bool) {
+	return change&PinRising != 0, change&PinFalling != 0
+}
+
+// atLevel returns whether this is a level-triggered interrupt and a pin with
+// the given value is at that level. The interrupt must then be triggered in
+// software, as there won't be an edge to trigger it.
+func (change PinChange) atLevel(value bool) bool {
+	return change&pinLevel != 0 && value == (change&PinRising != 0)
+}
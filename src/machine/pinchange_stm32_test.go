@@ -0,0 +1,45 @@
+// +build stm32
+
+package machine
+
+import "testing"
+
+func TestPinChangeEdges(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		change          PinChange
+		rising, falling bool
+	}{
+		{"PinRising", PinRising, true, false},
+		{"PinFalling", PinFalling, false, true},
+		{"PinToggle", PinToggle, true, true},
+		{"PinLevelHigh", PinLevelHigh, true, false},
+		{"PinLevelLow", PinLevelLow, false, true},
+	} {
+		rising, falling := tc.change.edges()
+		if rising != tc.rising || falling != tc.falling {
+			t.Errorf("%s: expected rising=%v falling=%v, got rising=%v falling=%v", tc.name, tc.rising, tc.falling, rising, falling)
+		}
+	}
+}
+
+func TestPinChangeAtLevel(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		change    PinChange
+		low, high bool // expected result for a low and a high pin
+	}{
+		{"PinRising", PinRising, false, false},
+		{"PinFalling", PinFalling, false, false},
+		{"PinToggle", PinToggle, false, false},
+		{"PinLevelHigh", PinLevelHigh, false, true},
+		{"PinLevelLow", PinLevelLow, true, false},
+	} {
+		if low := tc.change.atLevel(false); low != tc.low {
+			t.Errorf("%s: pin low: expected %v, got %v", tc.name, tc.low, low)
+		}
+		if high := tc.change.atLevel(true); high != tc.high {
+			t.Errorf("%s: pin high: expected %v, got %v", tc.name, tc.high, high)
+		}
+	}
+}
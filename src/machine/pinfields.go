@@ -0,0 +1,19 @@
+package machine
+
+// pinFields returns the bits to clear (mask) and the bits to set (bits) in a
+// configuration register that has a field of the given width for every pin, to
+// set the field of all pins in the pins mask to the given value. Bit n of pins
+// refers to the field at position n*width, so with 4-bit fields only the lower
+// 8 pins fit in a register. This is used to configure many pins of a GPIO port
+// with a single register write.
+func pinFields(pins uint16, width uint8, value uint32) (mask, bits uint32) {
+	fieldMask := uint32(1)<<width - 1
+	for pos := uint8(0); pins != 0; pos += width {
+		if pins&1 != 0 {
+			mask |= fieldMask << pos
+			bits |= (value & fieldMask) << pos
+		}
+		pins >>= 1
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package machine
+
+import (
+	"testing"
+)
+
+// configureFields sets the fields of all pins in the pins mask one at a time,
+// like Configure does for a single pin.
+func configureFields(reg uint32, pins uint16, width uint8, value uint32) uint32 {
+	for pin := uint8(0); pin < 16; pin++ {
+		if pins&(1<<pin) == 0 {
+			continue
+		}
+		mask, bits := pinFields(1<<pin, width, value)
+		reg = reg&^mask | bits
+	}
+	return reg
+}
+
+func TestPinFields(t *testing.T) {
+	for _, tc := range []struct {
+		width uint8
+		pins  []uint16
+	}{
+		{2, []uint16{0, 1, 0x8000, 0x00ff, 0xff00, 0xffff, 0x5a5a, 0x8421}},
+		{4, []uint16{0, 1, 0x80, 0x0f, 0xf0, 0xff, 0x5a, 0x81}},
+	} {
+		fieldMask := uint32(1)<<tc.width - 1
+		for _, pins := range tc.pins {
+			for value := uint32(0); value <= fieldMask; value++ {
+				for _, initial := range []uint32{0, 0xffffffff, 0x12345678} {
+					mask, bits := pinFields(pins, tc.width, value)
+					reg := initial&^mask | bits
+
+					// Configuring all pins at once must be the same as
+					// configuring them one by one.
+					if expected := configureFields(initial, pins, tc.width, value); reg != expected {
+						t.Errorf("pinFields(%#x, %d, %d) on %#x: expected %#x, got %#x", pins, tc.width, value, initial, expected, reg)
+					}
+
+					// All pins must end up with the requested value, and all other
+					// fields must be left alone.
+					for pin := uint8(0); pin < 32/tc.width; pin++ {
+						field := reg >> (pin * tc.width) & fieldMask
+						expected := initial >> (pin * tc.width) & fieldMask
+						if pins&(1<<pin) != 0 {
+							expected = value
+						}
+						if field != expected {
+							t.Errorf("pinFields(%#x, %d, %d) on %#x: pin %d has value %d instead of %d", pins, tc.width, value, initial, pin, field, expected)
+						}
+					}
+				}
+			}
+		}
+	}
+}
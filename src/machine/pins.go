@@ -0,0 +1,11 @@
+// +build !stm32,!gameboyadvance
+
+package machine
+
+// ConfigurePins configures all given pins with the same configuration. It is a
+// shortcut for calling Configure on each pin.
+func ConfigurePins(pins []Pin, config PinConfig) {
+	for _, p := range pins {
+		p.Configure(config)
+	}
+}
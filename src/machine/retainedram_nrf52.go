@@ -0,0 +1,44 @@
+// +build nrf52 nrf52840
+
+package machine
+
+import (
+	"device/nrf"
+	"unsafe"
+)
+
+// retainedRAMSize is the size of the buffer returned by RetainedRAM.
+const retainedRAMSize = 256
+
+// retainedRAM is kept across a reset because it is not zeroed at startup.
+//go:retained
+var retainedRAM [retainedRAMSize]byte
+
+// RetainedRAM returns a small buffer in RAM that keeps its contents across a
+// reset. The nRF52 has no separate backup RAM, so this is a normal global that
+// isn't initialized at startup. Its contents survive a soft reset (including
+// a reset by the watchdog or a lockup), a reset from the reset pin and waking
+// up from System OFF mode, as RetainedRAM also enables the retention of its
+// RAM sections in System OFF. They are undefined after a power-on reset or a
+// brown-out reset, so store a magic number or checksum with the data.
+//
+// The returned slice must not contain pointers.
+func RetainedRAM() []byte {
+	start := uintptr(unsafe.Pointer(&retainedRAM[0]))
+	for addr := start &^ 0xfff; addr < start+retainedRAMSize; addr += 0x1000 {
+		block, section := ramSection(addr)
+		nrf.POWER.RAM[block].POWERSET.Set(1 << (16 + section)) // SnRETENTION
+	}
+	return retainedRAM[:]
+}
+
+// ramSection returns the RAM block (the index in POWER.RAM) and the section in
+// that block of the given RAM address. RAM0 to RAM7 have two sections of 4kB,
+// RAM8 (only on the nRF52840) has six sections of 32kB.
+func ramSection(addr uintptr) (block, section uint32) {
+	offset := uint32(addr - 0x20000000)
+	if offset < 64*1024 {
+		return offset / 8192, offset / 4096 % 2
+	}
+	return 8, (offset - 64*1024) / (32 * 1024)
+}
@@ -0,0 +1,45 @@
+// +build stm32,stm32f407
+
+package machine
+
+import (
+	"device/stm32"
+	"unsafe"
+)
+
+// The 4kB backup SRAM of the STM32F4, see section 5.1.2 of RM0090.
+const (
+	backupSRAMStart = 0x40024000
+	backupSRAMSize  = 4096
+)
+
+// RetainedRAM returns the backup SRAM of the chip, after enabling access to it
+// and its low-power regulator. Unlike normal RAM, the backup SRAM keeps its
+// contents after any reset (including a power-on reset when VBAT is still
+// powered), in Standby mode (which resets the chip on wakeup), and when VDD is
+// lost as long as VBAT is connected to a battery. It is only erased by a
+// tamper event or a readout protection level change. The contents are
+// undefined after VBAT has been lost, so store a magic number or checksum with
+// the data.
+//
+// For data that only needs to survive a reset while the chip stays powered, a
+// global with the //go:retained pragma is simpler.
+func RetainedRAM() []byte {
+	// Enable the power interface and disable write protection of the backup
+	// domain.
+	stm32.RCC.APB1ENR.SetBits(stm32.RCC_APB1ENR_PWREN)
+	stm32.PWR.CR.SetBits(0x100) // PWR_CR_DBP
+
+	// Enable the clock of the backup SRAM.
+	stm32.RCC.AHB1ENR.SetBits(1 << 18) // RCC_AHB1ENR_BKPSRAMEN
+
+	// Enable the backup regulator, which keeps the backup SRAM powered from
+	// VBAT in Standby mode and when VDD is lost.
+	stm32.PWR.CSR.SetBits(0x200) // PWR_CSR_BRE
+
+	// Wait until the backup regulator is ready (PWR_CSR_BRR).
+	for !stm32.PWR.CSR.HasBits(0x8) {
+	}
+
+	return (*[backupSRAMSize]byte)(unsafe.Pointer(uintptr(backupSRAMStart)))[:]
+}
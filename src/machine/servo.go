@@ -0,0 +1,184 @@
+// +build sam,atsamd51
+
+package machine
+
+import (
+	"device/sam"
+	"errors"
+)
+
+var (
+	ErrInvalidServoPin     = errors.New("machine: servo pin does not support PWM")
+	ErrInvalidServoChannel = errors.New("machine: invalid servo channel")
+)
+
+const (
+	// Servos expect a pulse every 20ms (50Hz).
+	servoFrequency = 50
+
+	// The timers run at 120MHz/64 = 1.875MHz, so a frame is 37500 ticks and
+	// the pulse width resolution is about 0.53µs.
+	servoTimerFrequency = CPU_FREQUENCY / 64
+	servoPeriod         = servoTimerFrequency / servoFrequency
+)
+
+// ServoConfig is the configuration of a single servo channel in a ServoGroup.
+// The pulse widths are used to calibrate the servo: most servos accept pulses
+// between 1ms and 2ms, but many have a wider range.
+type ServoConfig struct {
+	Pin      Pin
+	MinPulse uint16 // pulse width in µs at 0 degrees (default 1000)
+	MaxPulse uint16 // pulse width in µs at Range degrees (default 2000)
+	Range    uint16 // range of motion in degrees (default 180)
+}
+
+// ServoGroup drives a number of hobby servos from the TCC timers, with one
+// servo channel per pin. All channels are updated in sync: new pulse widths set
+// with SetAngle or SetPulse are staged and only take effect when Update is
+// called, at the start of the next 20ms frame. A frame never contains a mix of
+// old and new pulse widths on channels driven by the same timer.
+//
+// Every timer drives a limited number of pins, as determined by the PWM pin
+// mapping:
+//
+//     TCC0: PA20, PA21, PA22, PA23 (4 channels)
+//     TCC1: PA16, PA17, PA18, PA19 (4 channels)
+//     TCC2: PA14, PA15 (2 channels)
+//
+// So a group can have at most 10 servos. The timers are started at the same
+// time in Configure, so that the frames of different timers start within a few
+// microseconds of each other. The timers are configured for a 20ms period,
+// which means they cannot be used for regular PWM at the same time.
+type ServoGroup struct {
+	servos []ServoConfig
+	timers []*sam.TCC_Type
+}
+
+// Configure sets up the timers and pins of all servos in the group. The index
+// of a servo in the servos slice is its channel number. No pulses are sent to
+// a servo until its position is set and Update is called.
+func (g *ServoGroup) Configure(servos []ServoConfig) error {
+	g.servos = make([]ServoConfig, len(servos))
+	g.timers = g.timers[:0]
+	for i, servo := range servos {
+		timer := PWM{servo.Pin}.getTimer()
+		if timer == nil {
+			return ErrInvalidServoPin
+		}
+		if servo.MinPulse == 0 && servo.MaxPulse == 0 {
+			servo.MinPulse = 1000
+			servo.MaxPulse = 2000
+		}
+		if servo.Range == 0 {
+			servo.Range = 180
+		}
+		g.servos[i] = servo
+
+		found := false
+		for _, t := range g.timers {
+			if t == timer {
+				found = true
+				break
+			}
+		}
+		if !found {
+			g.timers = append(g.timers, timer)
+		}
+	}
+
+	InitPWM()
+	for _, timer := range g.timers {
+		configureServoTimer(timer)
+	}
+	for _, servo := range g.servos {
+		PWM{servo.Pin}.configurePin()
+	}
+
+	// Start all timers at (nearly) the same time.
+	for _, timer := range g.timers {
+		timer.CTRLA.SetBits(sam.TCC_CTRLA_ENABLE)
+	}
+	for _, timer := range g.timers {
+		for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
+		}
+	}
+	return nil
+}
+
+// configureServoTimer configures the given timer for a 20ms period with all
+// outputs low, without enabling it.
+func configureServoTimer(timer *sam.TCC_Type) {
+	timer.CTRLA.ClearBits(sam.TCC_CTRLA_ENABLE)
+	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
+	}
+
+	timer.CTRLA.Set(sam.TCC_CTRLA_PRESCALER_DIV64 | sam.TCC_CTRLA_PRESCSYNC_GCLK)
+	timer.WAVE.Set(sam.TCC_WAVE_WAVEGEN_NPWM)
+	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_WAVE) {
+	}
+	timer.PER.Set(servoPeriod - 1)
+	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_PER) {
+	}
+	for i := range timer.CC {
+		timer.CC[i].Set(0)
+	}
+
+	// Lock the buffered registers, so that new pulse widths are only applied
+	// by Update.
+	timer.CTRLBSET.Set(sam.TCC_CTRLBSET_LUPD)
+	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CTRLB) {
+	}
+}
+
+// SetAngle stages a new position for the servo on the given channel, in
+// degrees between 0 and the range of the servo. Larger angles are clamped to
+// the range. The new position takes effect after the next call to Update.
+func (g *ServoGroup) SetAngle(channel int, degrees uint16) error {
+	if channel < 0 || channel >= len(g.servos) {
+		return ErrInvalidServoChannel
+	}
+	servo := g.servos[channel]
+	if degrees > servo.Range {
+		degrees = servo.Range
+	}
+	// MaxPulse may be smaller than MinPulse for a servo that is mounted in
+	// reverse.
+	pulse := int32(servo.MinPulse) + (int32(servo.MaxPulse)-int32(servo.MinPulse))*int32(degrees)/int32(servo.Range)
+	return g.SetPulse(channel, uint16(pulse))
+}
+
+// SetPulse stages a new pulse width in microseconds for the servo on the given
+// channel, overriding its calibration. A pulse width of 0 stops sending pulses
+// to the servo. The new pulse width takes effect after the next call to
+// Update.
+func (g *ServoGroup) SetPulse(channel int, microseconds uint16) error {
+	if channel < 0 || channel >= len(g.servos) {
+		return ErrInvalidServoChannel
+	}
+	pwm := PWM{g.servos[channel].Pin}
+	timer := pwm.getTimer()
+	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC0 | sam.TCC_SYNCBUSY_CC1 | sam.TCC_SYNCBUSY_CC2 | sam.TCC_SYNCBUSY_CC3) {
+	}
+	pwm.setChannelBuffer(uint32(microseconds) * (servoTimerFrequency / 1000) / 1000)
+	return nil
+}
+
+// Update applies all staged pulse widths at the start of the next frame, and
+// waits until that has happened (at most 20ms). This means that Update can be
+// called in a loop to change the servo positions once every frame.
+func (g *ServoGroup) Update() {
+	// Unlocking the buffered registers makes the timer copy all of them at the
+	// next update event (the end of the current frame). Lock them again right
+	// after that, so that new pulse widths don't leak into the next frame.
+	for _, timer := range g.timers {
+		timer.INTFLAG.Set(sam.TCC_INTFLAG_OVF)
+		timer.CTRLBCLR.Set(sam.TCC_CTRLBCLR_LUPD)
+	}
+	for _, timer := range g.timers {
+		for !timer.INTFLAG.HasBits(sam.TCC_INTFLAG_OVF) {
+		}
+		timer.CTRLBSET.Set(sam.TCC_CTRLBSET_LUPD)
+		for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CTRLB) {
+		}
+	}
+}
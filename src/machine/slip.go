@@ -0,0 +1,172 @@
+package machine
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	ErrSLIPFrameTooLarge = errors.New("machine: SLIP frame too large")
+	ErrSLIPInvalidEscape = errors.New("machine: invalid SLIP escape sequence")
+)
+
+// SLIPMaxFrameSize is the maximum size of a decoded SLIP frame, as recommended
+// by RFC 1055. Larger frames are rejected by both WriteFrame and ReadFrame.
+const SLIPMaxFrameSize = 1006
+
+// Special bytes used by SLIP framing.
+const (
+	slipEnd    = 0xC0 // end of frame
+	slipEsc    = 0xDB // start of escape sequence
+	slipEscEnd = 0xDC // escaped END byte
+	slipEscEsc = 0xDD // escaped ESC byte
+)
+
+// SLIP sends and receives packets over a serial link (usually a UART) using
+// Serial Line IP framing as described in RFC 1055. It does not implement IP in
+// any way: it only splits a byte stream into frames, which can contain any
+// data.
+//
+// Every frame ends with an END byte (0xC0). Frames are also preceded by an END
+// byte, so that line noise received before the frame is sent in a separate
+// (invalid) frame. Bytes in the frame that have a special meaning are escaped:
+//
+//     0xC0 (END) is sent as 0xDB 0xDC (ESC ESC_END)
+//     0xDB (ESC) is sent as 0xDB 0xDD (ESC ESC_ESC)
+//
+// Any other byte following an ESC byte is a protocol violation, and the frame
+// is dropped. Empty frames, for example from two consecutive END bytes, are
+// ignored.
+//
+// For example, to exchange frames over the default UART:
+//
+//     link := &machine.SLIP{Port: machine.UART0}
+//     link.WriteFrame([]byte("hello"))
+//
+// A SLIP object contains a receive buffer of SLIPMaxFrameSize bytes, so it
+// should usually be allocated globally or on the heap.
+type SLIP struct {
+	Port io.ReadWriter
+
+	buf     [SLIPMaxFrameSize]byte // partially received frame
+	n       int                    // number of bytes in buf
+	escaped bool                   // the last byte was an ESC byte
+	err     error                  // error in the current frame, reported at the end
+}
+
+// WriteFrame encodes the given frame and writes it to the port.
+func (s *SLIP) WriteFrame(frame []byte) error {
+	if len(frame) > SLIPMaxFrameSize {
+		return ErrSLIPFrameTooLarge
+	}
+	// Encode the frame in small chunks, to avoid a write for every byte.
+	var chunk [64]byte
+	chunk[0] = slipEnd
+	n := 1
+	for _, c := range frame {
+		if n+2 > len(chunk) {
+			if _, err := s.Port.Write(chunk[:n]); err != nil {
+				return err
+			}
+			n = 0
+		}
+		switch c {
+		case slipEnd:
+			chunk[n] = slipEsc
+			chunk[n+1] = slipEscEnd
+			n += 2
+		case slipEsc:
+			chunk[n] = slipEsc
+			chunk[n+1] = slipEscEsc
+			n += 2
+		default:
+			chunk[n] = c
+			n++
+		}
+	}
+	if n+1 > len(chunk) {
+		if _, err := s.Port.Write(chunk[:n]); err != nil {
+			return err
+		}
+		n = 0
+	}
+	chunk[n] = slipEnd
+	_, err := s.Port.Write(chunk[:n+1])
+	return err
+}
+
+// ReadFrame reads from the port until a complete frame has been received, and
+// copies it into the given buffer. It returns the size of the frame.
+//
+// ReadFrame does not block when no data is available: when the port returns no
+// data before the end of the frame, ReadFrame returns 0 and a nil error. The
+// partially received frame is kept and completed by following calls, so
+// ReadFrame can be called in a loop to wait for a frame (UART reads return
+// immediately when the receive buffer is empty).
+//
+// A frame that is too big or that contains an invalid escape sequence is
+// dropped, and ErrSLIPFrameTooLarge or ErrSLIPInvalidEscape is returned once
+// the end of the frame is received. When the given buffer is smaller than the
+// frame, the frame is truncated and io.ErrShortBuffer is returned.
+func (s *SLIP) ReadFrame(frame []byte) (int, error) {
+	var b [1]byte
+	for {
+		// Read one byte at a time, so that no data of the next frame is read
+		// by accident.
+		n, err := s.Port.Read(b[:])
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, nil
+		}
+		c := b[0]
+
+		if c == slipEnd {
+			n, err := s.n, s.err
+			if s.escaped {
+				err = ErrSLIPInvalidEscape
+			}
+			s.n = 0
+			s.escaped = false
+			s.err = nil
+			if err != nil {
+				return 0, err
+			}
+			if n == 0 {
+				// Empty frame, ignore it.
+				continue
+			}
+			if copy(frame, s.buf[:n]) < n {
+				return len(frame), io.ErrShortBuffer
+			}
+			return n, nil
+		}
+
+		if s.err != nil {
+			// Skip the rest of this frame.
+			continue
+		}
+		if s.escaped {
+			s.escaped = false
+			switch c {
+			case slipEscEnd:
+				c = slipEnd
+			case slipEscEsc:
+				c = slipEsc
+			default:
+				s.err = ErrSLIPInvalidEscape
+				continue
+			}
+		} else if c == slipEsc {
+			s.escaped = true
+			continue
+		}
+		if s.n == len(s.buf) {
+			s.err = ErrSLIPFrameTooLarge
+			continue
+		}
+		s.buf[s.n] = c
+		s.n++
+	}
+}
@@ -0,0 +1,121 @@
+package machine
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// slipTestPort is an in-memory serial port. Like a UART, it returns no data
+// instead of io.EOF when there is nothing to read.
+type slipTestPort struct {
+	bytes.Buffer
+}
+
+func (p *slipTestPort) Read(data []byte) (int, error) {
+	if p.Len() == 0 {
+		return 0, nil
+	}
+	return p.Buffer.Read(data)
+}
+
+func TestSLIPWriteFrame(t *testing.T) {
+	for _, tc := range []struct {
+		frame   []byte
+		encoded []byte
+	}{
+		{[]byte{}, []byte{0xC0, 0xC0}},
+		{[]byte("abc"), []byte{0xC0, 'a', 'b', 'c', 0xC0}},
+		{[]byte{0xC0, 1, 0xDB}, []byte{0xC0, 0xDB, 0xDC, 1, 0xDB, 0xDD, 0xC0}},
+		{[]byte{0xDC, 0xDD}, []byte{0xC0, 0xDC, 0xDD, 0xC0}},
+	} {
+		var buf slipTestPort
+		link := &SLIP{Port: &buf}
+		if err := link.WriteFrame(tc.frame); err != nil {
+			t.Errorf("WriteFrame(%x): %v", tc.frame, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), tc.encoded) {
+			t.Errorf("WriteFrame(%x): expected %x, got %x", tc.frame, tc.encoded, buf.Bytes())
+		}
+	}
+
+	link := &SLIP{Port: &slipTestPort{}}
+	if err := link.WriteFrame(make([]byte, SLIPMaxFrameSize+1)); err != ErrSLIPFrameTooLarge {
+		t.Errorf("WriteFrame of oversized frame: expected ErrSLIPFrameTooLarge, got %v", err)
+	}
+}
+
+func TestSLIPRoundTrip(t *testing.T) {
+	// Frames of all sizes, with lots of bytes that need escaping to test
+	// chunking in WriteFrame.
+	var buf slipTestPort
+	link := &SLIP{Port: &buf}
+	for _, size := range []int{1, 2, 31, 32, 33, 63, 64, 65, SLIPMaxFrameSize} {
+		frame := make([]byte, size)
+		for i := range frame {
+			frame[i] = []byte{0xC0, 0xDB, byte(i)}[i%3]
+		}
+		if err := link.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame of %d bytes: %v", size, err)
+		}
+		received := make([]byte, SLIPMaxFrameSize)
+		n, err := link.ReadFrame(received)
+		if err != nil {
+			t.Fatalf("ReadFrame of %d bytes: %v", size, err)
+		}
+		if !bytes.Equal(received[:n], frame) {
+			t.Errorf("ReadFrame of %d bytes: got a different frame of %d bytes", size, n)
+		}
+	}
+}
+
+func TestSLIPReadFrame(t *testing.T) {
+	var buf slipTestPort
+	link := &SLIP{Port: &buf}
+	frame := make([]byte, SLIPMaxFrameSize)
+	expectFrame := func(expected []byte, expectedErr error) {
+		t.Helper()
+		n, err := link.ReadFrame(frame)
+		if err != expectedErr {
+			t.Errorf("ReadFrame: expected error %v, got %v", expectedErr, err)
+		}
+		if !bytes.Equal(frame[:n], expected) {
+			t.Errorf("ReadFrame: expected %x, got %x", expected, frame[:n])
+		}
+	}
+
+	// No data available.
+	expectFrame([]byte{}, nil)
+
+	// Empty frames are skipped, so there is no need for a leading END byte.
+	buf.Write([]byte{0xC0, 0xC0, 'a', 0xDB, 0xDC, 'b', 0xC0, 'c', 0xC0})
+	expectFrame([]byte{'a', 0xC0, 'b'}, nil)
+	expectFrame([]byte{'c'}, nil)
+	expectFrame([]byte{}, nil)
+
+	// A frame that is received in parts.
+	buf.Write([]byte{0xC0, 'x', 0xDB})
+	expectFrame([]byte{}, nil)
+	buf.Write([]byte{0xDD, 'y', 0xC0})
+	expectFrame([]byte{'x', 0xDB, 'y'}, nil)
+
+	// Invalid escape sequences drop the frame.
+	buf.Write([]byte{'a', 0xDB, 'b', 'c', 0xC0, 'd', 0xDB, 0xC0, 'e', 0xC0})
+	expectFrame([]byte{}, ErrSLIPInvalidEscape)
+	expectFrame([]byte{}, ErrSLIPInvalidEscape)
+	expectFrame([]byte{'e'}, nil)
+
+	// Frames that are too large are dropped.
+	buf.Write(bytes.Repeat([]byte{'a'}, SLIPMaxFrameSize+1))
+	buf.Write([]byte{0xC0, 'b', 0xC0})
+	expectFrame([]byte{}, ErrSLIPFrameTooLarge)
+	expectFrame([]byte{'b'}, nil)
+
+	// The receive buffer is too small.
+	buf.Write([]byte{'a', 'b', 'c', 0xC0})
+	n, err := link.ReadFrame(frame[:2])
+	if n != 2 || err != io.ErrShortBuffer {
+		t.Errorf("ReadFrame with a short buffer: expected 2 and io.ErrShortBuffer, got %d and %v", n, err)
+	}
+}
@@ -1,4 +1,4 @@
-// +build sam stm32,!stm32f407
+// +build sam stm32,!stm32f407 !baremetal
 
 package machine
 
@@ -8,32 +8,16 @@ var (
 	ErrTxInvalidSliceSize = errors.New("SPI write and read slices must be same size")
 )
 
-// Tx handles read/write operation for SPI interface. Since SPI is a syncronous write/read
-// interface, there must always be the same number of bytes written as bytes read.
-// The Tx method knows about this, and offers a few different ways of calling it.
-//
-// This form sends the bytes in tx buffer, putting the resulting bytes read into the rx buffer.
-// Note that the tx and rx buffers must be the same size:
-//
-// 		spi.Tx(tx, rx)
-//
-// This form sends the tx buffer, ignoring the result. Useful for sending "commands" that return zeros
-// until all the bytes in the command packet have been received:
-//
-// 		spi.Tx(tx, nil)
-//
-// This form sends zeros, putting the result into the rx buffer. Good for reading a "result packet":
-//
-// 		spi.Tx(nil, rx)
-//
-func (spi SPI) Tx(w, r []byte) error {
+// tx sends and receives the bytes in w and r, one byte at a time. See SPI.Tx
+// for the ways in which it can be called.
+func (spi SPI) tx(w, r []byte) error {
 	var err error
 
 	switch {
 	case w == nil:
 		// read only, so write zero and read a result.
 		for i := range r {
-			r[i], err = spi.Transfer(0)
+			r[i], err = spi.transfer(0)
 			if err != nil {
 				return err
 			}
@@ -41,7 +25,7 @@ func (spi SPI) Tx(w, r []byte) error {
 	case r == nil:
 		// write only
 		for _, b := range w {
-			_, err = spi.Transfer(b)
+			_, err = spi.transfer(b)
 			if err != nil {
 				return err
 			}
@@ -54,7 +38,7 @@ func (spi SPI) Tx(w, r []byte) error {
 		}
 
 		for i, b := range w {
-			r[i], err = spi.Transfer(b)
+			r[i], err = spi.transfer(b)
 			if err != nil {
 				return err
 			}
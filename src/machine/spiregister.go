@@ -0,0 +1,81 @@
+package machine
+
+// SPIDevice is a single device on a SPI bus. It is usually a SPI bus together
+// with the chip select pin of the device, but it can also be a mock bus in
+// tests.
+type SPIDevice interface {
+	// WriteRead selects the device, sends all bytes in w, then receives
+	// len(r) bytes (while sending zeros) and deselects the device again. Both
+	// w and r may be empty. The chip select must stay active during the whole
+	// transaction. With a SPI bus and a chip select pin, it can be implemented
+	// as:
+	//
+	//     cs.Low()
+	//     spi.Tx(w, nil)
+	//     spi.Tx(nil, r)
+	//     cs.High()
+	WriteRead(w, r []byte) error
+}
+
+// SPIRegister reads and writes the registers of a device on a SPI bus that
+// exposes a register map, like many sensors. Every access is a single
+// transaction that starts with the register address, followed by the data.
+//
+// Devices differ in how they mark reads and writes in the address byte, which
+// is configured with the flags below. They are ORed into the register address
+// before it is sent. Some examples:
+//
+//     // BMP280, BME280 and most other sensors: bit 7 is set for reads.
+//     ReadFlag: 0x80
+//
+//     // LIS3DH, ADXL345: as above, and bit 6 enables auto-increment.
+//     ReadFlag: 0x80, MultiFlag: 0x40
+//
+//     // nRF24L01: read and write commands, bit 5 is set for writes.
+//     WriteFlag: 0x20
+//
+// A SPIRegister must not be copied after first use and must not be used from
+// multiple goroutines at the same time, as it contains the buffers for the
+// address and data bytes (so that an access doesn't need a heap allocation).
+type SPIRegister struct {
+	Device SPIDevice
+
+	// ReadFlag is ORed into the register address of every read.
+	ReadFlag uint8
+
+	// WriteFlag is ORed into the register address of every write.
+	WriteFlag uint8
+
+	// MultiFlag is ORed into the register address (in addition to ReadFlag)
+	// when reading multiple registers at once, for devices that only
+	// auto-increment the address when a bit is set. Leave it at zero for
+	// devices that always auto-increment.
+	MultiFlag uint8
+
+	buf [2]byte
+}
+
+// ReadReg returns the value of the register at the given address.
+func (r *SPIRegister) ReadReg(addr uint8) (uint8, error) {
+	r.buf[0] = addr | r.ReadFlag
+	err := r.Device.WriteRead(r.buf[:1], r.buf[1:2])
+	return r.buf[1], err
+}
+
+// WriteReg writes a value to the register at the given address.
+func (r *SPIRegister) WriteReg(addr, val uint8) error {
+	r.buf[0] = addr | r.WriteFlag
+	r.buf[1] = val
+	return r.Device.WriteRead(r.buf[:2], nil)
+}
+
+// ReadRegs reads len(buf) consecutive registers in a single transaction,
+// starting at the given address. The device must support auto-increment (see
+// MultiFlag).
+func (r *SPIRegister) ReadRegs(addr uint8, buf []byte) error {
+	r.buf[0] = addr | r.ReadFlag
+	if len(buf) > 1 {
+		r.buf[0] |= r.MultiFlag
+	}
+	return r.Device.WriteRead(r.buf[:1], buf)
+}
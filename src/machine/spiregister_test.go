@@ -0,0 +1,72 @@
+package machine
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// spiTestDevice is a fake SPI device with a register map, in the style of the
+// nRF24L01: bit 5 of the address byte is set for writes. Reads of multiple
+// registers only auto-increment when bit 6 is set.
+type spiTestDevice struct {
+	registers [32]byte
+	addresses []byte // first byte of every transaction
+	err       error
+}
+
+func (d *spiTestDevice) WriteRead(w, r []byte) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.addresses = append(d.addresses, w[0])
+	addr := w[0] & 0x1f
+	if w[0]&0x20 != 0 {
+		copy(d.registers[addr:], w[1:])
+		return nil
+	}
+	for i := range r {
+		r[i] = d.registers[addr]
+		if w[0]&0x40 != 0 {
+			addr++
+		}
+	}
+	return nil
+}
+
+func TestSPIRegister(t *testing.T) {
+	dev := &spiTestDevice{}
+	reg := &SPIRegister{Device: dev, WriteFlag: 0x20, MultiFlag: 0x40}
+
+	for i := uint8(0); i < 4; i++ {
+		if err := reg.WriteReg(3+i, 0xa0+i); err != nil {
+			t.Fatal("could not write register:", err)
+		}
+	}
+	val, err := reg.ReadReg(4)
+	if err != nil {
+		t.Fatal("could not read register:", err)
+	}
+	if val != 0xa1 {
+		t.Errorf("expected register 4 to be 0xa1, got %#x", val)
+	}
+	buf := make([]byte, 3)
+	if err := reg.ReadRegs(3, buf); err != nil {
+		t.Fatal("could not read registers:", err)
+	}
+	if !bytes.Equal(buf, []byte{0xa0, 0xa1, 0xa2}) {
+		t.Errorf("unexpected register values: %#v", buf)
+	}
+
+	// The flags must be added to the addresses as configured.
+	expected := []byte{0x23, 0x24, 0x25, 0x26, 0x04, 0x43}
+	if !bytes.Equal(dev.addresses, expected) {
+		t.Errorf("expected addresses %#v, got %#v", expected, dev.addresses)
+	}
+
+	// Errors of the device are returned.
+	dev.err = errors.New("bus error")
+	if _, err := reg.ReadReg(0); err != dev.err {
+		t.Errorf("expected bus error, got %v", err)
+	}
+}
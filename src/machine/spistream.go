@@ -0,0 +1,276 @@
+// +build sam,atsamd51
+
+package machine
+
+import (
+	"device/arm"
+	"device/sam"
+	"errors"
+	"runtime/volatile"
+	"unsafe"
+)
+
+var (
+	ErrSPIStreamBuffer  = errors.New("machine: SPI stream buffer must be between 1 and 65535 bytes")
+	ErrSPIStreamChannel = errors.New("machine: invalid DMA channel for SPI stream")
+)
+
+// dmaDescriptor is a DMA transfer descriptor, as read by the DMAC from memory.
+// Descriptors must be aligned to 16 bytes.
+type dmaDescriptor struct {
+	btctrl   volatile.Register16
+	btcnt    volatile.Register16
+	srcaddr  volatile.Register32 // end address of the source (after the last byte)
+	dstaddr  volatile.Register32
+	descaddr volatile.Register32 // next descriptor, or 0 for the last block
+}
+
+// Bits of the BTCTRL field of a DMA descriptor.
+const (
+	dmaBTCTRL_VALID         = 1 << 0
+	dmaBTCTRL_BLOCKACT_INT  = 1 << 3 // interrupt after the block and continue
+	dmaBTCTRL_BEATSIZE_BYTE = 0 << 8
+	dmaBTCTRL_SRCINC        = 1 << 10
+)
+
+// Number of DMA channels that can be used for SPI streams. Only the first four
+// channels have their own interrupt; the other channels share one.
+const spiStreamChannels = 4
+
+// The first descriptor of every channel is stored in a table pointed to by
+// BASEADDR, and the DMAC writes the state of active channels to a table pointed
+// to by WRBADDR. Only the first channels are used, so the tables don't need to
+// be larger than that.
+//go:align 16
+var dmaDescriptors [spiStreamChannels]dmaDescriptor
+
+//go:align 16
+var dmaWriteback [spiStreamChannels]dmaDescriptor
+
+// The second descriptor of the buffer ring of every SPI stream.
+//go:align 16
+var spiStreamDescriptors [spiStreamChannels]dmaDescriptor
+
+// Streams that are currently running, by DMA channel.
+var spiStreams [spiStreamChannels]*SPIStream
+
+// SPIStream continuously sends data over a SPI bus using DMA, without any gaps
+// between buffers. This is useful for peripherals that need a constant stream
+// of data, like audio DACs and some LED strips.
+//
+// Two buffers are sent alternately: while the DMA controller sends one buffer,
+// the other buffer can be refilled. Every time a buffer has been sent
+// completely, Next is called with that buffer and must return the buffer to
+// send after the buffer that is currently being sent. It may return the same
+// buffer after filling it with new data, or a different buffer that has been
+// prepared before. Next is called from an interrupt, so it must be fast.
+//
+// Timing is important. Next must return (and the returned buffer must be
+// filled) before the other buffer has been sent, which takes
+// len(buffer)*8/frequency seconds. If it is too late, the DMA controller sends
+// whatever is in the buffer at that time: usually the old data from the
+// previous round, possibly partially overwritten with new data. The stream
+// doesn't stop on such an underrun. Use larger buffers to allow for more time
+// to fill them.
+//
+// Data received on the SPI bus is ignored while the stream is running. The SPI
+// bus must be configured before starting the stream, and must not be used for
+// anything else until the stream is stopped.
+type SPIStream struct {
+	SPI SPI
+
+	// Channel is the DMA channel to use, between 0 and 3. Every stream that
+	// runs at the same time needs a different channel.
+	Channel uint8
+
+	// Next is called each time a buffer has been sent, see SPIStream. When it
+	// returns nil, the stream stops after the buffer that is currently being
+	// sent.
+	Next func(done []byte) []byte
+
+	buffers [2][]byte // to keep the buffers alive, and to pass them to Next
+}
+
+// Start starts sending the two given buffers alternately, starting with bufA.
+// Next is not called before bufA has been sent, so both buffers should contain
+// data to send. It returns an error when a buffer is empty or too large, or
+// when the DMA channel is invalid or already in use.
+func (s *SPIStream) Start(bufA, bufB []byte) error {
+	if s.Channel >= spiStreamChannels || spiStreams[s.Channel] != nil {
+		return ErrSPIStreamChannel
+	}
+	for _, buf := range [2][]byte{bufA, bufB} {
+		if len(buf) == 0 || len(buf) > 0xffff {
+			return ErrSPIStreamBuffer
+		}
+	}
+	trigger, ok := s.SPI.dmaTrigger()
+	if !ok {
+		return ErrSPIStreamChannel
+	}
+
+	if !sam.DMAC.CTRL.HasBits(sam.DMAC_CTRL_DMAENABLE) {
+		// The DMAC clock is enabled at reset, so the DMAC only needs to be
+		// enabled here. The descriptor tables can only be set while it is
+		// disabled.
+		sam.DMAC.BASEADDR.Set(uint32(uintptr(unsafe.Pointer(&dmaDescriptors))))
+		sam.DMAC.WRBADDR.Set(uint32(uintptr(unsafe.Pointer(&dmaWriteback))))
+		sam.DMAC.CTRL.Set(sam.DMAC_CTRL_DMAENABLE | sam.DMAC_CTRL_LVLEN0 | sam.DMAC_CTRL_LVLEN1 | sam.DMAC_CTRL_LVLEN2 | sam.DMAC_CTRL_LVLEN3)
+	}
+
+	// Received data would overflow the receive buffer, as nothing reads it.
+	s.SPI.Bus.CTRLB.ClearBits(sam.SERCOM_SPIM_CTRLB_RXEN)
+	for s.SPI.Bus.SYNCBUSY.HasBits(sam.SERCOM_SPIM_SYNCBUSY_CTRLB) {
+	}
+
+	// Set up a ring of two descriptors that point to each other, so that the
+	// DMAC keeps sending them until the channel is disabled.
+	first := &dmaDescriptors[s.Channel]
+	second := &spiStreamDescriptors[s.Channel]
+	s.buffers = [2][]byte{bufA, bufB}
+	s.setDescriptor(first, bufA, second)
+	s.setDescriptor(second, bufB, first)
+
+	channel := &sam.DMAC.CHANNEL[s.Channel]
+	channel.CHCTRLA.ClearBits(sam.DMAC_CHANNEL_CHCTRLA_ENABLE)
+	for channel.CHCTRLA.HasBits(sam.DMAC_CHANNEL_CHCTRLA_ENABLE) {
+	}
+	channel.CHCTRLA.Set(sam.DMAC_CHANNEL_CHCTRLA_SWRST)
+	for channel.CHCTRLA.HasBits(sam.DMAC_CHANNEL_CHCTRLA_SWRST) {
+	}
+	// Send a single byte every time the SERCOM data register is empty.
+	channel.CHCTRLA.Set((trigger << sam.DMAC_CHANNEL_CHCTRLA_TRIGSRC_Pos) |
+		(sam.DMAC_CHANNEL_CHCTRLA_TRIGACT_BURST << sam.DMAC_CHANNEL_CHCTRLA_TRIGACT_Pos) |
+		(sam.DMAC_CHANNEL_CHCTRLA_BURSTLEN_SINGLE << sam.DMAC_CHANNEL_CHCTRLA_BURSTLEN_Pos))
+	channel.CHINTFLAG.Set(sam.DMAC_CHANNEL_CHINTFLAG_TCMPL | sam.DMAC_CHANNEL_CHINTFLAG_TERR)
+	channel.CHINTENSET.Set(sam.DMAC_CHANNEL_CHINTENSET_TCMPL | sam.DMAC_CHANNEL_CHINTENSET_TERR)
+
+	spiStreams[s.Channel] = s
+	arm.EnableIRQ(sam.IRQ_DMAC_0 + uint32(s.Channel))
+	channel.CHCTRLA.SetBits(sam.DMAC_CHANNEL_CHCTRLA_ENABLE)
+	return nil
+}
+
+// Stop stops the stream immediately, possibly in the middle of a buffer, and
+// enables the SPI receiver again.
+func (s *SPIStream) Stop() {
+	if s.Channel >= spiStreamChannels || spiStreams[s.Channel] != s {
+		return // not running
+	}
+	arm.DisableIRQ(sam.IRQ_DMAC_0 + uint32(s.Channel))
+	s.stop()
+}
+
+// stop disables the DMA channel and releases it.
+func (s *SPIStream) stop() {
+	channel := &sam.DMAC.CHANNEL[s.Channel]
+	channel.CHCTRLA.ClearBits(sam.DMAC_CHANNEL_CHCTRLA_ENABLE)
+	for channel.CHCTRLA.HasBits(sam.DMAC_CHANNEL_CHCTRLA_ENABLE) {
+	}
+	channel.CHINTENCLR.Set(sam.DMAC_CHANNEL_CHINTENCLR_TCMPL | sam.DMAC_CHANNEL_CHINTENCLR_TERR)
+	spiStreams[s.Channel] = nil
+	s.buffers = [2][]byte{}
+
+	// Wait until the last byte has been sent before enabling the receiver.
+	for !s.SPI.Bus.INTFLAG.HasBits(sam.SERCOM_SPIM_INTFLAG_TXC) {
+	}
+	s.SPI.Bus.CTRLB.SetBits(sam.SERCOM_SPIM_CTRLB_RXEN)
+	for s.SPI.Bus.SYNCBUSY.HasBits(sam.SERCOM_SPIM_SYNCBUSY_CTRLB) {
+	}
+}
+
+// setDescriptor configures a descriptor to send the given buffer, followed by
+// the next descriptor.
+func (s *SPIStream) setDescriptor(desc *dmaDescriptor, buf []byte, next *dmaDescriptor) {
+	desc.btctrl.Set(0) // invalidate while changing it
+	desc.btcnt.Set(uint16(len(buf)))
+	desc.srcaddr.Set(uint32(uintptr(unsafe.Pointer(&buf[0])) + uintptr(len(buf))))
+	desc.dstaddr.Set(uint32(uintptr(unsafe.Pointer(&s.SPI.Bus.DATA))))
+	desc.descaddr.Set(uint32(uintptr(unsafe.Pointer(next))))
+	desc.btctrl.Set(dmaBTCTRL_VALID | dmaBTCTRL_BLOCKACT_INT | dmaBTCTRL_BEATSIZE_BYTE | dmaBTCTRL_SRCINC)
+}
+
+// handleInterrupt is called when a buffer has been sent or when the stream
+// stopped.
+func (s *SPIStream) handleInterrupt() {
+	channel := &sam.DMAC.CHANNEL[s.Channel]
+	flags := channel.CHINTFLAG.Get()
+	channel.CHINTFLAG.Set(flags)
+
+	if flags&sam.DMAC_CHANNEL_CHINTFLAG_TCMPL != 0 {
+		// The DMAC is now sending one buffer, and will send the buffer that
+		// was just finished after that. The finished buffer is the one the
+		// active descriptor points to.
+		first := &dmaDescriptors[s.Channel]
+		second := &spiStreamDescriptors[s.Channel]
+		index, desc, other := 0, first, second
+		if dmaWriteback[s.Channel].descaddr.Get() == uint32(uintptr(unsafe.Pointer(second))) {
+			index, desc, other = 1, second, first
+		}
+		var next []byte
+		if s.Next != nil {
+			next = s.Next(s.buffers[index])
+		}
+		if len(next) == 0 || len(next) > 0xffff {
+			// Stop after the current buffer: the DMAC finds an invalid
+			// descriptor and disables the channel with a transfer error.
+			desc.btctrl.Set(0)
+		} else {
+			s.buffers[index] = next
+			s.setDescriptor(desc, next, other)
+		}
+	}
+
+	if flags&sam.DMAC_CHANNEL_CHINTFLAG_TERR != 0 {
+		s.stop()
+	}
+}
+
+// dmaTrigger returns the DMA trigger source for sending data over this SPI
+// bus.
+func (spi SPI) dmaTrigger() (uint32, bool) {
+	// The TX trigger of SERCOMn is 0x05 + 2*n.
+	switch spi.Bus {
+	case sam.SERCOM0_SPIM:
+		return 0x05, true
+	case sam.SERCOM1_SPIM:
+		return 0x07, true
+	case sam.SERCOM2_SPIM:
+		return 0x09, true
+	case sam.SERCOM3_SPIM:
+		return 0x0B, true
+	case sam.SERCOM4_SPIM:
+		return 0x0D, true
+	case sam.SERCOM5_SPIM:
+		return 0x0F, true
+	default:
+		return 0, false
+	}
+}
+
+// handleSPIStream handles the interrupt of the given DMA channel.
+func handleSPIStream(channel uint8) {
+	if s := spiStreams[channel]; s != nil {
+		s.handleInterrupt()
+	}
+}
+
+//go:export DMAC_0_IRQHandler
+func handleDMAC_0() {
+	handleSPIStream(0)
+}
+
+//go:export DMAC_1_IRQHandler
+func handleDMAC_1() {
+	handleSPIStream(1)
+}
+
+//go:export DMAC_2_IRQHandler
+func handleDMAC_2() {
+	handleSPIStream(2)
+}
+
+//go:export DMAC_3_IRQHandler
+func handleDMAC_3() {
+	handleSPIStream(3)
+}
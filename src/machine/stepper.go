@@ -0,0 +1,223 @@
+package machine
+
+import "errors"
+
+var (
+	// ErrStepperNotSupported is returned by StepperMotor.Configure on chips
+	// without support for hardware timed steppers, or when all timers are
+	// already in use by other steppers.
+	ErrStepperNotSupported = errors.New("machine: stepper motors are not supported or all timers are in use")
+
+	// ErrInvalidSpeedProfile is returned by StepperMotor.SetSpeedProfile when
+	// the maximum speed or acceleration is zero or the maximum speed is too
+	// high.
+	ErrInvalidSpeedProfile = errors.New("machine: invalid stepper speed profile")
+)
+
+const (
+	// The step timing is calculated in ticks of a 1MHz timer.
+	stepperTickFrequency = 1000000
+
+	// StepperMaxSpeed is the highest supported speed in steps per second. See
+	// StepperMotor for the effect of the timer resolution at high speeds.
+	StepperMaxSpeed = 50000
+)
+
+// StepperConfig is the configuration of a stepper motor, connected through a
+// driver with a step and direction input, like the A4988, DRV8825 or the
+// TMC2208 in standalone mode.
+type StepperConfig struct {
+	// Step is pulsed high once for every (micro)step.
+	Step Pin
+
+	// Dir is high while moving to a higher position and low while moving to a
+	// lower position. Swap the wires of one coil of the motor to reverse the
+	// direction.
+	Dir Pin
+}
+
+// SpeedProfile is the speed profile of the moves of a stepper motor. Every
+// move accelerates at a constant rate from standstill up to the maximum speed,
+// then continues at that speed and decelerates at a constant rate to stop at
+// the target position (a trapezoidal profile). Short moves never reach the
+// maximum speed: they start decelerating halfway (a triangular profile).
+type SpeedProfile struct {
+	MaxSpeed     uint32 // maximum speed in steps per second
+	Acceleration uint32 // acceleration in steps per second²
+	Deceleration uint32 // deceleration in steps per second², or 0 for the same as Acceleration
+}
+
+// StepperMotor moves a stepper motor to a position with the speed profile set
+// by SetSpeedProfile, using a hardware timer for precise step timing. The step
+// pulses are generated from the timer interrupt, so the program can do other
+// work (or sleep) during a move. The position is counted in steps, starting at
+// zero (see SetPosition to set it after homing).
+//
+// The step intervals are calculated in real time with the algorithm described
+// by David Austin in "Generate stepper-motor speed profiles in real time"
+// (2005), which needs a single division per step. The timer counts in 1µs
+// ticks and every step starts at an exact tick, counted from the previous step
+// (so the interrupt latency doesn't add up), which means the step interval is
+// rounded to a whole microsecond. This limits the resolution of the speed at
+// high step rates: at 10000 steps per second (100µs per step) the speed can be
+// set in steps of 1%, at 50000 steps per second (20µs) only in steps of 5%.
+// Additionally, every step takes a few microseconds in the interrupt handler,
+// during which the CPU can't do anything else. Use microstepping to get a
+// smoother movement at low speeds rather than higher step rates at high
+// speeds.
+//
+// The step pulse lasts while the interrupt handler calculates the next
+// interval, which takes a few microseconds. That is long enough for common
+// drivers, which need a pulse of 1µs to 2µs.
+//
+// Where available, a stepper motor uses the following timers:
+//
+//   - STM32F103: TIM2 and TIM4, in that order, so there can be two motors.
+//     These are the same timers as used by the hardware decoder of Encoder on
+//     PA0/PA1 and PB6/PB7, which can't be used at the same time.
+type StepperMotor struct {
+	config   StepperConfig
+	position int32  // current position in steps
+	target   int32  // position to move to
+	forward  bool   // direction of the current move
+	running  bool   // timer is running
+	timer    uint8  // hardware timer (target specific)
+	wait     uint32 // ticks left of an interval longer than the timer period
+
+	// The state of the speed profile, as in the paper by David Austin: n is
+	// the number of steps since the start of the acceleration, or minus the
+	// number of steps until the end of the deceleration. The interval of the
+	// current step is c, in ticks with 8 fractional bits. When the maximum
+	// speed is reached, c is equal to cMin and n stays the same.
+	n     int32
+	c     uint32
+	c0    uint32 // interval of the first step
+	cMin  uint32 // interval at the maximum speed
+	accel uint32
+	decel uint32
+}
+
+// Position returns the current position of the motor, in steps.
+func (s *StepperMotor) Position() int32 {
+	return s.position
+}
+
+// Target returns the position the motor is moving to, as set by MoveTo or
+// Stop.
+func (s *StepperMotor) Target() int32 {
+	return s.target
+}
+
+// Moving returns whether the motor is currently moving.
+func (s *StepperMotor) Moving() bool {
+	return s.running
+}
+
+// setSpeedProfile calculates the parameters of the speed profile, see
+// SetSpeedProfile.
+func (s *StepperMotor) setSpeedProfile(profile SpeedProfile) error {
+	if profile.MaxSpeed == 0 || profile.MaxSpeed > StepperMaxSpeed || profile.Acceleration == 0 {
+		return ErrInvalidSpeedProfile
+	}
+	s.accel = profile.Acceleration
+	s.decel = profile.Deceleration
+	if s.decel == 0 {
+		s.decel = s.accel
+	}
+	s.cMin = stepperTickFrequency << 8 / profile.MaxSpeed
+
+	// The first interval is the time to move one step from standstill:
+	// sqrt(2/a) seconds. It is corrected by a factor 0.676, see equation 15 in
+	// the paper. So c0² = 0.676² * 2 * f² / a, in ticks with 8 fractional
+	// bits (multiplied by 256²).
+	const c0Factor = 59897 // 0.676² * 2 * 256²
+	s.c0 = isqrt64(c0Factor * stepperTickFrequency * stepperTickFrequency / uint64(s.accel))
+	if s.c0 < s.cMin {
+		s.c0 = s.cMin
+	}
+	return nil
+}
+
+// stepsToStop returns the number of steps it takes to decelerate to a stop
+// from the current speed.
+func (s *StepperMotor) stepsToStop() int32 {
+	if s.n < 0 {
+		return -s.n
+	}
+	// The speed after accelerating n steps at a is the same as the speed n*a/d
+	// steps before stopping at d.
+	return int32(uint64(s.n) * uint64(s.accel) / uint64(s.decel))
+}
+
+// update calculates the interval until the next step, after the position has
+// been updated for the current step (or when starting to move). It returns the
+// interval in ticks, or 0 when the motor has reached the target and stopped.
+// The direction of the next step is stored in s.forward.
+func (s *StepperMotor) update() uint32 {
+	distance := s.target - s.position
+	stop := s.stepsToStop()
+	if distance == 0 && stop <= 1 {
+		// Arrived at the target at (nearly) zero speed.
+		s.n = 0
+		return 0
+	}
+	forward := distance > 0
+	if distance < 0 {
+		distance = -distance
+	}
+
+	if s.n > 0 {
+		// Accelerating or at full speed: start decelerating when the target
+		// is near or when it is in the other direction.
+		if forward != s.forward || distance <= stop {
+			s.n = -stop
+		}
+	} else if s.n < 0 {
+		// Decelerating: accelerate again when the target has moved further
+		// away (through MoveTo) in the same direction.
+		if forward == s.forward && distance > stop {
+			s.n = int32(uint64(stop) * uint64(s.decel) / uint64(s.accel))
+			if s.n == 0 {
+				s.n = 1
+			}
+		}
+	}
+
+	switch {
+	case s.n == 0:
+		// First step from standstill, possibly in the other direction after
+		// decelerating.
+		s.forward = forward
+		s.c = s.c0
+	case s.n > 0 && s.c == s.cMin:
+		// Running at the maximum speed.
+		return s.c >> 8
+	default:
+		// Accelerate (n > 0) or decelerate (n < 0), equation 13 in the paper.
+		c := int64(s.c) - 2*int64(s.c)/(4*int64(s.n)+1)
+		if c < int64(s.cMin) {
+			c = int64(s.cMin)
+		}
+		s.c = uint32(c)
+	}
+	s.n++
+	return s.c >> 8
+}
+
+// isqrt64 returns the integer square root of x, rounded down.
+func isqrt64(x uint64) uint32 {
+	var root, bit uint64 = 0, 1 << 62
+	for bit > x {
+		bit >>= 2
+	}
+	for bit != 0 {
+		if x >= root+bit {
+			x -= root + bit
+			root = root>>1 + bit
+		} else {
+			root >>= 1
+		}
+		bit >>= 2
+	}
+	return uint32(root)
+}
@@ -0,0 +1,32 @@
+// +build !stm32f103xx
+
+package machine
+
+// Configure returns ErrStepperNotSupported, as there is no timer support for
+// stepper motors on this chip.
+func (s *StepperMotor) Configure(config StepperConfig) error {
+	return ErrStepperNotSupported
+}
+
+// SetSpeedProfile sets the maximum speed, acceleration and deceleration of the
+// motor.
+func (s *StepperMotor) SetSpeedProfile(profile SpeedProfile) error {
+	return s.setSpeedProfile(profile)
+}
+
+// MoveTo sets the target position of the motor, but the motor doesn't move on
+// this chip.
+func (s *StepperMotor) MoveTo(position int32) {
+	s.target = position
+}
+
+// Stop sets the target to the current position.
+func (s *StepperMotor) Stop() {
+	s.target = s.position
+}
+
+// SetPosition sets the current position (and the target) of the motor.
+func (s *StepperMotor) SetPosition(position int32) {
+	s.position = position
+	s.target = position
+}
@@ -0,0 +1,189 @@
+// +build stm32,stm32f103xx
+
+package machine
+
+import (
+	"device/arm"
+	"device/stm32"
+)
+
+// stepperMotors are the motors driven by TIM2 and TIM4, for the interrupt
+// handlers.
+var stepperMotors [2]*StepperMotor
+
+// Configure configures the step and direction pins and assigns a timer to the
+// motor. The position is not changed. It returns ErrStepperNotSupported when
+// both timers are already in use by other motors. A speed profile must be set
+// with SetSpeedProfile before the motor can move.
+func (s *StepperMotor) Configure(config StepperConfig) error {
+	if s.timer == 0 {
+		for i, m := range stepperMotors {
+			if m == nil {
+				stepperMotors[i] = s
+				s.timer = 2 + 2*uint8(i) // TIM2 or TIM4
+				break
+			}
+		}
+		if s.timer == 0 {
+			return ErrStepperNotSupported
+		}
+	}
+	s.config = config
+	config.Step.Configure(PinConfig{Mode: PinOutput50MHz + PinOutputModeGPPushPull})
+	config.Step.Low()
+	config.Dir.Configure(PinConfig{Mode: PinOutput50MHz + PinOutputModeGPPushPull})
+
+	// Count at 1MHz. The prescaler is only loaded on an update event, which is
+	// generated here with URS set, so that only overflows cause an interrupt.
+	tim := s.getTimer()
+	irq := uint32(stm32.IRQ_TIM2)
+	if s.timer == 4 {
+		stm32.RCC.APB1ENR.SetBits(stm32.RCC_APB1ENR_TIM4EN)
+		irq = stm32.IRQ_TIM4
+	} else {
+		stm32.RCC.APB1ENR.SetBits(stm32.RCC_APB1ENR_TIM2EN)
+	}
+	tim.CR1.Set(stm32.TIM_CR1_URS)
+	tim.PSC.Set(CPU_FREQUENCY/stepperTickFrequency - 1)
+	tim.EGR.Set(stm32.TIM_EGR_UG)
+	tim.SR.ClearBits(stm32.TIM_SR_UIF)
+	tim.DIER.SetBits(stm32.TIM_DIER_UIE)
+
+	// Steps are delayed by interrupts with the same or a higher priority, so
+	// use a higher priority than the runtime timer.
+	arm.SetPriority(irq, 0x40)
+	arm.EnableIRQ(irq)
+	return nil
+}
+
+// SetSpeedProfile sets the maximum speed, acceleration and deceleration of the
+// motor. The maximum speed must not be higher than StepperMaxSpeed. The
+// profile should only be changed while the motor is at a standstill: changes
+// during a move take effect immediately, but the speed doesn't follow the new
+// profile exactly until the next move.
+func (s *StepperMotor) SetSpeedProfile(profile SpeedProfile) error {
+	mask := arm.DisableInterrupts()
+	err := s.setSpeedProfile(profile)
+	arm.EnableInterrupts(mask)
+	return err
+}
+
+// MoveTo starts moving the motor to the given position, and returns
+// immediately. Use Moving to check whether the motor has arrived. A new target
+// can be set at any time, even while moving: the motor then decelerates (and
+// reverses, if needed) or accelerates to the new target, according to the
+// speed profile.
+func (s *StepperMotor) MoveTo(position int32) {
+	mask := arm.DisableInterrupts()
+	s.target = position
+	if !s.running && s.cMin != 0 {
+		s.start()
+	}
+	arm.EnableInterrupts(mask)
+}
+
+// Stop decelerates the motor to a standstill as fast as the speed profile
+// allows. The target is changed to the position where the motor will stop.
+func (s *StepperMotor) Stop() {
+	mask := arm.DisableInterrupts()
+	if s.running {
+		if s.forward {
+			s.target = s.position + s.stepsToStop()
+		} else {
+			s.target = s.position - s.stepsToStop()
+		}
+	}
+	arm.EnableInterrupts(mask)
+}
+
+// SetPosition sets the current position (and the target) of the motor, for
+// example to zero after homing. It must only be called while the motor is not
+// moving.
+func (s *StepperMotor) SetPosition(position int32) {
+	mask := arm.DisableInterrupts()
+	s.position = position
+	s.target = position
+	arm.EnableInterrupts(mask)
+}
+
+// start starts a move from standstill. It must be called with interrupts
+// disabled.
+func (s *StepperMotor) start() {
+	interval := s.update()
+	if interval == 0 {
+		return // already at the target
+	}
+	s.config.Dir.Set(s.forward)
+	s.running = true
+	tim := s.getTimer()
+	tim.CNT.Set(0)
+	s.setInterval(tim, interval)
+	tim.CR1.SetBits(stm32.TIM_CR1_CEN)
+}
+
+// setInterval sets the time until the next timer interrupt, counted from the
+// last update event. Intervals longer than the 16-bit timer period are split.
+func (s *StepperMotor) setInterval(tim *stm32.TIM_Type, interval uint32) {
+	period := interval
+	if period > 0x10000 {
+		period = 0x10000
+	}
+	s.wait = interval - period
+	tim.ARR.Set(period - 1)
+	if tim.CNT.Get() >= period-1 {
+		// The interrupt handler took longer than the interval, so the counter
+		// is already past the new period: step as soon as possible.
+		tim.CNT.Set(period - 1)
+	}
+}
+
+// handleInterrupt is called on every timer update event: it makes a step and
+// sets the timer to the next step, or stops it at the target.
+func (s *StepperMotor) handleInterrupt() {
+	tim := s.getTimer()
+	if s.wait != 0 {
+		// Continue a long interval.
+		s.setInterval(tim, s.wait)
+		return
+	}
+
+	s.config.Step.High()
+	if s.forward {
+		s.position++
+	} else {
+		s.position--
+	}
+	interval := s.update()
+	s.config.Step.Low()
+	if interval == 0 {
+		tim.CR1.ClearBits(stm32.TIM_CR1_CEN)
+		s.running = false
+		return
+	}
+	s.config.Dir.Set(s.forward)
+	s.setInterval(tim, interval)
+}
+
+// getTimer returns the timer used by this motor.
+func (s *StepperMotor) getTimer() *stm32.TIM_Type {
+	if s.timer == 4 {
+		return stm32.TIM4
+	}
+	return stm32.TIM2
+}
+
+//go:export TIM2_IRQHandler
+func handleTIM2() {
+	stm32.TIM2.SR.ClearBits(stm32.TIM_SR_UIF)
+	if s := stepperMotors[0]; s != nil {
+		s.handleInterrupt()
+	}
+}
+
+//go:export TIM4_IRQHandler
+func handleTIM4() {
+	stm32.TIM4.SR.ClearBits(stm32.TIM_SR_UIF)
+	if s := stepperMotors[1]; s != nil {
+		s.handleInterrupt()
+	}
+}
@@ -0,0 +1,155 @@
+package machine
+
+import "testing"
+
+// stepperMove runs the stepper to the target as the timer interrupt would,
+// calling step after every step. It returns the duration of the move in ticks
+// and the shortest step interval.
+func stepperMove(t *testing.T, s *StepperMotor, target int32, step func()) (duration, shortest uint32) {
+	s.target = target
+	shortest = ^uint32(0)
+	for interval := s.update(); interval != 0; interval = s.update() {
+		if interval < shortest {
+			shortest = interval
+		}
+		duration += interval
+		if s.forward {
+			s.position++
+		} else {
+			s.position--
+		}
+		if step != nil {
+			step()
+		}
+		if duration > 100*stepperTickFrequency {
+			t.Fatal("move does not end")
+		}
+	}
+	return
+}
+
+func TestStepperProfile(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		profile  SpeedProfile
+		target   int32
+		duration float64 // expected duration of the move in seconds
+		shortest uint32  // expected shortest step interval in ticks
+	}{
+		// Accelerate 0.5s (250 steps), 1.5s at 1000 steps/s (1500 steps),
+		// decelerate 0.5s (250 steps).
+		{"trapezoid", SpeedProfile{MaxSpeed: 1000, Acceleration: 2000}, 2000, 2.5, 1000},
+		{"reverse", SpeedProfile{MaxSpeed: 1000, Acceleration: 2000}, -2000, 2.5, 1000},
+		// Accelerate 0.5s (250 steps), 1.75s at full speed (1750 steps),
+		// decelerate 2s (1000 steps).
+		{"slow-stop", SpeedProfile{MaxSpeed: 1000, Acceleration: 2000, Deceleration: 500}, 3000, 4.25, 1000},
+		// Accelerate 50 steps up to 447 steps/s, then decelerate again.
+		{"triangle", SpeedProfile{MaxSpeed: 1000, Acceleration: 2000}, 100, 0.447, 2236},
+	} {
+		s := &StepperMotor{}
+		if err := s.setSpeedProfile(tc.profile); err != nil {
+			t.Fatalf("%s: could not set profile: %v", tc.name, err)
+		}
+
+		// The step interval must decrease until the middle of the move, and
+		// increase from then on.
+		var intervals []uint32
+		duration, shortest := stepperMove(t, s, tc.target, func() {
+			intervals = append(intervals, s.c>>8)
+		})
+		if s.position != tc.target {
+			t.Errorf("%s: expected to stop at %d, got %d", tc.name, tc.target, s.position)
+		}
+		// The approximation for the first step makes every ramp about 10ms
+		// shorter than the exact profile, at this acceleration. This only
+		// matters for short moves.
+		seconds := float64(duration) / stepperTickFrequency
+		if seconds < tc.duration*0.95 || seconds > tc.duration*1.02 {
+			t.Errorf("%s: expected the move to take %.3fs, got %.3fs", tc.name, tc.duration, seconds)
+		}
+		if shortest < tc.shortest*98/100 || shortest > tc.shortest*102/100 {
+			t.Errorf("%s: expected a shortest interval of %d ticks, got %d", tc.name, tc.shortest, shortest)
+		}
+		decelerating := false
+		for i := 1; i < len(intervals); i++ {
+			if intervals[i] > intervals[i-1] {
+				decelerating = true
+			} else if decelerating && intervals[i] < intervals[i-1] {
+				t.Errorf("%s: accelerating again at step %d", tc.name, i)
+				break
+			}
+		}
+	}
+}
+
+func TestStepperChangeTarget(t *testing.T) {
+	s := &StepperMotor{}
+	s.setSpeedProfile(SpeedProfile{MaxSpeed: 1000, Acceleration: 2000})
+
+	// Change the target back to 0 after 300 steps, at full speed. The motor
+	// overshoots by the 250 steps needed to stop, and then moves back.
+	furthest := int32(0)
+	reversals := 0
+	forward := true
+	stepperMove(t, s, 1000, func() {
+		if s.position == 300 && s.target == 1000 {
+			s.target = 0
+		}
+		if s.position > furthest {
+			furthest = s.position
+		}
+		if s.forward != forward {
+			forward = s.forward
+			reversals++
+		}
+	})
+	if s.position != 0 {
+		t.Errorf("expected to stop at 0, got %d", s.position)
+	}
+	if furthest < 540 || furthest > 560 {
+		t.Errorf("expected to overshoot to 550, got %d", furthest)
+	}
+	if reversals != 1 {
+		t.Errorf("expected to reverse once, got %d", reversals)
+	}
+
+	// Extending a move while decelerating accelerates again.
+	stepperMove(t, s, 100, func() {
+		if s.position == 90 {
+			s.target = 400
+		}
+	})
+	if s.position != 400 {
+		t.Errorf("expected to stop at 400, got %d", s.position)
+	}
+}
+
+func TestStepperSpeedProfile(t *testing.T) {
+	s := &StepperMotor{}
+	for _, profile := range []SpeedProfile{
+		{MaxSpeed: 0, Acceleration: 1000},
+		{MaxSpeed: 1000, Acceleration: 0},
+		{MaxSpeed: StepperMaxSpeed + 1, Acceleration: 1000},
+	} {
+		if err := s.setSpeedProfile(profile); err != ErrInvalidSpeedProfile {
+			t.Errorf("expected an error for %+v, got %v", profile, err)
+		}
+	}
+
+	// The first step takes 0.676*sqrt(2/a) seconds.
+	if err := s.setSpeedProfile(SpeedProfile{MaxSpeed: 1000, Acceleration: 2000}); err != nil {
+		t.Fatal("could not set profile:", err)
+	}
+	if c0 := s.c0 >> 8; c0 != 21377 {
+		t.Errorf("expected a first interval of 21377 ticks, got %d", c0)
+	}
+}
+
+func TestISqrt64(t *testing.T) {
+	for _, x := range []uint64{0, 1, 2, 3, 4, 15, 16, 17, 1<<32 - 1, 1 << 32, 1<<62 + 12345, 1<<64 - 1} {
+		root := uint64(isqrt64(x))
+		if root*root > x || (root+1)*(root+1) <= x && root != 1<<32-1 {
+			t.Errorf("isqrt64(%d) = %d", x, root)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// +build sam,atsamd51
+
+package machine
+
+import (
+	"device/arm"
+)
+
+// TouchSensor is a capacitive touch sensor (a button or a pad) connected to a
+// single pin.
+//
+// The capacitance of the pin is measured by discharging it and then counting
+// how long it takes to charge again through a pull-up resistor. A finger near
+// the pad increases the capacitance, and thus the charge time. This works on
+// every GPIO pin: the channel of a sensor is simply the pin it is connected to.
+// The PTC peripheral of the SAMD51 is not used, as its register interface is
+// not publicly documented (it is only usable through the QTouch library).
+//
+// The measured charge time is compared against a baseline, which is the charge
+// time when the sensor is not touched. The baseline is measured by Calibrate
+// and continuously adjusted while the sensor is not touched, so that slow
+// changes (temperature, humidity) don't cause false touch events.
+//
+// Sensitivity depends mostly on the size of the pad and the pull-up resistor.
+// The internal pull-up resistor (around 40kΩ) charges the pin in less than a
+// microsecond, which gives a very coarse measurement, so a large number of
+// samples is needed. With an external pull-up resistor of around 1MΩ (set
+// ExternalPullup), the charge time is much longer and a small pad or a pad
+// behind a plastic cover can be detected reliably.
+type TouchSensor struct {
+	Pin Pin
+
+	config   TouchConfig
+	baseline uint32 // charge time when not touched, see Baseline
+	touched  bool
+}
+
+// TouchConfig configures a TouchSensor. All fields are optional.
+type TouchConfig struct {
+	// Samples is the number of measurements that are summed for each reading.
+	// More samples give a less noisy value, but a reading takes longer. The
+	// default is 32.
+	Samples uint16
+
+	// Threshold is the value (as returned by Get) above which the sensor is
+	// considered touched. It is released again when the value drops below
+	// half the threshold. A lower threshold makes the sensor more sensitive.
+	// The default is 100, which means a 10% increase in capacitance.
+	Threshold uint16
+
+	// DriftShift sets how fast the baseline follows slow changes while the
+	// sensor is not touched: each reading moves the baseline 1/2^DriftShift of
+	// the way towards the measured value. The default is 6 (1/64).
+	DriftShift uint8
+
+	// ExternalPullup must be set when an external pull-up resistor is used, in
+	// which case the internal pull-up resistor is disabled.
+	ExternalPullup bool
+}
+
+// touchTimeout is the maximum number of loop iterations of a single
+// measurement, to not hang when the pin is shorted to ground.
+const touchTimeout = 10000
+
+// Configure sets up the pin for touch sensing and calibrates the sensor. The
+// sensor must not be touched while it is configured.
+func (t *TouchSensor) Configure(config TouchConfig) {
+	if config.Samples == 0 {
+		config.Samples = 32
+	}
+	if config.Threshold == 0 {
+		config.Threshold = 100
+	}
+	if config.DriftShift == 0 {
+		config.DriftShift = 6
+	}
+	t.config = config
+	t.Calibrate()
+}
+
+// Calibrate measures the baseline of the sensor, forgetting any previously
+// tracked drift. The sensor must not be touched while it is calibrated.
+func (t *TouchSensor) Calibrate() {
+	t.baseline = t.measure() * 16
+	t.touched = false
+}
+
+// Get returns the current touch value: how much the capacitance has increased
+// compared to the baseline, in units of 1/1000 of the baseline. An untouched
+// sensor returns a value near zero. This also updates the touch state returned
+// by Touched.
+func (t *TouchSensor) Get() uint16 {
+	raw := t.measure() * 16
+	var value uint32
+	if raw > t.baseline && t.baseline != 0 {
+		value = 0xffff
+		if v := uint64(raw-t.baseline) * 1000 / uint64(t.baseline); v < 0xffff {
+			value = uint32(v)
+		}
+	}
+
+	// Update the touch state, with some hysteresis to avoid bouncing.
+	if t.touched {
+		t.touched = value >= uint32(t.config.Threshold)/2
+	} else {
+		t.touched = value > uint32(t.config.Threshold)
+	}
+
+	// Track drift of the baseline while not touched. A decrease in capacitance
+	// can't be caused by a touch, so the baseline follows it immediately.
+	if raw < t.baseline {
+		t.baseline = raw
+	} else if !t.touched {
+		t.baseline += (raw - t.baseline) >> t.config.DriftShift
+	}
+	return uint16(value)
+}
+
+// Touched returns whether the sensor is currently touched. It takes a new
+// reading, just like Get.
+func (t *TouchSensor) Touched() bool {
+	t.Get()
+	return t.touched
+}
+
+// Baseline returns the current baseline of the sensor: the charge time when not
+// touched, summed over all samples, in 1/16 loop iterations. This can be useful
+// to tune the configuration.
+func (t *TouchSensor) Baseline() uint32 {
+	return t.baseline
+}
+
+// measure returns the sum of the charge times of a number of measurements, in
+// loop iterations.
+func (t *TouchSensor) measure() uint32 {
+	chargeMode := PinInputPullup
+	if t.config.ExternalPullup {
+		chargeMode = PinInput
+	}
+	var total uint32
+	for i := uint16(0); i < t.config.Samples; i++ {
+		// Discharge the pin.
+		t.Pin.Configure(PinConfig{Mode: PinOutput})
+		t.Pin.Low()
+		busyWaitMicroseconds(1)
+
+		// Count how long it takes to charge the pin. Interrupts would make
+		// the measurement very noisy, so disable them for this short time.
+		mask := arm.DisableInterrupts()
+		t.Pin.Configure(PinConfig{Mode: chargeMode})
+		n := uint32(0)
+		for !t.Pin.Get() && n < touchTimeout {
+			n++
+		}
+		arm.EnableInterrupts(mask)
+		total += n
+	}
+	return total
+}
@@ -441,6 +441,12 @@ const (
 	usb_CDC_ENDPOINT_OUT   = 2
 	usb_CDC_ENDPOINT_IN    = 3
 
+	// MIDI, only with -tags=usbmidi (see usb_midi.go)
+	usb_MIDI_AC_INTERFACE        = 2 // Audio Control
+	usb_MIDI_STREAMING_INTERFACE = 3 // MIDI Streaming
+	usb_MIDI_ENDPOINT_OUT        = 4
+	usb_MIDI_ENDPOINT_IN         = 5
+
 	// bmRequestType
 	usb_REQUEST_HOSTTODEVICE = 0x00
 	usb_REQUEST_DEVICETOHOST = 0x80
@@ -533,8 +539,8 @@ func newUSBSetup(data []byte) usbSetup {
 	u.bRequest = uint8(data[1])
 	u.wValueL = uint8(data[2])
 	u.wValueH = uint8(data[3])
-	u.wIndex = uint16(data[4]) | uint16(data[5]<<8)
-	u.wLength = uint16(data[6]) | uint16(data[7]<<8)
+	u.wIndex = uint16(data[4]) | uint16(data[5])<<8
+	u.wLength = uint16(data[6]) | uint16(data[7])<<8
 	return u
 }
 
@@ -0,0 +1,141 @@
+// +build sam
+
+package machine
+
+import "errors"
+
+// ErrInvalidUSBRequestType is returned by SetUSBControlHandler for request
+// types other than USBRequestClass and USBRequestVendor.
+var ErrInvalidUSBRequestType = errors.New("machine: USB control handlers can only be set for class and vendor requests")
+
+// Types of USB control requests, as in the type bits of bmRequestType, that
+// can be handled by the program with SetUSBControlHandler.
+const (
+	USBRequestClass  = usb_REQUEST_CLASS
+	USBRequestVendor = usb_REQUEST_VENDOR
+)
+
+// USBSetup is the setup packet of a USB control request, as received from the
+// host.
+type USBSetup struct {
+	RequestType uint8  // bmRequestType: direction, type and recipient
+	Request     uint8  // bRequest: the request code, defined by the class or vendor
+	Value       uint16 // wValue
+	Index       uint16 // wIndex: usually an interface or endpoint number
+	Length      uint16 // wLength: the length of the data stage
+}
+
+// DeviceToHost returns whether this is an IN request, where the device sends
+// a response to the host in the data stage.
+func (s USBSetup) DeviceToHost() bool {
+	return s.RequestType&usb_REQUEST_DIRECTION == usb_REQUEST_DEVICETOHOST
+}
+
+// USBControlHandler handles a class or vendor specific USB control request,
+// see SetUSBControlHandler. For a device-to-host request, the response must
+// be written to buf, which is as long as the host asked for (setup.Length) up
+// to a maximum of 256 bytes, and the length of the response must be returned.
+// For a host-to-device request, buf contains the data sent by the host, if
+// any. A handler returns false to reject the request, which stalls it: that's
+// what the host expects for requests the device doesn't support.
+type USBControlHandler func(setup USBSetup, buf []byte) (n int, ok bool)
+
+// The registered USB control handlers, see SetUSBControlHandler.
+var (
+	usbClassHandler  USBControlHandler
+	usbVendorHandler USBControlHandler
+)
+
+// SetUSBControlHandler registers the handler for class (USBRequestClass) or
+// vendor (USBRequestVendor) specific control requests on the USB port, such as
+// the vendor requests of WebUSB or the class requests of the DFU interface. For
+// class requests, the handler is only called for requests that are not handled
+// by the built-in USB CDC interface. Passing nil removes the handler, so that
+// such requests are rejected again.
+//
+// A control transfer consists of three stages, which are handled as follows:
+//
+//   - Setup: the host sends the setup packet and this package calls the
+//     handler for the request type.
+//   - Data: for a device-to-host request, the response from the handler is
+//     sent to the host (using multiple packets if needed). For a host-to-device
+//     request with data (setup.Length != 0), the data is received before
+//     calling the handler. Only a single packet of up to 64 bytes can be
+//     received, longer requests are rejected without calling the handler.
+//   - Status: the host (after an IN data stage) or the device (after an OUT
+//     request) sends an empty packet to confirm the transfer. A stall, when the
+//     handler returns false, replaces it.
+//
+// The handler is called from the USB interrupt, which is also used during
+// enumeration, so it must be fast: it blocks all other USB communication
+// (including USB CDC) and the host times out requests that take longer than
+// 50ms (500ms with a data stage). It must not block (like time.Sleep or a
+// channel operation that can wait) or allocate heap memory, and buf must not
+// be used after it returns.
+func SetUSBControlHandler(requestType uint8, handler USBControlHandler) error {
+	switch requestType {
+	case USBRequestClass:
+		usbClassHandler = handler
+	case USBRequestVendor:
+		usbVendorHandler = handler
+	default:
+		return ErrInvalidUSBRequestType
+	}
+	return nil
+}
+
+// handleControlRequest handles a class or vendor specific control request
+// with the registered handler. It returns false when the request must be
+// stalled.
+func handleControlRequest(setup usbSetup) bool {
+	var handler USBControlHandler
+	switch setup.bmRequestType & usb_REQUEST_TYPE {
+	case usb_REQUEST_CLASS:
+		handler = usbClassHandler
+	case usb_REQUEST_VENDOR:
+		handler = usbVendorHandler
+	}
+	if handler == nil {
+		return false
+	}
+	s := USBSetup{
+		RequestType: setup.bmRequestType,
+		Request:     setup.bRequest,
+		Value:       uint16(setup.wValueH)<<8 | uint16(setup.wValueL),
+		Index:       setup.wIndex,
+		Length:      setup.wLength,
+	}
+
+	if s.DeviceToHost() {
+		buf := udd_ep_control_in_buffer[:]
+		if int(s.Length) < len(buf) {
+			buf = buf[:s.Length]
+		}
+		n, ok := handler(s, buf)
+		if !ok {
+			return false
+		}
+		if n > len(buf) {
+			n = len(buf)
+		}
+		if n == 0 {
+			sendZlp(0)
+		} else {
+			sendUSBPacket(0, buf[:n])
+		}
+		return true
+	}
+
+	var data []byte
+	if s.Length != 0 {
+		if s.Length > usbEndpointPacketSize {
+			return false // data stage doesn't fit in a single packet
+		}
+		data = receiveUSBControlPacket()
+	}
+	if _, ok := handler(s, data); !ok {
+		return false
+	}
+	sendZlp(0)
+	return true
+}
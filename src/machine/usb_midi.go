@@ -0,0 +1,225 @@
+// +build sam,usbmidi
+
+package machine
+
+// USB MIDI support, as specified in the "Universal Serial Bus Device Class
+// Definition for MIDI Devices" version 1.0. It is only included when the
+// program is built with -tags=usbmidi: the MIDI function is then added to the
+// USB configuration next to the CDC (serial) function, so a device enumerates
+// as both a serial port and a MIDI device with a single MIDI IN and MIDI OUT
+// port (cable 0). Without this tag, the device only enumerates as a serial port
+// and MIDI0 doesn't exist.
+//
+// All MIDI data is transferred as 32-bit USB-MIDI event packets:
+//
+//     byte 0: cable number (high nibble) and code index number (low nibble)
+//     byte 1: MIDI_0, usually the MIDI status byte (for example 0x90 for a
+//             note on message on channel 1)
+//     byte 2: MIDI_1, the first data byte (for example the note number)
+//     byte 3: MIDI_2, the second data byte (for example the velocity)
+//
+// The code index number (CIN) is the type of the message, which for channel
+// messages is the same as the high nibble of the status byte. Messages that are
+// shorter than 3 bytes are padded with zeroes.
+//
+// Events are sent over a full speed bulk endpoint, and each event is sent as a
+// separate transfer. The host polls bulk endpoints whenever there is bandwidth
+// available, so on an otherwise idle bus an event usually arrives at the host
+// well within one 1ms USB frame. SendEvent blocks until the host has picked up
+// the event (or a timeout expires). Received events are buffered in a ring
+// buffer from the USB interrupt, which can hold up to 32 events; newer events
+// are dropped when it is full.
+
+const (
+	usb_AUDIO_CLASS                = 0x01
+	usb_AUDIO_SUBCLASS_CONTROL     = 0x01
+	usb_AUDIO_SUBCLASS_STREAMING   = 0x03
+	usb_AUDIO_CS_INTERFACE         = 0x24
+	usb_AUDIO_CS_ENDPOINT          = 0x25
+	usb_AUDIO_HEADER               = 0x01
+	usb_MIDI_IN_JACK               = 0x02
+	usb_MIDI_OUT_JACK              = 0x03
+	usb_MIDI_JACK_EMBEDDED         = 0x01
+	usb_MIDI_JACK_EXTERNAL         = 0x02
+	usb_MIDI_GENERAL               = 0x01
+	usb_MIDI_EMBEDDED_IN_JACK_ID   = 1 // data from the host
+	usb_MIDI_EXTERNAL_IN_JACK_ID   = 2
+	usb_MIDI_EMBEDDED_OUT_JACK_ID  = 3 // data to the host
+	usb_MIDI_EXTERNAL_OUT_JACK_ID  = 4
+	usb_MIDI_STREAMING_TOTAL_SIZE  = 7 + 6 + 6 + 9 + 9 + 9 + 5 + 9 + 5
+	usb_MIDI_CONTROL_HEADER_SIZE   = 9
+	usb_MIDI_STREAMING_HEADER_SIZE = 7
+)
+
+// MIDI0 is the USB MIDI interface, which is available next to UART0.
+var MIDI0 = USBMIDI{Buffer: NewRingBuffer()}
+
+const (
+	// USB MIDI is included in this program, see usb_midi_disabled.go.
+	usbMIDIEnabled = true
+
+	// midiInterfaces is the number of interfaces of the MIDI function.
+	midiInterfaces = 2
+
+	// midiSize is the size of the descriptor returned by
+	// MIDIDescriptor.Bytes.
+	midiSize = iadDescriptorSize +
+		interfaceDescriptorSize +
+		usb_MIDI_CONTROL_HEADER_SIZE +
+		interfaceDescriptorSize +
+		usb_MIDI_STREAMING_TOTAL_SIZE
+)
+
+// midiEndPoints are the endpoints of the MIDI function, which follow the CDC
+// endpoints.
+var midiEndPoints = []uint32{
+	(usb_ENDPOINT_TYPE_BULK | usbEndpointOut),
+	(usb_ENDPOINT_TYPE_BULK | usbEndpointIn)}
+
+// midiDescriptor returns the descriptor of the MIDI function, which follows
+// the CDC function in the configuration descriptor.
+func midiDescriptor() []byte {
+	return NewMIDIDescriptor(usb_MIDI_AC_INTERFACE, usb_MIDI_STREAMING_INTERFACE, usb_MIDI_ENDPOINT_OUT, usb_MIDI_ENDPOINT_IN).Bytes()
+}
+
+// MIDIDescriptor is the descriptor of the USB MIDI function: an Audio Control
+// interface and a MIDI Streaming interface with one embedded and one external
+// jack in each direction, and a bulk endpoint in each direction.
+type MIDIDescriptor struct {
+	iad IADDescriptor
+
+	acInterface InterfaceDescriptor
+	msInterface InterfaceDescriptor
+
+	out uint8 // OUT endpoint address
+	in  uint8 // IN endpoint address
+}
+
+// NewMIDIDescriptor returns a MIDI function descriptor using the given
+// interfaces (which must be consecutive) and endpoints.
+func NewMIDIDescriptor(acInterface, msInterface, out, in uint8) MIDIDescriptor {
+	return MIDIDescriptor{
+		iad:         NewIADDescriptor(acInterface, 2, usb_AUDIO_CLASS, usb_AUDIO_SUBCLASS_CONTROL, 0),
+		acInterface: NewInterfaceDescriptor(acInterface, 0, usb_AUDIO_CLASS, usb_AUDIO_SUBCLASS_CONTROL, 0),
+		msInterface: NewInterfaceDescriptor(msInterface, 2, usb_AUDIO_CLASS, usb_AUDIO_SUBCLASS_STREAMING, 0),
+		out:         out | usbEndpointOut,
+		in:          in | usbEndpointIn,
+	}
+}
+
+// Bytes returns MIDIDescriptor data.
+func (d MIDIDescriptor) Bytes() []byte {
+	buf := make([]byte, 0, midiSize)
+	buf = append(buf, d.iad.Bytes()...)
+
+	// Audio Control interface, with a class-specific header that points to
+	// the MIDI Streaming interface.
+	buf = append(buf, d.acInterface.Bytes()...)
+	buf = append(buf,
+		usb_MIDI_CONTROL_HEADER_SIZE, usb_AUDIO_CS_INTERFACE, usb_AUDIO_HEADER,
+		0x00, 0x01, // bcdADC: revision 1.0
+		usb_MIDI_CONTROL_HEADER_SIZE, 0x00, // wTotalLength
+		1,                              // bInCollection: one streaming interface
+		d.msInterface.bInterfaceNumber) // baInterfaceNr
+
+	// MIDI Streaming interface.
+	buf = append(buf, d.msInterface.Bytes()...)
+	buf = append(buf,
+		usb_MIDI_STREAMING_HEADER_SIZE, usb_AUDIO_CS_INTERFACE, usb_AUDIO_HEADER,
+		0x00, 0x01, // bcdMSC: revision 1.0
+		usb_MIDI_STREAMING_TOTAL_SIZE&0xff, usb_MIDI_STREAMING_TOTAL_SIZE>>8) // wTotalLength
+
+	// MIDI IN jacks: data flows from the embedded jack (the host) to the
+	// external jack.
+	buf = append(buf, 6, usb_AUDIO_CS_INTERFACE, usb_MIDI_IN_JACK, usb_MIDI_JACK_EMBEDDED, usb_MIDI_EMBEDDED_IN_JACK_ID, 0)
+	buf = append(buf, 6, usb_AUDIO_CS_INTERFACE, usb_MIDI_IN_JACK, usb_MIDI_JACK_EXTERNAL, usb_MIDI_EXTERNAL_IN_JACK_ID, 0)
+
+	// MIDI OUT jacks, each connected to one of the MIDI IN jacks.
+	buf = append(buf, 9, usb_AUDIO_CS_INTERFACE, usb_MIDI_OUT_JACK, usb_MIDI_JACK_EMBEDDED, usb_MIDI_EMBEDDED_OUT_JACK_ID,
+		1, usb_MIDI_EXTERNAL_IN_JACK_ID, 1, // one input pin, connected to the external IN jack
+		0)
+	buf = append(buf, 9, usb_AUDIO_CS_INTERFACE, usb_MIDI_OUT_JACK, usb_MIDI_JACK_EXTERNAL, usb_MIDI_EXTERNAL_OUT_JACK_ID,
+		1, usb_MIDI_EMBEDDED_IN_JACK_ID, 1, // one input pin, connected to the embedded IN jack
+		0)
+
+	// Bulk endpoints, using the 9-byte audio endpoint descriptor followed by a
+	// class-specific descriptor listing the embedded jack it belongs to.
+	buf = append(buf, 9, usb_ENDPOINT_DESCRIPTOR_TYPE, d.out, usb_ENDPOINT_TYPE_BULK,
+		usbEndpointPacketSize&0xff, usbEndpointPacketSize>>8, 0, 0, 0)
+	buf = append(buf, 5, usb_AUDIO_CS_ENDPOINT, usb_MIDI_GENERAL, 1, usb_MIDI_EMBEDDED_IN_JACK_ID)
+	buf = append(buf, 9, usb_ENDPOINT_DESCRIPTOR_TYPE, d.in, usb_ENDPOINT_TYPE_BULK,
+		usbEndpointPacketSize&0xff, usbEndpointPacketSize>>8, 0, 0, 0)
+	buf = append(buf, 5, usb_AUDIO_CS_ENDPOINT, usb_MIDI_GENERAL, 1, usb_MIDI_EMBEDDED_OUT_JACK_ID)
+
+	return buf
+}
+
+// MIDIEvent is a single 32-bit USB-MIDI event packet, see the description of
+// the event packet format at the top of this file.
+type MIDIEvent [4]byte
+
+// Cable returns the virtual cable number (0-15) of this event.
+func (e MIDIEvent) Cable() uint8 {
+	return e[0] >> 4
+}
+
+// CodeIndex returns the code index number of this event, which describes the
+// type of the MIDI message and how many bytes it contains.
+func (e MIDIEvent) CodeIndex() uint8 {
+	return e[0] & 0x0f
+}
+
+// USBMIDI is the MIDI interface that works over the USB port. Received events
+// are stored in the ring buffer, 4 bytes per event.
+type USBMIDI struct {
+	Buffer *RingBuffer
+}
+
+// SendNoteOn sends a note on message for the given channel (0-15), note and
+// velocity (both 0-127) on cable 0.
+func (midi USBMIDI) SendNoteOn(channel, note, velocity uint8) error {
+	return midi.SendEvent(MIDIEvent{0x09, 0x90 | channel&0x0f, note & 0x7f, velocity & 0x7f})
+}
+
+// SendNoteOff sends a note off message for the given channel (0-15), note and
+// release velocity (both 0-127) on cable 0.
+func (midi USBMIDI) SendNoteOff(channel, note, velocity uint8) error {
+	return midi.SendEvent(MIDIEvent{0x08, 0x80 | channel&0x0f, note & 0x7f, velocity & 0x7f})
+}
+
+// ReceiveEvent returns the next event received from the host. It returns false
+// if no event has been received.
+func (midi USBMIDI) ReceiveEvent() (MIDIEvent, bool) {
+	var e MIDIEvent
+	if midi.Buffer.Used() < uint8(len(e)) {
+		return e, false
+	}
+	for i := range e {
+		e[i], _ = midi.Buffer.Get()
+	}
+	return e, true
+}
+
+// Buffered returns the number of events currently stored in the RX buffer.
+func (midi USBMIDI) Buffered() int {
+	return int(midi.Buffer.Used()) / len(MIDIEvent{})
+}
+
+// receive stores the events in data (received from the host) in the ring
+// buffer. Events that don't fit in the buffer anymore are dropped. Usually
+// called by the IRQ handler for a machine.
+func (midi USBMIDI) receive(data []byte) {
+	for len(data) >= len(MIDIEvent{}) {
+		if bufferSize-int(midi.Buffer.Used()) < len(MIDIEvent{}) {
+			// Buffer is full.
+			return
+		}
+		if data[0] != 0 {
+			// Ignore padding (events that are all zero).
+			for _, b := range data[:len(MIDIEvent{})] {
+				midi.Buffer.Put(b)
+			}
+		}
+		data = data[len(MIDIEvent{}):]
+	}
+}
@@ -0,0 +1,20 @@
+// +build sam,!usbmidi
+
+package machine
+
+// Don't add a MIDI function to the USB configuration, see usb_midi.go.
+
+const (
+	usbMIDIEnabled = false
+	midiInterfaces = 0
+	midiSize       = 0
+)
+
+var midiEndPoints []uint32
+
+func midiDescriptor() []byte {
+	return nil
+}
+
+func handleMIDIEndpoint(ep uint32) {
+}
@@ -0,0 +1,41 @@
+package machine
+
+import "errors"
+
+// ErrVCCNotSupported is returned by ReadVCC on chips that can't measure their
+// own supply voltage, or for which this hasn't been implemented yet.
+var ErrVCCNotSupported = errors.New("machine: reading VCC is not supported")
+
+// ReadVCC measures the supply voltage of the chip itself and returns it in
+// millivolts. This is useful to monitor a battery that directly powers the
+// chip (without a regulator in between), for example a coin cell or two AA
+// batteries. The measurement uses an internal reference voltage of the chip,
+// so no external components are needed.
+//
+// This is different from measuring a voltage with an external voltage divider
+// on an ADC pin, which is needed for batteries with a higher voltage than the
+// chip can handle (such as a LiPo battery behind a regulator): in that case
+// the supply voltage is just the regulated voltage and says nothing about the
+// battery.
+//
+// The accuracy depends on the internal reference, which is specified in the
+// datasheet of each chip and is usually within a few percent. Where the chip
+// provides factory calibration values for the ADC or the reference, they are
+// used. See the documentation of each chip below for details:
+//
+//   - nRF52: the SAADC measures VDD directly, against the internal 0.6V
+//     reference with a gain of 1/6. The offset is calibrated before each
+//     measurement. The accuracy is about ±2%.
+//   - SAMD21: the ADC measures 1/4 of VDDANA against the internal 1.0V bandgap
+//     reference, using the factory calibration applied by InitADC (which must
+//     be called first). The accuracy is about ±2%.
+//   - STM32F103: the ADC measures the internal reference (VREFINT) against
+//     VDDA, and VDDA is calculated from the nominal VREFINT of 1.20V. This
+//     chip has no factory calibration for VREFINT, which may be anywhere
+//     between 1.16V and 1.24V, so the result may be off by about ±3.5%.
+//
+// On other chips, ErrVCCNotSupported is returned. ReadVCC must not be called
+// while the ADC is in use by something else.
+func ReadVCC() (uint32, error) {
+	return readVCC()
+}
@@ -0,0 +1,45 @@
+// +build sam,atsamd21
+
+package machine
+
+import (
+	"device/sam"
+)
+
+// readVCC measures 1/4 of VDDANA against the internal 1.0V reference, so
+// supply voltages up to 4V can be measured. The reference and gain are
+// restored afterwards, so that ADC.Get keeps working as before.
+func readVCC() (uint32, error) {
+	refctrl := sam.ADC.REFCTRL.Get()
+	inputctrl := sam.ADC.INPUTCTRL.Get()
+
+	sam.ADC.REFCTRL.Set(sam.ADC_REFCTRL_REFSEL_INT1V << sam.ADC_REFCTRL_REFSEL_Pos)
+	sam.ADC.INPUTCTRL.Set((sam.ADC_INPUTCTRL_GAIN_1X << sam.ADC_INPUTCTRL_GAIN_Pos) |
+		(sam.ADC_INPUTCTRL_MUXNEG_GND << sam.ADC_INPUTCTRL_MUXNEG_Pos) |
+		(sam.ADC_INPUTCTRL_MUXPOS_SCALEDIOVCC << sam.ADC_INPUTCTRL_MUXPOS_Pos))
+	waitADCSync()
+
+	sam.ADC.CTRLA.SetBits(sam.ADC_CTRLA_ENABLE)
+	waitADCSync()
+
+	// The first conversion after changing the reference is invalid, so do two
+	// conversions and only use the second.
+	var val uint32
+	for i := 0; i < 2; i++ {
+		sam.ADC.SWTRIG.SetBits(sam.ADC_SWTRIG_START)
+		waitADCSync()
+		for !sam.ADC.INTFLAG.HasBits(sam.ADC_INTFLAG_RESRDY) {
+		}
+		val = uint32(sam.ADC.RESULT.Get())
+		sam.ADC.INTFLAG.SetBits(sam.ADC_INTFLAG_RESRDY)
+		waitADCSync()
+	}
+
+	sam.ADC.CTRLA.ClearBits(sam.ADC_CTRLA_ENABLE)
+	waitADCSync()
+	sam.ADC.REFCTRL.Set(refctrl)
+	sam.ADC.INPUTCTRL.Set(inputctrl)
+	waitADCSync()
+
+	return val * 4 * 1000 / 4096, nil
+}
@@ -0,0 +1,7 @@
+// +build !nrf52,!nrf52840,!atsamd21,!stm32f103xx
+
+package machine
+
+func readVCC() (uint32, error) {
+	return 0, ErrVCCNotSupported
+}
@@ -0,0 +1,62 @@
+// +build nrf52 nrf52840
+
+package machine
+
+import (
+	"device/nrf"
+	"unsafe"
+)
+
+// readVCC measures VDD with the SAADC. With the internal 0.6V reference and a
+// gain of 1/6, the input range is 0..3.6V.
+func readVCC() (uint32, error) {
+	var value int16
+
+	nrf.SAADC.RESOLUTION.Set(nrf.SAADC_RESOLUTION_VAL_12bit)
+	nrf.SAADC.ENABLE.Set(nrf.SAADC_ENABLE_ENABLE_Enabled << nrf.SAADC_ENABLE_ENABLE_Pos)
+
+	// Calibrate the offset, which may drift with the temperature.
+	nrf.SAADC.TASKS_CALIBRATEOFFSET.Set(1)
+	for nrf.SAADC.EVENTS_CALIBRATEDONE.Get() == 0 {
+	}
+	nrf.SAADC.EVENTS_CALIBRATEDONE.Set(0)
+
+	// Measure VDD. Use a long acquisition time for a more stable result.
+	nrf.SAADC.CH[0].CONFIG.Set(((nrf.SAADC_CH_CONFIG_RESP_Bypass << nrf.SAADC_CH_CONFIG_RESP_Pos) & nrf.SAADC_CH_CONFIG_RESP_Msk) |
+		((nrf.SAADC_CH_CONFIG_RESP_Bypass << nrf.SAADC_CH_CONFIG_RESN_Pos) & nrf.SAADC_CH_CONFIG_RESN_Msk) |
+		((nrf.SAADC_CH_CONFIG_GAIN_Gain1_6 << nrf.SAADC_CH_CONFIG_GAIN_Pos) & nrf.SAADC_CH_CONFIG_GAIN_Msk) |
+		((nrf.SAADC_CH_CONFIG_REFSEL_Internal << nrf.SAADC_CH_CONFIG_REFSEL_Pos) & nrf.SAADC_CH_CONFIG_REFSEL_Msk) |
+		((nrf.SAADC_CH_CONFIG_TACQ_10us << nrf.SAADC_CH_CONFIG_TACQ_Pos) & nrf.SAADC_CH_CONFIG_TACQ_Msk) |
+		((nrf.SAADC_CH_CONFIG_MODE_SE << nrf.SAADC_CH_CONFIG_MODE_Pos) & nrf.SAADC_CH_CONFIG_MODE_Msk))
+	nrf.SAADC.CH[0].PSELN.Set(nrf.SAADC_CH_PSELP_PSELP_NC)
+	nrf.SAADC.CH[0].PSELP.Set(nrf.SAADC_CH_PSELP_PSELP_VDD)
+
+	// Destination for sample result.
+	nrf.SAADC.RESULT.PTR.Set(uint32(uintptr(unsafe.Pointer(&value))))
+	nrf.SAADC.RESULT.MAXCNT.Set(1) // One sample
+
+	// Start tasks.
+	nrf.SAADC.TASKS_START.Set(1)
+	for nrf.SAADC.EVENTS_STARTED.Get() == 0 {
+	}
+	nrf.SAADC.EVENTS_STARTED.Set(0x00)
+
+	// Start the sample task and wait until it is done.
+	nrf.SAADC.TASKS_SAMPLE.Set(1)
+	for nrf.SAADC.EVENTS_END.Get() == 0 {
+	}
+	nrf.SAADC.EVENTS_END.Set(0x00)
+
+	// Stop and disable the ADC.
+	nrf.SAADC.TASKS_STOP.Set(1)
+	for nrf.SAADC.EVENTS_STOPPED.Get() == 0 {
+	}
+	nrf.SAADC.EVENTS_STOPPED.Set(0)
+	nrf.SAADC.CH[0].PSELP.Set(nrf.SAADC_CH_PSELP_PSELP_NC)
+	nrf.SAADC.ENABLE.Set(nrf.SAADC_ENABLE_ENABLE_Disabled << nrf.SAADC_ENABLE_ENABLE_Pos)
+
+	if value < 0 {
+		value = 0
+	}
+	return uint32(value) * 3600 / 4096, nil
+}
@@ -0,0 +1,55 @@
+// +build stm32,stm32f103xx
+
+package machine
+
+import (
+	"device/arm"
+	"device/stm32"
+)
+
+// Nominal voltage of the internal reference (VREFINT) in millivolts. The
+// STM32F103 has no factory calibration value for it.
+const vrefintMillivolts = 1200
+
+// ADC channel of the internal reference.
+const adcChannelVREFINT = 17
+
+// readVCC measures VREFINT with ADC1. As VREFINT is (nominally) constant, the
+// measured value is inversely proportional to VDDA, which is the reference of
+// the ADC.
+func readVCC() (uint32, error) {
+	// The ADC clock must not exceed 14MHz: use PCLK2/6 (12MHz at 72MHz).
+	stm32.RCC.CFGR.ClearBits(stm32.RCC_CFGR_ADCPRE_Msk)
+	stm32.RCC.CFGR.SetBits(stm32.RCC_CFGR_ADCPRE_DIV_6)
+	stm32.RCC.APB2ENR.SetBits(stm32.RCC_APB2ENR_ADC1EN)
+
+	// Power on the ADC and the internal reference, wait for them to stabilize
+	// (about 10µs) and calibrate the ADC.
+	stm32.ADC1.CR2.SetBits(stm32.ADC_CR2_ADON | stm32.ADC_CR2_TSVREFE)
+	for i := 0; i < 1000; i++ {
+		arm.Asm("nop")
+	}
+	stm32.ADC1.CR2.SetBits(stm32.ADC_CR2_CAL)
+	for stm32.ADC1.CR2.HasBits(stm32.ADC_CR2_CAL) {
+	}
+
+	// The sample time of VREFINT must be at least 17.1µs: use the longest
+	// sample time of 239.5 cycles (20µs at 12MHz).
+	stm32.ADC1.SMPR1.SetBits(0x7 << stm32.ADC_SMPR1_SMP17_Pos)
+	stm32.ADC1.SQR1.Set(0) // one conversion
+	stm32.ADC1.SQR3.Set(adcChannelVREFINT << stm32.ADC_SQR3_SQ1_Pos)
+
+	// Setting ADON again starts the conversion.
+	stm32.ADC1.CR2.SetBits(stm32.ADC_CR2_ADON)
+	for !stm32.ADC1.SR.HasBits(stm32.ADC_SR_EOC) {
+	}
+	raw := stm32.ADC1.DR.Get() & 0xfff // reading DR clears EOC
+
+	// Power off the ADC and the internal reference.
+	stm32.ADC1.CR2.ClearBits(stm32.ADC_CR2_ADON | stm32.ADC_CR2_TSVREFE)
+
+	if raw == 0 {
+		return 0, nil // should not happen, but avoid dividing by zero
+	}
+	return vrefintMillivolts * 4095 / raw, nil
+}
@@ -227,7 +227,7 @@ func (t Type) Field(i int) StructField {
 			// There is a tag.
 			var tagNum uintptr
 			tagNum, p = readVarint(p)
-			field.Tag = readStringSidetable(unsafe.Pointer(&structNamesSidetable), tagNum)
+			field.Tag = StructTag(readStringSidetable(unsafe.Pointer(&structNamesSidetable), tagNum))
 		} else {
 			// There is no tag.
 			field.Tag = ""
@@ -452,12 +452,126 @@ type StructField struct {
 	// declared for unexported fields, or the empty string for exported fields.
 	PkgPath string
 
-	Type      Type
-	Tag       string
+	Type Type
+
+	// Tag is the struct tag of the field. Tags are only stored in the program
+	// when it is compiled with -reflect-tags, otherwise Tag is always empty.
+	// This is off by default because of the code size cost: every distinct
+	// tag string is stored once, plus one or two bytes for every field with a
+	// tag, in every struct type that is used with reflection. Older versions
+	// of TinyGo always stored tags, so programs that read them (for example
+	// with a JSON or config library) now need the flag.
+	Tag StructTag
+
 	Anonymous bool
 	Offset    uintptr
 }
 
+// A StructTag is the tag string in a struct field. By convention, tag strings
+// are a concatenation of optionally space-separated key:"value" pairs.
+type StructTag string
+
+// Get returns the value associated with key in the tag string. If there is no
+// such key in the tag, Get returns the empty string. To distinguish between a
+// tag that is not set and a tag that is set to the empty string, use Lookup.
+func (tag StructTag) Get(key string) string {
+	v, _ := tag.Lookup(key)
+	return v
+}
+
+// Lookup returns the value associated with key in the tag string. If the key
+// is present in the tag the value (which may be empty) is returned. Otherwise
+// the returned value will be the empty string. The ok return value reports
+// whether the value was explicitly set in the tag string.
+//
+// Unlike the main Go implementation, only the escape sequences \\, \", \n,
+// \r and \t are supported in values: values with other escape sequences are
+// treated as malformed, as if the key is not present.
+func (tag StructTag) Lookup(key string) (value string, ok bool) {
+	for tag != "" {
+		// Skip leading space.
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		// Scan to colon. A space, a quote or a control character is a syntax
+		// error.
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		name := string(tag[:i])
+		tag = tag[i+1:]
+
+		// Scan quoted string to find value.
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		qvalue := string(tag[1:i])
+		tag = tag[i+1:]
+
+		if key == name {
+			return unquoteTagValue(qvalue)
+		}
+	}
+	return "", false
+}
+
+// unquoteTagValue interprets the escape sequences in a quoted tag value
+// (without the quotes). The strconv package can't be used here, as it
+// indirectly imports this package.
+func unquoteTagValue(s string) (string, bool) {
+	hasEscape := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			hasEscape = true
+			break
+		}
+	}
+	if !hasEscape {
+		return s, true
+	}
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' {
+			i++
+			if i >= len(s) {
+				return "", false
+			}
+			switch s[i] {
+			case '\\', '"':
+				c = s[i]
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			default:
+				return "", false
+			}
+		}
+		buf = append(buf, c)
+	}
+	return string(buf), true
+}
+
 // TypeError is the error that is used in a panic when invoking a method on a
 // type that is not applicable to that type.
 type TypeError struct {
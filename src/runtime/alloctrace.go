@@ -0,0 +1,101 @@
+// +build gc.conservative,alloctrace
+
+package runtime
+
+// Allocation tracing, enabled with the -alloc-trace flag. Every heap
+// allocation records the address it was called from (the allocation site) in a
+// side table, which is stored right after the block state metadata of the heap.
+// DumpAllocs uses this table to print all live objects grouped by allocation
+// site.
+//
+// The overhead is one pointer for every block in the heap (including free
+// blocks), so 4 bytes per 16-byte block on 32-bit systems and 8 bytes per 32-
+// byte block on 64-bit systems. In other words, the heap has 20% less space for
+// objects. There is also a small overhead in every allocation, to store the
+// allocation site.
+//
+// There is no table to map program counters to source lines, so allocation
+// sites are printed as addresses. Use a tool like addr2line to find the
+// corresponding source line:
+//
+//     arm-none-eabi-addr2line -e firmware.elf 0x00001234
+//
+// Note that allocations done by the runtime on behalf of the program (for
+// example when appending to a slice or concatenating strings) are attributed to
+// the runtime function that does the allocation, not to the program. Not all
+// targets support getting the return address (in particular WebAssembly),
+// where all allocation sites are reported as nil.
+
+import (
+	"unsafe"
+)
+
+const allocTrace = true
+
+// allocSites is the start of the side table with the allocation site of every
+// heap block. Only the site of the head block of an object is used.
+var allocSites uintptr
+
+// initAllocSites reserves space for the allocation site table after the block
+// state metadata, and returns the new size of all heap metadata.
+func initAllocSites(metadataSize, totalSize uintptr) uintptr {
+	allocSites = (heapStart + metadataSize + unsafe.Alignof(heapStart) - 1) &^ (unsafe.Alignof(heapStart) - 1)
+	return allocSites - heapStart + totalSize/bytesPerBlock*unsafe.Sizeof(heapStart)
+}
+
+// allocSite returns a pointer to the allocation site of the given block.
+func (b gcBlock) allocSite() *uintptr {
+	return (*uintptr)(unsafe.Pointer(allocSites + uintptr(b)*unsafe.Sizeof(heapStart)))
+}
+
+// setAllocSite records where the object starting at the given block was
+// allocated.
+func setAllocSite(block, site uintptr) {
+	*gcBlock(block).allocSite() = site
+}
+
+// DumpAllocs runs a garbage collection cycle and then prints all live heap
+// objects grouped by allocation site, with the number of objects and the
+// number of bytes in use for each site. It does not allocate memory itself.
+func DumpAllocs() {
+	GC()
+	println("live heap objects by allocation site:")
+	totalObjects := uintptr(0)
+	totalBytes := uintptr(0)
+	for block := gcBlock(0); block < endBlock; block++ {
+		if block.state() != blockStateHead {
+			continue
+		}
+		site := *block.allocSite()
+
+		// Only print every allocation site once. This is quadratic in the
+		// number of objects, but it avoids allocating memory to group them.
+		seen := false
+		for prev := gcBlock(0); prev < block; prev++ {
+			if prev.state() == blockStateHead && *prev.allocSite() == site {
+				seen = true
+				break
+			}
+		}
+		if seen {
+			continue
+		}
+
+		// Count all objects allocated at this site.
+		objects := uintptr(0)
+		bytes := uintptr(0)
+		for obj := block; obj < endBlock; obj++ {
+			if obj.state() != blockStateHead || *obj.allocSite() != site {
+				continue
+			}
+			objects++
+			bytes += uintptr(obj.findNext()-obj) * bytesPerBlock
+		}
+		print("  ")
+		printptr(site)
+		println(":", objects, "objects,", bytes, "bytes")
+		totalObjects += objects
+		totalBytes += bytes
+	}
+	println("total:", totalObjects, "objects,", totalBytes, "bytes")
+}
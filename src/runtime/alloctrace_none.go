@@ -0,0 +1,20 @@
+// +build !gc.conservative !alloctrace
+
+package runtime
+
+// Allocation tracing is disabled, see alloctrace.go.
+
+const allocTrace = false
+
+func initAllocSites(metadataSize, totalSize uintptr) uintptr {
+	return metadataSize
+}
+
+func setAllocSite(block, site uintptr) {
+}
+
+// DumpAllocs prints all live heap objects grouped by allocation site. It is
+// only available when compiled with -alloc-trace.
+func DumpAllocs() {
+	println("runtime.DumpAllocs: allocation tracing is not enabled (use -alloc-trace)")
+}
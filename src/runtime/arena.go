@@ -0,0 +1,93 @@
+package runtime
+
+// This file implements arenas: regions of memory that are allocated from in
+// one go and freed all at once, without the GC.
+
+import (
+	"unsafe"
+)
+
+// Arena is a region of memory for data with a lifetime that is managed by the
+// application instead of the garbage collector, such as the buffers used while
+// parsing a message. Allocations from an arena (see AllocFrom) are very cheap
+// and are all freed at once by Reset. The rest of the program keeps using
+// the garbage collector as usual.
+//
+// The memory of an arena is allocated once by NewArena and is never freed, so
+// arenas should be created at startup and reused, not created for every
+// message. The garbage collector keeps the arena itself alive but doesn't look
+// inside: it does not scan the memory of the arena for pointers.
+//
+// This leads to the following safety rule: never store a pointer to memory
+// managed by the GC (anything allocated with new, make, append, a string
+// concatenation etc.) in memory allocated from an arena. The GC doesn't see
+// such a pointer, so it may free the object while it is still referenced from
+// the arena. Pointers into the same arena, or to globals, are fine. Likewise,
+// no pointer to arena memory may be used after Reset, as the memory will be
+// reused by later allocations.
+//
+// An arena must not be used from multiple goroutines or interrupts at the
+// same time.
+type Arena struct {
+	buf  unsafe.Pointer // memory of the arena
+	size uintptr        // size of buf
+	used uintptr        // number of bytes of buf that are allocated
+	next *Arena         // next arena in the list of all arenas
+}
+
+// arenaAlign is the alignment of all allocations from an arena. It is the
+// largest alignment of the basic types (int64 and float64) on all supported
+// architectures.
+const arenaAlign = 8
+
+// arenas is a list of all arenas, to skip them while scanning the heap.
+var arenas *Arena
+
+// NewArena allocates a new arena of the given size from the heap.
+func NewArena(size uintptr) *Arena {
+	a := &Arena{
+		buf:  alloc(size),
+		size: size,
+		next: arenas,
+	}
+	arenas = a
+	return a
+}
+
+// AllocFrom allocates size bytes of zeroed memory from the arena, aligned for
+// any type. It returns nil when the arena doesn't have enough free space left.
+// The memory stays valid until the arena is reset.
+func AllocFrom(a *Arena, size uintptr) unsafe.Pointer {
+	base := uintptr(a.buf)
+	start := (base+a.used+arenaAlign-1)&^(arenaAlign-1) - base
+	if start > a.size || size > a.size-start {
+		return nil // arena is full
+	}
+	ptr := unsafe.Pointer(base + start)
+	a.used = start + size
+	memzero(ptr, size)
+	return ptr
+}
+
+// Reset frees all memory allocated from the arena at once, so that it can be
+// reused for new allocations.
+func (a *Arena) Reset() {
+	a.used = 0
+}
+
+// Used returns the number of bytes allocated from the arena since it was
+// created or last reset, including padding for alignment.
+func (a *Arena) Used() uintptr {
+	return a.used
+}
+
+// isArenaMemory returns whether the heap object at the given address is the
+// memory of an arena, which must not be scanned by the GC.
+func isArenaMemory(addr uintptr) bool {
+	for a := arenas; a != nil; a = a.next {
+		if uintptr(a.buf) == addr {
+			return true
+		}
+	}
+	return false
+}
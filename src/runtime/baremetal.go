@@ -6,6 +6,11 @@ import (
 	"unsafe"
 )
 
+// The heap is the region from _heap_start to _heap_end, which is the rest of
+// RAM after the stack and globals unless its size is set with -heap-size. See
+// targets/arm.ld. The C heap (-c-heap-size) is placed right after it, see
+// cmalloc_heap.go.
+
 //go:extern _heap_start
 var heapStartSymbol unsafe.Pointer
 
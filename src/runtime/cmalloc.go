@@ -0,0 +1,85 @@
+// +build baremetal
+// +build !gc.none malloc.heap
+
+package runtime
+
+// This file provides malloc, free, calloc and realloc for C code that is
+// linked into the program, as there is no libc on baremetal targets. They are
+// weak definitions: a C library (or the program itself) that defines its own
+// allocator takes precedence over them.
+//
+// By default, C allocations come from the garbage collected heap (see
+// cmalloc_gc.go) and free doesn't do anything: memory is reclaimed when the
+// garbage collector can't find any pointer to it anymore. The collector scans
+// the globals and the stack conservatively on baremetal targets (including
+// those of C code), but it misses pointers stored where it doesn't look: in
+// .noinit globals, in a DMA descriptor or other memory outside RAM, in a form
+// that doesn't look like a pointer (like a tagged or XOR-ed pointer). An
+// allocation that is only referenced from such a place is freed while C code
+// still uses it.
+//
+// C libraries that do this need a separate C heap, which is set with
+// -c-heap-size (see cmalloc_heap.go). It is never scanned or collected, and
+// free releases memory right away.
+//
+// These are not provided on WebAssembly: the garbage collector doesn't scan the
+// globals and the stack of C code there, so no C allocation would be safe.
+
+import (
+	"unsafe"
+)
+
+// cmallocAlign is the alignment of pointers returned by malloc, which is also
+// the size of the header in front of each allocation (that stores its size).
+const cmallocAlign = 2 * unsafe.Sizeof(uintptr(0))
+
+//go:export malloc
+//go:weak
+func cMalloc(size uintptr) unsafe.Pointer {
+	return cmallocAlloc(size)
+}
+
+//go:export free
+//go:weak
+func cFree(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+	cmallocFree(ptr)
+}
+
+//go:export calloc
+//go:weak
+func cCalloc(nmemb, size uintptr) unsafe.Pointer {
+	if size != 0 && nmemb > ^uintptr(0)/size {
+		return nil // overflow
+	}
+	ptr := cmallocAlloc(nmemb * size)
+	if ptr != nil {
+		memzero(ptr, nmemb*size)
+	}
+	return ptr
+}
+
+//go:export realloc
+//go:weak
+func cRealloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	if ptr == nil {
+		return cmallocAlloc(size)
+	}
+	if size == 0 {
+		cmallocFree(ptr)
+		return nil
+	}
+	oldSize := cmallocSize(ptr)
+	if size <= oldSize {
+		return ptr
+	}
+	newPtr := cmallocAlloc(size)
+	if newPtr == nil {
+		return nil // the old allocation stays valid
+	}
+	memcpy(newPtr, ptr, oldSize)
+	cmallocFree(ptr)
+	return newPtr
+}
@@ -0,0 +1,29 @@
+// +build baremetal
+// +build !gc.none
+// +build !malloc.heap
+
+package runtime
+
+// C allocations from the garbage collected heap, see cmalloc.go.
+
+import (
+	"unsafe"
+)
+
+// cmallocAlloc allocates memory on the garbage collected heap. Like any heap
+// allocation, it panics when there is not enough memory left.
+func cmallocAlloc(size uintptr) unsafe.Pointer {
+	header := alloc(cmallocAlign + size)
+	*(*uintptr)(header) = size
+	return unsafe.Pointer(uintptr(header) + cmallocAlign)
+}
+
+// cmallocFree doesn't do anything: the memory is freed by the garbage collector
+// once it is no longer referenced.
+func cmallocFree(ptr unsafe.Pointer) {
+}
+
+// cmallocSize returns the size that was requested when allocating ptr.
+func cmallocSize(ptr unsafe.Pointer) uintptr {
+	return *(*uintptr)(unsafe.Pointer(uintptr(ptr) - cmallocAlign))
+}
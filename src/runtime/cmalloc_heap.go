@@ -0,0 +1,107 @@
+// +build baremetal,malloc.heap
+
+package runtime
+
+// C allocations from a separate C heap, which is set with -c-heap-size and
+// placed at the end of the heap region by the linker script (see
+// targets/arm.ld). It is a simple first-fit allocator: free blocks are kept in
+// a list sorted by address, so that neighboring free blocks can be merged.
+// Unlike allocations from the garbage collected heap, malloc returns nil when
+// there is not enough memory left.
+
+import (
+	"unsafe"
+)
+
+//go:extern _c_heap_start
+var cHeapStartSymbol unsafe.Pointer
+
+//go:extern _c_heap_end
+var cHeapEndSymbol unsafe.Pointer
+
+// cHeapBlock is the header of every block in the C heap, both allocated and
+// free. It is cmallocAlign bytes in size.
+type cHeapBlock struct {
+	size uintptr     // size of the block including this header
+	next *cHeapBlock // next free block (only used in free blocks)
+}
+
+var (
+	cHeapFree        *cHeapBlock // first free block, sorted by address
+	cHeapInitialized bool
+)
+
+// cHeapInit puts the whole C heap in the free list.
+func cHeapInit() {
+	start := (uintptr(unsafe.Pointer(&cHeapStartSymbol)) + cmallocAlign - 1) &^ (cmallocAlign - 1)
+	end := uintptr(unsafe.Pointer(&cHeapEndSymbol)) &^ (cmallocAlign - 1)
+	if end > start {
+		cHeapFree = (*cHeapBlock)(unsafe.Pointer(start))
+		cHeapFree.size = end - start
+		cHeapFree.next = nil
+	}
+	cHeapInitialized = true
+}
+
+// cmallocAlloc returns the first free block that is large enough, splitting
+// off the rest of the block if it is big enough to be useful. It returns nil
+// if there is no such block.
+func cmallocAlloc(size uintptr) unsafe.Pointer {
+	if !cHeapInitialized {
+		cHeapInit()
+	}
+	if size > uintptr(unsafe.Pointer(&cHeapEndSymbol))-uintptr(unsafe.Pointer(&cHeapStartSymbol)) {
+		return nil // would overflow below
+	}
+	needed := (cmallocAlign + size + cmallocAlign - 1) &^ (cmallocAlign - 1)
+	prev := &cHeapFree
+	for block := cHeapFree; block != nil; block = block.next {
+		if block.size >= needed {
+			if block.size-needed >= 2*cmallocAlign {
+				rest := (*cHeapBlock)(unsafe.Pointer(uintptr(unsafe.Pointer(block)) + needed))
+				rest.size = block.size - needed
+				rest.next = block.next
+				block.size = needed
+				*prev = rest
+			} else {
+				*prev = block.next
+			}
+			return unsafe.Pointer(uintptr(unsafe.Pointer(block)) + cmallocAlign)
+		}
+		prev = &block.next
+	}
+	return nil
+}
+
+// cmallocFree returns the block to the free list, merging it with the free
+// blocks right before and after it.
+func cmallocFree(ptr unsafe.Pointer) {
+	block := (*cHeapBlock)(unsafe.Pointer(uintptr(ptr) - cmallocAlign))
+	var prev *cHeapBlock
+	next := cHeapFree
+	for next != nil && uintptr(unsafe.Pointer(next)) < uintptr(unsafe.Pointer(block)) {
+		prev = next
+		next = next.next
+	}
+
+	block.next = next
+	if next != nil && uintptr(unsafe.Pointer(block))+block.size == uintptr(unsafe.Pointer(next)) {
+		block.size += next.size
+		block.next = next.next
+	}
+	if prev == nil {
+		cHeapFree = block
+	} else if uintptr(unsafe.Pointer(prev))+prev.size == uintptr(unsafe.Pointer(block)) {
+		prev.size += block.size
+		prev.next = block.next
+	} else {
+		prev.next = block
+	}
+}
+
+// cmallocSize returns the usable size of the allocation at ptr, which may be
+// a bit larger than requested.
+func cmallocSize(ptr unsafe.Pointer) uintptr {
+	block := (*cHeapBlock)(unsafe.Pointer(uintptr(ptr) - cmallocAlign))
+	return block.size - cmallocAlign
+}
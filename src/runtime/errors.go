@@ -0,0 +1,36 @@
+// +build go1.13
+
+package runtime
+
+// This file implements the error returned by fmt.Errorf calls that only wrap
+// an error with a constant prefix, after they have been replaced by
+// the compiler (see compiler/errorf.go). Only Go 1.13 and later support the %w
+// verb:
+//
+//     fmt.Errorf("could not read config: %w", err)
+
+// wrapError is the same as the error type returned by fmt.Errorf with a %w
+// verb: the message is already formatted, and the wrapped error is returned by
+// Unwrap for errors.Is, errors.As and errors.Unwrap.
+type wrapError struct {
+	msg string
+	err error
+}
+
+func (e *wrapError) Error() string {
+	return e.msg
+}
+
+func (e *wrapError) Unwrap() error {
+	return e.err
+}
+
+// wrapErrorf returns the same error as fmt.Errorf(prefix+"%w", err), without
+// needing the formatting code of the fmt package.
+func wrapErrorf(prefix string, err error) error {
+	if err == nil {
+		// fmt.Errorf doesn't wrap a nil error, it formats it like this.
+		return &wrapError{msg: prefix + "%!w(<nil>)"}
+	}
+	return &wrapError{msg: prefix + err.Error(), err: err}
+}
@@ -0,0 +1,25 @@
+// +build cortexm
+
+package runtime
+
+import (
+	"device/arm"
+)
+
+// initFPUStacking configures the FPU context stacking mode in the FPCCR
+// register, as selected by the fpu-stacking option of the target. By default
+// (lazy stacking) the register is not changed. See fpuStackingTag in target.go
+// for a description of the modes.
+//
+// This must only be done on chips with an FPU (Cortex-M4F and Cortex-M7F), as
+// the register does not exist on other chips. fpuStackingTag only allows the
+// other modes on targets with hardware floating point.
+func initFPUStacking() {
+	if fpuStackingFPCCR != fpuStackingLazy {
+		fpccr := arm.FPU.FPCCR.Get() &^ (arm.FPU_FPCCR_ASPEN_Msk | arm.FPU_FPCCR_LSPEN_Msk)
+		arm.FPU.FPCCR.Set(fpccr | fpuStackingFPCCR)
+	}
+}
+
+// The FPCCR value for lazy stacking, which is the reset value.
+const fpuStackingLazy = arm.FPU_FPCCR_ASPEN_Msk | arm.FPU_FPCCR_LSPEN_Msk
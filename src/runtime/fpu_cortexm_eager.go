@@ -0,0 +1,10 @@
+// +build cortexm,fpustacking.eager
+
+package runtime
+
+import (
+	"device/arm"
+)
+
+// Always save the FPU context when the interrupted code uses the FPU.
+const fpuStackingFPCCR = arm.FPU_FPCCR_ASPEN_Msk
@@ -0,0 +1,5 @@
+// +build cortexm,!fpustacking.eager,!fpustacking.none
+
+package runtime
+
+const fpuStackingFPCCR = fpuStackingLazy
@@ -0,0 +1,6 @@
+// +build cortexm,fpustacking.none
+
+package runtime
+
+// Never save the FPU context on interrupts.
+const fpuStackingFPCCR = 0
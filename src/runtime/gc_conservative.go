@@ -185,6 +185,10 @@ func init() {
 
 	// Allocate some memory to keep 2 bits of information about every block.
 	metadataSize := totalSize / (blocksPerStateByte * bytesPerBlock)
+	if allocTrace {
+		// Also keep the allocation site of every block, see alloctrace.go.
+		metadataSize = initAllocSites(metadataSize, totalSize)
+	}
 
 	// Align the pool.
 	poolStart = (heapStart + metadataSize + (bytesPerBlock - 1)) &^ (bytesPerBlock - 1)
@@ -271,6 +275,10 @@ func alloc(size uintptr) unsafe.Pointer {
 			for i := thisAlloc + 1; i != nextAlloc; i++ {
 				i.setState(blockStateTail)
 			}
+			if allocTrace {
+				// Record where this object was allocated (-alloc-trace).
+				setAllocSite(uintptr(thisAlloc), uintptr(returnAddress(0)))
+			}
 
 			// Return a pointer to this allocation.
 			pointer := thisAlloc.pointer()
@@ -339,6 +347,11 @@ func markRoot(addr, root uintptr) {
 				println("found unmarked pointer", root, "at address", addr)
 			}
 			head.setState(blockStateMark)
+			if arenas != nil && isArenaMemory(head.address()) {
+				// The memory of an arena may not contain pointers into
+				// the heap, so it doesn't need to be scanned.
+				return
+			}
 			next := block.findNext()
 			// TODO: avoid recursion as much as possible
 			markRoots(head.address(), next.address())
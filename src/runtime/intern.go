@@ -0,0 +1,62 @@
+package runtime
+
+// This file implements string interning: sharing the storage of identical
+// strings that are built at runtime.
+
+const (
+	// internTableSize is the number of strings that can be interned at the
+	// same time. When the table is full, an older string is evicted.
+	internTableSize = 32
+
+	// internMaxLength is the maximum length of a string that is interned.
+	// Longer strings are not stored in the table.
+	internMaxLength = 32
+)
+
+var (
+	internTable [internTableSize]string
+	internUsed  [internTableSize]bool // set when an entry was recently returned
+	internHand  uint8                 // next entry to consider for eviction
+)
+
+// InternString returns a canonical instance of s: when a string with the same
+// content was interned before and is still in the intern table, that string is
+// returned so that s itself can be garbage collected. Otherwise, s is added to
+// the table and returned.
+//
+// This only saves memory when the same content is built many times, for
+// example in a protocol parser that creates the same small strings over and
+// over again. Interning unique strings only costs time and keeps them alive
+// longer than necessary.
+//
+// The table has room for 32 strings of up to 32 bytes. Longer strings are
+// returned unchanged. When the table is full, an entry that wasn't returned
+// recently is evicted (using the clock algorithm, an approximation of LRU). A
+// string that is evicted stays valid, but a later identical string won't share
+// storage with it anymore.
+//
+// InternString must not be called from an interrupt handler.
+func InternString(s string) string {
+	if len(s) == 0 || len(s) > internMaxLength {
+		// Empty strings have no storage to share, and long strings are not
+		// stored in the table.
+		return s
+	}
+	for i := range internTable {
+		if internTable[i] == s {
+			internUsed[i] = true
+			return internTable[i]
+		}
+	}
+
+	// Not found: find an entry to replace. Entries that were used recently
+	// get a second chance.
+	for internUsed[internHand] {
+		internUsed[internHand] = false
+		internHand = (internHand + 1) % internTableSize
+	}
+	internTable[internHand] = s
+	internUsed[internHand] = true
+	internHand = (internHand + 1) % internTableSize
+	return s
+}
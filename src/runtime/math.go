@@ -1,6 +1,8 @@
 package runtime
 
-// This file redirects math stubs to their fallback implementation.
+// This file redirects math stubs to their fallback implementation. Sin, Cos
+// and Exp are redirected in math_full.go, or replaced by the reduced precision
+// versions in math_reduced.go with -math=reduced.
 // TODO: use optimized versions if possible.
 
 import (
@@ -69,12 +71,6 @@ func llvm_ceil(x float64) float64
 //go:linkname math_ceil math.ceil
 func math_ceil(x float64) float64
 
-//go:linkname math_Cos math.Cos
-func math_Cos(x float64) float64 { return math_cos(x) }
-
-//go:linkname math_cos math.cos
-func math_cos(x float64) float64
-
 //go:linkname math_Cosh math.Cosh
 func math_Cosh(x float64) float64 { return math_cosh(x) }
 
@@ -93,12 +89,6 @@ func math_Erfc(x float64) float64 { return math_erfc(x) }
 //go:linkname math_erfc math.erfc
 func math_erfc(x float64) float64
 
-//go:linkname math_Exp math.Exp
-func math_Exp(x float64) float64 { return math_exp(x) }
-
-//go:linkname math_exp math.exp
-func math_exp(x float64) float64
-
 //go:linkname math_Expm1 math.Expm1
 func math_Expm1(x float64) float64 { return math_expm1(x) }
 
@@ -219,12 +209,6 @@ func math_Remainder(x, y float64) float64 { return math_remainder(x, y) }
 //go:linkname math_remainder math.remainder
 func math_remainder(x, y float64) float64
 
-//go:linkname math_Sin math.Sin
-func math_Sin(x float64) float64 { return math_sin(x) }
-
-//go:linkname math_sin math.sin
-func math_sin(x float64) float64
-
 //go:linkname math_Sinh math.Sinh
 func math_Sinh(x float64) float64 { return math_sinh(x) }
 
@@ -0,0 +1,29 @@
+// +build !math.reduced
+
+package runtime
+
+// This file redirects the math stubs that have a reduced precision version in
+// math_reduced.go to their fallback implementation, like math.go does for the
+// other stubs.
+
+import (
+	_ "unsafe"
+)
+
+//go:linkname math_Cos math.Cos
+func math_Cos(x float64) float64 { return math_cos(x) }
+
+//go:linkname math_cos math.cos
+func math_cos(x float64) float64
+
+//go:linkname math_Exp math.Exp
+func math_Exp(x float64) float64 { return math_exp(x) }
+
+//go:linkname math_exp math.exp
+func math_exp(x float64) float64
+
+//go:linkname math_Sin math.Sin
+func math_Sin(x float64) float64 { return math_sin(x) }
+
+//go:linkname math_sin math.sin
+func math_sin(x float64) float64
@@ -0,0 +1,147 @@
+// +build math.reduced
+
+package runtime
+
+// This file implements reduced precision versions of some functions in the
+// math package, selected with -math=reduced. They use low degree polynomials
+// (the single precision coefficients of the Cephes library) and a simple range
+// reduction, which makes them a lot smaller than the full precision versions:
+// those need large tables of constants and high degree polynomials to be
+// accurate to 1 ULP over the whole range of a float64.
+//
+// The accuracy bounds are:
+//
+//   - Sin and Cos: an absolute error of at most 1e-8 for |x| <= 1e5. Larger
+//     arguments lose about as much accuracy as the spacing between float64
+//     values around x, as there is no extra precision in the range reduction.
+//     For |x| >= 2^29 and ±Inf the result is NaN.
+//   - Exp: a relative error of at most 1e-8, except for subnormal results
+//     (x < -708.39), which have an absolute error of at most 1e-316.
+//
+// Special cases (NaN, infinities and the limits for overflow and underflow)
+// are the same as in the math package. All other math functions use the full
+// precision implementation, see math.go.
+
+import (
+	_ "unsafe"
+)
+
+const (
+	// reducedTrigMax is the largest argument for which Sin and Cos calculate
+	// the result. The quadrant of larger arguments doesn't fit in an int32.
+	reducedTrigMax = 1 << 29
+
+	pio2Hi    = 1.57079632673412561417e+00 // first 33 bits of π/2
+	pio2Lo    = 6.07710050650619224932e-11 // π/2 - pio2Hi
+	twoOverPi = 6.36619772367581382433e-01 // 2/π
+
+	ln2Hi = 6.93147180369123816490e-01 // first 32 bits of ln(2)
+	ln2Lo = 1.90821492927058770002e-10 // ln(2) - ln2Hi
+	log2e = 1.44269504088896338700e+00 // 1/ln(2)
+
+	expOverflow  = 7.09782712893383973096e+02  // largest x for which Exp(x) is finite
+	expUnderflow = -7.45133219101941108420e+02 // smallest x for which Exp(x) is not zero
+)
+
+//go:linkname math_Sin math.Sin
+func math_Sin(x float64) float64 {
+	sign := false
+	if x < 0 {
+		x = -x
+		sign = true
+	}
+	if isNaN(x) || x >= reducedTrigMax {
+		return float64frombits(0x7FF8000000000001) // NaN
+	}
+	r, quadrant := trigReduce(x)
+	var y float64
+	switch quadrant {
+	case 0:
+		y = sinPoly(r)
+	case 1:
+		y = cosPoly(r)
+	case 2:
+		y = -sinPoly(r)
+	default:
+		y = -cosPoly(r)
+	}
+	if sign {
+		y = -y
+	}
+	return y
+}
+
+//go:linkname math_Cos math.Cos
+func math_Cos(x float64) float64 {
+	x = abs(x)
+	if isNaN(x) || x >= reducedTrigMax {
+		return float64frombits(0x7FF8000000000001) // NaN
+	}
+	r, quadrant := trigReduce(x)
+	switch quadrant {
+	case 0:
+		return cosPoly(r)
+	case 1:
+		return -sinPoly(r)
+	case 2:
+		return -cosPoly(r)
+	default:
+		return sinPoly(r)
+	}
+}
+
+// trigReduce returns r and the quadrant k&3 such that x = k*π/2 + r, with
+// |r| <= π/4. The argument x must be in the range [0, reducedTrigMax).
+func trigReduce(x float64) (float64, int) {
+	k := int32(x*twoOverPi + 0.5)
+	kf := float64(k)
+	r := (x - kf*pio2Hi) - kf*pio2Lo
+	return r, int(k & 3)
+}
+
+// sinPoly returns an approximation of sin(r) for |r| <= π/4.
+func sinPoly(r float64) float64 {
+	z := r * r
+	return r + r*z*((-1.9515295891e-4*z+8.3321608736e-3)*z-1.6666654611e-1)
+}
+
+// cosPoly returns an approximation of cos(r) for |r| <= π/4.
+func cosPoly(r float64) float64 {
+	z := r * r
+	return 1 - 0.5*z + z*z*((2.443315711809948e-5*z-1.388731625493765e-3)*z+4.166664568298827e-2)
+}
+
+//go:linkname math_Exp math.Exp
+func math_Exp(x float64) float64 {
+	switch {
+	case isNaN(x):
+		return x
+	case x > expOverflow:
+		return inf
+	case x < expUnderflow:
+		return 0
+	}
+
+	// Reduce to x = k*ln(2) + r, with |r| <= ln(2)/2, so that
+	// exp(x) = 2^k * exp(r).
+	var k int32
+	if x < 0 {
+		k = int32(x*log2e - 0.5)
+	} else {
+		k = int32(x*log2e + 0.5)
+	}
+	kf := float64(k)
+	r := (x - kf*ln2Hi) - kf*ln2Lo
+	y := ((((((1.9875691500e-4*r+1.3981999507e-3)*r+8.3334519073e-3)*r+4.1665795894e-2)*r+1.6666665459e-1)*r+5.0000001201e-1)*r*r + r + 1)
+
+	// Multiply by 2^k, which may not be representable itself: 2^1024 is out
+	// of range and 2^-1023 and below are subnormal.
+	if k > 1023 {
+		y *= 2
+		k--
+	} else if k < -1022 {
+		y *= float64frombits(uint64(1023-54) << 52) // 2^-54
+		k += 54
+	}
+	return y * float64frombits(uint64(k+1023)<<52)
+}
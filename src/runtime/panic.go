@@ -5,21 +5,6 @@ package runtime
 //go:export llvm.trap
 func trap()
 
-// Builtin function panic(msg), used as a compiler intrinsic.
-func _panic(message interface{}) {
-	printstring("panic: ")
-	printitf(message)
-	printnl()
-	abort()
-}
-
-// Cause a runtime panic, which is (currently) always a string.
-func runtimePanic(msg string) {
-	printstring("panic: runtime error: ")
-	println(msg)
-	abort()
-}
-
 // Try to recover a panicking goroutine.
 func _recover() interface{} {
 	// Deferred functions are currently not executed during panic, so there is
@@ -0,0 +1,23 @@
+// +build !panicreset
+
+package runtime
+
+// The default panic behavior: print the panic message and halt. See
+// panic_reset.go for -panic=reset.
+
+const panicReset = false
+
+// Builtin function panic(msg), used as a compiler intrinsic.
+func _panic(message interface{}) {
+	printstring("panic: ")
+	printitf(message)
+	printnl()
+	abort()
+}
+
+// Cause a runtime panic, which is (currently) always a string.
+func runtimePanic(msg string) {
+	printstring("panic: runtime error: ")
+	println(msg)
+	abort()
+}
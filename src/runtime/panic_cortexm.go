@@ -0,0 +1,79 @@
+// +build cortexm
+
+package runtime
+
+import (
+	"runtime/volatile"
+)
+
+// PanicReason is the cause of a reset with -panic=reset.
+type PanicReason uint32
+
+const (
+	PanicReasonPanic        PanicReason = iota + 1 // call to panic()
+	PanicReasonRuntimeError                        // runtime error, like an out of range index
+	PanicReasonHardFault                           // HardFault, for example from a stack overflow
+)
+
+// PanicInfo describes a panic that caused a reset with -panic=reset.
+type PanicInfo struct {
+	Reason PanicReason
+
+	// Site is the address of the call to panic (or the runtime function that
+	// detected the runtime error), or the program counter at the time of a
+	// HardFault. It can be converted to a source location with a tool like
+	// addr2line:
+	//
+	//     arm-none-eabi-addr2line -e firmware.elf 0x00001234
+	//
+	// Note that it can only be resolved using the same firmware image that
+	// caused the panic.
+	Site uintptr
+}
+
+// resetPanicInfo is the panic information that is preserved across a reset. It
+// is placed in the .noinit section, which is not zeroed at startup (see
+// targets/arm.ld).
+//go:retained
+var resetPanicInfo struct {
+	magic  uint32
+	reason uint32
+	site   uint32
+}
+
+// resetPanicMagic marks resetPanicInfo as valid. Memory that is not zeroed
+// contains random data after a power cycle, which is very unlikely to match.
+const resetPanicMagic = 0x50414e43 // "PANC"
+
+// LastPanic returns information about the panic that caused the last reset,
+// when the program is compiled with -panic=reset. It returns false when the
+// chip was not reset by a panic (or when the information has already been
+// read), for example after a power cycle. The information is cleared, so that
+// LastPanic only reports a panic once.
+//
+// The information is stored in RAM that is not initialized at startup, which
+// keeps its value during a reset, but not when the chip loses power. Programs
+// can declare their own globals that survive a reset in the same way:
+//
+//     //go:retained
+//     var bootCount uint32
+//
+// Such globals have an undefined value after a power cycle, and must not
+// contain pointers. See also machine.RetainedRAM.
+func LastPanic() (info PanicInfo, ok bool) {
+	if volatile.LoadUint32(&resetPanicInfo.magic) != resetPanicMagic {
+		return
+	}
+	info.Reason = PanicReason(volatile.LoadUint32(&resetPanicInfo.reason))
+	info.Site = uintptr(volatile.LoadUint32(&resetPanicInfo.site))
+	volatile.StoreUint32(&resetPanicInfo.magic, 0)
+	return info, true
+}
+
+// recordPanic stores the reason and site of a panic in resetPanicInfo, just
+// before a reset.
+func recordPanic(reason PanicReason, site uintptr) {
+	volatile.StoreUint32(&resetPanicInfo.reason, uint32(reason))
+	volatile.StoreUint32(&resetPanicInfo.site, uint32(site))
+	volatile.StoreUint32(&resetPanicInfo.magic, resetPanicMagic)
+}
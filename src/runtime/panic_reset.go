@@ -0,0 +1,36 @@
+// +build panicreset
+
+package runtime
+
+// With -panic=reset, a panic resets the chip instead of halting it, after
+// recording the reason and call site of the panic in RAM that survives the
+// reset. The program can read this information after the reset using
+// LastPanic. The panic message is still printed before the reset, for when a
+// console is connected.
+
+import (
+	"device/arm"
+)
+
+const panicReset = true
+
+// Builtin function panic(msg), used as a compiler intrinsic. It must not be
+// inlined, so that the return address is the call site of the panic.
+//go:noinline
+func _panic(message interface{}) {
+	recordPanic(PanicReasonPanic, uintptr(returnAddress(0)))
+	printstring("panic: ")
+	printitf(message)
+	printnl()
+	arm.SystemReset()
+}
+
+// Cause a runtime panic, which is (currently) always a string. The call site
+// is usually a runtime function like nilPanic or lookupPanic.
+//go:noinline
+func runtimePanic(msg string) {
+	recordPanic(PanicReasonRuntimeError, uintptr(returnAddress(0)))
+	printstring("panic: runtime error: ")
+	println(msg)
+	arm.SystemReset()
+}
@@ -0,0 +1,47 @@
+// +build preemptloops
+
+package runtime
+
+// This file implements the runtime side of -preempt-loops, see
+// compiler/preempt.go. A timer interrupt sets preemptRequested every
+// preemptMicros, and the compiler inserts a call to preemptLoop at every loop
+// back-edge, which yields to the scheduler when the flag is set.
+
+import (
+	"device/arm"
+	"runtime/volatile"
+)
+
+const preemptLoops = true
+
+// preemptRequested is set by the timer interrupt to ask the running goroutine
+// to yield at the next loop back-edge.
+var preemptRequested volatile.Register8
+
+// requestPreempt is called from the timer interrupt every preemptMicros.
+func requestPreempt() {
+	preemptRequested.Set(1)
+}
+
+// preemptLoop is called by the compiler at every loop back-edge. It is inlined,
+// so that it is only a load and a branch when preemption isn't requested.
+//go:inline
+func preemptLoop() {
+	if preemptRequested.Get() != 0 {
+		preempt()
+	}
+}
+
+// preempt yields to the scheduler, so that other goroutines get to run. It
+// doesn't do anything when running in an interrupt handler (the VECTACTIVE
+// field of ICSR is non-zero) or in the scheduler itself, where it isn't
+// possible to switch goroutines: the flag stays set until the next back-edge
+// in a goroutine.
+//go:noinline
+func preempt() {
+	if currentTask == nil || arm.SCB.ICSR.Get()&0x1ff != 0 {
+		return
+	}
+	preemptRequested.Set(0)
+	Gosched()
+}
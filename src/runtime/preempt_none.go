@@ -0,0 +1,9 @@
+// +build !preemptloops
+
+package runtime
+
+const preemptLoops = false
+
+func requestPreempt() {}
+
+func startPreemptTimer() {}
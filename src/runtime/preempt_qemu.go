@@ -0,0 +1,7 @@
+// +build preemptloops,qemu
+
+package runtime
+
+// startPreemptTimer doesn't need to do anything on QEMU: the SysTick timer is
+// already used to keep track of time, and handleSysTick requests preemption.
+func startPreemptTimer() {}
@@ -0,0 +1,22 @@
+// +build preemptloops,cortexm,!qemu
+
+package runtime
+
+import (
+	"device/arm"
+	"machine"
+)
+
+// startPreemptTimer configures the SysTick timer to interrupt every
+// preemptMicros. SysTick is not used for anything else on these chips. The
+// reload value is limited to 24 bits, which is enough for up to 1.6GHz.
+func startPreemptTimer() {
+	arm.SYST.RVR.Set(machine.CPU_FREQUENCY/1000000*preemptMicros - 1)
+	arm.SYST.CVR.Set(0)
+	arm.SYST.CSR.Set(arm.SYST_CSR_ENABLE_Msk | arm.SYST_CSR_TICKINT_Msk | arm.SYST_CSR_CLKSOURCE_Msk)
+}
+
+//go:export SysTick_Handler
+func handlePreemptTimer() {
+	requestPreempt()
+}
@@ -0,0 +1,62 @@
+// +build cortexm,vectors.ram
+
+package runtime
+
+// This file implements a RAM-resident interrupt vector table (-vectors=ram).
+// At startup, the vector table in flash is copied to the .ram_vectors section
+// in RAM (see targets/arm.ld) and the VTOR register is pointed to the copy.
+//
+// This is needed when handlers are replaced at runtime, for example by code
+// that shares the vector table with a bootloader or installs a handler that is
+// only known after startup: the table in flash can't be changed (or only by
+// erasing a whole flash page), while the copy in RAM can be written like any
+// other memory. The table that is currently used can be found with:
+//
+//     table := (*[16 + numInterrupts]uintptr)(unsafe.Pointer(uintptr(arm.SCB.VTOR.Get())))
+//
+// where entry 16+n is the handler of IRQ n. Handlers are Thumb code, so the
+// lowest bit of their address must be set.
+//
+// The copy costs 4 bytes of RAM for every entry in the table (16 system
+// exceptions plus the number of interrupts of the chip, for example 256 bytes
+// for 48 interrupts). As VTOR requires the table to be aligned to its size
+// rounded up to a power of two, up to the same amount may be lost to padding.
+//
+// The chip must have a VTOR register. All Cortex-M3, M4 and M7 chips have
+// one, but the Cortex-M0 doesn't and it is optional on the Cortex-M0+ (most
+// chips, like the SAMD21, do have it). Targets for chips without one set
+// no-vtor, which makes the compiler reject -vectors=ram.
+
+import (
+	"device/arm"
+	"unsafe"
+)
+
+//go:extern _svectors
+var _svectors [0]uintptr
+
+//go:extern _evectors
+var _evectors [0]uintptr
+
+//go:extern _ram_vectors
+var _ram_vectors [0]uintptr
+
+// initRAMVectors copies the interrupt vector table to RAM and uses the copy
+// from now on. With -pic=ropi, the load offset is added to all handlers. The
+// first entry is the initial stack pointer, which is not relocated.
+func initRAMVectors() {
+	offset := loadOffset()
+	src := uintptr(unsafe.Pointer(&_svectors)) + offset
+	dst := uintptr(unsafe.Pointer(&_ram_vectors))
+	size := uintptr(unsafe.Pointer(&_evectors)) - uintptr(unsafe.Pointer(&_svectors))
+	for i := uintptr(0); i < size; i += 4 {
+		handler := *(*uintptr)(unsafe.Pointer(src + i))
+		if i != 0 && handler != 0 {
+			handler += offset
+		}
+		*(*uintptr)(unsafe.Pointer(dst + i)) = handler
+	}
+	arm.SCB.VTOR.Set(uint32(dst))
+	arm.Asm("dsb")
+	arm.Asm("isb")
+}
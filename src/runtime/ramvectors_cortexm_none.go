@@ -0,0 +1,8 @@
+// +build cortexm,!vectors.ram
+
+package runtime
+
+// initRAMVectors does nothing without -vectors=ram: the vector table in flash
+// is used. See ramvectors_cortexm.go.
+func initRAMVectors() {
+}
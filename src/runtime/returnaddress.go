@@ -0,0 +1,12 @@
+// +build gc.conservative,alloctrace panicreset
+
+package runtime
+
+import (
+	"unsafe"
+)
+
+// returnAddress returns the return address of the current function (when level
+// is 0) or one of its callers.
+//go:export llvm.returnaddress
+func returnAddress(level uint32) unsafe.Pointer
@@ -0,0 +1,15 @@
+// +build !gc.conservative !alloctrace
+// +build !panicreset
+
+package runtime
+
+// The return address is only needed by allocation tracing and -panic=reset,
+// and not all targets support getting it.
+
+import (
+	"unsafe"
+)
+
+func returnAddress(level uint32) unsafe.Pointer {
+	return nil
+}
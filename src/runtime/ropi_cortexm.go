@@ -0,0 +1,70 @@
+// +build cortexm,ropi
+
+package runtime
+
+// This file implements startup support for read-only position independent code
+// (-pic=ropi). Such a program can run from a different flash address than the
+// one it was linked for, for example from either of two OTA update slots, while
+// RAM stays at the same address.
+//
+// The program must still be linked for one address (usually the first slot).
+// To start it from a different address, a bootloader must add the load offset
+// to the reset vector of the image before jumping to it (the initial stack
+// pointer is in RAM and stays valid). At startup, the runtime then:
+//
+//   - initializes .data from the actual location of the initial values,
+//   - adds the load offset to all pointers to flash in global variables, using
+//     the table created by transform.RelocateROPIGlobals, and
+//   - copies the interrupt vector table to RAM, adds the load offset to every
+//     handler and points the VTOR register to the copy (-pic=ropi implies
+//     -vectors=ram, see ramvectors_cortexm.go).
+//
+// Limitations:
+//
+//   - Only code and read-only data are position independent (ROPI), read-write
+//     data (RWPI) is not. RWPI would put the base address of RAM in r9, which
+//     isn't needed when only the flash address changes.
+//   - Constant globals that contain pointers to functions or other constant
+//     data (such as tables of function pointers) are moved to RAM so they can
+//     be relocated. This increases RAM usage.
+//   - The chip must have a VTOR register, which the Cortex-M0 doesn't have.
+//   - C code is compiled with -fropi, which doesn't allow pointers to
+//     functions or constant data in the initializers of global variables.
+//     Assembly code must not contain such absolute addresses either, except in
+//     the interrupt vector table.
+//   - Pointers to flash that are stored in flash by other means (for example
+//     in a //go:section global) can't be relocated.
+
+import (
+	"unsafe"
+)
+
+//go:extern _ropi_relocs_start
+var _ropi_relocs_start [0]uintptr
+
+//go:extern _ropi_relocs_end
+var _ropi_relocs_end [0]uintptr
+
+// loadOffset returns the difference between the flash address the program runs
+// from and the address it was linked for. Note that the address of external
+// symbols (such as the linker symbols above) is always the address at link
+// time.
+//go:linkname loadOffset tinygo_loadOffset
+func loadOffset() uintptr
+
+// relocate fixes up all pointers to flash in global variables after .data has
+// been initialized.
+func relocate() {
+	offset := loadOffset()
+	if offset == 0 {
+		// Running from the address the program was linked for.
+		return
+	}
+
+	// Relocate pointers to flash in global variables.
+	end := uintptr(unsafe.Pointer(&_ropi_relocs_end)) + offset
+	for reloc := uintptr(unsafe.Pointer(&_ropi_relocs_start)) + offset; reloc != end; reloc += unsafe.Sizeof(uintptr(0)) {
+		ptr := *(**uintptr)(unsafe.Pointer(reloc))
+		*ptr += offset
+	}
+}
@@ -0,0 +1,13 @@
+// +build cortexm,!ropi
+
+package runtime
+
+// loadOffset returns zero, as the program always runs from the address it was
+// linked for. See ropi_cortexm.go.
+func loadOffset() uintptr {
+	return 0
+}
+
+// relocate does nothing without -pic=ropi.
+func relocate() {
+}
@@ -281,9 +281,7 @@ func timerSleep(ticks uint32) {
 	// enable IRQ for CMP0 compare
 	sam.RTC_MODE0.INTENSET.SetBits(sam.RTC_MODE0_INTENSET_CMP0)
 
-	for timerWakeup.Get() == 0 {
-		arm.Asm("wfi")
-	}
+	sleepUntilWakeup(&timerWakeup)
 }
 
 //go:export RTC_IRQHandler
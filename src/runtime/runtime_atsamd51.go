@@ -6,6 +6,7 @@ import (
 	"device/arm"
 	"device/sam"
 	"machine"
+	"runtime/volatile"
 )
 
 type timeUnit int64
@@ -213,10 +214,7 @@ var (
 	timerLastCounter uint64
 )
 
-//go:volatile
-type isrFlag bool
-
-var timerWakeup isrFlag
+var timerWakeup volatile.Register8
 
 const asyncScheduler = false
 
@@ -243,7 +241,7 @@ func ticks() timeUnit {
 
 // ticks are in microseconds
 func timerSleep(ticks uint32) {
-	timerWakeup = false
+	timerWakeup.Set(0)
 	if ticks < 260 {
 		// due to delay waiting for the register value to sync, the minimum sleep value
 		// for the SAMD51 is 260us.
@@ -263,9 +261,7 @@ func timerSleep(ticks uint32) {
 	// enable IRQ for CMP0 compare
 	sam.RTC_MODE0.INTENSET.SetBits(sam.RTC_MODE0_INTENSET_CMP0)
 
-	for !timerWakeup {
-		arm.Asm("wfi")
-	}
+	sleepUntilWakeup(&timerWakeup)
 }
 
 //go:export RTC_IRQHandler
@@ -273,7 +269,7 @@ func handleRTC() {
 	// disable IRQ for CMP0 compare
 	sam.RTC_MODE0.INTFLAG.SetBits(sam.RTC_MODE0_INTENSET_CMP0)
 
-	timerWakeup = true
+	timerWakeup.Set(1)
 }
 
 func initUSBClock() {
@@ -4,6 +4,7 @@ package runtime
 
 import (
 	"device/arm"
+	"runtime/volatile"
 	"unsafe"
 )
 
@@ -23,6 +24,10 @@ var _sidata unsafe.Pointer
 var _edata unsafe.Pointer
 
 func preinit() {
+	// Configure how the FPU context is saved on interrupts, before any
+	// floating point instruction is executed.
+	initFPUStacking()
+
 	// Initialize .bss: zero-initialized global variables.
 	ptr := unsafe.Pointer(&_sbss)
 	for ptr != unsafe.Pointer(&_ebss) {
@@ -30,14 +35,22 @@ func preinit() {
 		ptr = unsafe.Pointer(uintptr(ptr) + 4)
 	}
 
-	// Initialize .data: global variables initialized from flash.
-	src := unsafe.Pointer(&_sidata)
+	// Initialize .data: global variables initialized from flash. The initial
+	// values may be at a different address than the one at link time with
+	// -pic=ropi.
+	src := unsafe.Pointer(uintptr(unsafe.Pointer(&_sidata)) + loadOffset())
 	dst := unsafe.Pointer(&_sdata)
 	for dst != unsafe.Pointer(&_edata) {
 		*(*uint32)(dst) = *(*uint32)(src)
 		dst = unsafe.Pointer(uintptr(dst) + 4)
 		src = unsafe.Pointer(uintptr(src) + 4)
 	}
+
+	// Relocate pointers to flash, with -pic=ropi.
+	relocate()
+
+	// Copy the interrupt vector table to RAM, with -vectors=ram.
+	initRAMVectors()
 }
 
 // calleeSavedRegs is the list of registers that must be saved and restored when
@@ -63,6 +76,32 @@ func (r *calleeSavedRegs) prepareStartTask(fn, args uintptr) {
 	r.r5 = args
 }
 
+// sleepUntilWakeup puts the CPU in a low-power sleep mode (using wfi) until the
+// given flag is set by an interrupt handler. This is used by sleepTicks, so that
+// the CPU sleeps instead of busy-waiting when all goroutines are blocked, which
+// can reduce power consumption by an order of magnitude or more depending on
+// the chip. The CPU is only woken up by interrupts, so the flag must be set from
+// an interrupt handler (usually a timer interrupt).
+//
+// Interrupts are disabled while checking the flag. Otherwise, an interrupt that
+// sets the flag right after it has been checked but before the wfi instruction
+// would make the CPU sleep until the next, unrelated, interrupt. A pending
+// interrupt still wakes up the CPU from wfi while interrupts are disabled with
+// PRIMASK, and it is handled as soon as interrupts are enabled again. Note that
+// FAULTMASK must not be set (as arm.DisableInterrupts does), as wfi doesn't
+// return on pending interrupts in that case.
+func sleepUntilWakeup(flag *volatile.Register8) {
+	for {
+		arm.Asm("cpsid i")
+		if flag.Get() != 0 {
+			arm.Asm("cpsie i")
+			return
+		}
+		arm.Asm("wfi")
+		arm.Asm("cpsie i")
+	}
+}
+
 func abort() {
 	// disable all interrupts
 	arm.DisableInterrupts()
@@ -109,13 +148,20 @@ func handleHardFault(sp *interruptStack) {
 		print("HardFault")
 	}
 	print(" with sp=", sp)
+	pc := uintptr(0)
 	if uintptr(unsafe.Pointer(&sp.PC)) >= 0x20000000 {
 		// Only print the PC if it points into memory.
 		// It may not point into memory during a stack overflow, so check that
 		// first before accessing the stack.
-		print(" pc=", sp.PC)
+		pc = sp.PC
+		print(" pc=", pc)
 	}
 	println()
+	if panicReset {
+		// Reset instead of locking up, see -panic=reset.
+		recordPanic(PanicReasonHardFault, pc)
+		arm.SystemReset()
+	}
 	abort()
 }
 
@@ -92,9 +92,7 @@ func rtc_sleep(ticks uint32) {
 		ticks = 2
 	}
 	nrf.RTC1.CC[0].Set((nrf.RTC1.COUNTER.Get() + ticks) & 0x00ffffff)
-	for rtc_wakeup.Get() == 0 {
-		arm.Asm("wfi")
-	}
+	sleepUntilWakeup(&rtc_wakeup)
 }
 
 //go:export RTC1_IRQHandler
@@ -13,13 +13,24 @@ import (
 
 type timeUnit int64
 
-const tickMicros = 1
+const tickMicros = 1000 // ticks are in microseconds
 
-var timestamp timeUnit
+// QEMU runs the emulated LM3S6965 at 12.5MHz by default. The SysTick timer is
+// configured to interrupt every millisecond to keep track of time.
+const (
+	cpuFrequency  = 12500000
+	systickMicros = 1000
+)
+
+var (
+	timestamp   volatile.Register32 // milliseconds since boot
+	timerWakeup volatile.Register8
+)
 
 //go:export Reset_Handler
 func main() {
 	preinit()
+	initSysTick()
 	initAll()
 	callMain()
 	arm.SemihostingCall(arm.SemihostingReportException, arm.SemihostingApplicationExit)
@@ -28,13 +39,36 @@ func main() {
 
 const asyncScheduler = false
 
+func initSysTick() {
+	arm.SYST.RVR.Set(cpuFrequency/1000000*systickMicros - 1)
+	arm.SYST.CVR.Set(0)
+	arm.SYST.CSR.Set(arm.SYST_CSR_ENABLE_Msk | arm.SYST_CSR_TICKINT_Msk | arm.SYST_CSR_CLKSOURCE_Msk)
+}
+
+// sleepTicks sleeps for at least the given number of ticks (microseconds),
+// rounded up to the next SysTick interrupt.
 func sleepTicks(d timeUnit) {
-	// TODO: actually sleep here for the given time.
-	timestamp += d
+	end := ticks() + d
+	for ticks() < end {
+		timerWakeup.Set(0)
+		if ticks() >= end {
+			break
+		}
+		sleepUntilWakeup(&timerWakeup)
+	}
 }
 
 func ticks() timeUnit {
-	return timestamp
+	return timeUnit(timestamp.Get()) * systickMicros
+}
+
+//go:export SysTick_Handler
+func handleSysTick() {
+	timestamp.Set(timestamp.Get() + 1)
+	timerWakeup.Set(1)
+	if preemptLoops && timestamp.Get()%(preemptMicros/systickMicros) == 0 {
+		requestPreempt()
+	}
 }
 
 // UART0 output register.
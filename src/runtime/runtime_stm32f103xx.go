@@ -181,9 +181,7 @@ func timerSleep(ticks uint32) {
 	stm32.TIM3.CR1.SetBits(stm32.TIM_CR1_CEN)
 
 	// wait till timer wakes up
-	for timerWakeup.Get() == 0 {
-		arm.Asm("wfi")
-	}
+	sleepUntilWakeup(&timerWakeup)
 }
 
 //go:export TIM3_IRQHandler
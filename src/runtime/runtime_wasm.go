@@ -76,3 +76,15 @@ func memset(ptr unsafe.Pointer, c byte, size uintptr) unsafe.Pointer {
 	}
 	return ptr
 }
+
+//go:export memmove
+func libc_memmove(dst, src unsafe.Pointer, size uintptr) unsafe.Pointer {
+	memmove(dst, src, size)
+	return dst
+}
+
+//go:export memcpy
+func libc_memcpy(dst, src unsafe.Pointer, size uintptr) unsafe.Pointer {
+	memcpy(dst, src, size)
+	return dst
+}
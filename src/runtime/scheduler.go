@@ -4,7 +4,8 @@ package runtime
 // cooperative round robin scheduler, with a runqueue that contains a linked
 // list of goroutines (tasks) that should be run next, in order of when they
 // were added to the queue (first-in, first-out). It also contains a sleep queue
-// with sleeping goroutines in order of when they should be re-activated.
+// with sleeping goroutines in order of when they should be re-activated, and
+// runs the timers of the time package (see timer.go).
 //
 // The scheduler is used both for the coroutine based scheduler and for the task
 // based scheduler (see compiler/goroutine-lowering.go for a description). In
@@ -18,6 +19,10 @@ import "unsafe"
 
 const schedulerDebug = false
 
+// preemptMicros is the interval at which a running goroutine is asked to yield
+// at the next loop back-edge, when compiled with -preempt-loops.
+const preemptMicros = 10000
+
 // State of a task. Internally represented as:
 //
 //     {i8* next, i8* ptr, i32/i64 data}
@@ -225,6 +230,8 @@ func addSleepTask(t *task, duration int64) {
 
 // Run the scheduler until all tasks have finished.
 func scheduler() {
+	startPreemptTimer()
+
 	// Main scheduler loop.
 	for {
 		scheduleLog("")
@@ -243,9 +250,15 @@ func scheduler() {
 			runqueuePushBack(t)
 		}
 
+		// Run the callbacks of expired timers, which may make goroutines
+		// runnable (for example by sending on the channel of a time.Ticker).
+		if timerQueue != nil {
+			runTimers(int64(now) * tickMicros)
+		}
+
 		t := runqueuePopFront()
 		if t == nil {
-			if sleepQueue == nil {
+			if sleepQueue == nil && timerQueue == nil {
 				// No more tasks to execute.
 				// It would be nice if we could detect deadlocks here, because
 				// there might still be functions waiting on each other in a
@@ -253,13 +266,26 @@ func scheduler() {
 				scheduleLog("  no tasks left!")
 				return
 			}
-			timeLeft := timeUnit(sleepQueue.state().data) - (now - sleepQueueBaseTime)
+			var timeLeft timeUnit
+			if sleepQueue != nil {
+				timeLeft = timeUnit(sleepQueue.state().data) - (now - sleepQueueBaseTime)
+			}
+			if timerQueue != nil {
+				timerLeft := timerTicksLeft(int64(now) * tickMicros)
+				if sleepQueue == nil || timerLeft < timeLeft {
+					timeLeft = timerLeft
+				}
+			}
 			if schedulerDebug {
 				println("  sleeping...", sleepQueue, uint(timeLeft))
 				for t := sleepQueue; t != nil; t = t.state().next {
 					println("    task sleeping:", t, timeUnit(t.state().data))
 				}
 			}
+			// Nothing to do until the next goroutine wakes up or the next
+			// timer expires. On microcontrollers, sleepTicks puts the CPU in
+			// a low-power sleep mode until a timer interrupt fires (see
+			// sleepUntilWakeup) instead of busy-waiting.
 			sleepTicks(timeLeft)
 			if asyncScheduler {
 				// The sleepTicks function above only sets a timeout at which
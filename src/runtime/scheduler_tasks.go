@@ -4,7 +4,27 @@ package runtime
 
 import "unsafe"
 
-const stackSize = 1024
+// defaultStackSize is the size of each goroutine stack, including the task
+// struct at the bottom of the stack, unless it is set with the
+// -goroutine-stack-size flag.
+const defaultStackSize = 1024
+
+// goroutineStackSize is the size of each goroutine stack as set by the
+// compiler with the -goroutine-stack-size flag, or zero for the default. It is
+// the same for all goroutines and it can't grow: a goroutine that needs more
+// stack space results in a "goroutine stack overflow" panic when it switches
+// to another goroutine (if the canary at the bottom of the stack was
+// overwritten), or silently corrupts memory below the stack.
+var goroutineStackSize uintptr
+
+// stackSize returns the size of each goroutine stack.
+//go:inline
+func stackSize() uintptr {
+	if goroutineStackSize == 0 {
+		return defaultStackSize
+	}
+	return goroutineStackSize
+}
 
 // Stack canary, to detect a stack overflow. The number is a random number
 // generated by random.org. The bit fiddling dance is necessary because
@@ -74,14 +94,57 @@ func swapTaskLower(oldTask, newTask *task)
 //go:extern tinygo_startTask
 var startTask [0]uint8
 
+// goroutinePoolSize is the number of goroutine stacks that are preallocated at
+// startup. It is set by the compiler with the -goroutine-pool flag. When it is
+// zero (the default), every goroutine stack is allocated on the heap.
+//
+// The pool is allocated as a single heap object of goroutinePoolSize*stackSize()
+// bytes when the main goroutine is started, so the main goroutine takes one of
+// the stacks. Stacks are returned to the pool when a goroutine exits. Starting
+// a goroutine when all stacks are in use results in a runtime panic.
+var goroutinePoolSize uintptr
+
+var (
+	goroutinePoolStacks unsafe.Pointer   // all preallocated stacks
+	goroutinePool       []unsafe.Pointer // stacks that are not in use
+)
+
+// initGoroutinePool preallocates all goroutine stacks.
+func initGoroutinePool() {
+	goroutinePoolStacks = alloc(goroutinePoolSize * stackSize())
+	goroutinePool = make([]unsafe.Pointer, goroutinePoolSize)
+	for i := range goroutinePool {
+		goroutinePool[i] = unsafe.Pointer(uintptr(goroutinePoolStacks) + uintptr(i)*stackSize())
+	}
+}
+
+// allocStack returns a new goroutine stack of stackSize() bytes, from the
+// goroutine pool if there is one.
+func allocStack() unsafe.Pointer {
+	if goroutinePoolSize == 0 {
+		return alloc(stackSize())
+	}
+	if goroutinePoolStacks == nil {
+		initGoroutinePool()
+	}
+	if len(goroutinePool) == 0 {
+		runtimePanic("goroutine pool exhausted")
+	}
+	stack := goroutinePool[len(goroutinePool)-1]
+	goroutinePool = goroutinePool[:len(goroutinePool)-1]
+	return stack
+}
+
 // startGoroutine starts a new goroutine with the given function pointer and
 // argument. It creates a new goroutine stack, prepares it for execution, and
 // adds it to the runqueue.
 func startGoroutine(fn, args uintptr) {
-	stack := alloc(stackSize)
+	stack := allocStack()
 	t := (*task)(stack)
-	t.sp = uintptr(stack) + stackSize
-	t.pc = uintptr(unsafe.Pointer(&startTask))
+	t.sp = uintptr(stack) + stackSize()
+	// startTask is an external symbol, so its address is the one at link time
+	// which must be relocated with -pic=ropi.
+	t.pc = uintptr(unsafe.Pointer(&startTask)) + loadOffset()
 	t.prepareStartTask(fn, args)
 	t.canary = stackCanary
 	scheduleLogTask("  start goroutine:", t)
@@ -95,6 +158,19 @@ func yield() {
 	swapTask(currentTask, &schedulerState)
 }
 
+// exitTask is called when a goroutine returns. It returns the goroutine stack
+// to the goroutine pool (if there is one) and switches to the scheduler, never
+// to resume this goroutine.
+//export runtime.exitTask
+func exitTask() {
+	if goroutinePoolSize != 0 {
+		// The stack is still in use until the task switch, but no other
+		// goroutine can take it from the pool before that happens.
+		goroutinePool = append(goroutinePool, unsafe.Pointer(currentTask))
+	}
+	yield()
+}
+
 // getSystemStackPointer returns the current stack pointer of the system stack.
 // This is not necessarily the same as the current stack pointer.
 func getSystemStackPointer() uintptr {
@@ -0,0 +1,96 @@
+package runtime
+
+// This file implements the runtime side of the timers in the time package:
+// time.Timer, time.Ticker and everything built on top of them (time.After,
+// time.AfterFunc, time.Tick). Timers are kept in a queue sorted by the time at
+// which they expire. The scheduler runs the callback of expired timers and
+// sleeps until the next timer expires when there is nothing else to do.
+//
+// The callbacks are run directly from the scheduler, not from a goroutine. The
+// callbacks set by the time package never block: they either do a non-blocking
+// send on the channel of the timer, or start a new goroutine (AfterFunc).
+
+// timer is the runtime representation of a timer. It must have the same layout
+// as runtimeTimer in the time package, as the time package allocates it and
+// passes it by pointer to startTimer and stopTimer. This layout is used by Go
+// 1.10 up to Go 1.13.
+type timer struct {
+	tb uintptr // unused
+	i  int     // unused
+
+	when   int64 // nanotime at which the timer expires
+	period int64 // if non-zero, the timer fires again after period nanoseconds
+	f      func(interface{}, uintptr)
+	arg    interface{}
+	seq    uintptr
+}
+
+// timerNode is a single entry in the timer queue.
+type timerNode struct {
+	next  *timerNode
+	timer *timer
+}
+
+// Timers that have been started, sorted by expiry time (earliest first).
+var timerQueue *timerNode
+
+// Start the given timer, which must not already be running.
+//go:linkname startTimer time.startTimer
+func startTimer(tim *timer) {
+	addTimer(&timerNode{timer: tim})
+}
+
+// Stop the given timer. It returns whether the timer was stopped, or false if
+// it already expired or was already stopped.
+//go:linkname stopTimer time.stopTimer
+func stopTimer(tim *timer) bool {
+	for q := &timerQueue; *q != nil; q = &(*q).next {
+		if (*q).timer == tim {
+			*q = (*q).next
+			return true
+		}
+	}
+	return false
+}
+
+// addTimer inserts the timer node in the timer queue, after all timers that
+// expire at the same time or earlier.
+func addTimer(tn *timerNode) {
+	q := &timerQueue
+	for ; *q != nil; q = &(*q).next {
+		if tn.timer.when < (*q).timer.when {
+			break
+		}
+	}
+	tn.next = *q
+	*q = tn
+}
+
+// runTimers runs the callback of all timers that expired at the given time (in
+// nanoseconds, as returned by nanotime). Periodic timers are put back in the
+// queue.
+func runTimers(now int64) {
+	for timerQueue != nil && timerQueue.timer.when <= now {
+		tn := timerQueue
+		timerQueue = tn.next
+		tn.next = nil
+		tim := tn.timer
+		if tim.period > 0 {
+			// Ticks that were missed, for example because a goroutine didn't
+			// yield for a long time, are dropped instead of delivered late:
+			// the timer fires next at the first period after now. This
+			// matches the behavior of the Go runtime. A Ticker has a channel
+			// with a capacity of one, so ticks are also dropped when the
+			// previous tick hasn't been received yet.
+			tim.when += tim.period * (1 + (now-tim.when)/tim.period)
+			addTimer(tn)
+		}
+		tim.f(tim.arg, tim.seq)
+	}
+}
+
+// timerTicksLeft returns the number of ticks until the first timer in the
+// timer queue expires, rounded up. The timer queue must not be empty.
+func timerTicksLeft(now int64) timeUnit {
+	return timeUnit((timerQueue.timer.when - now + tickMicros - 1) / tickMicros)
+}
@@ -0,0 +1,103 @@
+package sync
+
+// Map is a simple implementation of sync.Map, which is safe for use by multiple
+// goroutines under the cooperative scheduler. None of the methods below block
+// or otherwise yield to the scheduler, so every operation is atomic with
+// respect to other goroutines: the "concurrent" guarantees of the standard
+// library reduce to this cooperative safety. There are no interrupt-safety
+// guarantees.
+//
+// The key/value pairs are stored in a slice, as the compiler does not (yet)
+// support interface keys in regular maps. Lookups are therefore linear in the
+// number of keys, which is fine for the small caches this is usually used for.
+// Keys are compared with ==, so they must be comparable.
+//
+// The zero Map is empty and ready for use.
+type Map struct {
+	entries []mapEntry
+}
+
+type mapEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// find returns the index of the given key, or -1 if it is not in the map.
+func (m *Map) find(key interface{}) int {
+	for i := range m.entries {
+		if m.entries[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present. The ok result indicates whether value was found in the map.
+func (m *Map) Load(key interface{}) (value interface{}, ok bool) {
+	i := m.find(key)
+	if i < 0 {
+		return nil, false
+	}
+	return m.entries[i].value, true
+}
+
+// Store sets the value for a key.
+func (m *Map) Store(key, value interface{}) {
+	i := m.find(key)
+	if i < 0 {
+		m.entries = append(m.entries, mapEntry{key, value})
+		return
+	}
+	m.entries[i].value = value
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores and returns the given value. The loaded result is true if the value
+// was loaded, false if stored.
+func (m *Map) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	i := m.find(key)
+	if i >= 0 {
+		return m.entries[i].value, true
+	}
+	m.entries = append(m.entries, mapEntry{key, value})
+	return value, false
+}
+
+// Delete deletes the value for a key.
+func (m *Map) Delete(key interface{}) {
+	i := m.find(key)
+	if i < 0 {
+		return
+	}
+	copy(m.entries[i:], m.entries[i+1:])
+	m.entries[len(m.entries)-1] = mapEntry{} // allow the key and value to be GC'd
+	m.entries = m.entries[:len(m.entries)-1]
+}
+
+// Range calls f sequentially for each key and value present in the map. If f
+// returns false, Range stops the iteration.
+//
+// Like the standard library, Range does not iterate over a consistent snapshot
+// when the map is modified during the iteration (by f or by another goroutine
+// while f is blocked): no key is visited more than once, a key that is deleted
+// before it is visited is not visited at all, a key that is stored before it is
+// visited is visited with its new value, and keys that are added during the
+// iteration are not visited.
+func (m *Map) Range(f func(key, value interface{}) bool) {
+	// Iterate over a copy of the keys, as Delete may move entries around.
+	keys := make([]interface{}, len(m.entries))
+	for i := range m.entries {
+		keys[i] = m.entries[i].key
+	}
+	for _, key := range keys {
+		value, ok := m.Load(key)
+		if !ok {
+			// Deleted during the iteration.
+			continue
+		}
+		if !f(key, value) {
+			break
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// F is a type passed to fuzz targets.
+//
+// Only the seed corpus is supported: the inputs added with F.Add and the inputs
+// stored in testdata/fuzz/FuzzXxx (in the same format as used by the go
+// command) are replayed against the fuzz function, like `go test` does when it
+// is run without -fuzz. Active fuzzing with generated inputs is not supported.
+// This means fuzz targets can be used as regression tests, also on
+// microcontrollers or in an emulator, as the corpus is embedded in the test
+// binary.
+type F struct {
+	common
+	seeds  []CorpusEntry // added with F.Add
+	corpus []CorpusEntry // read from testdata/fuzz
+}
+
+var _ TB = (*F)(nil)
+
+// CorpusEntry is a single input in the seed corpus of a fuzz target.
+type CorpusEntry struct {
+	// Name of the input: the file name for inputs stored in testdata/fuzz.
+	Name string
+	// Values to pass to the fuzz function.
+	Values []interface{}
+}
+
+// FuzzTargetToCall is a reference to a fuzz target that should be called during
+// a test suite run, together with its seed corpus from testdata/fuzz.
+type FuzzTargetToCall struct {
+	// Name of the fuzz target to call.
+	Name string
+	// Function reference to the fuzz target.
+	Func func(*F)
+	// Inputs read from testdata/fuzz at compile time.
+	Corpus []CorpusEntry
+}
+
+// Add adds the arguments to the seed corpus of the fuzz target. The arguments
+// must match the parameters of the fuzz function, after the *T parameter.
+func (f *F) Add(args ...interface{}) {
+	f.seeds = append(f.seeds, CorpusEntry{
+		Name:   fmt.Sprintf("seed#%d", len(f.seeds)),
+		Values: args,
+	})
+}
+
+// Fuzz calls the fuzz function ff with every input in the seed corpus. The fuzz
+// function must have the signature func(*testing.T, []byte) or
+// func(*testing.T, string): other parameter types are not supported.
+func (f *F) Fuzz(ff interface{}) {
+	switch ff.(type) {
+	case func(*T, []byte), func(*T, string):
+	default:
+		f.Error("testing: unsupported fuzz function: only func(*testing.T, []byte) and func(*testing.T, string) are supported")
+		return
+	}
+
+	entries := append(f.seeds[:len(f.seeds):len(f.seeds)], f.corpus...)
+	for _, entry := range entries {
+		t := &T{
+			common: common{
+				name:   f.name + "/" + entry.Name,
+				output: &bytes.Buffer{},
+			},
+		}
+		if !callFuzzFunc(ff, t, entry.Values) {
+			t.Error("testing: corpus entry does not match the parameters of the fuzz function")
+		}
+		if t.failed {
+			f.Fail()
+			fmt.Fprintf(f.output, "    --- FAIL: %s\n", t.name)
+			fmt.Fprint(f.output, t.output)
+		}
+	}
+}
+
+// callFuzzFunc calls the fuzz function with the given values, and returns false
+// if the values don't match the parameters of the function.
+func callFuzzFunc(ff interface{}, t *T, values []interface{}) bool {
+	if len(values) != 1 {
+		return false
+	}
+	switch ff := ff.(type) {
+	case func(*T, []byte):
+		value, ok := values[0].([]byte)
+		if !ok {
+			return false
+		}
+		ff(t, value)
+	case func(*T, string):
+		value, ok := values[0].(string)
+		if !ok {
+			return false
+		}
+		ff(t, value)
+	default:
+		return false
+	}
+	return true
+}
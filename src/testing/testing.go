@@ -166,6 +166,8 @@ type TestToCall struct {
 type M struct {
 	// tests is a list of the test names to execute
 	Tests []TestToCall
+	// Fuzz is a list of fuzz targets, with their seed corpus
+	Fuzz []FuzzTargetToCall
 }
 
 // Run the test suite.
@@ -194,6 +196,30 @@ func (m *M) Run() int {
 		}
 	}
 
+	for _, target := range m.Fuzz {
+		f := &F{
+			common: common{
+				name:   target.Name,
+				output: &bytes.Buffer{},
+			},
+			corpus: target.Corpus,
+		}
+
+		fmt.Printf("=== RUN   %s\n", target.Name)
+		target.Func(f)
+
+		if f.failed {
+			fmt.Printf("--- FAIL: %s\n", target.Name)
+		} else {
+			fmt.Printf("--- PASS: %s\n", target.Name)
+		}
+		fmt.Println(f.output)
+
+		if f.failed {
+			failures++
+		}
+	}
+
 	if failures > 0 {
 		fmt.Printf("exit status %d\n", failures)
 		fmt.Println("FAIL")
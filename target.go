@@ -48,6 +48,9 @@ type TargetSpec struct {
 	OpenOCDInterface string   `json:"openocd-interface"`
 	OpenOCDTarget    string   `json:"openocd-target"`
 	OpenOCDTransport string   `json:"openocd-transport"`
+	StackRegion      string   `json:"stack-region"` // memory region for the stack, see targets/arm.ld
+	FPUStacking      string   `json:"fpu-stacking"` // FPU context stacking on interrupts: lazy, eager, none
+	NoVTOR           bool     `json:"no-vtor"`      // Cortex-M chip without a VTOR register, so the vector table can't be moved
 }
 
 // copyProperties copies all properties that are set in spec2 into itself.
@@ -120,6 +123,63 @@ func (spec *TargetSpec) copyProperties(spec2 *TargetSpec) {
 	if spec2.OpenOCDTransport != "" {
 		spec.OpenOCDTransport = spec2.OpenOCDTransport
 	}
+	if spec2.StackRegion != "" {
+		spec.StackRegion = spec2.StackRegion
+	}
+	if spec2.FPUStacking != "" {
+		spec.FPUStacking = spec2.FPUStacking
+	}
+	if spec2.NoVTOR {
+		spec.NoVTOR = true
+	}
+}
+
+// fpuStackingTag returns the build tag that selects how the FPU context is
+// stacked on exception entry on Cortex-M chips with an FPU (see
+// src/runtime/fpu_cortexm.go), or an empty string for the hardware default.
+//
+//   - lazy (default): space for the FPU registers is reserved on the stack, but
+//     they are only saved when the interrupt handler uses the FPU. This keeps
+//     interrupt latency low for handlers that don't use floating point, but
+//     the latency of handlers that do varies.
+//   - eager: the FPU registers are always saved when the interrupted code used
+//     the FPU. This makes interrupt latency deterministic, at the cost of 18
+//     extra words pushed on the stack on every interrupt.
+//   - none: FPU registers are never saved, which gives the lowest latency and
+//     stack usage. Interrupt handlers must not use floating point at all, as
+//     that would corrupt the FPU state of the interrupted code.
+//
+// The eager and none modes need an FPU, as they change the FPCCR register which
+// doesn't exist on other chips.
+func (spec *TargetSpec) fpuStackingTag() (string, error) {
+	switch spec.FPUStacking {
+	case "", "lazy":
+		return "", nil
+	case "eager", "none":
+		if !spec.hasHardwareFloat() {
+			return "", errors.New("fpu-stacking: " + spec.FPUStacking + " requires a target with hardware floating point (an FPU feature like +vfp4, or -mfloat-abi=hard or softfp)")
+		}
+		return "fpustacking." + spec.FPUStacking, nil
+	default:
+		return "", errors.New("unknown fpu-stacking option: " + spec.FPUStacking + " (valid: lazy, eager, none)")
+	}
+}
+
+// hasHardwareFloat returns whether code for this target uses the FPU, based on
+// the LLVM features and the float ABI passed to Clang. The last -mfloat-abi
+// flag wins, like in Clang, and -mfloat-abi=soft disables the FPU.
+func (spec *TargetSpec) hasHardwareFloat() bool {
+	for i := len(spec.CFlags) - 1; i >= 0; i-- {
+		if strings.HasPrefix(spec.CFlags[i], "-mfloat-abi=") {
+			return spec.CFlags[i] != "-mfloat-abi=soft"
+		}
+	}
+	for _, feature := range spec.Features {
+		if strings.HasPrefix(feature, "+vfp") || strings.HasPrefix(feature, "+fp-armv8") {
+			return true
+		}
+	}
+	return false
 }
 
 // load reads a target specification from the JSON in the given io.Reader. It
@@ -1,6 +1,13 @@
 package main
 
-import "testing"
+import (
+	"debug/elf"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestLoadTarget(t *testing.T) {
 	_, err := LoadTarget("arduino")
@@ -17,3 +24,115 @@ func TestLoadTarget(t *testing.T) {
 		t.Error("LoadTarget failed for wrong reason:", err)
 	}
 }
+
+func TestStackRegion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires a full cross compiling toolchain")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "tinygo-test")
+	if err != nil {
+		t.Fatal("could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// Create a custom target that puts the stack in CCMRAM.
+	target := filepath.Join(tmpdir, "stm32f4disco-ccm.json")
+	err = ioutil.WriteFile(target, []byte(`{"inherits": ["stm32f4disco"], "stack-region": "CCMRAM"}`), 0666)
+	if err != nil {
+		t.Fatal("could not write target file:", err)
+	}
+	executable := filepath.Join(tmpdir, "test.elf")
+	config := &BuildConfig{
+		opt:     "z",
+		wasmAbi: "js",
+	}
+	err = Build("./testdata/alias.go", executable, target, config)
+	if err != nil {
+		t.Fatal("failed to build:", err)
+	}
+
+	// The first word of the vector table is the initial stack pointer, which
+	// must point into CCMRAM (0x10000000-0x1000ffff).
+	f, err := elf.Open(executable)
+	if err != nil {
+		t.Fatal("could not open ELF file:", err)
+	}
+	defer f.Close()
+	text := f.Section(".text")
+	if text == nil {
+		t.Fatal("no .text section found")
+	}
+	data, err := text.Data()
+	if err != nil {
+		t.Fatal("could not read .text section:", err)
+	}
+	sp := binary.LittleEndian.Uint32(data)
+	if sp <= 0x10000000 || sp > 0x10010000 {
+		t.Errorf("initial stack pointer 0x%08x does not point into CCMRAM", sp)
+	}
+}
+
+func TestFPUStacking(t *testing.T) {
+	// The build tag selects the FPCCR configuration in the runtime, see
+	// src/runtime/fpu_cortexm_*.go. The value that is written to FPCCR is
+	// tested in TestFPUStackingFPCCR.
+	for _, tc := range []struct {
+		stacking string
+		tag      string
+	}{
+		{"", ""},
+		{"lazy", ""},
+		{"eager", "fpustacking.eager"},
+		{"none", "fpustacking.none"},
+	} {
+		spec := &TargetSpec{FPUStacking: tc.stacking, Features: []string{"+vfp4"}}
+		tag, err := spec.fpuStackingTag()
+		if err != nil {
+			t.Errorf("fpu-stacking %#v: unexpected error: %v", tc.stacking, err)
+			continue
+		}
+		if tag != tc.tag {
+			t.Errorf("fpu-stacking %#v: expected tag %#v, got %#v", tc.stacking, tc.tag, tag)
+		}
+	}
+
+	spec := &TargetSpec{FPUStacking: "always", Features: []string{"+vfp4"}}
+	if _, err := spec.fpuStackingTag(); err == nil {
+		t.Error("expected an error for an invalid fpu-stacking option")
+	}
+
+	// Chips without an FPU don't have the FPCCR register, so only lazy stacking
+	// (which doesn't change it) is allowed.
+	for _, tc := range []struct {
+		features []string
+		cflags   []string
+		fpu      bool
+	}{
+		{nil, nil, false},
+		{[]string{"+vfp4"}, nil, true},
+		{[]string{"+fp-armv8"}, nil, true},
+		{nil, []string{"-mfloat-abi=hard"}, true},
+		{nil, []string{"-mfloat-abi=softfp"}, true},
+		{[]string{"+vfp4"}, []string{"-mfloat-abi=soft"}, false},
+		{nil, []string{"-mfloat-abi=soft", "-mfloat-abi=hard"}, true},
+	} {
+		for _, stacking := range []string{"lazy", "eager", "none"} {
+			spec := &TargetSpec{FPUStacking: stacking, Features: tc.features, CFlags: tc.cflags}
+			_, err := spec.fpuStackingTag()
+			if (err == nil) != (tc.fpu || stacking == "lazy") {
+				t.Errorf("fpu-stacking %#v with features %v and cflags %v: unexpected error: %v", stacking, tc.features, tc.cflags, err)
+			}
+		}
+	}
+	for _, target := range []string{"qemu", "atsamd21g18a", "nrf51"} {
+		spec, err := LoadTarget(target)
+		if err != nil {
+			t.Fatal("could not load target:", err)
+		}
+		spec.FPUStacking = "eager"
+		if _, err := spec.fpuStackingTag(); err == nil {
+			t.Errorf("%s: expected an error, as the target doesn't use the FPU", target)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import "runtime"
+
+var garbage [][]byte
+
+func main() {
+	arena := runtime.NewArena(256)
+
+	// Allocations are aligned and zeroed.
+	p1 := runtime.AllocFrom(arena, 3)
+	p2 := runtime.AllocFrom(arena, 8)
+	println("aligned:", uintptr(p2)%8 == 0)
+	println("used:", arena.Used() >= 11 && arena.Used() <= 24)
+
+	// Fill some memory with a pattern.
+	buf := (*[64]byte)(runtime.AllocFrom(arena, 64))
+	for i := range buf {
+		buf[i] = byte(i * 3)
+	}
+
+	// The arena is not freed by the GC, and its contents are left alone.
+	for i := 0; i < 100; i++ {
+		garbage = append(garbage, make([]byte, 100))
+		if len(garbage) > 10 {
+			garbage = garbage[1:]
+		}
+	}
+	runtime.GC()
+	intact := true
+	for i := range buf {
+		if buf[i] != byte(i*3) {
+			intact = false
+		}
+	}
+	println("intact after GC:", intact)
+
+	// AllocFrom returns nil when the arena is full.
+	n := 0
+	for runtime.AllocFrom(arena, 16) != nil {
+		n++
+	}
+	println("full:", n > 0 && n < 16, arena.Used() <= 256)
+	println("too large:", runtime.AllocFrom(arena, 1000) == nil)
+
+	// After a reset, the memory is reused for new (zeroed) allocations.
+	arena.Reset()
+	println("used after reset:", arena.Used())
+	p3 := runtime.AllocFrom(arena, 64)
+	println("reused:", p3 == p1)
+	zeroed := true
+	for _, b := range (*[64]byte)(p3) {
+		if b != 0 {
+			zeroed = false
+		}
+	}
+	println("zeroed:", zeroed)
+}
@@ -0,0 +1,10 @@
+package main
+
+// parseLength parses a packet that starts with the length of the payload that
+// follows. It returns the length, or -1 if the packet is invalid.
+func parseLength(packet []byte) int {
+	if len(packet) == 0 || int(packet[0]) != len(packet)-1 {
+		return -1
+	}
+	return int(packet[0])
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFiltered(t *testing.T) {
+	t.Error("should not run with -run=Fuzz")
+}
+
+func FuzzParseLength(f *testing.F) {
+	f.Add([]byte{2, 'h', 'i'})
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		n := parseLength(packet)
+		if n < 0 {
+			t.Errorf("rejected packet of %d bytes", len(packet))
+		} else if n >= len(packet) {
+			t.Errorf("length %d out of bounds", n)
+		}
+	})
+}
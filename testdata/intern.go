@@ -0,0 +1,45 @@
+package main
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+func main() {
+	// Build identical strings at runtime, with separate storage.
+	a := build("GET")
+	b := build("GET")
+	println("separate storage:", dataPtr(a) != dataPtr(b))
+
+	// Interning returns a canonical instance with the same backing array.
+	a = runtime.InternString(a)
+	b = runtime.InternString(b)
+	println("interned:", a, b)
+	println("same storage:", dataPtr(a) == dataPtr(b))
+
+	// Different content is not shared.
+	c := runtime.InternString(build("PUT"))
+	println("different content:", c, dataPtr(a) != dataPtr(c))
+
+	// Long strings are not interned.
+	long1 := runtime.InternString(build("this string is too long to be interned"))
+	long2 := runtime.InternString(build("this string is too long to be interned"))
+	println("long strings shared:", dataPtr(long1) == dataPtr(long2))
+
+	// Fill the table with other strings, which evicts the strings above as
+	// they're not used anymore.
+	for i := 0; i < 64; i++ {
+		runtime.InternString(build(string([]byte{'x', byte('0' + i/10), byte('0' + i%10)})))
+	}
+	d := runtime.InternString(build("GET"))
+	println("after eviction:", d, dataPtr(a) == dataPtr(d))
+}
+
+// build returns a copy of s with its own backing array.
+func build(s string) string {
+	return string([]byte(s))
+}
+
+func dataPtr(s string) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&s))
+}
@@ -0,0 +1,19 @@
+package main
+
+// Program with two interrupt handlers, to test -print-interrupts.
+
+var svcCalls, pendSVCalls int
+
+//go:export SVC_Handler
+func handleSVC() {
+	svcCalls++
+}
+
+//go:export PendSV_Handler
+func handlePendSV() {
+	pendSVCalls++
+}
+
+func main() {
+	println(svcCalls, pendSVCalls)
+}
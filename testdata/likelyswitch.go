@@ -0,0 +1,31 @@
+package main
+
+type Shape interface{}
+
+type (
+	Circle   struct{ r int }
+	Square   struct{ side int }
+	Triangle struct{ base, height int }
+	Line     struct{ length int }
+)
+
+//go:noinline
+func area(s Shape) int {
+	switch s := s.(type) {
+	case *Circle:
+		return 3 * s.r * s.r
+	//go:likely *Square
+	case *Square:
+		return s.side * s.side
+	case *Triangle:
+		return s.base * s.height / 2
+	case *Line:
+		return 0
+	default:
+		return -1
+	}
+}
+
+func main() {
+	println("areas:", area(&Circle{2}), area(&Square{3}), area(&Triangle{4, 5}), area(&Line{6}), area(7))
+}
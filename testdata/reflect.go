@@ -254,6 +254,19 @@ func main() {
 	if rv.Len() != 2 || rv.Index(0).Int() != 3 {
 		panic("slice was changed while setting part of it")
 	}
+
+	// Struct tags
+	println("\nstruct tags:")
+	tagged := reflect.TypeOf(struct {
+		Name string `json:"name,omitempty" xml:"n"`
+		Age  int    `json:""`
+	}{})
+	println("json:", tagged.Field(0).Tag.Get("json"))
+	println("xml:", tagged.Field(0).Tag.Get("xml"))
+	_, ok := tagged.Field(0).Tag.Lookup("yaml")
+	println("yaml present:", ok)
+	value, ok := tagged.Field(1).Tag.Lookup("json")
+	println("empty json present:", ok, value == "")
 }
 
 func emptyFunc() {
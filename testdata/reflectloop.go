@@ -0,0 +1,48 @@
+package main
+
+import "reflect"
+
+type point struct {
+	X, Y int
+}
+
+type rect struct {
+	Min, Max point
+	Name     string
+}
+
+var calls int
+
+// count is called in the loop in numFields and changes memory, but not the
+// sidetables.
+//go:noinline
+func count() {
+	calls++
+}
+
+// numFields is exported so that the arguments are not known at compile time.
+// Both types are named, so the optimizer knows that the type needs a lookup in
+// the named types sidetable but not where in the sidetable: the lookup has to
+// stay, but it doesn't need to be done in every iteration of the loop. This is
+// the only call to NumField, so that it is inlined.
+//export numFields
+func numFields(kind, n int) int {
+	var t reflect.Type
+	if kind == 0 {
+		t = reflect.TypeOf(point{})
+	} else {
+		t = reflect.TypeOf(rect{})
+	}
+	sum := 0
+	for i := 0; i < n; i++ {
+		sum += t.NumField()
+		count()
+	}
+	return sum
+}
+
+func main() {
+	points := numFields(0, 3)
+	rects := numFields(1, 4)
+	println("fields:", points, rects, "calls:", calls)
+}
@@ -0,0 +1,58 @@
+package main
+
+import "unsafe"
+
+type withFunc struct {
+	b byte
+	f func()
+	n int32
+}
+
+type withMap struct {
+	m map[string]int
+	c chan int
+	p *int
+}
+
+const wordSize = unsafe.Sizeof(uintptr(0))
+
+// Function values are two words: a context pointer and a function pointer.
+const funcSize = unsafe.Sizeof(withFunc{}.f)
+
+// Array lengths must be constants.
+var funcWords [funcSize / wordSize]uintptr
+
+type sizer interface {
+	size() uintptr
+}
+
+type sized struct {
+	c chan int
+}
+
+func (s sized) size() uintptr {
+	return unsafe.Sizeof(s.c)
+}
+
+func main() {
+	println("func words:", len(funcWords))
+	println("func align:", unsafe.Alignof(withFunc{}.f) == unsafe.Alignof(uintptr(0)))
+	println("map size:", unsafe.Sizeof(withMap{}.m) == wordSize)
+	println("chan size:", unsafe.Sizeof(withMap{}.c) == wordSize)
+	println("map struct size:", unsafe.Sizeof(withMap{}) == 3*wordSize)
+
+	// The constant offsets must match the actual layout in memory.
+	var s withFunc
+	base := uintptr(unsafe.Pointer(&s))
+	println("offset f:", unsafe.Offsetof(s.f) == uintptr(unsafe.Pointer(&s.f))-base)
+	println("offset n:", unsafe.Offsetof(s.n) == uintptr(unsafe.Pointer(&s.n))-base)
+	var m withMap
+	base = uintptr(unsafe.Pointer(&m))
+	println("offset p:", unsafe.Offsetof(m.p) == uintptr(unsafe.Pointer(&m.p))-base)
+	var arr [3]withFunc
+	println("array stride:", unsafe.Sizeof(arr[0]) == uintptr(unsafe.Pointer(&arr[1]))-uintptr(unsafe.Pointer(&arr[0])))
+
+	// Also constant when called through an interface.
+	var i sizer = sized{}
+	println("interface method:", i.size() == wordSize)
+}
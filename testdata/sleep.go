@@ -0,0 +1,29 @@
+package main
+
+import "time"
+
+func main() {
+	// Sleep in the main goroutine, with no other goroutines to run: the
+	// scheduler has to wait until the timer wakes it up.
+	start := time.Now()
+	time.Sleep(20 * time.Millisecond)
+	println("slept long enough:", time.Since(start) >= 20*time.Millisecond)
+
+	// Wait for a goroutine that sleeps, while the main goroutine is blocked
+	// on a channel.
+	done := make(chan int)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		println("goroutine woke up")
+		done <- 1
+	}()
+	<-done
+	println("main woke up")
+
+	// Several short sleeps in a row.
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	println("short sleeps:", time.Since(start) >= 5*time.Millisecond)
+}
@@ -103,6 +103,14 @@ func main() {
 	println("copy foo -> bar:", copy(bar, foo))
 	printslice("bar", bar)
 
+	// copy between overlapping slices
+	overlap := []int{1, 2, 3, 4, 5, 6}
+	println("copy overlap forward:", copy(overlap[2:], overlap))
+	printslice("overlap", overlap)
+	overlap = []int{1, 2, 3, 4, 5, 6}
+	println("copy overlap backward:", copy(overlap, overlap[2:]))
+	printslice("overlap", overlap)
+
 	// append
 	var grow []int
 	println("slice is nil?", grow == nil, nil == grow)
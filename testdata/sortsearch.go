@@ -0,0 +1,14 @@
+package main
+
+import "sort"
+
+var primes = []int{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}
+
+func indexOf(x int) int {
+	return sort.Search(len(primes), func(i int) bool { return primes[i] >= x })
+}
+
+func main() {
+	println("index:", indexOf(1), indexOf(7), indexOf(8), indexOf(30))
+	println("sqrt:", sort.Search(100, func(i int) bool { return i*i >= 50 }))
+}
@@ -0,0 +1,21 @@
+#include <stddef.h>
+void *malloc(size_t size);
+void free(void *ptr);
+
+int test(void) {
+	if (malloc(2048) != NULL) {
+		return 1; // larger than the C heap
+	}
+	void *buf = malloc(512);
+	if (buf == NULL) {
+		return 2;
+	}
+	if (malloc(512) != NULL) {
+		return 3; // doesn't fit in the rest of the C heap
+	}
+	free(buf);
+	if (malloc(512) == NULL) {
+		return 4; // freed memory is reused
+	}
+	return 0;
+}
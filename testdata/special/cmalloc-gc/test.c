@@ -0,0 +1,24 @@
+#include <stddef.h>
+void *malloc(size_t size);
+void *calloc(size_t nmemb, size_t size);
+void *realloc(void *ptr, size_t size);
+void free(void *ptr);
+
+int test(void) {
+	int *buf = malloc(4 * sizeof(int));
+	for (int i = 0; i < 4; i++) {
+		buf[i] = i + 1;
+	}
+	buf = realloc(buf, 64 * sizeof(int)); // keeps the first 4 values
+	int *zero = calloc(16, sizeof(int));
+	int sum = 0;
+	for (int i = 0; i < 4; i++) {
+		sum += buf[i];
+	}
+	for (int i = 0; i < 16; i++) {
+		sum += zero[i];
+	}
+	free(buf);
+	free(zero);
+	return sum;
+}
@@ -0,0 +1,8 @@
+package main
+
+// int test(void);
+import "C"
+
+func main() {
+	println("result:", C.test())
+}
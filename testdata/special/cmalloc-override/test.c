@@ -0,0 +1,13 @@
+#include <stddef.h>
+static char buf[64];
+static int mallocCalls;
+
+void *malloc(size_t size) {
+	mallocCalls++;
+	return buf;
+}
+
+int test(void) {
+	malloc(4);
+	return mallocCalls;
+}
@@ -0,0 +1,22 @@
+package main
+
+import "runtime"
+
+type object struct {
+	value int
+}
+
+var objects [3]*object
+
+//go:noinline
+func newObject(i int) {
+	objects[i] = &object{i}
+}
+
+func main() {
+	before := runtime.Allocations()
+	for i := range objects {
+		newObject(i)
+	}
+	println("allocations:", runtime.Allocations()-before)
+}
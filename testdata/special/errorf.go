@@ -0,0 +1,23 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errNotFound = errors.New("not found")
+
+func main() {
+	err := fmt.Errorf("open config: %w", errNotFound)
+	println(err.Error())
+	println("is:", errors.Is(err, errNotFound), errors.Unwrap(err) == errNotFound)
+	err = fmt.Errorf("load: %w", err)
+	println(err.Error())
+	println("is:", errors.Is(err, errNotFound))
+	err = fmt.Errorf("read %d bytes: %w", 5, errNotFound)
+	println(err.Error())
+	println("is:", errors.Is(err, errNotFound))
+	err = fmt.Errorf("code: %w", 5)
+	println(err.Error())
+	println("unwrap:", errors.Unwrap(err) == nil)
+}
@@ -0,0 +1,20 @@
+package main
+
+var done = make(chan bool)
+
+func worker(n int) {
+	println("worker", n)
+	done <- true
+}
+
+func main() {
+	for i := 0; i < 4; i++ {
+		go worker(i)
+		<-done
+	}
+	go worker(4)
+	go worker(5)
+	println("starting one too many")
+	go worker(6)
+	println("not reached")
+}
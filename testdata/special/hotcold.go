@@ -0,0 +1,21 @@
+package main
+
+//go:export hotFunc
+//go:hot
+func hotFunc() {
+}
+
+//go:export coldFunc
+//go:cold
+func coldFunc() {
+}
+
+//go:export plainFunc
+func plainFunc() {
+}
+
+func main() {
+	hotFunc()
+	coldFunc()
+	plainFunc()
+}
@@ -0,0 +1,27 @@
+package main
+
+import "time"
+
+var events = make(chan int, 1)
+
+//go:export TIM2_IRQHandler
+func handleTIM2() {
+	debounce()
+}
+
+func debounce() {
+	time.Sleep(time.Millisecond)
+}
+
+//go:export TIM3_IRQHandler
+func handleTIM3() {
+	select {
+	case events <- 3:
+	default:
+	}
+}
+
+func main() {
+	handleTIM3()
+	println("event:", <-events)
+}
@@ -0,0 +1,10 @@
+package main
+
+func main() {
+	var v interface{} = 3
+	switch v.(type) {
+	//go:likely string
+	case int:
+		println("int")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import "math"
+
+var trig = []struct{ x, sin, cos float64 }{
+	{0, 0, 1},
+	{0.5, 0.479425538604203, 0.8775825618903728},
+	{-1, -0.8414709848078965, 0.5403023058681398},
+	{2, 0.9092974268256816, -0.4161468365471424},
+	{3.141592653589793, 1.2246467991473515e-16, -1},
+	{-10, 0.5440211108893699, -0.8390715290764524},
+	{100, -0.5063656411097588, 0.8623188722876839},
+	{12345.678, -0.7040813137533816, 0.7101193587160627},
+	{-99999, -0.860248280789742, -0.5098753724179009},
+}
+
+var exp = []struct{ x, exp float64 }{
+	{0, 1},
+	{1, 2.718281828459045},
+	{-1, 0.36787944117144233},
+	{0.1, 1.1051709180756477},
+	{10, 22026.465794806718},
+	{-20, 2.061153622438558e-09},
+	{100, 2.6881171418161356e+43},
+	{700, 1.0142320547350045e+304},
+	{-700, 9.85967654375977e-305},
+}
+
+func main() {
+	errors := 0
+	for _, tc := range trig {
+		if math.Abs(math.Sin(tc.x)-tc.sin) > 1e-8 || math.Abs(math.Cos(tc.x)-tc.cos) > 1e-8 {
+			println("inaccurate sin or cos:", tc.x)
+			errors++
+		}
+	}
+	for _, tc := range exp {
+		if math.Abs(math.Exp(tc.x)-tc.exp) > 1e-8*tc.exp {
+			println("inaccurate exp:", tc.x)
+			errors++
+		}
+	}
+	if !math.IsNaN(math.Sin(math.Inf(1))) || !math.IsNaN(math.Cos(math.NaN())) {
+		println("sin or cos is not NaN")
+		errors++
+	}
+	if !math.IsInf(math.Exp(710), 1) || math.Exp(-750) != 0 || !math.IsNaN(math.Exp(math.NaN())) {
+		println("wrong special case of exp")
+		errors++
+	}
+	println("errors:", errors)
+}
@@ -0,0 +1,14 @@
+package main
+
+import "runtime"
+
+func main() {
+	info, ok := runtime.LastPanic()
+	if !ok {
+		println("first boot")
+		panic("something went wrong")
+	}
+	println("reset after panic:", info.Reason == runtime.PanicReasonPanic, info.Site != 0)
+	_, ok = runtime.LastPanic()
+	println("cleared:", !ok)
+}
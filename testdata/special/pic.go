@@ -0,0 +1,28 @@
+package main
+
+import (
+	"runtime/volatile"
+	"time"
+)
+
+var index volatile.Register32
+
+var funcs = []func() int{one, two}
+
+var messages = []string{"first", "second"}
+
+func one() int { return 1 }
+func two() int { return 2 }
+
+func main() {
+	i := index.Get()
+	println(funcs[i](), funcs[i+1]())
+	println(messages[i], messages[i+1])
+	time.Sleep(time.Millisecond)
+	println("slept")
+	done := make(chan string)
+	go func() {
+		done <- "goroutine"
+	}()
+	println(<-done)
+}
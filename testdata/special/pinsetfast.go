@@ -0,0 +1,13 @@
+package main
+
+import "machine"
+
+//go:export pulse
+func pulse() {
+	machine.LED.SetFast()
+	machine.LED.ClearFast()
+}
+
+func main() {
+	pulse()
+}
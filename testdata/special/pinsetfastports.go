@@ -0,0 +1,16 @@
+package main
+
+import "machine"
+
+// On the pca10056, the LED is on port 0 and the SPI clock pin on port 1.
+//go:export pulse
+func pulse() {
+	machine.LED.SetFast()
+	machine.LED.ClearFast()
+	machine.SPI0_SCK_PIN.SetFast()
+	machine.SPI0_SCK_PIN.ClearFast()
+}
+
+func main() {
+	pulse()
+}
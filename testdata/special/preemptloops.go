@@ -0,0 +1,21 @@
+package main
+
+var started, stop, stopped bool
+
+func spin() {
+	started = true
+	for !stop {
+	}
+	stopped = true
+}
+
+func main() {
+	go spin()
+	for !started {
+	}
+	println("started")
+	stop = true
+	for !stopped {
+	}
+	println("stopped")
+}
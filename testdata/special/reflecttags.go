@@ -0,0 +1,15 @@
+package main
+
+import "reflect"
+
+type config struct {
+	Name string `json:"name"`
+	Port int
+}
+
+func main() {
+	field := reflect.TypeOf(config{}).Field(0)
+	println("name:", field.Name)
+	println("tag:", string(field.Tag))
+	println("json:", field.Tag.Get("json"))
+}
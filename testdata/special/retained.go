@@ -0,0 +1,21 @@
+package main
+
+import "device/arm"
+
+//go:retained
+var magic uint32
+
+//go:retained
+var bootCount uint32
+
+func main() {
+	if magic != 0x424f4f54 {
+		magic = 0x424f4f54
+		bootCount = 0
+	}
+	bootCount++
+	println("boot", bootCount)
+	if bootCount < 3 {
+		arm.SystemReset()
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"device/arm"
+	"unsafe"
+)
+
+//go:extern _svectors
+var _svectors [16]uintptr
+
+func main() {
+	vtor := uintptr(arm.SCB.VTOR.Get())
+	println("vectors in RAM:", vtor >= 0x20000000)
+	if vtor < 0x20000000 {
+		return
+	}
+	table := (*[16]uintptr)(unsafe.Pointer(vtor))
+	println("same as flash:", *table == _svectors)
+	// Entry 7 is reserved, so it can be changed without side effects.
+	table[7] = 0x1235
+	println("writable:", table[7] == 0x1235)
+}
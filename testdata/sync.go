@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+)
+
+func main() {
+	var m sync.Map
+
+	// Load, Store
+	_, ok := m.Load("foo")
+	println("load missing:", ok)
+	m.Store("foo", 3)
+	m.Store(5, "five")
+	value, ok := m.Load("foo")
+	println("load foo:", value.(int), ok)
+	m.Store("foo", 4)
+	value, ok = m.Load("foo")
+	println("load foo after store:", value.(int), ok)
+	value, ok = m.Load(5)
+	println("load 5:", value.(string), ok)
+
+	// LoadOrStore
+	actual, loaded := m.LoadOrStore("foo", 10)
+	println("loadorstore foo:", actual.(int), loaded)
+	actual, loaded = m.LoadOrStore("bar", 20)
+	println("loadorstore bar:", actual.(int), loaded)
+
+	// Delete
+	m.Delete("bar")
+	_, ok = m.Load("bar")
+	println("load bar after delete:", ok)
+	m.Delete("nonexistent")
+
+	// Range
+	m.Store("a", 1)
+	m.Store("b", 2)
+	m.Store("c", 3)
+	m.Range(func(key, value interface{}) bool {
+		printEntry(key, value)
+		return true
+	})
+
+	// Range that stops early
+	n := 0
+	m.Range(func(key, value interface{}) bool {
+		n++
+		return n < 2
+	})
+	println("range stopped after:", n)
+
+	// Range with modification: deleted keys are not visited, updated keys are
+	// visited with their new value and added keys are not visited.
+	m.Range(func(key, value interface{}) bool {
+		printEntry(key, value)
+		if key == "a" {
+			m.Delete("b")
+			m.Store("c", 30)
+			m.Store("d", 4)
+		}
+		return true
+	})
+	value, ok = m.Load("d")
+	println("load d:", value.(int), ok)
+}
+
+func printEntry(key, value interface{}) {
+	switch key := key.(type) {
+	case string:
+		print("range: ", key)
+	case int:
+		print("range: ", key)
+	}
+	switch value := value.(type) {
+	case string:
+		println(" =", value)
+	case int:
+		println(" =", value)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "time"
+
+func main() {
+	// A ticker delivers ticks periodically.
+	ticker := time.NewTicker(10 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		<-ticker.C
+		println("tick", i)
+	}
+
+	// After Stop, no more ticks are delivered. If the ticker would keep
+	// running, the scheduler would never exit and this test would time out.
+	ticker.Stop()
+	time.Sleep(25 * time.Millisecond)
+	select {
+	case <-ticker.C:
+		println("tick after stop")
+	default:
+		println("no tick after stop")
+	}
+
+	// Timers fire once.
+	timer := time.NewTimer(5 * time.Millisecond)
+	<-timer.C
+	println("timer expired")
+	println("stop expired timer:", timer.Stop())
+	timer = time.NewTimer(time.Hour)
+	println("stop running timer:", timer.Stop())
+
+	// Callbacks of AfterFunc run in a new goroutine.
+	done := make(chan struct{})
+	time.AfterFunc(5*time.Millisecond, func() {
+		println("after func")
+		close(done)
+	})
+	<-done
+	<-time.After(5 * time.Millisecond)
+	println("done")
+}
@@ -0,0 +1,149 @@
+package transform
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// FoldBranchConditions folds boolean expressions that are only used as the
+// condition of a conditional branch into the branch itself, so that the
+// boolean doesn't need to be materialized in a register. For example, the
+// condition in:
+//
+//     if !(a < b && c < d) {
+//         ...
+//     }
+//
+// is not computed as a single boolean value. Instead, the negation is folded by
+// swapping the branch targets and the && expression is turned into two
+// branches, one on each comparison. Similarly, a boolean that is converted to
+// an integer and compared against zero is replaced by the boolean itself.
+//
+// This transform must run after the LLVM module passes, as SimplifyCFG would
+// merge the branches again.
+func FoldBranchConditions(mod llvm.Module) {
+	builder := mod.Context().NewBuilder()
+	defer builder.Dispose()
+
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		// Collect all conditional branches first, as splitting a branch adds
+		// new basic blocks.
+		var branches []llvm.Value
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			br := bb.LastInstruction()
+			if br.IsABranchInst().IsNil() || br.OperandsCount() != 3 {
+				continue
+			}
+			branches = append(branches, br)
+		}
+		// Folding a branch may create new branches that can be folded as
+		// well, so use the list as a worklist.
+		for len(branches) != 0 {
+			br := branches[len(branches)-1]
+			branches = branches[:len(branches)-1]
+			branches = foldBranchCondition(builder, br, branches)
+		}
+	}
+}
+
+// foldBranchCondition folds the condition of the given conditional branch into
+// the branch, if the condition is only used by this branch. It returns the
+// branches list with new conditional branches that may be folded further
+// appended (including the given branch if it was changed).
+func foldBranchCondition(builder llvm.Builder, br llvm.Value, branches []llvm.Value) []llvm.Value {
+	cond := br.Operand(0)
+	if cond.IsAInstruction().IsNil() || len(getUses(cond)) != 1 {
+		// The condition is used elsewhere (for example stored or returned),
+		// so it must be materialized anyway.
+		return branches
+	}
+	// Operand 1 is the block taken when the condition is false, operand 2
+	// when it is true.
+	ifFalse, ifTrue := br.Operand(1).AsBasicBlock(), br.Operand(2).AsBasicBlock()
+	if ifFalse == ifTrue {
+		return branches
+	}
+	bb := br.InstructionParent()
+
+	switch {
+	case isBoolNot(cond):
+		// br (xor %c, true), T, F -> br %c, F, T
+		replaceBranch(builder, br, cond.Operand(0), ifFalse, ifTrue)
+		cond.EraseFromParentAsInstruction()
+		return append(branches, bb.LastInstruction())
+	case !cond.IsAICmpInst().IsNil():
+		// br (icmp ne (zext %c), 0), T, F -> br %c, T, F
+		// br (icmp eq (zext %c), 0), T, F -> br %c, F, T
+		ext := cond.Operand(0)
+		zero := cond.Operand(1)
+		if ext.IsAZExtInst().IsNil() || ext.Operand(0).Type().IntTypeWidth() != 1 || zero.IsAConstantInt().IsNil() || zero.ZExtValue() != 0 {
+			return branches
+		}
+		switch cond.IntPredicate() {
+		case llvm.IntNE:
+			replaceBranch(builder, br, ext.Operand(0), ifTrue, ifFalse)
+		case llvm.IntEQ:
+			replaceBranch(builder, br, ext.Operand(0), ifFalse, ifTrue)
+		default:
+			return branches
+		}
+		cond.EraseFromParentAsInstruction()
+		if ext.FirstUse().IsNil() {
+			ext.EraseFromParentAsInstruction()
+		}
+		return append(branches, bb.LastInstruction())
+	case !cond.IsABinaryOperator().IsNil() && (cond.InstructionOpcode() == llvm.And || cond.InstructionOpcode() == llvm.Or):
+		// br (and %a, %b), T, F -> br %a, %next, F; next: br %b, T, F
+		// br (or %a, %b), T, F  -> br %a, T, %next; next: br %b, T, F
+		// The block that is only reached through the new block must not
+		// have PHI nodes, as there is no way to change the incoming block of
+		// a PHI node. The other block gets a new incoming edge.
+		isAnd := cond.InstructionOpcode() == llvm.And
+		shared, single := ifFalse, ifTrue
+		if !isAnd {
+			shared, single = ifTrue, ifFalse
+		}
+		if !single.FirstInstruction().IsAPHINode().IsNil() {
+			return branches
+		}
+		next := llvm.AddBasicBlock(bb.Parent(), bb.AsValue().Name()+".next")
+		next.MoveAfter(bb)
+		for phi := shared.FirstInstruction(); !phi.IsAPHINode().IsNil(); phi = llvm.NextInstruction(phi) {
+			for i := 0; i < phi.IncomingCount(); i++ {
+				if phi.IncomingBlock(i) == bb {
+					phi.AddIncoming([]llvm.Value{phi.IncomingValue(i)}, []llvm.BasicBlock{next})
+					break
+				}
+			}
+		}
+		a, b := cond.Operand(0), cond.Operand(1)
+		if isAnd {
+			replaceBranch(builder, br, a, next, ifFalse)
+		} else {
+			replaceBranch(builder, br, a, ifTrue, next)
+		}
+		cond.EraseFromParentAsInstruction()
+		builder.SetInsertPointAtEnd(next)
+		builder.CreateCondBr(b, ifTrue, ifFalse)
+		return append(branches, bb.LastInstruction(), next.LastInstruction())
+	default:
+		return branches
+	}
+}
+
+// isBoolNot returns whether the given instruction is a boolean negation (xor
+// i1 %x, true).
+func isBoolNot(inst llvm.Value) bool {
+	if inst.IsABinaryOperator().IsNil() || inst.InstructionOpcode() != llvm.Xor || inst.Type().IntTypeWidth() != 1 {
+		return false
+	}
+	operand := inst.Operand(1)
+	return !operand.IsAConstantInt().IsNil() && operand.ZExtValue() == 1
+}
+
+// replaceBranch replaces the conditional branch br with a new conditional
+// branch on cond.
+func replaceBranch(builder llvm.Builder, br, cond llvm.Value, ifTrue, ifFalse llvm.BasicBlock) {
+	builder.SetInsertPointBefore(br)
+	builder.CreateCondBr(cond, ifTrue, ifFalse)
+	br.EraseFromParentAsInstruction()
+}
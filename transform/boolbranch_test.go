@@ -0,0 +1,10 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestFoldBranchConditions(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/boolbranch", FoldBranchConditions)
+}
@@ -0,0 +1,212 @@
+package transform
+
+// This file devirtualizes chains of interface method calls, such as a logger
+// that writes through a prefix writer to a multi writer:
+//
+//     var logger io.Writer = &prefixWriter{
+//         prefix: "log: ",
+//         w:      io.MultiWriter(machine.UART0, &ringBuffer),
+//     }
+//
+// Every layer calls the next one through an interface (and therefore through
+// an interface thunk, see compiler/interface-lowering.go), even though the
+// whole chain is known after package initialization. When only a single type
+// implements an interface, interface lowering already calls it directly. This
+// transform handles the case where there are multiple implementations but the
+// call always goes to the same one.
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// maxInterfaceChainDepth is the maximum number of layers that are inlined by
+// DevirtualizeInterfaceChains. It protects against chains that refer to
+// themselves.
+const maxInterfaceChainDepth = 8
+
+// DevirtualizeInterfaceChains replaces calls to interface thunks with a known
+// type code by a direct call to the method of that type. When the method
+// itself calls an interface thunk (it is a layer in a chain of writers, for
+// example), the call is inlined so that the next layer can be devirtualized as
+// well. This is repeated until the end of the chain.
+//
+// Type codes are known when the interface is loaded from a global that is
+// never written after initialization: the global optimizer marks these globals
+// constant, after which the loads are folded to the initializer (as computed
+// by the interp package). If any interface in the chain is assigned at runtime
+// (the global is stored to, or its address escapes), it can't be marked
+// constant and the call at that layer is left as it is, with the dynamic
+// dispatch through the thunk. Layers before it are still devirtualized.
+//
+// This transform must be run after interface lowering, as type codes and
+// thunks only exist after that. It inlines code and unrolls loops, so it should
+// not be used when optimizing for size.
+func DevirtualizeInterfaceChains(mod llvm.Module) {
+	// Fold loads from globals that are constant after initialization, such as
+	// interface values with a known type code.
+	foldPasses := llvm.NewPassManager()
+	defer foldPasses.Dispose()
+	foldPasses.AddGlobalOptimizerPass()
+	foldPasses.AddInstructionCombiningPass()
+
+	// Inline the layers that were marked alwaysinline, so that the next layer
+	// is loaded from a known address. Loops over a constant list of writers
+	// (like in io.MultiWriter) are unrolled, so that each writer is loaded from
+	// a constant address. These passes only run when a chain was found.
+	inlinePasses := llvm.NewPassManager()
+	defer inlinePasses.Dispose()
+	addAlwaysInlinerPass(inlinePasses)
+	inlinePasses.AddScalarReplAggregatesPass()
+	inlinePasses.AddGlobalOptimizerPass()
+	inlinePasses.AddInstructionCombiningPass()
+	inlinePasses.AddCFGSimplificationPass()
+	inlinePasses.AddLoopRotatePass()
+	inlinePasses.AddLoopUnrollPass()
+	inlinePasses.AddInstructionCombiningPass()
+
+	foldPasses.Run(mod)
+	for i := 0; i < maxInterfaceChainDepth; i++ {
+		if !devirtualizeThunkCalls(mod) {
+			break
+		}
+		inlinePasses.Run(mod)
+	}
+}
+
+// devirtualizeThunkCalls replaces every call to an interface method thunk that
+// has a constant type code with a direct call to the method. Calls to methods
+// that call an interface thunk themselves are marked alwaysinline. It returns
+// whether any layers are to be inlined.
+func devirtualizeThunkCalls(mod llvm.Module) bool {
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	alwaysInline := ctx.CreateEnumAttribute(llvm.AttributeKindID("alwaysinline"), 0)
+
+	changed := false
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !isInterfaceThunk(fn) {
+			continue
+		}
+		for _, call := range getUses(fn) {
+			if call.IsACallInst().IsNil() || call.CalledValue() != fn {
+				continue
+			}
+			typecode := call.Operand(fn.ParamsCount() - 1)
+			if typecode.IsAConstantInt().IsNil() {
+				continue // dynamic dispatch
+			}
+			target := thunkTarget(fn, typecode.ZExtValue())
+			if target.IsNil() {
+				continue
+			}
+
+			// Replace the thunk call with the call the thunk would make,
+			// using the thunk parameters as passed in this call.
+			builder.SetInsertPointBefore(call)
+			callee := target.CalledValue()
+			args := make([]llvm.Value, target.OperandsCount()-1)
+			for i := range args {
+				args[i] = remapThunkValue(builder, fn, call, target.Operand(i))
+			}
+			direct := builder.CreateCall(callee, args, "")
+			if call.Type().TypeKind() != llvm.VoidTypeKind {
+				call.ReplaceAllUsesWith(direct)
+			}
+			call.EraseFromParentAsInstruction()
+
+			if callsInterfaceThunk(callee) {
+				// This is a layer in a chain: inline it so that the call to
+				// the next layer can be devirtualized.
+				direct.AddCallSiteAttribute(-1, alwaysInline) // -1 is the function index
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// thunkTarget returns the call instruction in the given method thunk that is
+// made for the given type code, or nil if this is not a method thunk or it has
+// no (simple) call for this type code. A method thunk is a switch over all type
+// codes, where each case calls the method of that type with the receiver
+// possibly bitcast to a different pointer type.
+func thunkTarget(thunk llvm.Value, typecode uint64) llvm.Value {
+	sw := thunk.EntryBasicBlock().LastInstruction()
+	if sw.IsASwitchInst().IsNil() || sw.Operand(0) != thunk.LastParam() {
+		return llvm.Value{} // for example a type assert function
+	}
+	// Switch operands are the condition, the default block and then pairs of
+	// case values and blocks.
+	for i := 2; i+1 < sw.OperandsCount(); i += 2 {
+		if sw.Operand(i).ZExtValue() != typecode {
+			continue
+		}
+		bb := sw.Operand(i + 1).AsBasicBlock()
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			if !inst.IsABitCastInst().IsNil() {
+				continue
+			}
+			if inst.IsACallInst().IsNil() || inst.CalledValue().IsAFunction().IsNil() {
+				return llvm.Value{}
+			}
+			for i := 0; i < inst.OperandsCount()-1; i++ {
+				if !isThunkOperand(thunk, inst.Operand(i)) {
+					return llvm.Value{}
+				}
+			}
+			return inst
+		}
+	}
+	return llvm.Value{}
+}
+
+// isThunkOperand returns whether the given value can be passed to
+// remapThunkValue: it must be a parameter of the thunk (possibly bitcast) or a
+// constant.
+func isThunkOperand(thunk, value llvm.Value) bool {
+	if !value.IsABitCastInst().IsNil() {
+		return isThunkOperand(thunk, value.Operand(0))
+	}
+	if !value.IsAConstant().IsNil() {
+		return true
+	}
+	for _, param := range thunk.Params() {
+		if value == param {
+			return true
+		}
+	}
+	return false
+}
+
+// remapThunkValue returns the value to use in place of the given operand of a
+// call in a thunk, when that call is made directly from the call site of the
+// thunk instead.
+func remapThunkValue(builder llvm.Builder, thunk, call, value llvm.Value) llvm.Value {
+	if !value.IsABitCastInst().IsNil() {
+		return builder.CreateBitCast(remapThunkValue(builder, thunk, call, value.Operand(0)), value.Type(), "")
+	}
+	for i, param := range thunk.Params() {
+		if value == param {
+			return call.Operand(i)
+		}
+	}
+	return value // constant
+}
+
+// callsInterfaceThunk returns whether the given function calls an interface
+// method thunk anywhere in its body.
+func callsInterfaceThunk(fn llvm.Value) bool {
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			if inst.IsACallInst().IsNil() {
+				continue
+			}
+			callee := inst.CalledValue()
+			if !callee.IsAFunction().IsNil() && isInterfaceThunk(callee) {
+				return true
+			}
+		}
+	}
+	return false
+}
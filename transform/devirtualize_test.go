@@ -0,0 +1,58 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+
+	"tinygo.org/x/go-llvm"
+)
+
+func TestDevirtualizeInterfaceChains(t *testing.T) {
+	t.Parallel()
+	ctx := llvm.NewContext()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/devirtualize.ll")
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatalf("could not load module:\n%v", err)
+	}
+
+	DevirtualizeInterfaceChains(mod)
+
+	// The static chain is inlined, up to the calls to the counters at the end
+	// of the chain (twice for each counter, once for the prefix and once for
+	// the data).
+	calls := calledFunctions(mod.NamedFunction("main.logStatic"))
+	expected := []string{
+		"(*main.countWriter).Write",
+		"(*main.countWriter).Write",
+		"(*main.countWriter).Write",
+		"(*main.countWriter).Write",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("static chain was not devirtualized, calls: %v", calls)
+	}
+
+	// The dynamically assigned writer might be any writer, so it must still be
+	// called through the thunk.
+	calls = calledFunctions(mod.NamedFunction("main.logDynamic"))
+	if !reflect.DeepEqual(calls, []string{"(main.Writer).Write"}) {
+		t.Errorf("dynamic writer must be called through the thunk, calls: %v", calls)
+	}
+}
+
+// calledFunctions returns the names of all functions called directly in the
+// given function, in order.
+func calledFunctions(fn llvm.Value) []string {
+	var calls []string
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			if !inst.IsACallInst().IsNil() && !inst.CalledValue().IsAFunction().IsNil() {
+				calls = append(calls, inst.CalledValue().Name())
+			}
+		}
+	}
+	return calls
+}
@@ -0,0 +1,236 @@
+package transform
+
+// This file replaces integer division by a constant with a multiplication by a
+// "magic number" and some shifts, as described in chapter 10 of Hacker's
+// Delight by Henry S. Warren. For example, for unsigned integers:
+//
+//     x / 10 == (x * 0xcccccccd) >> 35
+//
+// LLVM already does this in the backend, but only when the target has an
+// instruction that returns the high 32 bits of a 32x32 bit multiplication
+// (like UMULL). The Cortex-M0 and M0+ have neither such an instruction nor a
+// hardware divider, so every division is a call to __aeabi_uidiv or
+// __aeabi_idiv, which loops over all bits of the quotient and takes around 100
+// cycles. They do have a 32-bit multiplication (MULS), so the high bits of the
+// product can be computed from 16-bit halves with four of them, see
+// createMulHighUnsigned. That is a lot faster, and often not much bigger than
+// the call with its argument setup (and the library function isn't needed at
+// all if all divisions are by constants).
+
+import (
+	"math/bits"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// OptimizeConstantDivisions replaces signed and unsigned divisions and
+// remainders of integers up to 32 bits by a constant with a multiply-shift
+// sequence. It should only be run on targets without a hardware divider and
+// without a 32x32->64 bit multiply instruction, as LLVM already does this
+// (better) on other targets. Divisions by a power of two are left alone: they
+// are already lowered to shifts.
+func OptimizeConstantDivisions(mod llvm.Module) {
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	i32Type := ctx.Int32Type()
+
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); {
+				div := inst
+				inst = llvm.NextInstruction(inst)
+
+				opcode := div.InstructionOpcode()
+				if opcode != llvm.UDiv && opcode != llvm.URem && opcode != llvm.SDiv && opcode != llvm.SRem {
+					continue
+				}
+				if div.Type().TypeKind() != llvm.IntegerTypeKind || div.Type().IntTypeWidth() > 32 {
+					continue // vector or 64-bit division
+				}
+				divisor := div.Operand(1)
+				if divisor.IsAConstantInt().IsNil() {
+					continue
+				}
+				signed := opcode == llvm.SDiv || opcode == llvm.SRem
+				var d uint32
+				if signed {
+					d = uint32(divisor.SExtValue())
+					if divisor.SExtValue() < 0 {
+						d = -d // absolute value
+					}
+				} else {
+					d = uint32(divisor.ZExtValue())
+				}
+				if d&(d-1) == 0 {
+					continue // zero (undefined behavior) or a power of two
+				}
+
+				// Smaller integers are divided as a 32-bit integer.
+				builder.SetInsertPointBefore(div)
+				x := div.Operand(0)
+				if x.Type().IntTypeWidth() < 32 {
+					if signed {
+						x = builder.CreateSExt(x, i32Type, "")
+					} else {
+						x = builder.CreateZExt(x, i32Type, "")
+					}
+				}
+
+				var quotient llvm.Value
+				if signed {
+					quotient = createSignedDivision(builder, x, int32(divisor.SExtValue()))
+				} else {
+					quotient = createUnsignedDivision(builder, x, d)
+				}
+				result := quotient
+				if opcode == llvm.URem || opcode == llvm.SRem {
+					// x % d == x - x/d*d
+					c := llvm.ConstInt(i32Type, uint64(d), false)
+					if signed {
+						c = llvm.ConstInt(i32Type, uint64(divisor.SExtValue()), true)
+					}
+					product := builder.CreateMul(quotient, c, "")
+					result = builder.CreateSub(x, product, "")
+				}
+				if result.Type() != div.Type() {
+					result = builder.CreateTrunc(result, div.Type(), "")
+				}
+				div.ReplaceAllUsesWith(result)
+				div.EraseFromParentAsInstruction()
+			}
+		}
+	}
+}
+
+// createUnsignedDivision returns x/d for an i32 x and a constant d that is not
+// a power of two.
+func createUnsignedDivision(builder llvm.Builder, x llvm.Value, d uint32) llvm.Value {
+	i32Type := x.Type()
+	if d > 1<<31 {
+		// The quotient is either 0 or 1.
+		return builder.CreateZExt(builder.CreateICmp(llvm.IntUGE, x, llvm.ConstInt(i32Type, uint64(d), false), ""), i32Type, "")
+	}
+	magic, shift, add := unsignedDivisionMagic(d)
+	high := createMulHighUnsigned(builder, x, magic)
+	if !add {
+		// x/d == (x*magic) >> (32+shift)
+		return builder.CreateLShr(high, llvm.ConstInt(i32Type, uint64(shift), false), "")
+	}
+	// The magic number needs 33 bits, so the top bit is added separately:
+	// x/d == (high + (x-high)/2) >> (shift-1)
+	half := builder.CreateLShr(builder.CreateSub(x, high, ""), llvm.ConstInt(i32Type, 1, false), "")
+	sum := builder.CreateAdd(high, half, "")
+	return builder.CreateLShr(sum, llvm.ConstInt(i32Type, uint64(shift-1), false), "")
+}
+
+// createSignedDivision returns x/d (rounded towards zero) for an i32 x and a
+// constant d whose absolute value is not a power of two.
+func createSignedDivision(builder llvm.Builder, x llvm.Value, d int32) llvm.Value {
+	i32Type := x.Type()
+	magic, shift := signedDivisionMagic(d)
+
+	// Calculate the high 32 bits of the signed product x*magic from the
+	// unsigned product: when x is negative, magic has to be subtracted from
+	// it, and when magic is negative, x. Hacker's Delight then adds x back for
+	// a negative magic and a positive d, and subtracts it for a positive magic
+	// and a negative d. On balance, x is subtracted when d is negative.
+	high := createMulHighUnsigned(builder, x, uint32(magic))
+	sign := builder.CreateAShr(x, llvm.ConstInt(i32Type, 31, false), "")
+	high = builder.CreateSub(high, builder.CreateAnd(sign, llvm.ConstInt(i32Type, uint64(uint32(magic)), false), ""), "")
+	if d < 0 {
+		high = builder.CreateSub(high, x, "")
+	}
+	if shift != 0 {
+		high = builder.CreateAShr(high, llvm.ConstInt(i32Type, uint64(shift), false), "")
+	}
+
+	// Round towards zero by adding one to negative quotients.
+	signBit := builder.CreateLShr(high, llvm.ConstInt(i32Type, 31, false), "")
+	return builder.CreateAdd(high, signBit, "")
+}
+
+// createMulHighUnsigned returns the high 32 bits of the 64-bit product of x
+// and a constant, using only 32-bit multiplications of the 16-bit halves.
+// None of the intermediate sums overflow.
+func createMulHighUnsigned(builder llvm.Builder, x llvm.Value, magic uint32) llvm.Value {
+	i32Type := x.Type()
+	c16 := llvm.ConstInt(i32Type, 16, false)
+	mask := llvm.ConstInt(i32Type, 0xffff, false)
+	magicLow := llvm.ConstInt(i32Type, uint64(magic&0xffff), false)
+	magicHigh := llvm.ConstInt(i32Type, uint64(magic>>16), false)
+
+	xLow := builder.CreateAnd(x, mask, "")
+	xHigh := builder.CreateLShr(x, c16, "")
+	low := builder.CreateMul(xLow, magicLow, "")
+	mid1 := builder.CreateAdd(builder.CreateMul(xHigh, magicLow, ""), builder.CreateLShr(low, c16, ""), "")
+	mid2 := builder.CreateAdd(builder.CreateMul(xLow, magicHigh, ""), builder.CreateAnd(mid1, mask, ""), "")
+	high := builder.CreateAdd(builder.CreateMul(xHigh, magicHigh, ""), builder.CreateLShr(mid1, c16, ""), "")
+	return builder.CreateAdd(high, builder.CreateLShr(mid2, c16, ""), "")
+}
+
+// unsignedDivisionMagic returns the magic number and shift for an unsigned
+// division by d, which must not be a power of two and at most 1<<31. If add is
+// false, x/d == (x*magic) >> (32+shift) for every 32-bit x. Otherwise, the
+// magic number needs 33 bits and only the lower 32 bits are returned; see
+// createUnsignedDivision for how it's used.
+func unsignedDivisionMagic(d uint32) (magic uint32, shift uint, add bool) {
+	// Try to find a 32-bit magic number: ceil(2**p/d) is exact for all 32-bit
+	// x if it doesn't exceed 2**p/d by more than 2**(p-32)/d.
+	for p := uint(32); p < 64; p++ {
+		m := (uint64(1)<<p + uint64(d) - 1) / uint64(d)
+		if m >= 1<<32 {
+			break // a larger p only needs a larger magic number
+		}
+		if m*uint64(d)-uint64(1)<<p <= uint64(1)<<(p-32) {
+			return uint32(m), p - 32, false
+		}
+	}
+
+	// Use a 33-bit magic number 2**32 + m, with l = ceil(log2(d)).
+	l := uint(bits.Len32(d - 1))
+	m := (uint64(1)<<32)*(uint64(1)<<l-uint64(d))/uint64(d) + 1
+	return uint32(m), l, true
+}
+
+// signedDivisionMagic returns the magic number and shift for a signed
+// division by d, whose absolute value must not be a power of two. This is the
+// algorithm from figure 10-1 of Hacker's Delight.
+func signedDivisionMagic(d int32) (magic int32, shift uint) {
+	const two31 = uint32(1) << 31
+	ad := uint32(d)
+	if d < 0 {
+		ad = -ad
+	}
+	t := two31 + uint32(d)>>31
+	anc := t - 1 - t%ad // absolute value of nc
+	p := uint(31)
+	q1 := two31 / anc // q1 = 2**p/|nc|
+	r1 := two31 - q1*anc
+	q2 := two31 / ad // q2 = 2**p/|d|
+	r2 := two31 - q2*ad
+	for {
+		p++
+		q1 *= 2
+		r1 *= 2
+		if r1 >= anc {
+			q1++
+			r1 -= anc
+		}
+		q2 *= 2
+		r2 *= 2
+		if r2 >= ad {
+			q2++
+			r2 -= ad
+		}
+		delta := ad - r2
+		if q1 > delta || (q1 == delta && r1 != 0) {
+			break
+		}
+	}
+	magic = int32(q2 + 1)
+	if d < 0 {
+		magic = -magic
+	}
+	return magic, p - 32
+}
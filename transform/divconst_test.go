@@ -0,0 +1,127 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"tinygo.org/x/go-llvm"
+)
+
+func TestOptimizeConstantDivisions(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/divconst", func(mod llvm.Module) {
+		OptimizeConstantDivisions(mod)
+	})
+}
+
+// TestConstantDivisionsCortexM0 checks that a division by 10 on a Cortex-M0
+// (which has no hardware divider) is compiled to multiplications and shifts
+// instead of a call to the division routine in compiler-rt (__aeabi_idiv or
+// __divsi3, depending on the ABI).
+func TestConstantDivisionsCortexM0(t *testing.T) {
+	t.Parallel()
+	llvm.InitializeAllTargets()
+	llvm.InitializeAllTargetMCs()
+	llvm.InitializeAllTargetInfos()
+	llvm.InitializeAllAsmPrinters()
+
+	triple := "armv6m-none-eabi"
+	target, err := llvm.GetTargetFromTriple(triple)
+	if err != nil {
+		t.Fatalf("could not get target for %s: %v", triple, err)
+	}
+	machine := target.CreateTargetMachine(triple, "cortex-m0", "", llvm.CodeGenLevelDefault, llvm.RelocStatic, llvm.CodeModelDefault)
+	defer machine.Dispose()
+
+	for _, optimize := range []bool{false, true} {
+		ctx := llvm.NewContext()
+		buf, err := llvm.NewMemoryBufferFromFile("testdata/divconst.ll")
+		if err != nil {
+			t.Fatal("could not read file:", err)
+		}
+		mod, err := ctx.ParseIR(buf)
+		if err != nil {
+			t.Fatalf("could not load module:\n%v", err)
+		}
+		// Only keep the division by 10.
+		for fn := mod.FirstFunction(); !fn.IsNil(); {
+			next := llvm.NextFunction(fn)
+			if fn.Name() != "sdiv10" {
+				fn.EraseFromParentAsFunction()
+			}
+			fn = next
+		}
+		if optimize {
+			OptimizeConstantDivisions(mod)
+		}
+
+		asm, err := machine.EmitToMemoryBuffer(mod, llvm.AssemblyFile)
+		if err != nil {
+			t.Fatal("could not compile module:", err)
+		}
+		// The function doesn't call anything except for the division routine.
+		hasCall := strings.Contains(string(asm.Bytes()), "\tbl\t")
+		if hasCall == optimize {
+			t.Errorf("expected a library call for the division: %v, got:\n%s", !optimize, asm.Bytes())
+		}
+		asm.Dispose()
+	}
+}
+
+func TestDivisionMagic(t *testing.T) {
+	t.Parallel()
+
+	// Known magic numbers from Hacker's Delight.
+	if magic, shift, add := unsignedDivisionMagic(10); magic != 0xcccccccd || shift != 3 || add {
+		t.Errorf("unsigned magic for 10: got %#x, %d, %v", magic, shift, add)
+	}
+	if magic, shift, add := unsignedDivisionMagic(7); magic != 0x24924925 || shift != 3 || !add {
+		t.Errorf("unsigned magic for 7: got %#x, %d, %v", magic, shift, add)
+	}
+	if magic, shift := signedDivisionMagic(7); magic != -0x6db6db6d || shift != 2 {
+		t.Errorf("signed magic for 7: got %#x, %d", magic, shift)
+	}
+	if magic, shift := signedDivisionMagic(-7); magic != 0x6db6db6d || shift != 2 {
+		t.Errorf("signed magic for -7: got %#x, %d", magic, shift)
+	}
+
+	// Check the magic numbers by evaluating the same sequence as
+	// createUnsignedDivision and createSignedDivision in Go, for some
+	// divisors and dividends near the edges.
+	values := []uint32{0, 1, 2, 6, 7, 9, 10, 99, 100, 1000, 12345678, 1<<31 - 1, 1 << 31, 1<<31 + 1, 1<<32 - 7, 1<<32 - 1}
+	divisors := []uint32{3, 5, 6, 7, 10, 11, 25, 100, 125, 641, 1000, 6700417, 1<<31 - 1, 1<<31 + 1, 3000000000}
+	for _, d := range divisors {
+		for _, x := range values {
+			if d <= 1<<31 {
+				var q uint32
+				magic, shift, add := unsignedDivisionMagic(d)
+				high := uint32(uint64(x) * uint64(magic) >> 32)
+				if add {
+					q = (high + (x-high)/2) >> (shift - 1)
+				} else {
+					q = high >> shift
+				}
+				if q != x/d {
+					t.Errorf("%d / %d: expected %d, got %d", x, d, x/d, q)
+				}
+			}
+			if d < 1<<31 {
+				for _, sd := range []int32{int32(d), -int32(d)} {
+					sx := int32(x)
+					magic, shift := signedDivisionMagic(sd)
+					q := int32(int64(sx) * int64(magic) >> 32)
+					if sd > 0 && magic < 0 {
+						q += sx
+					} else if sd < 0 && magic > 0 {
+						q -= sx
+					}
+					q >>= shift
+					q += int32(uint32(q) >> 31)
+					if q != sx/sd {
+						t.Errorf("%d / %d: expected %d, got %d", sx, sd, sx/sd, q)
+					}
+				}
+			}
+		}
+	}
+}
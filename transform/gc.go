@@ -0,0 +1,222 @@
+package transform
+
+// This file removes stack slot tracking for the garbage collector where it
+// turns out to be unnecessary after optimization. Pointers are tracked by
+// inserting calls to runtime.trackPointer during IR construction. These calls
+// are later turned into stores to a stack object (see makeGCStackSlots in the
+// compiler package), which has a cost in every function that needs one.
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// OptimizeTrackPointers removes calls to runtime.trackPointer for pointers that
+// don't need to be visible to the garbage collector. A pointer only needs to be
+// tracked if a garbage collection cycle may run while the pointer is live,
+// which can only happen during a call that (directly or indirectly) allocates
+// heap memory. Tracking is removed for:
+//
+//   - pointers to stack allocations, for example heap allocations that have
+//     been converted to stack allocations by OptimizeAllocs, as the garbage
+//     collector ignores pointers outside the heap anyway.
+//   - pointers that are created after the last call that may allocate, so that
+//     no garbage collection cycle can happen while they are live.
+//
+// Functions without any tracked pointers left don't need a stack object at all.
+// This pass should be run after all other optimizations, in particular after
+// OptimizeAllocs.
+func OptimizeTrackPointers(mod llvm.Module) {
+	trackPointer := mod.NamedFunction("runtime.trackPointer")
+	if trackPointer.IsNil() || trackPointer.FirstUse().IsNil() {
+		return // nothing to do
+	}
+
+	allocating := findAllocatingFunctions(mod)
+
+	// Cached per function: the set of basic blocks from which an allocating
+	// call may be reached.
+	reachability := map[llvm.Value]map[llvm.BasicBlock]bool{}
+
+	for _, call := range getUses(trackPointer) {
+		if call.IsACallInst().IsNil() {
+			panic("expected runtime.trackPointer use to be a call")
+		}
+		ptr := call.Operand(0)
+		if ptr.IsAInstruction().IsNil() {
+			// Constants and parameters are left for makeGCStackSlots.
+			continue
+		}
+		if alloca := stackAllocation(ptr); !alloca.IsNil() {
+			// Stack allocations themselves are never freed by the GC, but
+			// makeGCStackSlots must still know about allocas that contain
+			// pointers so that the heap objects they refer to are kept alive.
+			if !typeHasPointers(alloca.Type().ElementType()) {
+				call.EraseFromParentAsInstruction()
+			}
+			continue
+		}
+		fn := call.InstructionParent().Parent()
+		reaches, ok := reachability[fn]
+		if !ok {
+			reaches = findAllocatingBlocks(fn, allocating)
+			reachability[fn] = reaches
+		}
+		// Look whether an allocating call may happen after the pointer is
+		// created. Check from the pointer itself instead of the call to
+		// runtime.trackPointer, as optimizations may have replaced the tracked
+		// value with an identical value created earlier.
+		if !mayAllocateAfter(ptr, allocating, reaches) {
+			call.EraseFromParentAsInstruction()
+		}
+	}
+}
+
+// stackAllocation returns the alloca instruction the given pointer is derived
+// from, or a nil value if it is not derived from a stack allocation.
+func stackAllocation(ptr llvm.Value) llvm.Value {
+	for !ptr.IsABitCastInst().IsNil() || !ptr.IsAGetElementPtrInst().IsNil() {
+		ptr = ptr.Operand(0)
+	}
+	return ptr.IsAAllocaInst()
+}
+
+// typeHasPointers returns whether this type is a pointer or contains pointers.
+// If the type is an aggregate type, it will check whether there is a pointer
+// inside.
+func typeHasPointers(t llvm.Type) bool {
+	switch t.TypeKind() {
+	case llvm.PointerTypeKind:
+		return true
+	case llvm.StructTypeKind:
+		for _, subType := range t.StructElementTypes() {
+			if typeHasPointers(subType) {
+				return true
+			}
+		}
+		return false
+	case llvm.ArrayTypeKind:
+		return typeHasPointers(t.ElementType())
+	default:
+		return false
+	}
+}
+
+// findAllocatingFunctions returns the set of functions that may trigger a
+// garbage collection cycle. These are all functions that (recursively) call
+// runtime.alloc or runtime.GC, or that call a function pointer which might do
+// so.
+func findAllocatingFunctions(mod llvm.Module) map[llvm.Value]struct{} {
+	allocating := map[llvm.Value]struct{}{}
+	var worklist []llvm.Value
+	for _, name := range []string{"runtime.alloc", "runtime.GC"} {
+		fn := mod.NamedFunction(name)
+		if !fn.IsNil() {
+			allocating[fn] = struct{}{}
+			worklist = append(worklist, fn)
+		}
+	}
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if hasIndirectCall(fn) {
+			allocating[fn] = struct{}{}
+			worklist = append(worklist, fn)
+		}
+	}
+
+	// Mark all callers of allocating functions as allocating.
+	for len(worklist) != 0 {
+		fn := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		for _, use := range getUses(fn) {
+			if use.IsACallInst().IsNil() || use.CalledValue() != fn {
+				continue
+			}
+			parent := use.InstructionParent().Parent()
+			if _, ok := allocating[parent]; !ok {
+				allocating[parent] = struct{}{}
+				worklist = append(worklist, parent)
+			}
+		}
+	}
+	return allocating
+}
+
+// hasIndirectCall returns whether the function contains a call to something
+// other than a function, such as a function pointer.
+func hasIndirectCall(fn llvm.Value) bool {
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			if !inst.IsACallInst().IsNil() && inst.CalledValue().IsAFunction().IsNil() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isAllocatingCall returns whether the given instruction is a call that may
+// trigger a garbage collection cycle.
+func isAllocatingCall(inst llvm.Value, allocating map[llvm.Value]struct{}) bool {
+	if inst.IsACallInst().IsNil() {
+		return false
+	}
+	called := inst.CalledValue()
+	if called.IsAFunction().IsNil() {
+		return true // function pointer
+	}
+	_, ok := allocating[called]
+	return ok
+}
+
+// findAllocatingBlocks returns the set of basic blocks in the function from
+// which an allocating call may be reached, including the blocks that contain
+// such a call themselves.
+func findAllocatingBlocks(fn llvm.Value, allocating map[llvm.Value]struct{}) map[llvm.BasicBlock]bool {
+	reaches := map[llvm.BasicBlock]bool{}
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			if isAllocatingCall(inst, allocating) {
+				reaches[bb] = true
+				break
+			}
+		}
+	}
+
+	// Propagate backwards through the control flow graph until nothing
+	// changes anymore.
+	for changed := true; changed; {
+		changed = false
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			if reaches[bb] {
+				continue
+			}
+			terminator := bb.LastInstruction()
+			for i := 0; i < terminator.OperandsCount(); i++ {
+				successor := terminator.Operand(i)
+				if successor.IsBasicBlock() && reaches[successor.AsBasicBlock()] {
+					reaches[bb] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return reaches
+}
+
+// mayAllocateAfter returns whether an allocating call may be executed after the
+// given instruction, in the same function.
+func mayAllocateAfter(inst llvm.Value, allocating map[llvm.Value]struct{}, reaches map[llvm.BasicBlock]bool) bool {
+	for next := llvm.NextInstruction(inst); !next.IsNil(); next = llvm.NextInstruction(next) {
+		if isAllocatingCall(next, allocating) {
+			return true
+		}
+	}
+	terminator := inst.InstructionParent().LastInstruction()
+	for i := 0; i < terminator.OperandsCount(); i++ {
+		successor := terminator.Operand(i)
+		if successor.IsBasicBlock() && reaches[successor.AsBasicBlock()] {
+			return true
+		}
+	}
+	return false
+}
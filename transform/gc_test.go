@@ -0,0 +1,10 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestOptimizeTrackPointers(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/gc", OptimizeTrackPointers)
+}
@@ -0,0 +1,86 @@
+package transform
+
+// This file removes calls to package initializers that don't do anything. Many
+// packages have an initializer that is empty, because they have no global
+// variables with an initial value that must be computed at runtime and no
+// init() functions, or because their init() functions are empty.
+
+import (
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// RemoveEmptyInits removes calls to empty package initializers from
+// runtime.initAll, so that they don't need to be called at startup (or
+// interpreted at compile time). An initializer is empty when it only returns,
+// possibly after calling other empty functions (such as empty init() functions
+// of the same package). Any other instruction means the initializer is kept,
+// including stores, volatile loads and stores (for example to access memory
+// mapped I/O) and calls to any other function.
+//
+// The initializers themselves are not removed here, that is left to the
+// optimizer once they are unused.
+func RemoveEmptyInits(mod llvm.Module) {
+	initAll := mod.NamedFunction("runtime.initAll")
+	if initAll.IsNil() || initAll.IsDeclaration() {
+		return
+	}
+
+	// Find calls to empty functions in runtime.initAll. Do this before
+	// removing them, to not disturb iteration over the instructions.
+	empty := map[llvm.Value]bool{}
+	var calls []llvm.Value
+	for bb := initAll.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			if inst.IsACallInst().IsNil() {
+				continue
+			}
+			if isEmptyFunction(inst.CalledValue(), empty) {
+				calls = append(calls, inst)
+			}
+		}
+	}
+	for _, call := range calls {
+		call.EraseFromParentAsInstruction()
+	}
+}
+
+// isEmptyFunction returns whether the given function only returns, apart from
+// debug information and calls to other empty functions. Results are stored in
+// the cache, which also avoids infinite recursion in recursive functions.
+func isEmptyFunction(fn llvm.Value, cache map[llvm.Value]bool) bool {
+	if fn.IsAFunction().IsNil() || fn.IsDeclaration() {
+		// Function pointer or external function: may do anything.
+		return false
+	}
+	if isEmpty, ok := cache[fn]; ok {
+		return isEmpty
+	}
+	cache[fn] = false // assume it is not empty while checking recursive calls
+
+	if !llvm.NextBasicBlock(fn.EntryBasicBlock()).IsNil() {
+		// More than one basic block, so there is some control flow.
+		return false
+	}
+	for inst := fn.EntryBasicBlock().FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+		switch {
+		case !inst.IsAReturnInst().IsNil():
+			if inst.OperandsCount() != 0 {
+				return false // returns a value, so not an initializer
+			}
+		case !inst.IsACallInst().IsNil():
+			callee := inst.CalledValue()
+			if !callee.IsAFunction().IsNil() && strings.HasPrefix(callee.Name(), "llvm.dbg.") {
+				continue // debug information
+			}
+			if !isEmptyFunction(callee, cache) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	cache[fn] = true
+	return true
+}
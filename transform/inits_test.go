@@ -0,0 +1,10 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestRemoveEmptyInits(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/inits", RemoveEmptyInits)
+}
@@ -0,0 +1,149 @@
+package transform
+
+// This file removes interface values that are created and then immediately
+// taken apart again, which is common when a value passes through a helper
+// function that accepts an interface{} and is type-asserted back to its
+// concrete type after inlining.
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// OptimizeInterfaceRoundTrips finds interface values that are only used to
+// extract their type code and value, and replaces those extracts with the type
+// code and value that were used to create the interface. For example:
+//
+//     %itf.0 = insertvalue %runtime._interface undef, i32 3, 0
+//     %itf = insertvalue %runtime._interface %itf.0, i8* %pack.int, 1
+//     %typecode = extractvalue %runtime._interface %itf, 0
+//     %ok = icmp eq i32 3, %typecode
+//     ...
+//     %ptr = extractvalue %runtime._interface %itf, 1
+//     %value = ptrtoint i8* %ptr to i32
+//
+// After this transform, the type assert compares two constants (and is folded
+// to a constant) and %value is replaced with the value that was put in the
+// interface before %pack.int, so both the boxing and the unboxing are gone.
+//
+// When the interface value is used in any other way (for example passed to a
+// function, stored in memory or merged with a different interface in a PHI
+// node), it is left alone. This transform must be run after interface
+// lowering, as type codes are only known after that.
+func OptimizeInterfaceRoundTrips(mod llvm.Module) {
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		var interfaces []llvm.Value
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				if isInterfaceCreation(inst) {
+					interfaces = append(interfaces, inst)
+				}
+			}
+		}
+		for _, itf := range interfaces {
+			removeInterfaceRoundTrip(itf)
+		}
+	}
+}
+
+// isInterfaceCreation returns whether the given instruction is the last of the
+// two insertvalue instructions that create an interface value: the first
+// inserts the type code and the second (this one) inserts the value.
+func isInterfaceCreation(inst llvm.Value) bool {
+	if inst.IsAInsertValueInst().IsNil() || inst.Type().StructName() != "runtime._interface" {
+		return false
+	}
+	if indices := inst.Indices(); len(indices) != 1 || indices[0] != 1 {
+		return false
+	}
+	typecodeInsert := inst.Operand(0)
+	if typecodeInsert.IsAInsertValueInst().IsNil() || !typecodeInsert.Operand(0).IsUndef() {
+		return false
+	}
+	indices := typecodeInsert.Indices()
+	return len(indices) == 1 && indices[0] == 0
+}
+
+// removeInterfaceRoundTrip replaces all extracts from the given interface value
+// with the inserted type code and value, if the interface is only used by such
+// extracts. The interface value is then removed.
+func removeInterfaceRoundTrip(itf llvm.Value) {
+	uses := getUses(itf)
+	if len(uses) == 0 {
+		return // dead code, will be removed anyway
+	}
+	for _, use := range uses {
+		if use.IsAExtractValueInst().IsNil() || len(use.Indices()) != 1 {
+			// The interface is used in some other way, so it must be created
+			// anyway.
+			return
+		}
+	}
+
+	typecodeInsert := itf.Operand(0)
+	typecode := typecodeInsert.Operand(1)
+	value := itf.Operand(1)
+	for _, extract := range uses {
+		users := getUses(extract)
+		if extract.Indices()[0] == 0 {
+			extract.ReplaceAllUsesWith(typecode)
+			for _, user := range users {
+				foldConstantICmp(user)
+			}
+		} else {
+			extract.ReplaceAllUsesWith(value)
+			for _, user := range users {
+				removeCastRoundTrip(user, value)
+			}
+		}
+		extract.EraseFromParentAsInstruction()
+	}
+	itf.EraseFromParentAsInstruction()
+	if typecodeInsert.FirstUse().IsNil() {
+		typecodeInsert.EraseFromParentAsInstruction()
+	}
+	if !value.IsAInstruction().IsNil() && value.FirstUse().IsNil() {
+		value.EraseFromParentAsInstruction()
+	}
+}
+
+// foldConstantICmp replaces the given instruction with a constant if it is an
+// integer comparison of two constants, such as a type assert on a known type
+// code.
+func foldConstantICmp(inst llvm.Value) {
+	if inst.IsAICmpInst().IsNil() || inst.Operand(0).IsAConstant().IsNil() || inst.Operand(1).IsAConstant().IsNil() {
+		return
+	}
+	inst.ReplaceAllUsesWith(llvm.ConstICmp(inst.IntPredicate(), inst.Operand(0), inst.Operand(1)))
+	inst.EraseFromParentAsInstruction()
+}
+
+// removeCastRoundTrip replaces the given instruction with the original value
+// if it is a cast that undoes the cast that created value, for example a
+// ptrtoint of an inttoptr to the original type.
+func removeCastRoundTrip(inst, value llvm.Value) {
+	if inst.IsACastInst().IsNil() {
+		return
+	}
+	// The cast that created value may be an instruction or, for constants, a
+	// constant expression.
+	var opcode llvm.Opcode
+	if !value.IsACastInst().IsNil() {
+		opcode = value.InstructionOpcode()
+	} else if !value.IsAConstantExpr().IsNil() {
+		opcode = value.Opcode()
+	} else {
+		return
+	}
+	switch {
+	case opcode == llvm.IntToPtr && inst.InstructionOpcode() == llvm.PtrToInt:
+	case opcode == llvm.BitCast && inst.InstructionOpcode() == llvm.BitCast:
+	default:
+		return
+	}
+	original := value.Operand(0)
+	if original.Type() != inst.Type() {
+		return
+	}
+	inst.ReplaceAllUsesWith(original)
+	inst.EraseFromParentAsInstruction()
+}
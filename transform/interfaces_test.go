@@ -0,0 +1,10 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestOptimizeInterfaceRoundTrips(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/interfaces", OptimizeInterfaceRoundTrips)
+}
@@ -0,0 +1,127 @@
+package transform
+
+// This file checks that interrupt handlers don't call operations that block
+// the scheduler. An interrupt handler runs on the stack of whatever goroutine
+// it interrupted, outside of the scheduler, so it can't be suspended: a
+// blocking operation like time.Sleep or a channel send that has to wait for a
+// receiver would switch to another goroutine in the middle of the handler and
+// corrupt the scheduler state or deadlock the program.
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// blockingFunctions are the runtime functions that may block the current
+// goroutine, with a description for the error message. Every function that
+// blocks ends up calling runtime.yield, but the other functions give a more
+// useful description.
+var blockingFunctions = map[string]string{
+	"time.Sleep":         "time.Sleep",
+	"runtime.Gosched":    "runtime.Gosched",
+	"runtime.chanSend":   "a channel send",
+	"runtime.chanRecv":   "a channel receive",
+	"runtime.chanSelect": "a select statement without default case",
+	"runtime.deadlock":   "an operation that blocks forever",
+	"runtime.yield":      "a switch to another goroutine",
+}
+
+// CheckInterruptHandlers returns an error for each interrupt handler that may
+// call a blocking operation, such as time.Sleep or a channel operation that has
+// to wait. Interrupt handlers are recognized by their (exported) name:
+// *_Handler and *_IRQHandler on Cortex-M (except Reset_Handler, which runs the
+// main goroutine) and __vector_* on AVR. Only direct calls are followed:
+// calls through an interface or func value are assumed to be non-blocking.
+//
+// This check must be run before optimizations, as the goroutine lowering pass
+// removes or changes the calls to runtime.yield.
+func CheckInterruptHandlers(mod llvm.Module) []error {
+	var handlers []llvm.Value
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.IsDeclaration() && isInterruptHandler(fn.Name()) {
+			handlers = append(handlers, fn)
+		}
+	}
+	sort.Slice(handlers, func(i, j int) bool {
+		return handlers[i].Name() < handlers[j].Name()
+	})
+
+	var errs []error
+	for _, handler := range handlers {
+		path := findBlockingCall(handler)
+		if path == nil {
+			continue
+		}
+		names := make([]string, len(path))
+		for i, fn := range path {
+			names[i] = fn.Name()
+		}
+		callee := path[len(path)-1].Name()
+		errs = append(errs, errors.New("interrupt handler "+handler.Name()+" may block the scheduler with "+blockingFunctions[callee]+": "+strings.Join(names, " -> ")))
+	}
+	return errs
+}
+
+// isInterruptHandler returns whether the function with the given name is an
+// interrupt handler in the vector table.
+func isInterruptHandler(name string) bool {
+	if name == "Reset_Handler" {
+		return false
+	}
+	return strings.HasSuffix(name, "_Handler") || strings.HasSuffix(name, "_IRQHandler") || strings.HasPrefix(name, "__vector_")
+}
+
+// findBlockingCall returns the shortest call path from the given function to
+// one of the blockingFunctions, or nil if no blocking function is reachable.
+func findBlockingCall(fn llvm.Value) []llvm.Value {
+	parents := map[llvm.Value]llvm.Value{fn: llvm.Value{}}
+	worklist := []llvm.Value{fn}
+	for len(worklist) != 0 {
+		caller := worklist[0]
+		worklist = worklist[1:]
+		for bb := caller.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				if inst.IsACallInst().IsNil() {
+					continue
+				}
+				callee := inst.CalledValue()
+				if callee.IsAFunction().IsNil() || !isBlockingCall(inst) {
+					continue // indirect call or a call that doesn't block
+				}
+				if _, ok := parents[callee]; ok {
+					continue // already visited
+				}
+				parents[callee] = caller
+				if _, ok := blockingFunctions[callee.Name()]; ok {
+					// Found a blocking call. Reconstruct the path to it.
+					var path []llvm.Value
+					for f := callee; !f.IsNil(); f = parents[f] {
+						path = append([]llvm.Value{f}, path...)
+					}
+					return path
+				}
+				if !callee.IsDeclaration() {
+					worklist = append(worklist, callee)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isBlockingCall returns false for calls that never block, even though the
+// called function may block in other cases. This is a select statement with a
+// default case: runtime.chanSelect with the blocking parameter set to false.
+func isBlockingCall(call llvm.Value) bool {
+	callee := call.CalledValue()
+	if callee.Name() != "runtime.chanSelect" || callee.ParamsCount() < 3 {
+		return true
+	}
+	// The blocking parameter is followed by the context and parent handle
+	// parameters of every Go function.
+	blocking := call.Operand(callee.ParamsCount() - 3)
+	return blocking.IsAConstantInt().IsNil() || blocking.ZExtValue() != 0
+}
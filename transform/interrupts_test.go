@@ -0,0 +1,36 @@
+package transform
+
+import (
+	"os"
+	"testing"
+
+	"tinygo.org/x/go-llvm"
+)
+
+func TestCheckInterruptHandlers(t *testing.T) {
+	t.Parallel()
+	ctx := llvm.NewContext()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/interrupts.ll")
+	os.Stat("testdata/interrupts.ll") // make sure this file is tracked by `go test` caching
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatalf("could not load module:\n%v", err)
+	}
+
+	errs := CheckInterruptHandlers(mod)
+	expected := []string{
+		"interrupt handler TIM2_IRQHandler may block the scheduler with time.Sleep: TIM2_IRQHandler -> main.wait -> time.Sleep",
+		"interrupt handler USART1_IRQHandler may block the scheduler with a channel send: USART1_IRQHandler -> runtime.chanSend",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("unexpected error:\n%s\nexpected:\n%s", err, expected[i])
+		}
+	}
+}
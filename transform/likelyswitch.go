@@ -0,0 +1,195 @@
+package transform
+
+// This file checks the dominant case of a switch before all other cases. LLVM
+// lowers a switch to a jump table or a binary search tree, which means that
+// even a case that is taken almost always needs a few comparisons or an
+// indirect jump. When the branch weights of the switch say that one case is
+// taken most of the time, it is better to check that case first with a single
+// comparison and only do the full switch when it doesn't match.
+//
+// The branch weights usually come from a //go:likely pragma on a type switch
+// case, see compiler/typeswitch.go.
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// PeelLikelySwitchCases peels the dominant case off every switch instruction
+// with branch weights in which a single case has at least two thirds of the
+// total weight. For example:
+//
+//     switch i32 %x, label %default [
+//         i32 1, label %one
+//         i32 2, label %two
+//         i32 3, label %three
+//     ], !prof !{!"branch_weights", i32 1, i32 1, i32 2000, i32 1}
+//
+// is replaced with:
+//
+//     %switch.likely = icmp eq i32 %x, 2
+//     br i1 %switch.likely, label %two, label %switch.unlikely, !prof ...
+//   switch.unlikely:
+//     switch i32 %x, label %default [
+//         i32 1, label %one
+//         i32 3, label %three
+//     ], !prof ...
+//
+// The dominant case must be the only case that goes to its destination block,
+// so that the PHI nodes in that block stay the same.
+//
+// This transform must run after the LLVM module passes, as SimplifyCFG would
+// merge the branch back into the switch.
+func PeelLikelySwitchCases(mod llvm.Module) {
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	profKind := ctx.MDKindID("prof")
+
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		var switches []llvm.Value
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			sw := bb.LastInstruction()
+			if sw.IsASwitchInst().IsNil() || sw.Metadata(profKind).IsNil() {
+				continue
+			}
+			switches = append(switches, sw)
+		}
+		for _, sw := range switches {
+			peelLikelySwitchCase(ctx, builder, profKind, sw)
+		}
+	}
+}
+
+// peelLikelySwitchCase peels the dominant case off the given switch
+// instruction, if there is one. See PeelLikelySwitchCases.
+func peelLikelySwitchCase(ctx llvm.Context, builder llvm.Builder, profKind int, sw llvm.Value) {
+	// The operands of a switch are the condition, the default block and a
+	// value and destination block for each case. The branch weights are the
+	// "branch_weights" string followed by a weight for the default block and
+	// for each case.
+	numCases := sw.OperandsCount()/2 - 1
+	prof := sw.Metadata(profKind)
+	if prof.OperandsCount() != numCases+2 {
+		return
+	}
+	weights := make([]uint64, numCases+1)
+	var total uint64
+	for i := range weights {
+		weight := prof.Operand(i + 1)
+		if weight.IsAConstantInt().IsNil() {
+			return
+		}
+		weights[i] = weight.ZExtValue()
+		total += weights[i]
+	}
+	if total == 0 {
+		return
+	}
+	likely := 0 // index of the dominant case, 0 means there is none
+	for i := 1; i < len(weights); i++ {
+		if weights[i]*3 >= total*2 {
+			likely = i
+		}
+	}
+	if likely == 0 {
+		return
+	}
+	cond := sw.Operand(0)
+	defaultBlock := sw.Operand(1).AsBasicBlock()
+	likelyValue := sw.Operand(likely * 2)
+	likelyBlock := sw.Operand(likely*2 + 1).AsBasicBlock()
+	if defaultBlock == likelyBlock {
+		return
+	}
+	for i := 1; i <= numCases; i++ {
+		if i != likely && sw.Operand(i*2+1).AsBasicBlock() == likelyBlock {
+			return // another case goes to the same block
+		}
+	}
+
+	// Check the dominant case first.
+	bb := sw.InstructionParent()
+	builder.SetInsertPointBefore(sw)
+	isLikely := builder.CreateICmp(llvm.IntEQ, cond, likelyValue, "switch.likely")
+	unlikelyBlock := defaultBlock
+	if numCases > 1 {
+		unlikelyBlock = llvm.AddBasicBlock(bb.Parent(), "switch.unlikely")
+		unlikelyBlock.MoveAfter(bb)
+	}
+	br := builder.CreateCondBr(isLikely, likelyBlock, unlikelyBlock)
+	br.SetMetadata(profKind, createBranchWeights(ctx, []uint64{weights[likely], total - weights[likely]}))
+
+	if numCases > 1 {
+		// Do the full switch on the remaining cases in the new block. All
+		// edges from the old switch (except the one to the dominant case)
+		// now come from the new block, so update the PHI nodes in the
+		// destination blocks.
+		builder.SetInsertPointAtEnd(unlikelyBlock)
+		newSwitch := builder.CreateSwitch(cond, defaultBlock, numCases-1)
+		newWeights := []uint64{weights[0]}
+		successors := map[llvm.BasicBlock]bool{defaultBlock: true}
+		for i := 1; i <= numCases; i++ {
+			if i == likely {
+				continue
+			}
+			dest := sw.Operand(i*2 + 1).AsBasicBlock()
+			newSwitch.AddCase(sw.Operand(i*2), dest)
+			newWeights = append(newWeights, weights[i])
+			successors[dest] = true
+		}
+		newSwitch.SetMetadata(profKind, createBranchWeights(ctx, newWeights))
+		for dest := range successors {
+			replacePHIIncomingBlock(builder, dest, bb, unlikelyBlock)
+		}
+	}
+	sw.EraseFromParentAsInstruction()
+}
+
+// createBranchWeights returns a branch_weights metadata node with the given
+// weights, scaled down to fit in 32 bits if needed.
+func createBranchWeights(ctx llvm.Context, weights []uint64) llvm.Metadata {
+	var max uint64
+	for _, weight := range weights {
+		if weight > max {
+			max = weight
+		}
+	}
+	shift := uint(0)
+	for max>>shift > 0xffffffff {
+		shift++
+	}
+	operands := []llvm.Metadata{ctx.MDString("branch_weights")}
+	for _, weight := range weights {
+		operands = append(operands, llvm.ConstInt(ctx.Int32Type(), weight>>shift, false).ConstantAsMetadata())
+	}
+	return ctx.MDNode(operands)
+}
+
+// replacePHIIncomingBlock changes the incoming block of all PHI nodes in the
+// given block from oldBlock to newBlock. A PHI node can't be changed in place,
+// so each PHI node is replaced with a new one.
+func replacePHIIncomingBlock(builder llvm.Builder, block, oldBlock, newBlock llvm.BasicBlock) {
+	var phis []llvm.Value
+	for phi := block.FirstInstruction(); !phi.IsAPHINode().IsNil(); phi = llvm.NextInstruction(phi) {
+		phis = append(phis, phi)
+	}
+	for _, phi := range phis {
+		var values []llvm.Value
+		var blocks []llvm.BasicBlock
+		for i := 0; i < phi.IncomingCount(); i++ {
+			incoming := phi.IncomingBlock(i)
+			if incoming == oldBlock {
+				incoming = newBlock
+			}
+			values = append(values, phi.IncomingValue(i))
+			blocks = append(blocks, incoming)
+		}
+		builder.SetInsertPointBefore(phi)
+		name := phi.Name()
+		newPHI := builder.CreatePHI(phi.Type(), "")
+		newPHI.AddIncoming(values, blocks)
+		phi.ReplaceAllUsesWith(newPHI)
+		phi.EraseFromParentAsInstruction()
+		newPHI.SetName(name)
+	}
+}
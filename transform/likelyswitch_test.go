@@ -0,0 +1,10 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestPeelLikelySwitchCases(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/likelyswitch", PeelLikelySwitchCases)
+}
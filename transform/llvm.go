@@ -1,6 +1,17 @@
 package transform
 
+// This file contains helper functions for LLVM that are not exposed in the Go
+// bindings.
+
+/*
+typedef struct LLVMOpaquePassManager *LLVMPassManagerRef;
+void LLVMAddAlwaysInlinerPass(LLVMPassManagerRef PM);
+*/
+import "C"
+
 import (
+	"unsafe"
+
 	"tinygo.org/x/go-llvm"
 )
 
@@ -15,3 +26,9 @@ func getUses(value llvm.Value) []llvm.Value {
 	}
 	return uses
 }
+
+// addAlwaysInlinerPass adds the pass that inlines all calls to functions and
+// call sites marked alwaysinline.
+func addAlwaysInlinerPass(pm llvm.PassManager) {
+	C.LLVMAddAlwaysInlinerPass(C.LLVMPassManagerRef(unsafe.Pointer(pm.C)))
+}
@@ -1,10 +1,15 @@
 package transform
 
 import (
+	"strconv"
+	"strings"
+
 	"tinygo.org/x/go-llvm"
 )
 
-// OptimizeMaps eliminates created but unused maps.
+// OptimizeMaps eliminates created but unused maps, and replaces lookups in
+// maps that are only filled with constant keys and values (such as map
+// literals) with the looked up value.
 //
 // In the future, this should statically allocate created but never modified
 // maps. This has not yet been implemented, however.
@@ -17,8 +22,14 @@ func OptimizeMaps(mod llvm.Module) {
 
 	hashmapBinarySet := mod.NamedFunction("runtime.hashmapBinarySet")
 	hashmapStringSet := mod.NamedFunction("runtime.hashmapStringSet")
+	hashmapBinaryGet := mod.NamedFunction("runtime.hashmapBinaryGet")
+	hashmapStringGet := mod.NamedFunction("runtime.hashmapStringGet")
+	builder := mod.Context().NewBuilder()
+	defer builder.Dispose()
 
 	for _, makeInst := range getUses(hashmapMake) {
+		foldMapLookups(builder, makeInst, hashmapBinarySet, hashmapStringSet, hashmapBinaryGet, hashmapStringGet)
+
 		updateInsts := []llvm.Value{}
 		unknownUses := false // are there any uses other than setting a value?
 
@@ -45,3 +56,233 @@ func OptimizeMaps(mod llvm.Module) {
 		}
 	}
 }
+
+// foldMapLookups replaces lookups with a constant key in the map created by
+// makeInst with the value stored in the map, if that value is known at compile
+// time. This is the case when the map is only filled with constant keys and
+// values directly after it is created, and lookups happen before the map is
+// modified in any other way.
+//
+// If all lookups are replaced this way, the map will usually only be created
+// and filled but never read, so it can be eliminated entirely afterwards.
+func foldMapLookups(builder llvm.Builder, makeInst, binarySet, stringSet, binaryGet, stringGet llvm.Value) {
+	// The map must not be used by anything other than the set and get
+	// functions, otherwise it may be modified in ways that can't be seen here.
+	numSets := 0
+	for _, use := range getUses(makeInst) {
+		if use.IsACallInst().IsNil() || use.Operand(0) != makeInst {
+			return
+		}
+		switch use.CalledValue() {
+		case binarySet, stringSet:
+			numSets++
+		case binaryGet, stringGet:
+		default:
+			return
+		}
+	}
+
+	// Walk through the basic block that creates the map, tracking the
+	// contents of the map as it is filled. Lookups are folded until there is
+	// a write that cannot be tracked or that happens after the map has
+	// already been read (which means it is not just a map literal).
+	contents := map[string]llvm.Value{}
+	literalSets := 0
+	filled := false   // true after the first lookup
+	modified := false // true when the contents are no longer known
+	var next llvm.Value
+	for inst := llvm.NextInstruction(makeInst); !inst.IsNil() && !modified; inst = next {
+		// Folding a lookup removes the call, so get the next instruction
+		// before that happens.
+		next = llvm.NextInstruction(inst)
+		if inst.IsACallInst().IsNil() || inst.Operand(0) != makeInst {
+			continue
+		}
+		switch inst.CalledValue() {
+		case binarySet, stringSet:
+			key, ok := getMapCallKey(inst, stringSet)
+			value := getStoredConstant(inst, inst.Operand(getMapCallValueIndex(inst, stringSet)))
+			if filled || !ok || value.IsNil() {
+				modified = true
+				break
+			}
+			contents[key] = value
+			literalSets++
+		case binaryGet, stringGet:
+			filled = true
+			foldMapLookup(builder, inst, contents, stringGet)
+		}
+	}
+
+	if literalSets != numSets {
+		// There are writes to the map other than the ones that fill the map
+		// initially, so lookups outside of this basic block may not see the
+		// same contents.
+		return
+	}
+
+	// The map is never modified after it has been filled, so all lookups in
+	// other basic blocks (which must come after the map is filled) can be
+	// folded too.
+	for _, use := range getUses(makeInst) {
+		switch use.CalledValue() {
+		case binaryGet, stringGet:
+			if use.InstructionParent() != makeInst.InstructionParent() {
+				foldMapLookup(builder, use, contents, stringGet)
+			}
+		}
+	}
+}
+
+// foldMapLookup replaces a single hashmap get call with a store of the known
+// value (or the zero value, if the key is not present) into the output value
+// pointer.
+func foldMapLookup(builder llvm.Builder, call llvm.Value, contents map[string]llvm.Value, stringGet llvm.Value) {
+	key, ok := getMapCallKey(call, stringGet)
+	if !ok {
+		return
+	}
+	alloca := call.Operand(getMapCallValueIndex(call, stringGet))
+	if !alloca.IsABitCastInst().IsNil() {
+		alloca = alloca.Operand(0)
+	}
+	if alloca.IsAAllocaInst().IsNil() {
+		return
+	}
+	valueType := alloca.Type().ElementType()
+	value, found := contents[key]
+	if !found {
+		// A lookup of a key that doesn't exist returns the zero value.
+		value = llvm.ConstNull(valueType)
+	} else if value.Type() != valueType {
+		return
+	}
+	builder.SetInsertPointBefore(call)
+	builder.CreateStore(value, alloca)
+	commaOk := uint64(0)
+	if found {
+		commaOk = 1
+	}
+	call.ReplaceAllUsesWith(llvm.ConstInt(call.Type(), commaOk, false))
+	call.EraseFromParentAsInstruction()
+}
+
+// getMapCallValueIndex returns the operand index of the value pointer in a
+// hashmap get or set call.
+func getMapCallValueIndex(call, stringFn llvm.Value) int {
+	if call.CalledValue() == stringFn {
+		return 3 // map, key pointer, key length, value
+	}
+	return 2 // map, key, value
+}
+
+// getMapCallKey returns the contents of the key used in a hashmap get or set
+// call, if it is known at compile time. String keys are prefixed with "s" and
+// integer keys with "i" so that they can be compared by value, even when they
+// are stored in different globals.
+func getMapCallKey(call, stringFn llvm.Value) (string, bool) {
+	if call.CalledValue() != stringFn {
+		// Binary key: only integer keys that are stored to the key alloca
+		// just before the call are supported.
+		key := getStoredConstant(call, call.Operand(1))
+		if key.IsNil() || key.IsAConstantInt().IsNil() {
+			return "", false
+		}
+		return "i" + strconv.FormatUint(key.ZExtValue(), 10), true
+	}
+
+	// String key.
+	length := call.Operand(2)
+	if length.IsAConstantInt().IsNil() {
+		return "", false
+	}
+	n := length.ZExtValue()
+	if n == 0 {
+		return "s", true
+	}
+	ptr := call.Operand(1)
+	if ptr.IsAConstantExpr().IsNil() || ptr.Opcode() != llvm.GetElementPtr {
+		return "", false
+	}
+	global := ptr.Operand(0).IsAGlobalVariable()
+	if global.IsNil() || !global.IsGlobalConstant() || global.IsDeclaration() {
+		return "", false
+	}
+	for i := 1; i < ptr.OperandsCount(); i++ {
+		index := ptr.Operand(i)
+		if index.IsAConstantInt().IsNil() || index.ZExtValue() != 0 {
+			// Not a pointer to the start of the string.
+			return "", false
+		}
+	}
+	init := global.Initializer()
+	if init.Type().TypeKind() != llvm.ArrayTypeKind || uint64(init.Type().ArrayLength()) < n {
+		return "", false
+	}
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = byte(llvm.ConstExtractValue(init, []uint32{uint32(i)}).ZExtValue())
+	}
+	return "s" + string(buf), true
+}
+
+// getStoredConstant returns the constant that is stored to the alloca ptr
+// points to before it is passed to the given hashmap call. It returns nil if
+// the value is not known, for example because the alloca is stored to in
+// multiple places.
+func getStoredConstant(call, ptr llvm.Value) llvm.Value {
+	alloca := ptr
+	if !alloca.IsABitCastInst().IsNil() {
+		alloca = alloca.Operand(0)
+	}
+	if alloca.IsAAllocaInst().IsNil() {
+		return llvm.Value{}
+	}
+
+	// Check that the alloca is only written once, and that the pointer is only
+	// passed to calls that don't modify it.
+	var store llvm.Value
+	for _, use := range getUses(alloca) {
+		switch {
+		case !use.IsAStoreInst().IsNil():
+			if !store.IsNil() || use.Operand(1) != alloca || !use.Operand(0).IsConstant() {
+				return llvm.Value{}
+			}
+			store = use
+		case !use.IsALoadInst().IsNil():
+			// Loads don't modify the value.
+		case !use.IsABitCastInst().IsNil():
+			for _, bitcastUse := range getUses(use) {
+				if bitcastUse.IsACallInst().IsNil() {
+					return llvm.Value{}
+				}
+				if bitcastUse == call {
+					// The hashmap functions only read the key and the value
+					// of a set call.
+					continue
+				}
+				fn := bitcastUse.CalledValue()
+				if fn.IsAFunction().IsNil() {
+					return llvm.Value{}
+				}
+				if strings.HasPrefix(fn.Name(), "llvm.lifetime.") || hasFlag(bitcastUse, use, "readonly") {
+					continue
+				}
+				return llvm.Value{}
+			}
+		default:
+			return llvm.Value{}
+		}
+	}
+	if store.IsNil() || store.InstructionParent() != call.InstructionParent() {
+		return llvm.Value{}
+	}
+
+	// The store must happen before the call.
+	for inst := llvm.PrevInstruction(call); !inst.IsNil(); inst = llvm.PrevInstruction(inst) {
+		if inst == store {
+			return store.Operand(0)
+		}
+	}
+	return llvm.Value{}
+}
@@ -0,0 +1,54 @@
+package transform
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// mutexMethods are all methods of sync.Mutex and sync.RWMutex that only have
+// an effect when there are multiple goroutines.
+var mutexMethods = []string{
+	"(*sync.Mutex).Lock",
+	"(*sync.Mutex).Unlock",
+	"(*sync.RWMutex).Lock",
+	"(*sync.RWMutex).Unlock",
+	"(*sync.RWMutex).RLock",
+	"(*sync.RWMutex).RUnlock",
+}
+
+// OptimizeMutexes removes all calls to lock and unlock a sync.Mutex or
+// sync.RWMutex in programs that never start a goroutine. In such a program, a
+// mutex can never be contended, so locking and unlocking it has no effect
+// (apart from detecting bugs like unlocking an unlocked mutex, which are not
+// detected anymore).
+//
+// Whether a goroutine is started is detected by looking for uses of
+// runtime.makeGoroutine (coroutine scheduler) or runtime.startGoroutine (task
+// scheduler), so this must be run before goroutine lowering. Dead functions
+// should be removed beforehand, as any remaining goroutine start (even in an
+// unused function) disables this optimization.
+//
+// Note that this only removes the calls, not the memory used by the mutex: the
+// layout of types containing a mutex has already been fixed at this point.
+func OptimizeMutexes(mod llvm.Module) {
+	for _, name := range []string{"runtime.makeGoroutine", "runtime.startGoroutine"} {
+		fn := mod.NamedFunction(name)
+		if !fn.IsNil() && !fn.FirstUse().IsNil() {
+			// The program may start a goroutine, so mutexes are needed.
+			return
+		}
+	}
+
+	for _, name := range mutexMethods {
+		fn := mod.NamedFunction(name)
+		if fn.IsNil() {
+			continue
+		}
+		for _, call := range getUses(fn) {
+			if call.IsACallInst().IsNil() || call.CalledValue() != fn {
+				// Not a call, for example a method value.
+				continue
+			}
+			call.EraseFromParentAsInstruction()
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestOptimizeMutexes(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/mutex", OptimizeMutexes)
+}
+
+func TestOptimizeMutexesGoroutine(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/mutex-goroutine", OptimizeMutexes)
+}
@@ -0,0 +1,153 @@
+package transform
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// RemoveDeadPanics removes panic blocks (basic blocks ending in an unreachable
+// instruction, usually after a call to a runtime panic function) that are not
+// reachable anymore. After inlining and constant propagation, a condition
+// guarding a panic is often known to be false, for example in:
+//
+//     var array [16]byte
+//     getElement(array[:], 3) // bounds check in getElement is never true
+//
+// The branch on this constant condition is replaced with an unconditional
+// branch to the non-panic block and the panic block is removed. Strings that
+// were only used as the panic message in these blocks are removed as well, to
+// reduce code size.
+func RemoveDeadPanics(mod llvm.Module) {
+	builder := mod.Context().NewBuilder()
+	defer builder.Dispose()
+
+	// Globals that were referenced from removed panic blocks. They may be
+	// unused now.
+	var globals []llvm.Value
+
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+
+		// Find all panic blocks that are unreachable, or that are only
+		// reachable through a branch on a constant condition that is never
+		// taken. Do this before modifying the function, to not disturb
+		// iteration over the basic blocks.
+		var deadBlocks []llvm.BasicBlock
+		for bb := llvm.NextBasicBlock(fn.EntryBasicBlock()); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			if isPanicBlock(bb) && bb.AsValue().FirstUse().IsNil() {
+				deadBlocks = append(deadBlocks, bb)
+			}
+		}
+		var branches []llvm.Value
+		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			br := bb.LastInstruction()
+			if br.IsABranchInst().IsNil() || br.OperandsCount() != 3 || br.Operand(0).IsAConstantInt().IsNil() {
+				continue
+			}
+			branches = append(branches, br)
+		}
+
+		for _, br := range branches {
+			// Operand 1 is the block taken when the condition is false,
+			// operand 2 when it is true.
+			live, dead := br.Operand(1), br.Operand(2)
+			if br.Operand(0).ZExtValue() != 0 {
+				live, dead = dead, live
+			}
+			deadBlock := dead.AsBasicBlock()
+			uses := getUses(dead)
+			if live == dead || !isPanicBlock(deadBlock) || len(uses) != 1 || uses[0] != br {
+				// Only remove the panic block if this branch is the only
+				// way to reach it. Otherwise there may be PHI nodes to
+				// update.
+				continue
+			}
+			builder.SetInsertPointBefore(br)
+			builder.CreateBr(live.AsBasicBlock())
+			br.EraseFromParentAsInstruction()
+			deadBlocks = append(deadBlocks, deadBlock)
+		}
+
+		for _, bb := range deadBlocks {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				for i := 0; i < inst.OperandsCount(); i++ {
+					globals = appendReferencedGlobals(globals, inst.Operand(i))
+				}
+			}
+			bb.EraseFromParent()
+		}
+	}
+
+	// Remove panic messages (and other constants) that are not used anymore.
+	// Removing a global may make other globals unused, for example the string
+	// data referenced from a string header that was passed to panic().
+	for len(globals) != 0 {
+		global := globals[len(globals)-1]
+		globals = globals[:len(globals)-1]
+		if global.IsNil() || !isUnusedConstantGlobal(global) {
+			continue
+		}
+		initializer := global.Initializer()
+		if !initializer.IsNil() {
+			globals = appendReferencedGlobals(globals, initializer)
+		}
+		// There may still be (unused) constant expressions referencing this
+		// global, so replace them before removing it.
+		global.ReplaceAllUsesWith(llvm.Undef(global.Type()))
+		global.EraseFromParentAsGlobal()
+		// The same global may be in the list multiple times, so make sure it
+		// isn't removed twice.
+		for i := range globals {
+			if globals[i] == global {
+				globals[i] = llvm.Value{}
+			}
+		}
+	}
+}
+
+// isPanicBlock returns whether the given basic block ends in an unreachable
+// instruction, which means it doesn't return (usually because it panics).
+func isPanicBlock(bb llvm.BasicBlock) bool {
+	return !bb.LastInstruction().IsAUnreachableInst().IsNil()
+}
+
+// appendReferencedGlobals appends all global variables referenced by the given
+// value (directly or through a constant expression) to the globals slice.
+func appendReferencedGlobals(globals []llvm.Value, value llvm.Value) []llvm.Value {
+	switch {
+	case !value.IsAGlobalVariable().IsNil():
+		return append(globals, value)
+	case !value.IsAConstant().IsNil() && value.IsAGlobalValue().IsNil():
+		for i := 0; i < value.OperandsCount(); i++ {
+			globals = appendReferencedGlobals(globals, value.Operand(i))
+		}
+	}
+	return globals
+}
+
+// isUnusedConstantGlobal returns whether the given global is a constant that is
+// not visible outside of this module and that has no uses, except for constant
+// expressions that are not used either.
+func isUnusedConstantGlobal(global llvm.Value) bool {
+	if !global.IsGlobalConstant() {
+		return false
+	}
+	switch global.Linkage() {
+	case llvm.InternalLinkage, llvm.PrivateLinkage:
+	default:
+		return false
+	}
+	return isUnusedConstant(global)
+}
+
+// isUnusedConstant returns whether the given constant is not used by any
+// instruction or global, possibly through other constant expressions.
+func isUnusedConstant(value llvm.Value) bool {
+	for _, use := range getUses(value) {
+		if use.IsAConstantExpr().IsNil() || !isUnusedConstant(use) {
+			return false
+		}
+	}
+	return true
+}
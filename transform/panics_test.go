@@ -0,0 +1,10 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestRemoveDeadPanics(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/panics", RemoveDeadPanics)
+}
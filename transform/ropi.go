@@ -0,0 +1,183 @@
+package transform
+
+// This file prepares global variables for read-only position independent code
+// (ROPI), used by -pic=ropi. With ROPI, code and read-only data (flash) may be
+// loaded at a different address than the one the program was linked for, while
+// read-write data (RAM) stays at the same address. LLVM makes sure that code
+// only refers to functions and constant globals PC-relatively, but it doesn't
+// do anything about pointers in the initializers of global variables: they
+// still contain the address at link time. These pointers are fixed up at
+// startup by the runtime, using a table created here.
+
+import (
+	"errors"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// ropiRelocSection is the section of the relocation table, see targets/arm.ld
+// and src/runtime/ropi_cortexm.go.
+const ropiRelocSection = ".ropi_relocs"
+
+// RelocateROPIGlobals makes sure all pointers in global initializers that point
+// to flash (functions and constant globals) can be relocated at startup. It
+// does this in two steps:
+//
+//   - Constant globals that contain such pointers are made writable, so that
+//     they are placed in RAM. Flash can't be modified at runtime, so these
+//     pointers would otherwise be wrong when the program is not loaded at the
+//     address it was linked for.
+//   - A table is created (in the .ropi_relocs section) with the address of
+//     every pointer in a writable global that points to flash. The runtime
+//     adds the load offset to each of them after initializing .data.
+//
+// An error is returned for pointers that can't be relocated this way, for
+// example pointers to flash in a global with an explicit section (which may be
+// in flash) or in a constant expression that is not a simple pointer cast.
+// This transform must be run after all other transforms, as it relies on the
+// final set of globals and their initializers.
+func RelocateROPIGlobals(mod llvm.Module) error {
+	ctx := mod.Context()
+	targetData := llvm.NewTargetData(mod.DataLayout())
+	defer targetData.Dispose()
+	i8ptrType := llvm.PointerType(ctx.Int8Type(), 0)
+	uintptrType := ctx.IntType(targetData.PointerSize() * 8)
+
+	// Move constant globals with pointers to flash to RAM.
+	var globals []llvm.Value
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		initializer := global.Initializer()
+		if initializer.IsNil() {
+			continue // external global, not defined in this module
+		}
+		globals = append(globals, global)
+		if !global.IsGlobalConstant() {
+			continue
+		}
+		offsets, err := ropiRelocOffsets(targetData, initializer, 0, nil)
+		if err != nil {
+			return errors.New(global.Name() + ": " + err.Error())
+		}
+		if len(offsets) != 0 {
+			if global.Section() != "" {
+				return errors.New(global.Name() + ": cannot relocate pointers in a constant global in section " + global.Section())
+			}
+			global.SetGlobalConstant(false)
+		}
+	}
+
+	// Create the relocation table with the address of every pointer to flash
+	// in a (now) writable global.
+	var relocs []llvm.Value
+	for _, global := range globals {
+		if global.IsGlobalConstant() {
+			continue // no pointers to flash, checked above
+		}
+		offsets, err := ropiRelocOffsets(targetData, global.Initializer(), 0, nil)
+		if err != nil {
+			return errors.New(global.Name() + ": " + err.Error())
+		}
+		if len(offsets) != 0 && global.Section() != "" {
+			// Explicit sections are used for special purposes, such as
+			// .noinit which is not initialized at startup.
+			return errors.New(global.Name() + ": cannot relocate pointers in a global in section " + global.Section())
+		}
+		for _, offset := range offsets {
+			reloc := llvm.ConstBitCast(global, i8ptrType)
+			reloc = llvm.ConstGEP(reloc, []llvm.Value{llvm.ConstInt(uintptrType, offset, false)})
+			relocs = append(relocs, reloc)
+		}
+	}
+	if len(relocs) == 0 {
+		return nil
+	}
+	table := llvm.ConstArray(i8ptrType, relocs)
+	// The table is only referenced from the linker script, which keeps it
+	// with KEEP(*(.ropi_relocs)).
+	global := llvm.AddGlobal(mod, table.Type(), "tinygo_ropiRelocs")
+	global.SetInitializer(table)
+	global.SetGlobalConstant(true)
+	global.SetSection(ropiRelocSection)
+	global.SetAlignment(targetData.PointerSize())
+	return nil
+}
+
+// ropiRelocOffsets appends the offset (relative to the start of the global) of
+// every pointer to flash in the given initializer to offsets.
+func ropiRelocOffsets(targetData llvm.TargetData, value llvm.Value, offset uint64, offsets []uint64) ([]uint64, error) {
+	if !value.IsAConstantStruct().IsNil() {
+		for i := 0; i < value.OperandsCount(); i++ {
+			var err error
+			fieldOffset := offset + targetData.ElementOffset(value.Type(), i)
+			offsets, err = ropiRelocOffsets(targetData, value.Operand(i), fieldOffset, offsets)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return offsets, nil
+	}
+	if !value.IsAConstantArray().IsNil() {
+		elementSize := targetData.TypeAllocSize(value.Type().ElementType())
+		for i := 0; i < value.OperandsCount(); i++ {
+			var err error
+			offsets, err = ropiRelocOffsets(targetData, value.Operand(i), offset+uint64(i)*elementSize, offsets)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return offsets, nil
+	}
+	if value.IsAConstantExpr().IsNil() && value.IsAGlobalValue().IsNil() {
+		// Integers, floats, strings (i8 arrays), null, undef, etc.
+		return offsets, nil
+	}
+
+	// This is a pointer, or an integer that was created from a pointer with
+	// ptrtoint. Find the global it is based upon.
+	base := value
+	for !base.IsAConstantExpr().IsNil() {
+		switch base.Opcode() {
+		case llvm.BitCast, llvm.GetElementPtr, llvm.PtrToInt, llvm.IntToPtr:
+			base = base.Operand(0)
+		default:
+			if ropiReferencesFlash(base) {
+				return nil, errors.New("cannot relocate pointer in constant expression")
+			}
+			return offsets, nil
+		}
+	}
+	if !isFlashGlobal(base) {
+		return offsets, nil
+	}
+	if targetData.TypeAllocSize(value.Type()) != uint64(targetData.PointerSize()) {
+		return nil, errors.New("cannot relocate pointer that was truncated or extended")
+	}
+	return append(offsets, offset), nil
+}
+
+// ropiReferencesFlash returns whether the given constant refers to a function
+// or a constant global anywhere in its operands.
+func ropiReferencesFlash(value llvm.Value) bool {
+	if isFlashGlobal(value) {
+		return true
+	}
+	if value.IsAConstantExpr().IsNil() {
+		return false
+	}
+	for i := 0; i < value.OperandsCount(); i++ {
+		if ropiReferencesFlash(value.Operand(i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFlashGlobal returns whether the given value is a function or a constant
+// global, which are both placed in flash. External globals (such as linker
+// symbols) are assumed to be in RAM.
+func isFlashGlobal(value llvm.Value) bool {
+	if !value.IsAFunction().IsNil() {
+		return true
+	}
+	return !value.IsAGlobalVariable().IsNil() && value.IsGlobalConstant() && !value.Initializer().IsNil()
+}
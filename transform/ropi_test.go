@@ -0,0 +1,17 @@
+package transform
+
+import (
+	"testing"
+
+	"tinygo.org/x/go-llvm"
+)
+
+func TestRelocateROPIGlobals(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/ropi", func(mod llvm.Module) {
+		err := RelocateROPIGlobals(mod)
+		if err != nil {
+			t.Error("failed to relocate globals:", err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package transform
+
+import (
+	"strconv"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// OptimizeSliceCopy lowers calls to runtime.sliceCopy (the copy builtin) to the
+// llvm.memmove intrinsic, so that the optimizer and the backend can generate
+// better code for it. The source and destination of a copy may overlap, for
+// example in:
+//
+//     copy(buf[1:], buf)
+//
+// so memmove semantics are required. Only when the source and destination are
+// proven not to overlap (because they point to different objects) is the copy
+// lowered to llvm.memcpy instead.
+func OptimizeSliceCopy(mod llvm.Module) {
+	sliceCopy := mod.NamedFunction("runtime.sliceCopy")
+	if sliceCopy.IsNil() {
+		// nothing to optimize
+		return
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+
+	for _, call := range getUses(sliceCopy) {
+		if call.IsACallInst().IsNil() || call.CalledValue() != sliceCopy {
+			continue
+		}
+		dst := call.Operand(0)
+		src := call.Operand(1)
+		dstLen := call.Operand(2)
+		srcLen := call.Operand(3)
+		elemSize := call.Operand(4)
+
+		// n = min(srcLen, dstLen)
+		builder.SetInsertPointBefore(call)
+		isSmaller := builder.CreateICmp(llvm.IntULT, srcLen, dstLen, "")
+		n := builder.CreateSelect(isSmaller, srcLen, dstLen, "copy.n")
+		size := builder.CreateMul(n, elemSize, "copy.size")
+
+		name := "llvm.memmove"
+		if !mayOverlap(dst, src) {
+			name = "llvm.memcpy"
+		}
+		fn := getMemcpyIntrinsic(mod, name, dst.Type(), size.Type())
+		isVolatile := llvm.ConstInt(ctx.Int1Type(), 0, false)
+		builder.CreateCall(fn, []llvm.Value{dst, src, size, isVolatile}, "")
+
+		call.ReplaceAllUsesWith(n)
+		call.EraseFromParentAsInstruction()
+	}
+}
+
+// getMemcpyIntrinsic returns the llvm.memcpy or llvm.memmove intrinsic (given
+// in name) for the given pointer and length type, declaring it if necessary.
+func getMemcpyIntrinsic(mod llvm.Module, name string, ptrType, lenType llvm.Type) llvm.Value {
+	name += ".p0i8.p0i8.i" + strconv.Itoa(lenType.IntTypeWidth())
+	fn := mod.NamedFunction(name)
+	if fn.IsNil() {
+		ctx := mod.Context()
+		fnType := llvm.FunctionType(ctx.VoidType(), []llvm.Type{ptrType, ptrType, lenType, ctx.Int1Type()}, false)
+		fn = llvm.AddFunction(mod, name, fnType)
+	}
+	return fn
+}
+
+// mayOverlap returns false if the two pointers are known to point into
+// different objects (and thus the memory they point to cannot overlap), and
+// true otherwise.
+func mayOverlap(ptr1, ptr2 llvm.Value) bool {
+	obj1 := getUnderlyingObject(ptr1)
+	obj2 := getUnderlyingObject(ptr2)
+	if obj1.IsNil() || obj2.IsNil() {
+		// Not known where these pointers point to.
+		return true
+	}
+	return obj1 == obj2
+}
+
+// getUnderlyingObject returns the object (alloca, global or heap allocation)
+// the given pointer points into, or nil if it is not known.
+func getUnderlyingObject(ptr llvm.Value) llvm.Value {
+	for {
+		switch {
+		case !ptr.IsAAllocaInst().IsNil(), !ptr.IsAGlobalVariable().IsNil():
+			return ptr
+		case !ptr.IsACallInst().IsNil():
+			if ptr.CalledValue().Name() == "runtime.alloc" {
+				// Every heap allocation is a new object.
+				return ptr
+			}
+			return llvm.Value{}
+		case !ptr.IsABitCastInst().IsNil(), !ptr.IsAGetElementPtrInst().IsNil():
+			ptr = ptr.Operand(0)
+		case !ptr.IsAConstantExpr().IsNil():
+			switch ptr.Opcode() {
+			case llvm.BitCast, llvm.GetElementPtr:
+				ptr = ptr.Operand(0)
+			default:
+				return llvm.Value{}
+			}
+		default:
+			return llvm.Value{}
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestOptimizeSliceCopy(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/slicecopy", OptimizeSliceCopy)
+}
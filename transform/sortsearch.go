@@ -0,0 +1,221 @@
+package transform
+
+// This file specializes calls to sort.Search with a simple predicate, such as:
+//
+//     i := sort.Search(len(a), func(i int) bool { return a[i] >= x })
+//
+// sort.Search calls the predicate through a func value in every step of the
+// binary search. The predicate is a different closure at every call site, so
+// the call can't be made direct (or be inlined) in sort.Search itself, and the
+// call overhead is often larger than the comparison. Here, the binary search
+// loop is duplicated for the predicate and inlined into the caller, together
+// with the predicate.
+
+import (
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// maxSearchPredicateSize is the maximum number of instructions of a predicate
+// that is inlined by SpecializeSortSearch. Larger predicates are left alone, as
+// inlining them (and the loop) at every call site would increase code size
+// more than it saves in call overhead.
+const maxSearchPredicateSize = 20
+
+// SpecializeSortSearch replaces calls to sort.Search with a constant predicate
+// by a binary search loop in the caller, with the predicate inlined. The
+// predicate must be trivial: a single comparison or so, without loops, stores
+// or calls (except for calls that panic, like bounds checks). Calls with other
+// predicates are left alone.
+//
+// This transform must be run before LowerFuncValues, as it needs to know which
+// function a func value points to.
+func SpecializeSortSearch(mod llvm.Module) {
+	search := mod.NamedFunction("sort.Search")
+	if search.IsNil() || search.ParamsCount() != 5 {
+		// sort.Search isn't used, or it has an unexpected signature. It has
+		// the parameters n, the predicate as context and function pointer (or
+		// function ID), and the context and parent handle of any Go function.
+		return
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+	alwaysInline := ctx.CreateEnumAttribute(llvm.AttributeKindID("alwaysinline"), 0)
+
+	specialized := map[llvm.Value]llvm.Value{} // predicate -> search loop
+	for _, call := range getUses(search) {
+		if call.IsACallInst().IsNil() || call.CalledValue() != search {
+			continue
+		}
+		n := call.Operand(0)
+		predicate := searchPredicate(call.Operand(2))
+		if predicate.IsNil() || !isSimpleSearchPredicate(predicate, n.Type()) {
+			continue
+		}
+		loop, ok := specialized[predicate]
+		if !ok {
+			loop = createSearchLoop(mod, builder, predicate, alwaysInline)
+			specialized[predicate] = loop
+		}
+
+		// sort.Search(n, f) -> f$search(n, f.context)
+		builder.SetInsertPointBefore(call)
+		direct := builder.CreateCall(loop, []llvm.Value{n, call.Operand(1)}, "")
+		call.ReplaceAllUsesWith(direct)
+		call.EraseFromParentAsInstruction()
+	}
+	if len(specialized) == 0 {
+		return
+	}
+
+	// Inline the predicates into the loops and the loops into the callers.
+	// The loops are removed by the inliner afterwards.
+	inlinePasses := llvm.NewPassManager()
+	defer inlinePasses.Dispose()
+	addAlwaysInlinerPass(inlinePasses)
+	inlinePasses.Run(mod)
+}
+
+// searchPredicate returns the function that is called by a func value with the
+// given function pointer, or nil if it is not known. With the doubleword func
+// value implementation, this is a (possibly bitcast) function pointer. With
+// the switch implementation, it is a pointer (converted to an integer) to a
+// constant runtime.funcValueWithSignature global that contains the function
+// pointer (see compiler/func.go).
+func searchPredicate(funcPtr llvm.Value) llvm.Value {
+	if !funcPtr.IsAConstantExpr().IsNil() && funcPtr.Opcode() == llvm.PtrToInt {
+		global := funcPtr.Operand(0)
+		if global.IsAGlobalVariable().IsNil() || !strings.HasSuffix(global.Name(), "$withSignature") || global.Initializer().IsNil() {
+			return llvm.Value{}
+		}
+		funcPtr = llvm.ConstExtractValue(global.Initializer(), []uint32{0})
+		if funcPtr.IsAConstantExpr().IsNil() || funcPtr.Opcode() != llvm.PtrToInt {
+			return llvm.Value{}
+		}
+		funcPtr = funcPtr.Operand(0)
+	}
+	for !funcPtr.IsAConstantExpr().IsNil() && funcPtr.Opcode() == llvm.BitCast {
+		funcPtr = funcPtr.Operand(0)
+	}
+	if funcPtr.IsAFunction().IsNil() {
+		return llvm.Value{}
+	}
+	return funcPtr
+}
+
+// isSimpleSearchPredicate returns whether the given function can be inlined in
+// a specialized binary search loop: it has the signature of a func(int) bool
+// and a small body without loops, stores or calls that return. Calls that are
+// followed by unreachable (runtime.lookupPanic etc.) are allowed, so that
+// predicates with a bounds check are inlined too.
+func isSimpleSearchPredicate(fn llvm.Value, intType llvm.Type) bool {
+	if fn.IsDeclaration() || fn.ParamsCount() != 3 || fn.Param(0).Type() != intType {
+		return false
+	}
+	if returnType := fn.Type().ElementType().ReturnType(); returnType.TypeKind() != llvm.IntegerTypeKind || returnType.IntTypeWidth() != 1 {
+		return false
+	}
+
+	// Number the basic blocks, to check that all branches go forward.
+	blocks := map[llvm.BasicBlock]int{}
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		blocks[bb] = len(blocks)
+	}
+	size := 0
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			if !inst.IsACallInst().IsNil() {
+				callee := inst.CalledValue()
+				if !callee.IsAFunction().IsNil() && strings.HasPrefix(callee.Name(), "llvm.dbg.") {
+					continue // debug info only, doesn't generate code
+				}
+			}
+			size++
+			if size > maxSearchPredicateSize {
+				return false
+			}
+			switch inst.InstructionOpcode() {
+			case llvm.Store, llvm.Alloca, llvm.Invoke, llvm.Switch, llvm.IndirectBr:
+				return false
+			case llvm.Call:
+				next := llvm.NextInstruction(inst)
+				if next.IsNil() || next.InstructionOpcode() != llvm.Unreachable {
+					return false // call that may return, or have side effects
+				}
+			case llvm.Br:
+				for i := 0; i < inst.OperandsCount(); i++ {
+					op := inst.Operand(i)
+					if !op.IsBasicBlock() {
+						continue // condition
+					}
+					if blocks[op.AsBasicBlock()] <= blocks[bb] {
+						return false // loop
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// createSearchLoop creates a copy of sort.Search that calls the given predicate
+// directly. The function and the call to the predicate are marked
+// alwaysinline. The loop is the
+// same as in the Go standard library:
+//
+//     func Search(n int, f func(int) bool) int {
+//         i, j := 0, n
+//         for i < j {
+//             h := int(uint(i+j) >> 1)
+//             if !f(h) {
+//                 i = h + 1
+//             } else {
+//                 j = h
+//             }
+//         }
+//         return i
+//     }
+func createSearchLoop(mod llvm.Module, builder llvm.Builder, predicate llvm.Value, alwaysInline llvm.Attribute) llvm.Value {
+	ctx := mod.Context()
+	intType := predicate.Param(0).Type()
+	i8ptrType := predicate.Param(1).Type()
+	fnType := llvm.FunctionType(intType, []llvm.Type{intType, i8ptrType}, false)
+	fn := llvm.AddFunction(mod, predicate.Name()+"$search", fnType)
+	fn.SetLinkage(llvm.InternalLinkage)
+	fn.AddFunctionAttr(alwaysInline)
+	n := fn.Param(0)
+	n.SetName("n")
+	context := fn.Param(1)
+	context.SetName("context")
+
+	entry := ctx.AddBasicBlock(fn, "entry")
+	loop := ctx.AddBasicBlock(fn, "for.loop")
+	body := ctx.AddBasicBlock(fn, "for.body")
+	done := ctx.AddBasicBlock(fn, "for.done")
+	builder.SetInsertPointAtEnd(entry)
+	builder.CreateBr(loop)
+
+	builder.SetInsertPointAtEnd(loop)
+	i := builder.CreatePHI(intType, "i")
+	j := builder.CreatePHI(intType, "j")
+	builder.CreateCondBr(builder.CreateICmp(llvm.IntSLT, i, j, "cmp"), body, done)
+
+	builder.SetInsertPointAtEnd(body)
+	one := llvm.ConstInt(intType, 1, false)
+	h := builder.CreateLShr(builder.CreateAdd(i, j, "sum"), one, "h")
+	found := builder.CreateCall(predicate, []llvm.Value{h, context, llvm.Undef(i8ptrType)}, "found")
+	found.AddCallSiteAttribute(-1, alwaysInline) // -1 is the function index
+	nextI := builder.CreateSelect(found, i, builder.CreateAdd(h, one, "h.next"), "i.next")
+	nextJ := builder.CreateSelect(found, h, j, "j.next")
+	builder.CreateBr(loop)
+
+	i.AddIncoming([]llvm.Value{llvm.ConstInt(intType, 0, false), nextI}, []llvm.BasicBlock{entry, body})
+	j.AddIncoming([]llvm.Value{n, nextJ}, []llvm.BasicBlock{entry, body})
+
+	builder.SetInsertPointAtEnd(done)
+	builder.CreateRet(i)
+	return fn
+}
@@ -0,0 +1,21 @@
+package transform
+
+import (
+	"testing"
+
+	"tinygo.org/x/go-llvm"
+)
+
+func TestSpecializeSortSearch(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/sortsearch", func(mod llvm.Module) {
+		SpecializeSortSearch(mod)
+	})
+}
+
+func TestSpecializeSortSearchSwitch(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/sortsearch-switch", func(mod llvm.Module) {
+		SpecializeSortSearch(mod)
+	})
+}
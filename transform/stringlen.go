@@ -0,0 +1,120 @@
+package transform
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// OptimizeStringLength replaces the length of strings created by string
+// concatenation (runtime.stringConcat) and []byte to string conversion
+// (runtime.stringFromBytes) with the length of the input, so that it can be
+// constant folded. For example, the following is folded to a constant:
+//
+//     len(foo + "bar") // where foo is a constant string
+//
+// Additionally, if the resulting string is only used for its length, the call
+// that creates it (and the heap allocation it does) is removed.
+func OptimizeStringLength(mod llvm.Module) {
+	stringConcat := mod.NamedFunction("runtime.stringConcat")
+	stringFromBytes := mod.NamedFunction("runtime.stringFromBytes")
+	if stringConcat.IsNil() && stringFromBytes.IsNil() {
+		// nothing to optimize
+		return
+	}
+
+	builder := mod.Context().NewBuilder()
+	defer builder.Dispose()
+
+	var calls []llvm.Value
+	for _, fn := range []llvm.Value{stringConcat, stringFromBytes} {
+		if fn.IsNil() {
+			continue
+		}
+		for _, call := range getUses(fn) {
+			if call.IsACallInst().IsNil() || call.CalledValue() != fn {
+				continue
+			}
+			calls = append(calls, call)
+		}
+	}
+
+	// Replace the length of all created strings with the calculated length.
+	for _, call := range calls {
+		for _, use := range getUses(call) {
+			if use.IsAExtractValueInst().IsNil() || use.Type().TypeKind() != llvm.IntegerTypeKind {
+				// Not a string length.
+				continue
+			}
+			use.ReplaceAllUsesWith(getStringLength(builder, call, stringConcat))
+			use.EraseFromParentAsInstruction()
+		}
+	}
+
+	// Remove calls that are not used anymore. Removing one call (such as the
+	// outer call of a nested string concatenation) may make other calls
+	// unused, so repeat until no more calls can be removed.
+	for changed := true; changed; {
+		changed = false
+		for i, call := range calls {
+			if call.IsNil() || !isUnusedString(call) {
+				continue
+			}
+			for _, use := range getUses(call) {
+				use.EraseFromParentAsInstruction()
+			}
+			call.EraseFromParentAsInstruction()
+			calls[i] = llvm.Value{}
+			changed = true
+		}
+	}
+}
+
+// getStringLength returns the length of the string returned by call, which is
+// a call to runtime.stringConcat or runtime.stringFromBytes. The returned value
+// is a constant if the length is known at compile time.
+func getStringLength(builder llvm.Builder, call, stringConcat llvm.Value) llvm.Value {
+	if call.CalledValue() != stringConcat {
+		// runtime.stringFromBytes(ptr, len, cap, ...)
+		return call.Operand(1)
+	}
+
+	// runtime.stringConcat(x.ptr, x.len, y.ptr, y.len, ...)
+	xlen := getStringOperandLength(builder, call.Operand(1), stringConcat)
+	ylen := getStringOperandLength(builder, call.Operand(3), stringConcat)
+	if xlen.IsConstant() && ylen.IsConstant() {
+		return llvm.ConstAdd(xlen, ylen)
+	}
+	builder.SetInsertPointBefore(call)
+	return builder.CreateAdd(xlen, ylen, "concat.len")
+}
+
+// getStringOperandLength returns the length of a string passed to
+// runtime.stringConcat. If this string was itself created by a call to
+// runtime.stringConcat or runtime.stringFromBytes, the length is calculated
+// from the input to that call instead.
+func getStringOperandLength(builder llvm.Builder, length, stringConcat llvm.Value) llvm.Value {
+	if length.IsAExtractValueInst().IsNil() {
+		return length
+	}
+	call := length.Operand(0)
+	if call.IsACallInst().IsNil() {
+		return length
+	}
+	switch call.CalledValue().Name() {
+	case "runtime.stringConcat", "runtime.stringFromBytes":
+		return getStringLength(builder, call, stringConcat)
+	default:
+		return length
+	}
+}
+
+// isUnusedString returns whether the string returned by the given call is not
+// used, except for extracting the pointer of the string which is then not used
+// either.
+func isUnusedString(call llvm.Value) bool {
+	for _, use := range getUses(call) {
+		if use.IsAExtractValueInst().IsNil() || !use.FirstUse().IsNil() {
+			return false
+		}
+	}
+	return true
+}
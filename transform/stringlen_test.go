@@ -0,0 +1,10 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestOptimizeStringLength(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/stringlen", OptimizeStringLength)
+}
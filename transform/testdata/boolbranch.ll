@@ -0,0 +1,110 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+declare void @foo()
+
+declare void @bar()
+
+; if !(a < b) { foo() } else { bar() }
+; The negation is folded into the branch by swapping the targets.
+define void @testNot(i32 %a, i32 %b) {
+entry:
+  %cmp = icmp ult i32 %a, %b
+  %not = xor i1 %cmp, true
+  br i1 %not, label %then, label %else
+
+then:
+  call void @foo()
+  ret void
+
+else:
+  call void @bar()
+  ret void
+}
+
+; A bool that was converted to a byte (for example after being loaded from
+; memory) is compared against zero.
+define void @testZext(i32 %a, i32 %b) {
+entry:
+  %cmp = icmp ult i32 %a, %b
+  %byte = zext i1 %cmp to i8
+  %isfalse = icmp eq i8 %byte, 0
+  br i1 %isfalse, label %then, label %else
+
+then:
+  call void @foo()
+  ret void
+
+else:
+  call void @bar()
+  ret void
+}
+
+; if a < b && c < d { foo() }
+; The && expression is split in two branches.
+define void @testAnd(i32 %a, i32 %b, i32 %c, i32 %d) {
+entry:
+  %cmp1 = icmp ult i32 %a, %b
+  %cmp2 = icmp ult i32 %c, %d
+  %and = and i1 %cmp1, %cmp2
+  br i1 %and, label %then, label %done
+
+then:
+  call void @foo()
+  br label %done
+
+done:
+  ret void
+}
+
+; x := 0; if a < b || !(c < d) { x = 1 }; return x
+; The || expression is split in two branches, and a new incoming edge is added
+; to the PHI node in the shared block.
+define i32 @testOr(i32 %a, i32 %b, i32 %c, i32 %d) {
+entry:
+  %cmp1 = icmp ult i32 %a, %b
+  %cmp2 = icmp ult i32 %c, %d
+  %not = xor i1 %cmp2, true
+  %or = or i1 %cmp1, %not
+  br i1 %or, label %done, label %else
+
+else:
+  br label %done
+
+done:
+  %x = phi i32 [ 1, %entry ], [ 0, %else ]
+  ret i32 %x
+}
+
+; The bool is stored, so it must be materialized anyway.
+define void @testStored(i32 %a, i32 %b, i1* %ptr) {
+entry:
+  %cmp = icmp ult i32 %a, %b
+  %not = xor i1 %cmp, true
+  store i1 %not, i1* %ptr
+  br i1 %not, label %then, label %else
+
+then:
+  call void @foo()
+  ret void
+
+else:
+  call void @bar()
+  ret void
+}
+
+; The bool is returned, so it must be materialized anyway.
+define i1 @testReturned(i32 %a, i32 %b, i32 %c, i32 %d) {
+entry:
+  %cmp1 = icmp ult i32 %a, %b
+  %cmp2 = icmp ult i32 %c, %d
+  %and = and i1 %cmp1, %cmp2
+  br i1 %and, label %then, label %done
+
+then:
+  call void @foo()
+  br label %done
+
+done:
+  ret i1 %and
+}
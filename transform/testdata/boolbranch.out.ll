@@ -0,0 +1,99 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+declare void @foo()
+
+declare void @bar()
+
+define void @testNot(i32 %a, i32 %b) {
+entry:
+  %cmp = icmp ult i32 %a, %b
+  br i1 %cmp, label %else, label %then
+
+then:                                             ; preds = %entry
+  call void @foo()
+  ret void
+
+else:                                             ; preds = %entry
+  call void @bar()
+  ret void
+}
+
+define void @testZext(i32 %a, i32 %b) {
+entry:
+  %cmp = icmp ult i32 %a, %b
+  br i1 %cmp, label %else, label %then
+
+then:                                             ; preds = %entry
+  call void @foo()
+  ret void
+
+else:                                             ; preds = %entry
+  call void @bar()
+  ret void
+}
+
+define void @testAnd(i32 %a, i32 %b, i32 %c, i32 %d) {
+entry:
+  %cmp1 = icmp ult i32 %a, %b
+  %cmp2 = icmp ult i32 %c, %d
+  br i1 %cmp1, label %entry.next, label %done
+
+entry.next:                                       ; preds = %entry
+  br i1 %cmp2, label %then, label %done
+
+then:                                             ; preds = %entry.next
+  call void @foo()
+  br label %done
+
+done:                                             ; preds = %entry.next, %entry, %then
+  ret void
+}
+
+define i32 @testOr(i32 %a, i32 %b, i32 %c, i32 %d) {
+entry:
+  %cmp1 = icmp ult i32 %a, %b
+  %cmp2 = icmp ult i32 %c, %d
+  br i1 %cmp1, label %done, label %entry.next
+
+entry.next:                                       ; preds = %entry
+  br i1 %cmp2, label %else, label %done
+
+else:                                             ; preds = %entry.next
+  br label %done
+
+done:                                             ; preds = %entry.next, %entry, %else
+  %x = phi i32 [ 1, %entry ], [ 0, %else ], [ 1, %entry.next ]
+  ret i32 %x
+}
+
+define void @testStored(i32 %a, i32 %b, i1* %ptr) {
+entry:
+  %cmp = icmp ult i32 %a, %b
+  %not = xor i1 %cmp, true
+  store i1 %not, i1* %ptr
+  br i1 %not, label %then, label %else
+
+then:                                             ; preds = %entry
+  call void @foo()
+  ret void
+
+else:                                             ; preds = %entry
+  call void @bar()
+  ret void
+}
+
+define i1 @testReturned(i32 %a, i32 %b, i32 %c, i32 %d) {
+entry:
+  %cmp1 = icmp ult i32 %a, %b
+  %cmp2 = icmp ult i32 %c, %d
+  %and = and i1 %cmp1, %cmp2
+  br i1 %and, label %then, label %done
+
+then:                                             ; preds = %entry
+  call void @foo()
+  br label %done
+
+done:                                             ; preds = %then, %entry
+  ret i1 %and
+}
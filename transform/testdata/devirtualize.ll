@@ -0,0 +1,122 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime._interface = type { i32, i8* }
+%main.countWriter = type { i32 }
+%main.prefixWriter = type { i32, %runtime._interface }
+%main.multiWriter = type { { %runtime._interface*, i32, i32 } }
+
+; A static chain, as created by the interp package:
+;     var logger Writer = &prefixWriter{prefix: 7, w: &multiWriter{
+;         writers: []Writer{&counter1, &counter2},
+;     }}
+@main.counter1 = internal global %main.countWriter zeroinitializer
+@main.counter2 = internal global %main.countWriter zeroinitializer
+@main.writers = internal global [2 x %runtime._interface] [%runtime._interface { i32 1, i8* bitcast (%main.countWriter* @main.counter1 to i8*) }, %runtime._interface { i32 1, i8* bitcast (%main.countWriter* @main.counter2 to i8*) }]
+@main.multi = internal global %main.multiWriter { { %runtime._interface*, i32, i32 } { %runtime._interface* getelementptr inbounds ([2 x %runtime._interface], [2 x %runtime._interface]* @main.writers, i32 0, i32 0), i32 2, i32 2 } }
+@main.prefix = internal global %main.prefixWriter { i32 7, %runtime._interface { i32 3, i8* bitcast (%main.multiWriter* @main.multi to i8*) } }
+@main.logger = internal global %runtime._interface { i32 2, i8* bitcast (%main.prefixWriter* @main.prefix to i8*) }
+
+; A writer that is assigned at runtime, in setDynamic.
+@main.dynamic = internal global %runtime._interface { i32 1, i8* bitcast (%main.countWriter* @main.counter1 to i8*) }
+
+define internal void @"(*main.countWriter).Write"(%main.countWriter* %w, i32 %n, i8* %context, i8* %parentHandle) {
+entry:
+  %count.ptr = getelementptr inbounds %main.countWriter, %main.countWriter* %w, i32 0, i32 0
+  %count = load i32, i32* %count.ptr
+  %count.new = add i32 %count, %n
+  store i32 %count.new, i32* %count.ptr
+  ret void
+}
+
+define internal void @"(*main.prefixWriter).Write"(%main.prefixWriter* %w, i32 %n, i8* %context, i8* %parentHandle) {
+entry:
+  %prefix.ptr = getelementptr inbounds %main.prefixWriter, %main.prefixWriter* %w, i32 0, i32 0
+  %prefix = load i32, i32* %prefix.ptr
+  %itf.ptr = getelementptr inbounds %main.prefixWriter, %main.prefixWriter* %w, i32 0, i32 1
+  %itf = load %runtime._interface, %runtime._interface* %itf.ptr
+  %typecode = extractvalue %runtime._interface %itf, 0
+  %value = extractvalue %runtime._interface %itf, 1
+  call void @"(main.Writer).Write"(i8* %value, i32 %prefix, i8* undef, i8* null, i32 %typecode)
+  call void @"(main.Writer).Write"(i8* %value, i32 %n, i8* undef, i8* null, i32 %typecode)
+  ret void
+}
+
+define internal void @"(*main.multiWriter).Write"(%main.multiWriter* %w, i32 %n, i8* %context, i8* %parentHandle) {
+entry:
+  %writers.ptr = getelementptr inbounds %main.multiWriter, %main.multiWriter* %w, i32 0, i32 0
+  %writers = load { %runtime._interface*, i32, i32 }, { %runtime._interface*, i32, i32 }* %writers.ptr
+  %writers.data = extractvalue { %runtime._interface*, i32, i32 } %writers, 0
+  %writers.len = extractvalue { %runtime._interface*, i32, i32 } %writers, 1
+  br label %loop.cond
+
+loop.cond:
+  %i = phi i32 [ 0, %entry ], [ %i.next, %loop.body ]
+  %done = icmp sge i32 %i, %writers.len
+  br i1 %done, label %loop.done, label %loop.body
+
+loop.body:
+  %itf.ptr = getelementptr inbounds %runtime._interface, %runtime._interface* %writers.data, i32 %i
+  %itf = load %runtime._interface, %runtime._interface* %itf.ptr
+  %typecode = extractvalue %runtime._interface %itf, 0
+  %value = extractvalue %runtime._interface %itf, 1
+  call void @"(main.Writer).Write"(i8* %value, i32 %n, i8* undef, i8* null, i32 %typecode)
+  %i.next = add i32 %i, 1
+  br label %loop.cond
+
+loop.done:
+  ret void
+}
+
+define internal void @"(main.Writer).Write"(i8* %0, i32 %1, i8* %2, i8* %3, i32 %actualType) unnamed_addr {
+entry:
+  switch i32 %actualType, label %default [
+    i32 1, label %"*main.countWriter"
+    i32 2, label %"*main.prefixWriter"
+    i32 3, label %"*main.multiWriter"
+  ]
+
+default:
+  unreachable
+
+"*main.countWriter":
+  %4 = bitcast i8* %0 to %main.countWriter*
+  call void @"(*main.countWriter).Write"(%main.countWriter* %4, i32 %1, i8* %2, i8* %3)
+  ret void
+
+"*main.prefixWriter":
+  %5 = bitcast i8* %0 to %main.prefixWriter*
+  call void @"(*main.prefixWriter).Write"(%main.prefixWriter* %5, i32 %1, i8* %2, i8* %3)
+  ret void
+
+"*main.multiWriter":
+  %6 = bitcast i8* %0 to %main.multiWriter*
+  call void @"(*main.multiWriter).Write"(%main.multiWriter* %6, i32 %1, i8* %2, i8* %3)
+  ret void
+}
+
+define void @main.logStatic(i32 %n) {
+entry:
+  %itf = load %runtime._interface, %runtime._interface* @main.logger
+  %typecode = extractvalue %runtime._interface %itf, 0
+  %value = extractvalue %runtime._interface %itf, 1
+  call void @"(main.Writer).Write"(i8* %value, i32 %n, i8* undef, i8* null, i32 %typecode)
+  ret void
+}
+
+define void @main.logDynamic(i32 %n) {
+entry:
+  %itf = load %runtime._interface, %runtime._interface* @main.dynamic
+  %typecode = extractvalue %runtime._interface %itf, 0
+  %value = extractvalue %runtime._interface %itf, 1
+  call void @"(main.Writer).Write"(i8* %value, i32 %n, i8* undef, i8* null, i32 %typecode)
+  ret void
+}
+
+define void @main.setDynamic(%main.countWriter* %w) {
+entry:
+  %value = bitcast %main.countWriter* %w to i8*
+  %itf = insertvalue %runtime._interface { i32 1, i8* undef }, i8* %value, 1
+  store %runtime._interface %itf, %runtime._interface* @main.dynamic
+  ret void
+}
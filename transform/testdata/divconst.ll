@@ -0,0 +1,71 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv6m-none-eabi"
+
+define i32 @udiv10(i32 %x) {
+  %q = udiv i32 %x, 10
+  ret i32 %q
+}
+
+; 7 needs a 33-bit magic number.
+define i32 @udiv7(i32 %x) {
+  %q = udiv i32 %x, 7
+  ret i32 %q
+}
+
+define i32 @udivLarge(i32 %x) {
+  %q = udiv i32 %x, 3000000000
+  ret i32 %q
+}
+
+define i32 @urem10(i32 %x) {
+  %r = urem i32 %x, 10
+  ret i32 %r
+}
+
+define i32 @sdiv10(i32 %x) {
+  %q = sdiv i32 %x, 10
+  ret i32 %q
+}
+
+define i32 @sdivMinus7(i32 %x) {
+  %q = sdiv i32 %x, -7
+  ret i32 %q
+}
+
+define i32 @srem7(i32 %x) {
+  %r = srem i32 %x, 7
+  ret i32 %r
+}
+
+define i16 @udiv16(i16 %x) {
+  %q = udiv i16 %x, 1000
+  ret i16 %q
+}
+
+define i8 @srem8(i8 %x) {
+  %r = srem i8 %x, 3
+  ret i8 %r
+}
+
+; Divisions by a power of two are already lowered to shifts.
+define i32 @udiv8(i32 %x) {
+  %q = udiv i32 %x, 8
+  ret i32 %q
+}
+
+define i32 @sdiv8(i32 %x) {
+  %q = sdiv i32 %x, -8
+  ret i32 %q
+}
+
+; Not a constant.
+define i32 @udivVar(i32 %x, i32 %y) {
+  %q = udiv i32 %x, %y
+  ret i32 %q
+}
+
+; Not supported.
+define i64 @udiv64(i64 %x) {
+  %q = udiv i64 %x, 10
+  ret i64 %q
+}
@@ -0,0 +1,213 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv6m-none-eabi"
+
+define i32 @udiv10(i32 %x) {
+  %1 = and i32 %x, 65535
+  %2 = lshr i32 %x, 16
+  %3 = mul i32 %1, 52429
+  %4 = mul i32 %2, 52429
+  %5 = lshr i32 %3, 16
+  %6 = add i32 %4, %5
+  %7 = mul i32 %1, 52428
+  %8 = and i32 %6, 65535
+  %9 = add i32 %7, %8
+  %10 = mul i32 %2, 52428
+  %11 = lshr i32 %6, 16
+  %12 = add i32 %10, %11
+  %13 = lshr i32 %9, 16
+  %14 = add i32 %12, %13
+  %15 = lshr i32 %14, 3
+  ret i32 %15
+}
+
+define i32 @udiv7(i32 %x) {
+  %1 = and i32 %x, 65535
+  %2 = lshr i32 %x, 16
+  %3 = mul i32 %1, 18725
+  %4 = mul i32 %2, 18725
+  %5 = lshr i32 %3, 16
+  %6 = add i32 %4, %5
+  %7 = mul i32 %1, 9362
+  %8 = and i32 %6, 65535
+  %9 = add i32 %7, %8
+  %10 = mul i32 %2, 9362
+  %11 = lshr i32 %6, 16
+  %12 = add i32 %10, %11
+  %13 = lshr i32 %9, 16
+  %14 = add i32 %12, %13
+  %15 = sub i32 %x, %14
+  %16 = lshr i32 %15, 1
+  %17 = add i32 %14, %16
+  %18 = lshr i32 %17, 2
+  ret i32 %18
+}
+
+define i32 @udivLarge(i32 %x) {
+  %1 = icmp uge i32 %x, -1294967296
+  %2 = zext i1 %1 to i32
+  ret i32 %2
+}
+
+define i32 @urem10(i32 %x) {
+  %1 = and i32 %x, 65535
+  %2 = lshr i32 %x, 16
+  %3 = mul i32 %1, 52429
+  %4 = mul i32 %2, 52429
+  %5 = lshr i32 %3, 16
+  %6 = add i32 %4, %5
+  %7 = mul i32 %1, 52428
+  %8 = and i32 %6, 65535
+  %9 = add i32 %7, %8
+  %10 = mul i32 %2, 52428
+  %11 = lshr i32 %6, 16
+  %12 = add i32 %10, %11
+  %13 = lshr i32 %9, 16
+  %14 = add i32 %12, %13
+  %15 = lshr i32 %14, 3
+  %16 = mul i32 %15, 10
+  %17 = sub i32 %x, %16
+  ret i32 %17
+}
+
+define i32 @sdiv10(i32 %x) {
+  %1 = and i32 %x, 65535
+  %2 = lshr i32 %x, 16
+  %3 = mul i32 %1, 26215
+  %4 = mul i32 %2, 26215
+  %5 = lshr i32 %3, 16
+  %6 = add i32 %4, %5
+  %7 = mul i32 %1, 26214
+  %8 = and i32 %6, 65535
+  %9 = add i32 %7, %8
+  %10 = mul i32 %2, 26214
+  %11 = lshr i32 %6, 16
+  %12 = add i32 %10, %11
+  %13 = lshr i32 %9, 16
+  %14 = add i32 %12, %13
+  %15 = ashr i32 %x, 31
+  %16 = and i32 %15, 1717986919
+  %17 = sub i32 %14, %16
+  %18 = ashr i32 %17, 2
+  %19 = lshr i32 %18, 31
+  %20 = add i32 %18, %19
+  ret i32 %20
+}
+
+define i32 @sdivMinus7(i32 %x) {
+  %1 = and i32 %x, 65535
+  %2 = lshr i32 %x, 16
+  %3 = mul i32 %1, 56173
+  %4 = mul i32 %2, 56173
+  %5 = lshr i32 %3, 16
+  %6 = add i32 %4, %5
+  %7 = mul i32 %1, 28086
+  %8 = and i32 %6, 65535
+  %9 = add i32 %7, %8
+  %10 = mul i32 %2, 28086
+  %11 = lshr i32 %6, 16
+  %12 = add i32 %10, %11
+  %13 = lshr i32 %9, 16
+  %14 = add i32 %12, %13
+  %15 = ashr i32 %x, 31
+  %16 = and i32 %15, 1840700269
+  %17 = sub i32 %14, %16
+  %18 = sub i32 %17, %x
+  %19 = ashr i32 %18, 2
+  %20 = lshr i32 %19, 31
+  %21 = add i32 %19, %20
+  ret i32 %21
+}
+
+define i32 @srem7(i32 %x) {
+  %1 = and i32 %x, 65535
+  %2 = lshr i32 %x, 16
+  %3 = mul i32 %1, 9363
+  %4 = mul i32 %2, 9363
+  %5 = lshr i32 %3, 16
+  %6 = add i32 %4, %5
+  %7 = mul i32 %1, 37449
+  %8 = and i32 %6, 65535
+  %9 = add i32 %7, %8
+  %10 = mul i32 %2, 37449
+  %11 = lshr i32 %6, 16
+  %12 = add i32 %10, %11
+  %13 = lshr i32 %9, 16
+  %14 = add i32 %12, %13
+  %15 = ashr i32 %x, 31
+  %16 = and i32 %15, -1840700269
+  %17 = sub i32 %14, %16
+  %18 = ashr i32 %17, 2
+  %19 = lshr i32 %18, 31
+  %20 = add i32 %18, %19
+  %21 = mul i32 %20, 7
+  %22 = sub i32 %x, %21
+  ret i32 %22
+}
+
+define i16 @udiv16(i16 %x) {
+  %1 = zext i16 %x to i32
+  %2 = and i32 %1, 65535
+  %3 = lshr i32 %1, 16
+  %4 = mul i32 %2, 19923
+  %5 = mul i32 %3, 19923
+  %6 = lshr i32 %4, 16
+  %7 = add i32 %5, %6
+  %8 = mul i32 %2, 4194
+  %9 = and i32 %7, 65535
+  %10 = add i32 %8, %9
+  %11 = mul i32 %3, 4194
+  %12 = lshr i32 %7, 16
+  %13 = add i32 %11, %12
+  %14 = lshr i32 %10, 16
+  %15 = add i32 %13, %14
+  %16 = lshr i32 %15, 6
+  %17 = trunc i32 %16 to i16
+  ret i16 %17
+}
+
+define i8 @srem8(i8 %x) {
+  %1 = sext i8 %x to i32
+  %2 = and i32 %1, 65535
+  %3 = lshr i32 %1, 16
+  %4 = mul i32 %2, 21846
+  %5 = mul i32 %3, 21846
+  %6 = lshr i32 %4, 16
+  %7 = add i32 %5, %6
+  %8 = mul i32 %2, 21845
+  %9 = and i32 %7, 65535
+  %10 = add i32 %8, %9
+  %11 = mul i32 %3, 21845
+  %12 = lshr i32 %7, 16
+  %13 = add i32 %11, %12
+  %14 = lshr i32 %10, 16
+  %15 = add i32 %13, %14
+  %16 = ashr i32 %1, 31
+  %17 = and i32 %16, 1431655766
+  %18 = sub i32 %15, %17
+  %19 = lshr i32 %18, 31
+  %20 = add i32 %18, %19
+  %21 = mul i32 %20, 3
+  %22 = sub i32 %1, %21
+  %23 = trunc i32 %22 to i8
+  ret i8 %23
+}
+
+define i32 @udiv8(i32 %x) {
+  %q = udiv i32 %x, 8
+  ret i32 %q
+}
+
+define i32 @sdiv8(i32 %x) {
+  %q = sdiv i32 %x, -8
+  ret i32 %q
+}
+
+define i32 @udivVar(i32 %x, i32 %y) {
+  %q = udiv i32 %x, %y
+  ret i32 %q
+}
+
+define i64 @udiv64(i64 %x) {
+  %q = udiv i64 %x, 10
+  ret i64 %q
+}
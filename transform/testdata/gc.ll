@@ -0,0 +1,122 @@
+target datalayout = "e-m:e-p:32:32-i64:64-n32:64-S128"
+target triple = "wasm32-unknown-unknown-wasm"
+
+@main.global = global i8* null
+
+declare nonnull i8* @runtime.alloc(i32)
+
+declare void @runtime.trackPointer(i8*)
+
+declare void @main.nonAllocating(i8*)
+
+define void @main.allocating() {
+  %1 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %1)
+  store i8* %1, i8** @main.global
+  ret void
+}
+
+; An allocation that was converted to a stack allocation doesn't need to be
+; tracked, even when there is an allocation afterwards.
+define void @testStackAlloc() {
+  %stackalloc.alloca = alloca [1 x i32]
+  store [1 x i32] zeroinitializer, [1 x i32]* %stackalloc.alloca
+  %stackalloc = bitcast [1 x i32]* %stackalloc.alloca to i8*
+  call void @runtime.trackPointer(i8* %stackalloc)
+  call void @main.nonAllocating(i8* %stackalloc)
+  %1 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %1)
+  store i8* %1, i8** @main.global
+  ret void
+}
+
+; A stack allocation that contains pointers must stay tracked, as the heap
+; objects it refers to must be kept alive.
+define void @testStackAllocPointers() {
+  %stackalloc.alloca = alloca i8*
+  %1 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %1)
+  store i8* %1, i8** %stackalloc.alloca
+  %stackalloc = bitcast i8** %stackalloc.alloca to i8*
+  call void @runtime.trackPointer(i8* %stackalloc)
+  call void @main.nonAllocating(i8* %stackalloc)
+  %2 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %2)
+  store i8* %2, i8** @main.global
+  ret void
+}
+
+; A heap pointer that is live while another allocation is done must be
+; tracked.
+define i8* @testLiveAcrossAlloc() {
+  %1 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %1)
+  %2 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %2)
+  store i8* %2, i8** @main.global
+  ret i8* %1
+}
+
+; Calls to functions that don't allocate can't trigger a GC cycle.
+define void @testNonAllocatingCall() {
+  %1 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %1)
+  call void @main.nonAllocating(i8* %1)
+  ret void
+}
+
+; Functions that call runtime.alloc indirectly also allocate.
+define void @testIndirectAlloc() {
+  %1 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %1)
+  call void @main.allocating()
+  call void @main.nonAllocating(i8* %1)
+  ret void
+}
+
+; A function pointer may allocate.
+define void @testFunctionPointer(void ()* %fn) {
+  %1 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %1)
+  call void %fn()
+  call void @main.nonAllocating(i8* %1)
+  ret void
+}
+
+; An allocation in a later basic block (here, the next loop iteration) may
+; trigger a GC cycle.
+define void @testLoop(i32 %n) {
+entry:
+  br label %loop
+
+loop:
+  %i = phi i32 [ 0, %entry ], [ %next, %loop ]
+  %0 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %0)
+  call void @main.nonAllocating(i8* %0)
+  %next = add i32 %i, 1
+  %done = icmp eq i32 %next, %n
+  br i1 %done, label %exit, label %loop
+
+exit:
+  ret void
+}
+
+; No allocation is done after the last pointer is created, so nothing needs to
+; be tracked in the other branch.
+define void @testBranch(i1 %cond) {
+entry:
+  %0 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %0)
+  br i1 %cond, label %alloc, label %exit
+
+alloc:
+  %1 = call i8* @runtime.alloc(i32 4)
+  call void @runtime.trackPointer(i8* %1)
+  call void @main.nonAllocating(i8* %1)
+  br label %exit
+
+exit:
+  call void @main.nonAllocating(i8* %0)
+  ret void
+}
@@ -0,0 +1,62 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+@main.counter = internal global i32 0
+
+declare void @runtime.printint32(i32)
+
+define internal void @runtime.initAll(i8* %context, i8* %parentHandle) unnamed_addr {
+entry:
+  call void @runtime.init(i8* undef, i8* undef)
+  call void @sync.init(i8* undef, i8* undef)
+  call void @machine.init(i8* undef, i8* undef)
+  call void @fmt.init(i8* undef, i8* undef)
+  call void @main.init(i8* undef, i8* undef)
+  ret void
+}
+
+; Empty package initializer.
+define internal void @runtime.init(i8* %context, i8* %parentHandle) unnamed_addr {
+entry:
+  ret void
+}
+
+; Package initializer that only calls an empty init() function.
+define internal void @sync.init(i8* %context, i8* %parentHandle) unnamed_addr {
+entry:
+  call void @"sync.init#1"(i8* undef, i8* undef)
+  ret void
+}
+
+define internal void @"sync.init#1"(i8* %context, i8* %parentHandle) unnamed_addr {
+entry:
+  ret void
+}
+
+; Package initializer with a volatile store (MMIO), must be kept.
+define internal void @machine.init(i8* %context, i8* %parentHandle) unnamed_addr {
+entry:
+  store volatile i32 1, i32* inttoptr (i32 1073741824 to i32*)
+  ret void
+}
+
+; Package initializer that calls an external function, must be kept.
+define internal void @fmt.init(i8* %context, i8* %parentHandle) unnamed_addr {
+entry:
+  call void @runtime.printint32(i32 3)
+  ret void
+}
+
+; Package initializer that calls an init() function with a side effect, must be
+; kept.
+define internal void @main.init(i8* %context, i8* %parentHandle) unnamed_addr {
+entry:
+  call void @"main.init#1"(i8* undef, i8* undef)
+  ret void
+}
+
+define internal void @"main.init#1"(i8* %context, i8* %parentHandle) unnamed_addr {
+entry:
+  store i32 5, i32* @main.counter
+  ret void
+}
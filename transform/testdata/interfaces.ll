@@ -0,0 +1,73 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime._interface = type { i32, i8* }
+
+declare void @runtime.interfaceTypeAssert(i1)
+
+declare void @useInterface(%runtime._interface)
+
+; An int that is put in an interface and immediately type asserted back. Both
+; the boxing and the unboxing are removed.
+define i32 @roundTrip(i32 %x) {
+entry:
+  %pack.int = inttoptr i32 %x to i8*
+  %itf.0 = insertvalue %runtime._interface undef, i32 3, 0
+  %itf = insertvalue %runtime._interface %itf.0, i8* %pack.int, 1
+  %interface.type = extractvalue %runtime._interface %itf, 0
+  %typeassert.ok = icmp eq i32 3, %interface.type
+  br i1 %typeassert.ok, label %typeassert.ok1, label %typeassert.next
+
+typeassert.ok1:
+  %typeassert.value.ptr = extractvalue %runtime._interface %itf, 1
+  %unpack.int = ptrtoint i8* %typeassert.value.ptr to i32
+  br label %typeassert.next
+
+typeassert.next:
+  %typeassert.value = phi i32 [ 0, %entry ], [ %unpack.int, %typeassert.ok1 ]
+  call void @runtime.interfaceTypeAssert(i1 %typeassert.ok)
+  ret i32 %typeassert.value
+}
+
+; A pointer that is put in an interface and type asserted back.
+define i32* @pointerRoundTrip(i32* %p) {
+entry:
+  %pack.ptr = bitcast i32* %p to i8*
+  %itf.0 = insertvalue %runtime._interface undef, i32 5, 0
+  %itf = insertvalue %runtime._interface %itf.0, i8* %pack.ptr, 1
+  %interface.type = extractvalue %runtime._interface %itf, 0
+  %typeassert.ok = icmp eq i32 5, %interface.type
+  br i1 %typeassert.ok, label %typeassert.ok1, label %typeassert.next
+
+typeassert.ok1:
+  %typeassert.value.ptr = extractvalue %runtime._interface %itf, 1
+  %unpack.ptr = bitcast i8* %typeassert.value.ptr to i32*
+  br label %typeassert.next
+
+typeassert.next:
+  %typeassert.value = phi i32* [ null, %entry ], [ %unpack.ptr, %typeassert.ok1 ]
+  call void @runtime.interfaceTypeAssert(i1 %typeassert.ok)
+  ret i32* %typeassert.value
+}
+
+; The interface is also passed to a function in between, so it must be kept.
+define i32 @interposedUse(i32 %x) {
+entry:
+  %pack.int = inttoptr i32 %x to i8*
+  %itf.0 = insertvalue %runtime._interface undef, i32 3, 0
+  %itf = insertvalue %runtime._interface %itf.0, i8* %pack.int, 1
+  call void @useInterface(%runtime._interface %itf)
+  %interface.type = extractvalue %runtime._interface %itf, 0
+  %typeassert.ok = icmp eq i32 3, %interface.type
+  br i1 %typeassert.ok, label %typeassert.ok1, label %typeassert.next
+
+typeassert.ok1:
+  %typeassert.value.ptr = extractvalue %runtime._interface %itf, 1
+  %unpack.int = ptrtoint i8* %typeassert.value.ptr to i32
+  br label %typeassert.next
+
+typeassert.next:
+  %typeassert.value = phi i32 [ 0, %entry ], [ %unpack.int, %typeassert.ok1 ]
+  call void @runtime.interfaceTypeAssert(i1 %typeassert.ok)
+  ret i32 %typeassert.value
+}
@@ -0,0 +1,55 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime._interface = type { i32, i8* }
+
+declare void @runtime.interfaceTypeAssert(i1)
+
+declare void @useInterface(%runtime._interface)
+
+define i32 @roundTrip(i32 %x) {
+entry:
+  br i1 true, label %typeassert.ok1, label %typeassert.next
+
+typeassert.ok1:                                   ; preds = %entry
+  br label %typeassert.next
+
+typeassert.next:                                  ; preds = %typeassert.ok1, %entry
+  %typeassert.value = phi i32 [ 0, %entry ], [ %x, %typeassert.ok1 ]
+  call void @runtime.interfaceTypeAssert(i1 true)
+  ret i32 %typeassert.value
+}
+
+define i32* @pointerRoundTrip(i32* %p) {
+entry:
+  br i1 true, label %typeassert.ok1, label %typeassert.next
+
+typeassert.ok1:                                   ; preds = %entry
+  br label %typeassert.next
+
+typeassert.next:                                  ; preds = %typeassert.ok1, %entry
+  %typeassert.value = phi i32* [ null, %entry ], [ %p, %typeassert.ok1 ]
+  call void @runtime.interfaceTypeAssert(i1 true)
+  ret i32* %typeassert.value
+}
+
+define i32 @interposedUse(i32 %x) {
+entry:
+  %pack.int = inttoptr i32 %x to i8*
+  %itf.0 = insertvalue %runtime._interface undef, i32 3, 0
+  %itf = insertvalue %runtime._interface %itf.0, i8* %pack.int, 1
+  call void @useInterface(%runtime._interface %itf)
+  %interface.type = extractvalue %runtime._interface %itf, 0
+  %typeassert.ok = icmp eq i32 3, %interface.type
+  br i1 %typeassert.ok, label %typeassert.ok1, label %typeassert.next
+
+typeassert.ok1:                                   ; preds = %entry
+  %typeassert.value.ptr = extractvalue %runtime._interface %itf, 1
+  %unpack.int = ptrtoint i8* %typeassert.value.ptr to i32
+  br label %typeassert.next
+
+typeassert.next:                                  ; preds = %typeassert.ok1, %entry
+  %typeassert.value = phi i32 [ 0, %entry ], [ %unpack.int, %typeassert.ok1 ]
+  call void @runtime.interfaceTypeAssert(i1 %typeassert.ok)
+  ret i32 %typeassert.value
+}
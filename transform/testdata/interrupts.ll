@@ -0,0 +1,81 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime.channel = type { i32, i32, i8, %runtime.channelBlockedList*, i32, i32, i32, i8* }
+%runtime.channelBlockedList = type { %runtime.channelBlockedList*, i8*, %runtime.chanSelectState*, { %runtime.channelBlockedList*, i32, i32 } }
+%runtime.chanSelectState = type { %runtime.channel*, i8* }
+
+@main.ch = global %runtime.channel* null
+@main.counter = global i32 0
+
+declare void @time.Sleep(i64, i8*, i8*)
+
+declare void @runtime.chanSend(%runtime.channel*, i8*, i8*, i8*)
+
+declare { i32, i1 } @runtime.chanSelect(i8*, %runtime.chanSelectState*, i32, i32, i1, i8*, i8*)
+
+declare void @runtime.lookupPanic(i8*, i8*)
+
+; Blocking, through another function.
+define void @TIM2_IRQHandler() {
+entry:
+  call void @main.wait(i8* undef, i8* null)
+  ret void
+}
+
+define internal void @main.wait(i8* %context, i8* %parentHandle) {
+entry:
+  call void @main.increment(i8* undef, i8* null)
+  call void @time.Sleep(i64 1000000, i8* undef, i8* null)
+  ret void
+}
+
+; Not blocking: a select with a default case never waits.
+define void @TIM3_IRQHandler() {
+entry:
+  %states = alloca [1 x %runtime.chanSelectState]
+  %states.ptr = getelementptr [1 x %runtime.chanSelectState], [1 x %runtime.chanSelectState]* %states, i32 0, i32 0
+  %result = call { i32, i1 } @runtime.chanSelect(i8* undef, %runtime.chanSelectState* %states.ptr, i32 1, i32 1, i1 false, i8* undef, i8* null)
+  call void @main.increment(i8* undef, i8* null)
+  ret void
+}
+
+define internal void @main.increment(i8* %context, i8* %parentHandle) {
+entry:
+  %value = load i32, i32* @main.counter
+  %new = add i32 %value, 1
+  store i32 %new, i32* @main.counter
+  %overflow = icmp eq i32 %new, 0
+  br i1 %overflow, label %panic, label %done
+
+panic:
+  call void @runtime.lookupPanic(i8* undef, i8* null)
+  unreachable
+
+done:
+  ret void
+}
+
+; Blocking: a send on a channel waits until there is a receiver or space in the
+; buffer.
+define void @USART1_IRQHandler() {
+entry:
+  %value = alloca i32
+  %value.bitcast = bitcast i32* %value to i8*
+  %ch = load %runtime.channel*, %runtime.channel** @main.ch
+  call void @runtime.chanSend(%runtime.channel* %ch, i8* %value.bitcast, i8* undef, i8* null)
+  ret void
+}
+
+; Not an interrupt handler: runs in a goroutine, so it may block.
+define void @Reset_Handler() {
+entry:
+  call void @main.wait(i8* undef, i8* null)
+  ret void
+}
+
+define void @main.main(i8* %context, i8* %parentHandle) {
+entry:
+  call void @main.wait(i8* undef, i8* null)
+  ret void
+}
@@ -0,0 +1,104 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+declare void @foo(i32)
+
+; A switch where case 2 is taken almost always. It is checked first, and the
+; PHI node in %done gets the new block as incoming block for the other cases.
+define i32 @testDominant(i32 %x) {
+entry:
+  switch i32 %x, label %default [
+    i32 1, label %one
+    i32 2, label %two
+    i32 3, label %done
+    i32 4, label %done
+  ], !prof !0
+
+one:
+  call void @foo(i32 1)
+  br label %done
+
+two:
+  call void @foo(i32 2)
+  br label %done
+
+default:
+  call void @foo(i32 0)
+  br label %done
+
+done:
+  %result = phi i32 [ 1, %one ], [ 2, %two ], [ 3, %entry ], [ 3, %entry ], [ 0, %default ]
+  ret i32 %result
+}
+
+; A switch with a single case that dominates: it becomes a conditional branch.
+define void @testSingle(i32 %x) {
+entry:
+  switch i32 %x, label %default [
+    i32 5, label %five
+  ], !prof !1
+
+five:
+  call void @foo(i32 5)
+  ret void
+
+default:
+  ret void
+}
+
+; No case has two thirds of the total weight, so nothing changes.
+define void @testEven(i32 %x) {
+entry:
+  switch i32 %x, label %default [
+    i32 1, label %one
+    i32 2, label %two
+  ], !prof !2
+
+one:
+  call void @foo(i32 1)
+  ret void
+
+two:
+  call void @foo(i32 2)
+  ret void
+
+default:
+  ret void
+}
+
+; The dominant case shares its destination with another case, so it can't be
+; checked separately without changing the PHI node.
+define i32 @testShared(i32 %x) {
+entry:
+  switch i32 %x, label %default [
+    i32 1, label %done
+    i32 2, label %done
+  ], !prof !3
+
+default:
+  br label %done
+
+done:
+  %result = phi i32 [ 1, %entry ], [ 1, %entry ], [ 0, %default ]
+  ret i32 %result
+}
+
+; A switch without branch weights is not changed.
+define void @testNoWeights(i32 %x) {
+entry:
+  switch i32 %x, label %default [
+    i32 1, label %one
+  ]
+
+one:
+  call void @foo(i32 1)
+  ret void
+
+default:
+  ret void
+}
+
+!0 = !{!"branch_weights", i32 1, i32 1, i32 2000, i32 1, i32 1}
+!1 = !{!"branch_weights", i32 1, i32 2000}
+!2 = !{!"branch_weights", i32 1, i32 2000, i32 2000}
+!3 = !{!"branch_weights", i32 1, i32 1, i32 2000}
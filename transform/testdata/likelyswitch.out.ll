@@ -0,0 +1,100 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+declare void @foo(i32)
+
+define i32 @testDominant(i32 %x) {
+entry:
+  %switch.likely = icmp eq i32 %x, 2
+  br i1 %switch.likely, label %two, label %switch.unlikely, !prof !0
+
+switch.unlikely:                                  ; preds = %entry
+  switch i32 %x, label %default [
+    i32 1, label %one
+    i32 3, label %done
+    i32 4, label %done
+  ], !prof !1
+
+one:                                              ; preds = %switch.unlikely
+  call void @foo(i32 1)
+  br label %done
+
+two:                                              ; preds = %entry
+  call void @foo(i32 2)
+  br label %done
+
+default:                                          ; preds = %switch.unlikely
+  call void @foo(i32 0)
+  br label %done
+
+done:                                             ; preds = %switch.unlikely, %switch.unlikely, %default, %two, %one
+  %result = phi i32 [ 1, %one ], [ 2, %two ], [ 3, %switch.unlikely ], [ 3, %switch.unlikely ], [ 0, %default ]
+  ret i32 %result
+}
+
+define void @testSingle(i32 %x) {
+entry:
+  %switch.likely = icmp eq i32 %x, 5
+  br i1 %switch.likely, label %five, label %default, !prof !2
+
+five:                                             ; preds = %entry
+  call void @foo(i32 5)
+  ret void
+
+default:                                          ; preds = %entry
+  ret void
+}
+
+define void @testEven(i32 %x) {
+entry:
+  switch i32 %x, label %default [
+    i32 1, label %one
+    i32 2, label %two
+  ], !prof !3
+
+one:                                              ; preds = %entry
+  call void @foo(i32 1)
+  ret void
+
+two:                                              ; preds = %entry
+  call void @foo(i32 2)
+  ret void
+
+default:                                          ; preds = %entry
+  ret void
+}
+
+define i32 @testShared(i32 %x) {
+entry:
+  switch i32 %x, label %default [
+    i32 1, label %done
+    i32 2, label %done
+  ], !prof !4
+
+default:                                          ; preds = %entry
+  br label %done
+
+done:                                             ; preds = %default, %entry, %entry
+  %result = phi i32 [ 1, %entry ], [ 1, %entry ], [ 0, %default ]
+  ret i32 %result
+}
+
+define void @testNoWeights(i32 %x) {
+entry:
+  switch i32 %x, label %default [
+    i32 1, label %one
+  ]
+
+one:                                              ; preds = %entry
+  call void @foo(i32 1)
+  ret void
+
+default:                                          ; preds = %entry
+  ret void
+}
+
+!0 = !{!"branch_weights", i32 2000, i32 4}
+!1 = !{!"branch_weights", i32 1, i32 1, i32 1, i32 1}
+!2 = !{!"branch_weights", i32 2000, i32 1}
+!3 = !{!"branch_weights", i32 1, i32 2000, i32 2000}
+!4 = !{!"branch_weights", i32 1, i32 1, i32 2000}
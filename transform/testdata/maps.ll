@@ -26,8 +26,8 @@ define void @testUnused() {
     ret void
 }
 
-; Note that the following function should ideally be optimized (it could simply
-; return 42), but isn't at the moment.
+; This map is only filled with constant values before it is read, so the lookup
+; can be replaced with the value that is stored in the map.
 define i32 @testReadonly() {
     ; create the map
     %map = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0)
@@ -49,6 +49,35 @@ define i32 @testReadonly() {
     ret i32 %loadedValue
 }
 
+; The map is modified with an unknown value before it is read, so the lookup
+; must be kept.
+define i32 @testModified(i32 %x) {
+    ; create the map
+    %map = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0)
+
+    ; create the value to be stored
+    %hashmap.value = alloca i32
+    store i32 42, i32* %hashmap.value
+
+    ; store the value
+    %hashmap.value.bitcast = bitcast i32* %hashmap.value to i8*
+    call void @runtime.hashmapStringSet(%runtime.hashmap* %map, i8* getelementptr inbounds ([6 x i8], [6 x i8]* @answer, i32 0, i32 0), i32 6, i8* %hashmap.value.bitcast)
+
+    ; overwrite the value with a value that is not known at compile time
+    %hashmap.value2 = alloca i32
+    store i32 %x, i32* %hashmap.value2
+    %hashmap.value2.bitcast = bitcast i32* %hashmap.value2 to i8*
+    call void @runtime.hashmapStringSet(%runtime.hashmap* %map, i8* getelementptr inbounds ([6 x i8], [6 x i8]* @answer, i32 0, i32 0), i32 6, i8* %hashmap.value2.bitcast)
+
+    ; load the value back
+    %hashmap.value3 = alloca i32
+    %hashmap.value3.bitcast = bitcast i32* %hashmap.value3 to i8*
+    %commaOk = call i1 @runtime.hashmapStringGet(%runtime.hashmap* %map, i8* getelementptr inbounds ([6 x i8], [6 x i8]* @answer, i32 0, i32 0), i32 6, i8* %hashmap.value3.bitcast)
+    %loadedValue = load i32, i32* %hashmap.value3
+
+    ret i32 %loadedValue
+}
+
 define %runtime.hashmap* @testUsed() {
     %1 = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0)
     ret %runtime.hashmap* %1
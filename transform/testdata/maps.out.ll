@@ -16,15 +16,26 @@ define void @testUnused() {
 }
 
 define i32 @testReadonly() {
+  %hashmap.value2 = alloca i32
+  store i32 42, i32* %hashmap.value2
+  %loadedValue = load i32, i32* %hashmap.value2
+  ret i32 %loadedValue
+}
+
+define i32 @testModified(i32 %x) {
   %map = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0)
   %hashmap.value = alloca i32
   store i32 42, i32* %hashmap.value
   %hashmap.value.bitcast = bitcast i32* %hashmap.value to i8*
   call void @runtime.hashmapStringSet(%runtime.hashmap* %map, i8* getelementptr inbounds ([6 x i8], [6 x i8]* @answer, i32 0, i32 0), i32 6, i8* %hashmap.value.bitcast)
   %hashmap.value2 = alloca i32
+  store i32 %x, i32* %hashmap.value2
   %hashmap.value2.bitcast = bitcast i32* %hashmap.value2 to i8*
-  %commaOk = call i1 @runtime.hashmapStringGet(%runtime.hashmap* %map, i8* getelementptr inbounds ([6 x i8], [6 x i8]* @answer, i32 0, i32 0), i32 6, i8* %hashmap.value2.bitcast)
-  %loadedValue = load i32, i32* %hashmap.value2
+  call void @runtime.hashmapStringSet(%runtime.hashmap* %map, i8* getelementptr inbounds ([6 x i8], [6 x i8]* @answer, i32 0, i32 0), i32 6, i8* %hashmap.value2.bitcast)
+  %hashmap.value3 = alloca i32
+  %hashmap.value3.bitcast = bitcast i32* %hashmap.value3 to i8*
+  %commaOk = call i1 @runtime.hashmapStringGet(%runtime.hashmap* %map, i8* getelementptr inbounds ([6 x i8], [6 x i8]* @answer, i32 0, i32 0), i32 6, i8* %hashmap.value3.bitcast)
+  %loadedValue = load i32, i32* %hashmap.value3
   ret i32 %loadedValue
 }
 
@@ -0,0 +1,34 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%sync.Mutex = type { i1 }
+
+@main.mu = global %sync.Mutex zeroinitializer
+@main.counter = global i32 0
+
+declare void @"(*sync.Mutex).Lock"(%sync.Mutex*, i8*, i8*)
+
+declare void @"(*sync.Mutex).Unlock"(%sync.Mutex*, i8*, i8*)
+
+declare i32 @runtime.makeGoroutine(i32, i8*, i8*)
+
+; This program starts a goroutine (go increment()), so the lock and unlock
+; calls must be kept.
+define void @main.main(i8* %context, i8* %parentHandle) {
+entry:
+  %fn = call i32 @runtime.makeGoroutine(i32 ptrtoint (void (i8*, i8*)* @main.increment to i32), i8* undef, i8* null)
+  %fn.ptr = inttoptr i32 %fn to void (i8*, i8*)*
+  call void %fn.ptr(i8* undef, i8* null)
+  call void @main.increment(i8* undef, i8* null)
+  ret void
+}
+
+define void @main.increment(i8* %context, i8* %parentHandle) {
+entry:
+  call void @"(*sync.Mutex).Lock"(%sync.Mutex* @main.mu, i8* undef, i8* null)
+  %value = load i32, i32* @main.counter
+  %new = add i32 %value, 1
+  store i32 %new, i32* @main.counter
+  call void @"(*sync.Mutex).Unlock"(%sync.Mutex* @main.mu, i8* undef, i8* null)
+  ret void
+}
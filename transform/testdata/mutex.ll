@@ -0,0 +1,37 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%sync.Mutex = type { i1 }
+%sync.RWMutex = type { %sync.Mutex, i32 }
+
+@main.mu = global %sync.Mutex zeroinitializer
+@main.rw = global %sync.RWMutex zeroinitializer
+@main.counter = global i32 0
+
+declare void @"(*sync.Mutex).Lock"(%sync.Mutex*, i8*, i8*)
+
+declare void @"(*sync.Mutex).Unlock"(%sync.Mutex*, i8*, i8*)
+
+declare void @"(*sync.RWMutex).RLock"(%sync.RWMutex*, i8*, i8*)
+
+declare void @"(*sync.RWMutex).RUnlock"(%sync.RWMutex*, i8*, i8*)
+
+; No goroutines are started in this program, so all lock and unlock calls are
+; removed.
+define void @main.increment(i8* %context, i8* %parentHandle) {
+entry:
+  call void @"(*sync.Mutex).Lock"(%sync.Mutex* @main.mu, i8* undef, i8* null)
+  %value = load i32, i32* @main.counter
+  %new = add i32 %value, 1
+  store i32 %new, i32* @main.counter
+  call void @"(*sync.Mutex).Unlock"(%sync.Mutex* @main.mu, i8* undef, i8* null)
+  ret void
+}
+
+define i32 @main.read(i8* %context, i8* %parentHandle) {
+entry:
+  call void @"(*sync.RWMutex).RLock"(%sync.RWMutex* @main.rw, i8* undef, i8* null)
+  %value = load i32, i32* @main.counter
+  call void @"(*sync.RWMutex).RUnlock"(%sync.RWMutex* @main.rw, i8* undef, i8* null)
+  ret i32 %value
+}
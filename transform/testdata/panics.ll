@@ -0,0 +1,61 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+@"main.getElement$string" = internal unnamed_addr constant [18 x i8] c"index out of range"
+@"main.testShared$string" = internal unnamed_addr constant [4 x i8] c"zero"
+@main.array = global [16 x i8] zeroinitializer
+
+declare void @runtime.runtimePanic(i8*, i32, i8*, i8*)
+
+declare void @runtime.lookupPanic(i8*, i8*)
+
+; This is getElement(array[:], 3) after getElement has been inlined: the bounds
+; check (3 >= 16) is known to be false. Therefore the panic block and its
+; message are removed.
+define i8 @testInlinedBoundsCheck() {
+entry:
+  br i1 false, label %panic, label %next
+
+panic:
+  call void @runtime.runtimePanic(i8* getelementptr inbounds ([18 x i8], [18 x i8]* @"main.getElement$string", i32 0, i32 0), i32 18, i8* undef, i8* null)
+  unreachable
+
+next:
+  %ptr = getelementptr inbounds [16 x i8], [16 x i8]* @main.array, i32 0, i32 3
+  %value = load i8, i8* %ptr
+  ret i8 %value
+}
+
+; The bounds check is not known at compile time, so it must be kept.
+define i8 @testBoundsCheck(i32 %index) {
+entry:
+  %outofbounds = icmp uge i32 %index, 16
+  br i1 %outofbounds, label %panic, label %next
+
+panic:
+  call void @runtime.lookupPanic(i8* undef, i8* null)
+  unreachable
+
+next:
+  %ptr = getelementptr inbounds [16 x i8], [16 x i8]* @main.array, i32 0, i32 %index
+  %value = load i8, i8* %ptr
+  ret i8 %value
+}
+
+; The panic block is also reachable through a branch that is not constant, so
+; it is kept together with its message.
+define void @testShared(i32 %x) {
+entry:
+  br i1 false, label %panic, label %check
+
+check:
+  %iszero = icmp eq i32 %x, 0
+  br i1 %iszero, label %panic, label %exit
+
+panic:
+  call void @runtime.runtimePanic(i8* getelementptr inbounds ([4 x i8], [4 x i8]* @"main.testShared$string", i32 0, i32 0), i32 4, i8* undef, i8* null)
+  unreachable
+
+exit:
+  ret void
+}
@@ -0,0 +1,50 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+@"main.testShared$string" = internal unnamed_addr constant [4 x i8] c"zero"
+@main.array = global [16 x i8] zeroinitializer
+
+declare void @runtime.runtimePanic(i8*, i32, i8*, i8*)
+
+declare void @runtime.lookupPanic(i8*, i8*)
+
+define i8 @testInlinedBoundsCheck() {
+entry:
+  br label %next
+
+next:                                             ; preds = %entry
+  %ptr = getelementptr inbounds [16 x i8], [16 x i8]* @main.array, i32 0, i32 3
+  %value = load i8, i8* %ptr
+  ret i8 %value
+}
+
+define i8 @testBoundsCheck(i32 %index) {
+entry:
+  %outofbounds = icmp uge i32 %index, 16
+  br i1 %outofbounds, label %panic, label %next
+
+panic:                                            ; preds = %entry
+  call void @runtime.lookupPanic(i8* undef, i8* null)
+  unreachable
+
+next:                                             ; preds = %entry
+  %ptr = getelementptr inbounds [16 x i8], [16 x i8]* @main.array, i32 0, i32 %index
+  %value = load i8, i8* %ptr
+  ret i8 %value
+}
+
+define void @testShared(i32 %x) {
+entry:
+  br i1 false, label %panic, label %check
+
+check:                                            ; preds = %entry
+  %iszero = icmp eq i32 %x, 0
+  br i1 %iszero, label %panic, label %exit
+
+panic:                                            ; preds = %check, %entry
+  call void @runtime.runtimePanic(i8* getelementptr inbounds ([4 x i8], [4 x i8]* @"main.testShared$string", i32 0, i32 0), i32 4, i8* undef, i8* null)
+  unreachable
+
+exit:                                             ; preds = %check
+  ret void
+}
@@ -0,0 +1,26 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+@"main.string" = internal unnamed_addr constant [5 x i8] c"hello"
+@_sidata = external global i8
+
+; Constant without pointers to flash, stays in flash.
+@main.constNoPointers = internal constant { i32, i32 } { i32 1, i32 2 }
+
+; Constant with a pointer to flash, moved to RAM and relocated.
+@main.constString = internal unnamed_addr constant { i8*, i32 } { i8* getelementptr inbounds ([5 x i8], [5 x i8]* @"main.string", i32 0, i32 0), i32 5 }
+
+; Constant that only points to RAM, stays in flash.
+@main.constPointsToRAM = internal constant i32* @main.plainInt
+
+; Global with a function pointer (as an integer) and a pointer to a constant
+; global at a non-zero offset.
+@main.funcTable = internal global [2 x { i32, i8* }] [{ i32, i8* } { i32 ptrtoint (void ()* @main.foo to i32), i8* null }, { i32, i8* } { i32 3, i8* bitcast ({ i32, i32 }* @main.constNoPointers to i8*) }]
+
+; Global that points to RAM only (directly and through an external global).
+@main.plainInt = internal global i32 5
+@main.ramPointers = internal global { i32*, i8* } { i32* @main.plainInt, i8* @_sidata }
+
+define internal void @main.foo() {
+  ret void
+}
@@ -0,0 +1,16 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+@main.string = internal unnamed_addr constant [5 x i8] c"hello"
+@_sidata = external global i8
+@main.constNoPointers = internal constant { i32, i32 } { i32 1, i32 2 }
+@main.constString = internal unnamed_addr global { i8*, i32 } { i8* getelementptr inbounds ([5 x i8], [5 x i8]* @main.string, i32 0, i32 0), i32 5 }
+@main.constPointsToRAM = internal constant i32* @main.plainInt
+@main.funcTable = internal global [2 x { i32, i8* }] [{ i32, i8* } { i32 ptrtoint (void ()* @main.foo to i32), i8* null }, { i32, i8* } { i32 3, i8* bitcast ({ i32, i32 }* @main.constNoPointers to i8*) }]
+@main.plainInt = internal global i32 5
+@main.ramPointers = internal global { i32*, i8* } { i32* @main.plainInt, i8* @_sidata }
+@tinygo_ropiRelocs = constant [3 x i8*] [i8* bitcast ({ i8*, i32 }* @main.constString to i8*), i8* bitcast ([2 x { i32, i8* }]* @main.funcTable to i8*), i8* getelementptr (i8, i8* bitcast ([2 x { i32, i8* }]* @main.funcTable to i8*), i32 12)], section ".ropi_relocs", align 4
+
+define internal void @main.foo() {
+  ret void
+}
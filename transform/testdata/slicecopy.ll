@@ -0,0 +1,43 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+@buf1 = global [8 x i8] zeroinitializer
+@buf2 = global [8 x i8] zeroinitializer
+
+declare i32 @runtime.sliceCopy(i8* nocapture, i8* nocapture readonly, i32, i32, i32)
+
+declare i8* @runtime.alloc(i32)
+
+declare void @llvm.memmove.p0i8.p0i8.i32(i8* nocapture, i8* nocapture readonly, i32, i1) #0
+
+declare void @llvm.memcpy.p0i8.p0i8.i32(i8* nocapture writeonly, i8* nocapture readonly, i32, i1) #0
+
+; Copy between unknown (possibly overlapping) slices must use memmove.
+define i32 @testUnknown(i8* %dst, i32 %dstLen, i8* %src, i32 %srcLen) {
+  %n = call i32 @runtime.sliceCopy(i8* %dst, i8* %src, i32 %dstLen, i32 %srcLen, i32 4)
+  ret i32 %n
+}
+
+; Copy within the same buffer (for example, copy(buf[1:], buf)) overlaps and
+; must use memmove.
+define i32 @testOverlap() {
+  %dst = getelementptr inbounds [8 x i8], [8 x i8]* @buf1, i32 0, i32 1
+  %src = getelementptr inbounds [8 x i8], [8 x i8]* @buf1, i32 0, i32 0
+  %n = call i32 @runtime.sliceCopy(i8* %dst, i8* %src, i32 7, i32 8, i32 1)
+  ret i32 %n
+}
+
+; Copy between two different globals cannot overlap, so can use memcpy.
+define i32 @testGlobals() {
+  %n = call i32 @runtime.sliceCopy(i8* getelementptr inbounds ([8 x i8], [8 x i8]* @buf1, i32 0, i32 0), i8* getelementptr inbounds ([8 x i8], [8 x i8]* @buf2, i32 0, i32 0), i32 8, i32 8, i32 1)
+  ret i32 %n
+}
+
+; Copy from a global into a new heap allocation cannot overlap either.
+define i8* @testAlloc() {
+  %dst = call i8* @runtime.alloc(i32 8)
+  %n = call i32 @runtime.sliceCopy(i8* %dst, i8* getelementptr inbounds ([8 x i8], [8 x i8]* @buf1, i32 0, i32 0), i32 8, i32 8, i32 1)
+  ret i8* %dst
+}
+
+attributes #0 = { argmemonly nounwind }
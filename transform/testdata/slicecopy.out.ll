@@ -0,0 +1,41 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+@buf1 = global [8 x i8] zeroinitializer
+@buf2 = global [8 x i8] zeroinitializer
+
+declare i32 @runtime.sliceCopy(i8* nocapture, i8* nocapture readonly, i32, i32, i32)
+
+declare i8* @runtime.alloc(i32)
+
+declare void @llvm.memmove.p0i8.p0i8.i32(i8* nocapture, i8* nocapture readonly, i32, i1) #0
+
+declare void @llvm.memcpy.p0i8.p0i8.i32(i8* nocapture writeonly, i8* nocapture readonly, i32, i1) #0
+
+define i32 @testUnknown(i8* %dst, i32 %dstLen, i8* %src, i32 %srcLen) {
+  %1 = icmp ult i32 %srcLen, %dstLen
+  %copy.n = select i1 %1, i32 %srcLen, i32 %dstLen
+  %copy.size = mul i32 %copy.n, 4
+  call void @llvm.memmove.p0i8.p0i8.i32(i8* %dst, i8* %src, i32 %copy.size, i1 false)
+  ret i32 %copy.n
+}
+
+define i32 @testOverlap() {
+  %dst = getelementptr inbounds [8 x i8], [8 x i8]* @buf1, i32 0, i32 1
+  %src = getelementptr inbounds [8 x i8], [8 x i8]* @buf1, i32 0, i32 0
+  call void @llvm.memmove.p0i8.p0i8.i32(i8* %dst, i8* %src, i32 7, i1 false)
+  ret i32 7
+}
+
+define i32 @testGlobals() {
+  call void @llvm.memcpy.p0i8.p0i8.i32(i8* getelementptr inbounds ([8 x i8], [8 x i8]* @buf1, i32 0, i32 0), i8* getelementptr inbounds ([8 x i8], [8 x i8]* @buf2, i32 0, i32 0), i32 8, i1 false)
+  ret i32 8
+}
+
+define i8* @testAlloc() {
+  %dst = call i8* @runtime.alloc(i32 8)
+  call void @llvm.memcpy.p0i8.p0i8.i32(i8* %dst, i8* getelementptr inbounds ([8 x i8], [8 x i8]* @buf1, i32 0, i32 0), i32 8, i1 false)
+  ret i8* %dst
+}
+
+attributes #0 = { argmemonly nounwind }
@@ -0,0 +1,64 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime.typecodeID = type { %runtime.typecodeID*, i32 }
+%runtime.funcValueWithSignature = type { i32, %runtime.typecodeID* }
+%runtime.funcValue = type { i8*, i32 }
+
+@"reflect/types.type:func:{basic:int}{basic:bool}" = external constant %runtime.typecodeID
+@"main.search$1$withSignature" = internal constant %runtime.funcValueWithSignature { i32 ptrtoint (i1 (i32, i8*, i8*)* @"main.search$1" to i32), %runtime.typecodeID* @"reflect/types.type:func:{basic:int}{basic:bool}" }
+
+declare i32 @runtime.getFuncPtr(i8*, i32, %runtime.typecodeID*, i8*, i8*)
+
+; With switch func values, the predicate is passed as a context and a pointer
+; to the function with its signature, which is converted to a function pointer
+; with runtime.getFuncPtr until func values are lowered.
+define internal i32 @sort.Search(i32 %n, i8* %f.context, i32 %f.id, i8* %context, i8* %parentHandle) {
+entry:
+  br label %for.loop
+
+for.loop:
+  %i = phi i32 [ 0, %entry ], [ %i.next, %for.body ]
+  %j = phi i32 [ %n, %entry ], [ %j.next, %for.body ]
+  %cmp = icmp slt i32 %i, %j
+  br i1 %cmp, label %for.body, label %for.done
+
+for.body:
+  %sum = add i32 %i, %j
+  %h = lshr i32 %sum, 1
+  %funcptr = call i32 @runtime.getFuncPtr(i8* %f.context, i32 %f.id, %runtime.typecodeID* @"reflect/types.type:func:{basic:int}{basic:bool}", i8* undef, i8* undef)
+  %f = inttoptr i32 %funcptr to i1 (i32, i8*, i8*)*
+  %found = call i1 %f(i32 %h, i8* %f.context, i8* undef)
+  %h.next = add i32 %h, 1
+  %i.next = select i1 %found, i32 %i, i32 %h.next
+  %j.next = select i1 %found, i32 %h, i32 %j
+  br label %for.loop
+
+for.done:
+  ret i32 %i
+}
+
+; The predicate func(i int) bool { return i*i >= x }, with x as the context.
+define internal i1 @"main.search$1"(i32 %i, i8* %context, i8* %parentHandle) {
+entry:
+  %x = ptrtoint i8* %context to i32
+  %square = mul i32 %i, %i
+  %result = icmp sge i32 %square, %x
+  ret i1 %result
+}
+
+define i32 @main.search(i32 %x) {
+entry:
+  %context = inttoptr i32 %x to i8*
+  %index = call i32 @sort.Search(i32 100, i8* %context, i32 ptrtoint (%runtime.funcValueWithSignature* @"main.search$1$withSignature" to i32), i8* undef, i8* undef)
+  ret i32 %index
+}
+
+; The predicate is not known, so this call is left alone.
+define i32 @main.searchDynamic(%runtime.funcValue %f) {
+entry:
+  %f.context = extractvalue %runtime.funcValue %f, 0
+  %f.id = extractvalue %runtime.funcValue %f, 1
+  %index = call i32 @sort.Search(i32 10, i8* %f.context, i32 %f.id, i8* undef, i8* undef)
+  ret i32 %index
+}
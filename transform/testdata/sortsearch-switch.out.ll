@@ -0,0 +1,77 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime.typecodeID = type { %runtime.typecodeID*, i32 }
+%runtime.funcValueWithSignature = type { i32, %runtime.typecodeID* }
+%runtime.funcValue = type { i8*, i32 }
+
+@"reflect/types.type:func:{basic:int}{basic:bool}" = external constant %runtime.typecodeID
+@"main.search$1$withSignature" = internal constant %runtime.funcValueWithSignature { i32 ptrtoint (i1 (i32, i8*, i8*)* @"main.search$1" to i32), %runtime.typecodeID* @"reflect/types.type:func:{basic:int}{basic:bool}" }
+
+declare i32 @runtime.getFuncPtr(i8*, i32, %runtime.typecodeID*, i8*, i8*)
+
+define internal i32 @sort.Search(i32 %n, i8* %f.context, i32 %f.id, i8* %context, i8* %parentHandle) {
+entry:
+  br label %for.loop
+
+for.loop:                                         ; preds = %for.body, %entry
+  %i = phi i32 [ 0, %entry ], [ %i.next, %for.body ]
+  %j = phi i32 [ %n, %entry ], [ %j.next, %for.body ]
+  %cmp = icmp slt i32 %i, %j
+  br i1 %cmp, label %for.body, label %for.done
+
+for.body:                                         ; preds = %for.loop
+  %sum = add i32 %i, %j
+  %h = lshr i32 %sum, 1
+  %funcptr = call i32 @runtime.getFuncPtr(i8* %f.context, i32 %f.id, %runtime.typecodeID* @"reflect/types.type:func:{basic:int}{basic:bool}", i8* undef, i8* undef)
+  %f = inttoptr i32 %funcptr to i1 (i32, i8*, i8*)*
+  %found = call i1 %f(i32 %h, i8* %f.context, i8* undef)
+  %h.next = add i32 %h, 1
+  %i.next = select i1 %found, i32 %i, i32 %h.next
+  %j.next = select i1 %found, i32 %h, i32 %j
+  br label %for.loop
+
+for.done:                                         ; preds = %for.loop
+  ret i32 %i
+}
+
+define internal i1 @"main.search$1"(i32 %i, i8* %context, i8* %parentHandle) {
+entry:
+  %x = ptrtoint i8* %context to i32
+  %square = mul i32 %i, %i
+  %result = icmp sge i32 %square, %x
+  ret i1 %result
+}
+
+define i32 @main.search(i32 %x) {
+entry:
+  %context = inttoptr i32 %x to i8*
+  br label %for.loop.i
+
+for.loop.i:                                       ; preds = %for.body.i, %entry
+  %i.i = phi i32 [ 0, %entry ], [ %i.next.i, %for.body.i ]
+  %j.i = phi i32 [ 100, %entry ], [ %j.next.i, %for.body.i ]
+  %cmp.i = icmp slt i32 %i.i, %j.i
+  br i1 %cmp.i, label %for.body.i, label %"main.search$1$search.exit"
+
+for.body.i:                                       ; preds = %for.loop.i
+  %sum.i = add i32 %i.i, %j.i
+  %h.i = lshr i32 %sum.i, 1
+  %square.i.i = mul i32 %h.i, %h.i
+  %result.i.i = icmp sge i32 %square.i.i, %x
+  %h.next.i = add i32 %h.i, 1
+  %i.next.i = select i1 %result.i.i, i32 %i.i, i32 %h.next.i
+  %j.next.i = select i1 %result.i.i, i32 %h.i, i32 %j.i
+  br label %for.loop.i
+
+"main.search$1$search.exit":                      ; preds = %for.loop.i
+  ret i32 %i.i
+}
+
+define i32 @main.searchDynamic(%runtime.funcValue %f) {
+entry:
+  %f.context = extractvalue %runtime.funcValue %f, 0
+  %f.id = extractvalue %runtime.funcValue %f, 1
+  %index = call i32 @sort.Search(i32 10, i8* %f.context, i32 %f.id, i8* undef, i8* undef)
+  ret i32 %index
+}
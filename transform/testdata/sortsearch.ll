@@ -0,0 +1,83 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+declare void @runtime.lookupPanic(i8*, i8*)
+
+declare void @runtime.printint32(i32, i8*, i8*)
+
+define internal i32 @sort.Search(i32 %n, i8* %f.context, i1 (i32, i8*, i8*)* %f.funcptr, i8* %context, i8* %parentHandle) {
+entry:
+  br label %for.loop
+
+for.loop:
+  %i = phi i32 [ 0, %entry ], [ %i.next, %for.body ]
+  %j = phi i32 [ %n, %entry ], [ %j.next, %for.body ]
+  %cmp = icmp slt i32 %i, %j
+  br i1 %cmp, label %for.body, label %for.done
+
+for.body:
+  %sum = add i32 %i, %j
+  %h = lshr i32 %sum, 1
+  %found = call i1 %f.funcptr(i32 %h, i8* %f.context, i8* undef)
+  %h.next = add i32 %h, 1
+  %i.next = select i1 %found, i32 %i, i32 %h.next
+  %j.next = select i1 %found, i32 %h, i32 %j
+  br label %for.loop
+
+for.done:
+  ret i32 %i
+}
+
+; The predicate func(i int) bool { return a[i] >= x }, with a pointer to the
+; closure context {a.ptr, a.len, x}.
+define internal i1 @"main.search$1"(i32 %i, i8* %context, i8* %parentHandle) {
+entry:
+  %unpack = bitcast i8* %context to { i32*, i32, i32 }*
+  %a.len.ptr = getelementptr inbounds { i32*, i32, i32 }, { i32*, i32, i32 }* %unpack, i32 0, i32 1
+  %a.len = load i32, i32* %a.len.ptr
+  %inbounds = icmp ult i32 %i, %a.len
+  br i1 %inbounds, label %lookup.next, label %lookup.throw
+
+lookup.next:
+  %a.ptr.ptr = getelementptr inbounds { i32*, i32, i32 }, { i32*, i32, i32 }* %unpack, i32 0, i32 0
+  %a.ptr = load i32*, i32** %a.ptr.ptr
+  %elem.ptr = getelementptr inbounds i32, i32* %a.ptr, i32 %i
+  %elem = load i32, i32* %elem.ptr
+  %x.ptr = getelementptr inbounds { i32*, i32, i32 }, { i32*, i32, i32 }* %unpack, i32 0, i32 2
+  %x = load i32, i32* %x.ptr
+  %result = icmp sge i32 %elem, %x
+  ret i1 %result
+
+lookup.throw:
+  call void @runtime.lookupPanic(i8* undef, i8* undef)
+  unreachable
+}
+
+; A predicate that prints something, which is not inlined.
+define internal i1 @"main.searchPrint$1"(i32 %i, i8* %context, i8* %parentHandle) {
+entry:
+  call void @runtime.printint32(i32 %i, i8* undef, i8* undef)
+  %result = icmp sge i32 %i, 5
+  ret i1 %result
+}
+
+; A call with a constant predicate.
+define i32 @main.search(i8* %context) {
+entry:
+  %index = call i32 @sort.Search(i32 10, i8* %context, i1 (i32, i8*, i8*)* @"main.search$1", i8* undef, i8* undef)
+  ret i32 %index
+}
+
+; The predicate is not simple, so this call is left alone.
+define i32 @main.searchPrint() {
+entry:
+  %index = call i32 @sort.Search(i32 10, i8* undef, i1 (i32, i8*, i8*)* @"main.searchPrint$1", i8* undef, i8* undef)
+  ret i32 %index
+}
+
+; The predicate is not known, so this call is left alone.
+define i32 @main.searchDynamic(i8* %f.context, i1 (i32, i8*, i8*)* %f.funcptr) {
+entry:
+  %index = call i32 @sort.Search(i32 10, i8* %f.context, i1 (i32, i8*, i8*)* %f.funcptr, i8* undef, i8* undef)
+  ret i32 %index
+}
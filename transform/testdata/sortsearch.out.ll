@@ -0,0 +1,88 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+declare void @runtime.lookupPanic(i8*, i8*)
+
+declare void @runtime.printint32(i32, i8*, i8*)
+
+define internal i32 @sort.Search(i32 %n, i8* %f.context, i1 (i32, i8*, i8*)* %f.funcptr, i8* %context, i8* %parentHandle) {
+entry:
+  br label %for.loop
+
+for.loop:                                         ; preds = %for.body, %entry
+  %i = phi i32 [ 0, %entry ], [ %i.next, %for.body ]
+  %j = phi i32 [ %n, %entry ], [ %j.next, %for.body ]
+  %cmp = icmp slt i32 %i, %j
+  br i1 %cmp, label %for.body, label %for.done
+
+for.body:                                         ; preds = %for.loop
+  %sum = add i32 %i, %j
+  %h = lshr i32 %sum, 1
+  %found = call i1 %f.funcptr(i32 %h, i8* %f.context, i8* undef)
+  %h.next = add i32 %h, 1
+  %i.next = select i1 %found, i32 %i, i32 %h.next
+  %j.next = select i1 %found, i32 %h, i32 %j
+  br label %for.loop
+
+for.done:                                         ; preds = %for.loop
+  ret i32 %i
+}
+
+define internal i1 @"main.searchPrint$1"(i32 %i, i8* %context, i8* %parentHandle) {
+entry:
+  call void @runtime.printint32(i32 %i, i8* undef, i8* undef)
+  %result = icmp sge i32 %i, 5
+  ret i1 %result
+}
+
+define i32 @main.search(i8* %context) {
+entry:
+  br label %for.loop.i
+
+for.loop.i:                                       ; preds = %"main.search$1.exit.i", %entry
+  %i.i = phi i32 [ 0, %entry ], [ %i.next.i, %"main.search$1.exit.i" ]
+  %j.i = phi i32 [ 10, %entry ], [ %j.next.i, %"main.search$1.exit.i" ]
+  %cmp.i = icmp slt i32 %i.i, %j.i
+  br i1 %cmp.i, label %for.body.i, label %"main.search$1$search.exit"
+
+for.body.i:                                       ; preds = %for.loop.i
+  %sum.i = add i32 %i.i, %j.i
+  %h.i = lshr i32 %sum.i, 1
+  %unpack.i.i = bitcast i8* %context to { i32*, i32, i32 }*
+  %a.len.ptr.i.i = getelementptr inbounds { i32*, i32, i32 }, { i32*, i32, i32 }* %unpack.i.i, i32 0, i32 1
+  %a.len.i.i = load i32, i32* %a.len.ptr.i.i
+  %inbounds.i.i = icmp ult i32 %h.i, %a.len.i.i
+  br i1 %inbounds.i.i, label %"main.search$1.exit.i", label %lookup.throw.i.i
+
+lookup.throw.i.i:                                 ; preds = %for.body.i
+  call void @runtime.lookupPanic(i8* undef, i8* undef)
+  unreachable
+
+"main.search$1.exit.i":                           ; preds = %for.body.i
+  %a.ptr.ptr.i.i = getelementptr inbounds { i32*, i32, i32 }, { i32*, i32, i32 }* %unpack.i.i, i32 0, i32 0
+  %a.ptr.i.i = load i32*, i32** %a.ptr.ptr.i.i
+  %elem.ptr.i.i = getelementptr inbounds i32, i32* %a.ptr.i.i, i32 %h.i
+  %elem.i.i = load i32, i32* %elem.ptr.i.i
+  %x.ptr.i.i = getelementptr inbounds { i32*, i32, i32 }, { i32*, i32, i32 }* %unpack.i.i, i32 0, i32 2
+  %x.i.i = load i32, i32* %x.ptr.i.i
+  %result.i.i = icmp sge i32 %elem.i.i, %x.i.i
+  %h.next.i = add i32 %h.i, 1
+  %i.next.i = select i1 %result.i.i, i32 %i.i, i32 %h.next.i
+  %j.next.i = select i1 %result.i.i, i32 %h.i, i32 %j.i
+  br label %for.loop.i
+
+"main.search$1$search.exit":                      ; preds = %for.loop.i
+  ret i32 %i.i
+}
+
+define i32 @main.searchPrint() {
+entry:
+  %index = call i32 @sort.Search(i32 10, i8* undef, i1 (i32, i8*, i8*)* @"main.searchPrint$1", i8* undef, i8* undef)
+  ret i32 %index
+}
+
+define i32 @main.searchDynamic(i8* %f.context, i1 (i32, i8*, i8*)* %f.funcptr) {
+entry:
+  %index = call i32 @sort.Search(i32 10, i8* %f.context, i1 (i32, i8*, i8*)* %f.funcptr, i8* undef, i8* undef)
+  ret i32 %index
+}
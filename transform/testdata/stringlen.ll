@@ -0,0 +1,54 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime._string = type { i8*, i32 }
+
+@foo = constant [3 x i8] c"foo"
+@bar = constant [3 x i8] c"bar"
+
+declare %runtime._string @runtime.stringConcat(i8*, i32, i8*, i32, i8*, i8*)
+
+declare %runtime._string @runtime.stringFromBytes(i8*, i32, i32, i8*, i8*)
+
+declare void @printString(i8*, i32)
+
+; len("foo" + "bar") is folded and the concatenation is removed.
+define i32 @testConcat() {
+  %s = call %runtime._string @runtime.stringConcat(i8* getelementptr inbounds ([3 x i8], [3 x i8]* @foo, i32 0, i32 0), i32 3, i8* getelementptr inbounds ([3 x i8], [3 x i8]* @bar, i32 0, i32 0), i32 3, i8* undef, i8* null)
+  %s.len = extractvalue %runtime._string %s, 1
+  ret i32 %s.len
+}
+
+; len("foo" + "bar" + "foo") is folded too.
+define i32 @testNestedConcat() {
+  %s1 = call %runtime._string @runtime.stringConcat(i8* getelementptr inbounds ([3 x i8], [3 x i8]* @foo, i32 0, i32 0), i32 3, i8* getelementptr inbounds ([3 x i8], [3 x i8]* @bar, i32 0, i32 0), i32 3, i8* undef, i8* null)
+  %s1.ptr = extractvalue %runtime._string %s1, 0
+  %s1.len = extractvalue %runtime._string %s1, 1
+  %s2 = call %runtime._string @runtime.stringConcat(i8* %s1.ptr, i32 %s1.len, i8* getelementptr inbounds ([3 x i8], [3 x i8]* @foo, i32 0, i32 0), i32 3, i8* undef, i8* null)
+  %s2.len = extractvalue %runtime._string %s2, 1
+  ret i32 %s2.len
+}
+
+; len(string(buf)) is the length of buf.
+define i32 @testFromBytes(i8* %buf.ptr, i32 %buf.len, i32 %buf.cap) {
+  %s = call %runtime._string @runtime.stringFromBytes(i8* %buf.ptr, i32 %buf.len, i32 %buf.cap, i8* undef, i8* null)
+  %s.len = extractvalue %runtime._string %s, 1
+  ret i32 %s.len
+}
+
+; The length of a runtime string can't be folded, but can still be calculated
+; without doing the concatenation.
+define i32 @testRuntime(i8* %x.ptr, i32 %x.len) {
+  %s = call %runtime._string @runtime.stringConcat(i8* %x.ptr, i32 %x.len, i8* getelementptr inbounds ([3 x i8], [3 x i8]* @foo, i32 0, i32 0), i32 3, i8* undef, i8* null)
+  %s.len = extractvalue %runtime._string %s, 1
+  ret i32 %s.len
+}
+
+; The string itself is used, so the concatenation must be kept.
+define i32 @testUsed() {
+  %s = call %runtime._string @runtime.stringConcat(i8* getelementptr inbounds ([3 x i8], [3 x i8]* @foo, i32 0, i32 0), i32 3, i8* getelementptr inbounds ([3 x i8], [3 x i8]* @bar, i32 0, i32 0), i32 3, i8* undef, i8* null)
+  %s.ptr = extractvalue %runtime._string %s, 0
+  %s.len = extractvalue %runtime._string %s, 1
+  call void @printString(i8* %s.ptr, i32 %s.len)
+  ret i32 %s.len
+}
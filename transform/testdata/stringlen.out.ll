@@ -0,0 +1,37 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime._string = type { i8*, i32 }
+
+@foo = constant [3 x i8] c"foo"
+@bar = constant [3 x i8] c"bar"
+
+declare %runtime._string @runtime.stringConcat(i8*, i32, i8*, i32, i8*, i8*)
+
+declare %runtime._string @runtime.stringFromBytes(i8*, i32, i32, i8*, i8*)
+
+declare void @printString(i8*, i32)
+
+define i32 @testConcat() {
+  ret i32 6
+}
+
+define i32 @testNestedConcat() {
+  ret i32 9
+}
+
+define i32 @testFromBytes(i8* %buf.ptr, i32 %buf.len, i32 %buf.cap) {
+  ret i32 %buf.len
+}
+
+define i32 @testRuntime(i8* %x.ptr, i32 %x.len) {
+  %concat.len = add i32 %x.len, 3
+  ret i32 %concat.len
+}
+
+define i32 @testUsed() {
+  %s = call %runtime._string @runtime.stringConcat(i8* getelementptr inbounds ([3 x i8], [3 x i8]* @foo, i32 0, i32 0), i32 3, i8* getelementptr inbounds ([3 x i8], [3 x i8]* @bar, i32 0, i32 0), i32 3, i8* undef, i8* null)
+  %s.ptr = extractvalue %runtime._string %s, 0
+  call void @printString(i8* %s.ptr, i32 6)
+  ret i32 6
+}
@@ -0,0 +1,106 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime._string = type { i8*, i32 }
+
+declare %runtime._string @"(*main.Foo).String"(i8*, i8*, i8*)
+
+declare %runtime._string @"(*main.Bar).String"(i8*, i8*, i8*)
+
+declare %runtime._string @"(*main.Foo).Name"(i8*, i8*, i8*)
+
+declare %runtime._string @"(*main.Bar).Name"(i8*, i8*, i8*)
+
+define internal %runtime._string @"(main.Stringer).String"(i8* %0, i8* %1, i8* %parentHandle, i32 %actualType) unnamed_addr {
+entry:
+  switch i32 %actualType, label %default [
+    i32 68, label %"*main.Foo"
+    i32 67, label %"*main.Bar"
+  ]
+
+default:                                          ; preds = %entry
+  unreachable
+
+"*main.Foo":                                      ; preds = %entry
+  %2 = call %runtime._string @"(*main.Foo).String"(i8* %0, i8* %1, i8* %parentHandle)
+  ret %runtime._string %2
+
+"*main.Bar":                                      ; preds = %entry
+  %3 = call %runtime._string @"(*main.Bar).String"(i8* %0, i8* %1, i8* %parentHandle)
+  ret %runtime._string %3
+}
+
+define internal %runtime._string @"(main.Namer).Name"(i8* %0, i8* %1, i8* %parentHandle, i32 %actualType) unnamed_addr {
+entry:
+  switch i32 %actualType, label %default [
+    i32 68, label %"*main.Foo"
+    i32 67, label %"*main.Bar"
+  ]
+
+default:                                          ; preds = %entry
+  unreachable
+
+"*main.Foo":                                      ; preds = %entry
+  %2 = call %runtime._string @"(*main.Foo).Name"(i8* %0, i8* %1, i8* %parentHandle)
+  ret %runtime._string %2
+
+"*main.Bar":                                      ; preds = %entry
+  %3 = call %runtime._string @"(*main.Bar).Name"(i8* %0, i8* %1, i8* %parentHandle)
+  ret %runtime._string %3
+}
+
+define internal i1 @"main.Stringer$typeassert"(i32 %actualType) unnamed_addr {
+entry:
+  switch i32 %actualType, label %else [
+    i32 68, label %then
+    i32 67, label %then
+  ]
+
+then:                                             ; preds = %entry, %entry
+  ret i1 true
+
+else:                                             ; preds = %entry
+  ret i1 false
+}
+
+define internal i1 @"main.Other$typeassert"(i32 %actualType) unnamed_addr {
+entry:
+  switch i32 %actualType, label %else [
+    i32 68, label %then
+    i32 69, label %then
+  ]
+
+then:                                             ; preds = %entry, %entry
+  ret i1 true
+
+else:                                             ; preds = %entry
+  ret i1 false
+}
+
+define %runtime._string @stringer(i8* %value, i32 %typecode) {
+entry:
+  %0 = call %runtime._string @"(main.Stringer).String"(i8* %value, i8* undef, i8* null, i32 %typecode)
+  ret %runtime._string %0
+}
+
+define %runtime._string @wrapper(i8* %value, i32 %typecode) {
+entry:
+  %0 = call %runtime._string @"(main.Stringer).String"(i8* %value, i8* undef, i8* null, i32 %typecode)
+  ret %runtime._string %0
+}
+
+define %runtime._string @namer(i8* %value, i32 %typecode) {
+entry:
+  %0 = call %runtime._string @"(main.Namer).Name"(i8* %value, i8* undef, i8* null, i32 %typecode)
+  ret %runtime._string %0
+}
+
+define i1 @typeasserts(i32 %typecode) {
+entry:
+  %0 = call i1 @"main.Stringer$typeassert"(i32 %typecode)
+  %1 = call i1 @"main.Stringer$typeassert"(i32 %typecode)
+  %2 = call i1 @"main.Other$typeassert"(i32 %typecode)
+  %3 = and i1 %0, %1
+  %4 = and i1 %3, %2
+  ret i1 %4
+}
@@ -0,0 +1,22 @@
+; dst[i] = a[i] + b[i] for every element, without bounds checks.
+define void @addFloats(float* noalias %dst, float* noalias %a, float* noalias %b, i32 %n) {
+entry:
+  %nonempty = icmp sgt i32 %n, 0
+  br i1 %nonempty, label %loop, label %exit
+
+loop:
+  %i = phi i32 [ 0, %entry ], [ %next, %loop ]
+  %a.ptr = getelementptr inbounds float, float* %a, i32 %i
+  %b.ptr = getelementptr inbounds float, float* %b, i32 %i
+  %dst.ptr = getelementptr inbounds float, float* %dst, i32 %i
+  %a.val = load float, float* %a.ptr
+  %b.val = load float, float* %b.ptr
+  %sum = fadd float %a.val, %b.val
+  store float %sum, float* %dst.ptr
+  %next = add nuw nsw i32 %i, 1
+  %done = icmp eq i32 %next, %n
+  br i1 %done, label %exit, label %loop
+
+exit:
+  ret void
+}
@@ -0,0 +1,142 @@
+package transform
+
+// This file merges identical interface thunks. TinyGo doesn't use itables for
+// interfaces: instead, interface lowering creates a thunk for every method of
+// an interface that is called, which is a type switch over all types that
+// implement the interface, and a type assert function for every interface
+// that is asserted to. Different interfaces often result in identical thunks:
+// when two interfaces are implemented by the same set of types (which is
+// common in small programs), their type assert functions are identical, and so
+// are the thunks of methods they have in common. Only one of them is needed.
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// MergeInterfaceThunks replaces thunks created by interface lowering (method
+// thunks like "(main.Stringer).String" and type asserts like
+// "main.Stringer$typeassert") with an identical thunk, if there is one, and
+// removes them. Two thunks are identical when they switch over the same type
+// codes and call the same methods.
+//
+// Type identity is not affected: interface values and type codes are not
+// changed and thunks are only ever called directly (their address is not
+// significant), so it is not observable that two thunks are the same function.
+// This transform must be run after interface lowering.
+func MergeInterfaceThunks(mod llvm.Module) {
+	// Group the thunks by function type: only thunks of the same type can be
+	// identical.
+	thunks := map[llvm.Type][]llvm.Value{}
+	var types []llvm.Type // to iterate in a deterministic order
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !isInterfaceThunk(fn) {
+			continue
+		}
+		fnType := fn.Type()
+		if _, ok := thunks[fnType]; !ok {
+			types = append(types, fnType)
+		}
+		thunks[fnType] = append(thunks[fnType], fn)
+	}
+
+	for _, fnType := range types {
+		var unique []llvm.Value
+	thunkLoop:
+		for _, fn := range thunks[fnType] {
+			for _, other := range unique {
+				if functionsEqual(fn, other) {
+					fn.ReplaceAllUsesWith(other)
+					fn.EraseFromParentAsFunction()
+					continue thunkLoop
+				}
+			}
+			unique = append(unique, fn)
+		}
+	}
+}
+
+// isInterfaceThunk returns whether this function is a method thunk or type
+// assert function created by interface lowering, which have the type code as
+// the last parameter named "actualType" (see compiler/interface-lowering.go).
+func isInterfaceThunk(fn llvm.Value) bool {
+	if fn.IsDeclaration() || fn.Linkage() != llvm.InternalLinkage || fn.ParamsCount() == 0 {
+		return false
+	}
+	return fn.LastParam().Name() == "actualType"
+}
+
+// functionsEqual returns whether both functions have the same type and the same
+// instructions, operating on the same values. This is a simple comparison that
+// is sufficient for interface thunks, but it does not look at attributes or
+// metadata.
+func functionsEqual(fn1, fn2 llvm.Value) bool {
+	if fn1.Type() != fn2.Type() {
+		return false
+	}
+
+	// Map values local to the function (parameters, basic blocks and
+	// instructions) to their index, so they can be compared between the two
+	// functions.
+	locals1 := localValueIndices(fn1)
+	locals2 := localValueIndices(fn2)
+	if len(locals1) != len(locals2) {
+		return false
+	}
+	operandsEqual := func(v1, v2 llvm.Value) bool {
+		index1, isLocal1 := locals1[v1]
+		index2, isLocal2 := locals2[v2]
+		if isLocal1 || isLocal2 {
+			return isLocal1 && isLocal2 && index1 == index2
+		}
+		// Constants, globals and functions are uniqued by LLVM.
+		return v1 == v2
+	}
+
+	bb2 := fn2.FirstBasicBlock()
+	for bb1 := fn1.FirstBasicBlock(); !bb1.IsNil(); bb1 = llvm.NextBasicBlock(bb1) {
+		if bb2.IsNil() {
+			return false
+		}
+		inst2 := bb2.FirstInstruction()
+		for inst1 := bb1.FirstInstruction(); !inst1.IsNil(); inst1 = llvm.NextInstruction(inst1) {
+			if inst2.IsNil() {
+				return false
+			}
+			if inst1.InstructionOpcode() != inst2.InstructionOpcode() || inst1.Type() != inst2.Type() || inst1.OperandsCount() != inst2.OperandsCount() {
+				return false
+			}
+			if !inst1.IsAICmpInst().IsNil() && inst1.IntPredicate() != inst2.IntPredicate() {
+				return false
+			}
+			for i := 0; i < inst1.OperandsCount(); i++ {
+				if !operandsEqual(inst1.Operand(i), inst2.Operand(i)) {
+					return false
+				}
+			}
+			inst2 = llvm.NextInstruction(inst2)
+		}
+		if !inst2.IsNil() {
+			return false
+		}
+		bb2 = llvm.NextBasicBlock(bb2)
+	}
+	return bb2.IsNil()
+}
+
+// localValueIndices returns the index of every parameter, basic block and
+// instruction of the given function, in the order they appear.
+func localValueIndices(fn llvm.Value) map[llvm.Value]int {
+	indices := map[llvm.Value]int{}
+	for _, param := range fn.Params() {
+		indices[param] = len(indices)
+	}
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		indices[bb.AsValue()] = len(indices)
+	}
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			indices[inst] = len(indices)
+		}
+	}
+	return indices
+}
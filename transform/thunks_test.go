@@ -0,0 +1,10 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestMergeInterfaceThunks(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/thunks", MergeInterfaceThunks)
+}
@@ -0,0 +1,47 @@
+package transform
+
+// The Go bindings for LLVM do not include the vectorization passes, so they are
+// declared here. They are part of libLLVM, which is already linked in.
+
+/*
+typedef struct LLVMOpaquePassManager *LLVMPassManagerRef;
+void LLVMAddLoopVectorizePass(LLVMPassManagerRef PM);
+void LLVMAddSLPVectorizePass(LLVMPassManagerRef PM);
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// Vectorize runs the LLVM loop and SLP (superword-level parallelism)
+// vectorizers over the module, so that simple elementwise loops over slices
+// and arrays, for example:
+//
+//     for i := range dst {
+//         dst[i] = a[i] + b[i]
+//     }
+//
+// can use SIMD instructions. The vectorizers use the cost model of the given
+// target machine, which is based on the CPU and features of the target (see the
+// "cpu" and "features" properties of a target). Therefore, loops are only
+// vectorized on targets that have SIMD instructions and when the cost model
+// considers it profitable. On targets without SIMD, the code stays scalar.
+//
+// Vectorization usually increases code size, so it should not be used when
+// optimizing for size.
+func Vectorize(mod llvm.Module, machine llvm.TargetMachine) {
+	pm := llvm.NewPassManager()
+	defer pm.Dispose()
+	machine.AddAnalysisPasses(pm)
+	C.LLVMAddLoopVectorizePass(C.LLVMPassManagerRef(unsafe.Pointer(pm.C)))
+	C.LLVMAddSLPVectorizePass(C.LLVMPassManagerRef(unsafe.Pointer(pm.C)))
+
+	// Clean up after the vectorizers, like the standard optimization
+	// pipeline does.
+	pm.AddInstructionCombiningPass()
+	pm.AddCFGSimplificationPass()
+	pm.Run(mod)
+}
@@ -0,0 +1,50 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"tinygo.org/x/go-llvm"
+)
+
+func TestVectorize(t *testing.T) {
+	t.Parallel()
+	llvm.InitializeAllTargets()
+	llvm.InitializeAllTargetMCs()
+	llvm.InitializeAllTargetInfos()
+
+	for _, tc := range []struct {
+		triple     string
+		cpu        string
+		vectorized bool
+	}{
+		{"x86_64-unknown-linux", "", true},          // SSE
+		{"thumbv7em-none-eabi", "cortex-m4", false}, // FPU, but no SIMD
+	} {
+		target, err := llvm.GetTargetFromTriple(tc.triple)
+		if err != nil {
+			t.Fatalf("could not get target for %s: %v", tc.triple, err)
+		}
+		machine := target.CreateTargetMachine(tc.triple, tc.cpu, "", llvm.CodeGenLevelDefault, llvm.RelocStatic, llvm.CodeModelDefault)
+
+		ctx := llvm.NewContext()
+		buf, err := llvm.NewMemoryBufferFromFile("testdata/vectorize.ll")
+		if err != nil {
+			t.Fatal("could not read file:", err)
+		}
+		mod, err := ctx.ParseIR(buf)
+		if err != nil {
+			t.Fatalf("could not load module:\n%v", err)
+		}
+		mod.SetTarget(tc.triple)
+		mod.SetDataLayout(machine.CreateTargetData().String())
+
+		Vectorize(mod, machine)
+
+		// The loop is vectorized if there are float vector operations.
+		vectorized := strings.Contains(mod.String(), "x float>")
+		if vectorized != tc.vectorized {
+			t.Errorf("%s (%s): expected vectorized=%v, got %v:\n%s", tc.triple, tc.cpu, tc.vectorized, vectorized, mod.String())
+		}
+	}
+}